@@ -0,0 +1,44 @@
+/**
+ * Copyright (c) 2018 Cisco Systems
+ * Author: Steven Barth <stbarth@cisco.com>
+ */
+
+// Command cisco_telemetry runs the Cisco telemetry input plugins as an external
+// plugin of an unmodified Telegraf using the execd input.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/common/shim"
+
+	// Plugins register themselves both in-tree and for the shim
+	_ "github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry"
+	_ "github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_gnmi"
+	_ "github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt"
+)
+
+var pollInterval = flag.Duration("poll_interval", 1*time.Second, "how often to send metrics")
+var pollIntervalDisabled = flag.Bool("poll_interval_disabled", false, "disable polling, e.g. for service inputs only")
+var configFile = flag.String("config", "", "path to the config file for the plugins")
+
+func main() {
+	flag.Parse()
+	if *pollIntervalDisabled {
+		*pollInterval = shim.PollIntervalDisabled
+	}
+
+	s := shim.New()
+	if err := s.LoadConfig(configFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Err loading input: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := s.Run(*pollInterval); err != nil {
+		fmt.Fprintf(os.Stderr, "Err: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,172 @@
+// Package auth provides credentials sent as gRPC metadata before each (re)dial
+// of a device or gateway, obtained from the configuration, a file, an external
+// command or an OAuth2 token endpoint.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider of credentials as gRPC metadata
+type Provider interface {
+	Credentials(ctx context.Context) (map[string]string, error)
+}
+
+// Config selecting and configuring an authentication provider
+type Config struct {
+	AuthProvider string   `toml:"auth_provider"`
+	AuthFile     string   `toml:"auth_file"`
+	AuthCommand  []string `toml:"auth_command"`
+
+	// OAuth2 client credentials flow
+	OAuth2TokenURL     string   `toml:"oauth2_token_url"`
+	OAuth2ClientID     string   `toml:"oauth2_client_id"`
+	OAuth2ClientSecret string   `toml:"oauth2_client_secret"`
+	OAuth2Scopes       []string `toml:"oauth2_scopes"`
+}
+
+// Provider configured, using username and password for static credentials
+func (c *Config) Provider(username string, password string) (Provider, error) {
+	switch c.AuthProvider {
+	case "", "static":
+		return &staticProvider{username: username, password: password}, nil
+	case "file":
+		if len(c.AuthFile) == 0 {
+			return nil, fmt.Errorf("missing auth_file")
+		}
+		return &fileProvider{path: c.AuthFile}, nil
+	case "exec":
+		if len(c.AuthCommand) == 0 {
+			return nil, fmt.Errorf("missing auth_command")
+		}
+		return &execProvider{command: c.AuthCommand}, nil
+	case "oauth2":
+		if len(c.OAuth2TokenURL) == 0 {
+			return nil, fmt.Errorf("missing oauth2_token_url")
+		}
+		return &oauth2Provider{config: c, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("invalid auth provider: %s", c.AuthProvider)
+	}
+}
+
+// Static credentials from the configuration
+type staticProvider struct {
+	username string
+	password string
+}
+
+func (p *staticProvider) Credentials(_ context.Context) (map[string]string, error) {
+	if len(p.username) == 0 {
+		return nil, nil
+	}
+	return map[string]string{"username": p.username, "password": p.password}, nil
+}
+
+// Credentials read from a JSON object in a file on every dial
+type fileProvider struct {
+	path string
+}
+
+func (p *fileProvider) Credentials(_ context.Context) (map[string]string, error) {
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCredentials(data)
+}
+
+// Credentials printed as JSON object by a command, e.g. fetching them from TACACS+
+type execProvider struct {
+	command []string
+}
+
+func (p *execProvider) Credentials(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	data, err := exec.CommandContext(ctx, p.command[0], p.command[1:]...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", p.command[0], err)
+	}
+	return parseCredentials(data)
+}
+
+// Bearer token of the OAuth2 client credentials flow, cached until shortly before expiry
+type oauth2Provider struct {
+	config  *Config
+	client  *http.Client
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (p *oauth2Provider) Credentials(ctx context.Context) (map[string]string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.token) == 0 || time.Now().After(p.expires) {
+		if err := p.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]string{"authorization": "Bearer " + p.token}, nil
+}
+
+func (p *oauth2Provider) refresh(ctx context.Context) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(p.config.OAuth2Scopes) > 0 {
+		form.Set("scope", strings.Join(p.config.OAuth2Scopes, " "))
+	}
+
+	request, err := http.NewRequest("POST", p.config.OAuth2TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(url.QueryEscape(p.config.OAuth2ClientID), url.QueryEscape(p.config.OAuth2ClientSecret))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed: %s", response.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return err
+	}
+	if len(token.AccessToken) == 0 {
+		return fmt.Errorf("token response without access_token")
+	}
+
+	// Refresh tokens a little early to not send them while expiring
+	p.token = token.AccessToken
+	p.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - 30*time.Second)
+	return nil
+}
+
+// ParseCredentials from a JSON object of metadata keys and values
+func parseCredentials(data []byte) (map[string]string, error) {
+	var credentials map[string]string
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("invalid credentials: %v", err)
+	}
+	return credentials, nil
+}
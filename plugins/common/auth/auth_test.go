@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticProvider(t *testing.T) {
+	provider, err := (&Config{}).Provider("user", "secret")
+	assert.Nil(t, err)
+
+	credentials, err := provider.Credentials(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"username": "user", "password": "secret"}, credentials)
+
+	_, err = (&Config{AuthProvider: "kerberos"}).Provider("", "")
+	assert.NotNil(t, err)
+}
+
+func TestFileProvider(t *testing.T) {
+	file, err := ioutil.TempFile("", "credentials")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString(`{"username": "user", "password": "rotated"}`)
+	file.Close()
+
+	provider, err := (&Config{AuthProvider: "file", AuthFile: file.Name()}).Provider("", "")
+	assert.Nil(t, err)
+
+	credentials, err := provider.Credentials(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"username": "user", "password": "rotated"}, credentials)
+}
+
+func TestExecProvider(t *testing.T) {
+	provider, err := (&Config{AuthProvider: "exec",
+		AuthCommand: []string{"echo", `{"username": "tacacs-user", "password": "otp"}`}}).Provider("", "")
+	assert.Nil(t, err)
+
+	credentials, err := provider.Credentials(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"username": "tacacs-user", "password": "otp"}, credentials)
+}
+
+func TestOAuth2Provider(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		username, password, _ := r.BasicAuth()
+		assert.Equal(t, "client", username)
+		assert.Equal(t, "secret", password)
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		assert.Equal(t, "telemetry read", r.Form.Get("scope"))
+		w.Write([]byte(`{"access_token": "token", "token_type": "bearer", "expires_in": 3600}`))
+	}))
+	defer server.Close()
+
+	provider, err := (&Config{AuthProvider: "oauth2", OAuth2TokenURL: server.URL, OAuth2ClientID: "client",
+		OAuth2ClientSecret: "secret", OAuth2Scopes: []string{"telemetry", "read"}}).Provider("", "")
+	assert.Nil(t, err)
+
+	// Tokens are cached until they expire
+	for i := 0; i < 2; i++ {
+		credentials, err := provider.Credentials(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"authorization": "Bearer token"}, credentials)
+	}
+	assert.Equal(t, 1, requests)
+}
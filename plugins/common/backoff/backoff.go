@@ -0,0 +1,59 @@
+// Package backoff provides exponentially growing delays with jitter between redials, so that
+// unreachable devices are not redialed at a fixed rate forever.
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Uptime of a connection after which the delay starts from the minimum again if not configured
+const DefaultResetAfter = time.Minute
+
+// Backoff doubling the delay from Min up to Max on each attempt, randomized by equal jitter.
+// Without Max above Min the delay is fixed at Min, like a plain redial interval.
+type Backoff struct {
+	Min        time.Duration
+	Max        time.Duration
+	ResetAfter time.Duration
+
+	attempt uint
+}
+
+// Next delay before redialing
+func (b *Backoff) Next() time.Duration {
+	if b.Max <= b.Min {
+		return b.Min
+	}
+
+	delay := b.Min
+	for i := uint(0); i < b.attempt && delay < b.Max; i++ {
+		if delay > b.Max/2 {
+			delay = b.Max
+			break
+		}
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	if delay < b.Max {
+		b.attempt++
+	}
+
+	// Half of the delay is random, so devices failing at once are not redialed at once
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// Established resets the delay to the minimum once a connection has been up for ResetAfter,
+// connections failing right after being established keep backing off
+func (b *Backoff) Established(uptime time.Duration) {
+	resetAfter := b.ResetAfter
+	if resetAfter <= 0 {
+		resetAfter = DefaultResetAfter
+	}
+	if uptime >= resetAfter {
+		b.attempt = 0
+	}
+}
@@ -0,0 +1,30 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixed(t *testing.T) {
+	b := &Backoff{Min: 10 * time.Second}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 10*time.Second, b.Next())
+	}
+}
+
+func TestExponential(t *testing.T) {
+	b := &Backoff{Min: time.Second, Max: 10 * time.Second, ResetAfter: time.Minute}
+	for _, expected := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		delay := b.Next()
+		assert.True(t, delay >= expected*time.Second/2 && delay <= expected*time.Second, "%v of %vs", delay, expected)
+	}
+
+	// Short-lived connections do not reset the delay
+	b.Established(time.Second)
+	assert.True(t, b.Next() >= 5*time.Second)
+
+	b.Established(time.Minute)
+	assert.True(t, b.Next() <= time.Second)
+}
@@ -0,0 +1,82 @@
+// Package cpulimit detects the CPU limit of the container the collector runs in, so that
+// concurrent work is sized by the CPUs actually available rather than by those of the host.
+package cpulimit
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Mount point of the cgroup filesystem
+const cgroupRoot = "/sys/fs/cgroup"
+
+// CPUs available to the process, the CFS quota of its cgroup rounded up if limited, otherwise
+// the number of CPUs of the host
+func CPUs() int {
+	return cpus(cgroupRoot, runtime.NumCPU())
+}
+
+func cpus(root string, host int) int {
+	quota, period, ok := cgroupV2Quota(root)
+	if !ok {
+		quota, period, ok = cgroupV1Quota(root)
+	}
+	if !ok || quota <= 0 || period <= 0 {
+		return host
+	}
+
+	limit := int((quota + period - 1) / period)
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > host {
+		limit = host
+	}
+	return limit
+}
+
+// CgroupV2Quota of cpu.max, e.g. "200000 100000", the quota being -1 if unlimited ("max")
+func cgroupV2Quota(root string) (int64, int64, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(root, "cpu.max"))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	parts := strings.Fields(string(data))
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	if parts[0] == "max" {
+		return -1, 0, true
+	}
+	quota, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	period, err := strconv.ParseInt(parts[1], 10, 64)
+	return quota, period, err == nil
+}
+
+// CgroupV1Quota of cpu.cfs_quota_us and cpu.cfs_period_us, the quota being -1 if unlimited
+func cgroupV1Quota(root string) (int64, int64, bool) {
+	for _, dir := range []string{"cpu", "cpu,cpuacct", "cpuacct,cpu"} {
+		quota, err := readInt(filepath.Join(root, dir, "cpu.cfs_quota_us"))
+		if err != nil {
+			continue
+		}
+		period, err := readInt(filepath.Join(root, dir, "cpu.cfs_period_us"))
+		return quota, period, err == nil
+	}
+	return 0, 0, false
+}
+
+func readInt(file string) (int64, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,35 @@
+package cpulimit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCPUs(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	// Without cgroup the CPUs of the host are available
+	assert.Equal(t, 8, cpus(root, 8))
+
+	// cgroup v1 without and with quota
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "cpu,cpuacct"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "cpu,cpuacct", "cpu.cfs_quota_us"), []byte("-1\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "cpu,cpuacct", "cpu.cfs_period_us"), []byte("100000\n"), 0644))
+	assert.Equal(t, 8, cpus(root, 8))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "cpu,cpuacct", "cpu.cfs_quota_us"), []byte("150000\n"), 0644))
+	assert.Equal(t, 2, cpus(root, 8))
+
+	// cgroup v2 takes precedence, limits are rounded up and capped by the host
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "cpu.max"), []byte("max 100000\n"), 0644))
+	assert.Equal(t, 8, cpus(root, 8))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "cpu.max"), []byte("50000 100000\n"), 0644))
+	assert.Equal(t, 1, cpus(root, 8))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "cpu.max"), []byte("1600000 100000\n"), 0644))
+	assert.Equal(t, 8, cpus(root, 8))
+}
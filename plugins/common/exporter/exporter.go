@@ -0,0 +1,166 @@
+// Package exporter serves the last values of telemetry fields in the Prometheus
+// text exposition format, allowing to scrape a plugin directly.
+package exporter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter caching the last value of each numeric field per series
+type Exporter struct {
+	expiration time.Duration
+	server     *http.Server
+	mutex      sync.Mutex
+	samples    map[string]*sample
+}
+
+type sample struct {
+	name    string
+	labels  string
+	value   float64
+	updated time.Time
+}
+
+// New creates an exporter dropping values not updated within expiration
+func New(expiration time.Duration) *Exporter {
+	return &Exporter{expiration: expiration, samples: make(map[string]*sample)}
+}
+
+// Start serving the /metrics endpoint on address
+func (e *Exporter) Start(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	e.server = &http.Server{Handler: mux}
+	go e.server.Serve(listener)
+
+	return nil
+}
+
+// Stop serving the endpoint
+func (e *Exporter) Stop() {
+	if e.server != nil {
+		e.server.Close()
+	}
+}
+
+// Update the cached values with the numeric fields of a metric
+func (e *Exporter) Update(measurement string, fields map[string]interface{}, tags map[string]string) {
+	labels := formatLabels(tags)
+	now := time.Now()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for field, value := range fields {
+		number, ok := toFloat(value)
+		if !ok {
+			continue
+		}
+
+		name := sanitize(measurement + "_" + field)
+		key := name + labels
+		s, ok := e.samples[key]
+		if !ok {
+			s = &sample{name: name, labels: labels}
+			e.samples[key] = s
+		}
+		s.value, s.updated = number, now
+	}
+}
+
+// ServeHTTP writes all current values in the text exposition format
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mutex.Lock()
+	samples := make([]*sample, 0, len(e.samples))
+	for key, s := range e.samples {
+		if e.expiration > 0 && time.Since(s.updated) > e.expiration {
+			delete(e.samples, key)
+			continue
+		}
+		samples = append(samples, s)
+	}
+
+	// Sort by name to write the samples of a metric family consecutively
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].name != samples[j].name {
+			return samples[i].name < samples[j].name
+		}
+		return samples[i].labels < samples[j].labels
+	})
+
+	var out strings.Builder
+	for i, s := range samples {
+		if i == 0 || samples[i-1].name != s.name {
+			fmt.Fprintf(&out, "# TYPE %s untyped\n", s.name)
+		}
+		fmt.Fprintf(&out, "%s%s %v\n", s.name, s.labels, s.value)
+	}
+	e.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(out.String()))
+}
+
+// FormatLabels of a series sorted by name
+func formatLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	labels := make([]string, 0, len(tags))
+	for key, value := range tags {
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+		labels = append(labels, fmt.Sprintf(`%s="%s"`, sanitize(key), value))
+	}
+	sort.Strings(labels)
+
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// Sanitize a metric or label name by replacing invalid characters with underscores
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// ToFloat converts numeric and boolean values
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case int32:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,35 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExporter(t *testing.T) {
+	e := New(time.Hour)
+	e.Update("type:model/some/path", map[string]interface{}{"in-octets": uint64(5), "up": true, "name": "eth0"},
+		map[string]string{"Producer": "router1", "description": `uplink "core"`})
+	e.Update("type:model/some/path", map[string]interface{}{"in-octets": uint64(7)},
+		map[string]string{"Producer": "router1", "description": `uplink "core"`})
+	e.Update("type:model/some/path", map[string]interface{}{"in-octets": int64(3)},
+		map[string]string{"Producer": "router2"})
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, `# TYPE type_model_some_path_in_octets untyped
+type_model_some_path_in_octets{Producer="router1",description="uplink \"core\""} 7
+type_model_some_path_in_octets{Producer="router2"} 3
+# TYPE type_model_some_path_up untyped
+type_model_some_path_up{Producer="router1",description="uplink \"core\""} 1
+`, recorder.Body.String())
+
+	// Values not updated within the expiration are dropped
+	e.expiration = time.Nanosecond
+	recorder = httptest.NewRecorder()
+	e.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Empty(t, recorder.Body.String())
+}
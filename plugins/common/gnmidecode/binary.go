@@ -0,0 +1,40 @@
+package gnmidecode
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Representations of bytes and Any values
+const (
+	BinaryBase64 = "base64"
+	BinaryHex    = "hex"
+	BinaryLength = "length"
+	BinaryDrop   = "drop"
+)
+
+// BinaryValue of a bytes or Any value in the configured representation, nil if dropped, and
+// false for values of other types. Any values are represented by their serialized message.
+func (d *Decoder) binaryValue(val *gnmi.TypedValue) (interface{}, bool) {
+	var data []byte
+	switch v := val.GetValue().(type) {
+	case *gnmi.TypedValue_BytesVal:
+		data = v.BytesVal
+	case *gnmi.TypedValue_AnyVal:
+		data = v.AnyVal.GetValue()
+	default:
+		return nil, false
+	}
+
+	switch d.BinaryPolicy {
+	case BinaryHex:
+		return hex.EncodeToString(data), true
+	case BinaryLength:
+		return int64(len(data)), true
+	case BinaryDrop:
+		return nil, true
+	}
+	return base64.StdEncoding.EncodeToString(data), true
+}
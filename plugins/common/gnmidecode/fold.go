@@ -0,0 +1,85 @@
+package gnmidecode
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Folded checks whether a list key is folded into field names instead of becoming a tag
+func (d *Decoder) folded(key string) bool {
+	for _, folded := range d.FoldKeys {
+		if folded == key {
+			return true
+		}
+	}
+	return false
+}
+
+// FoldedSelector returns the XPath selector of the folded keys of a path element, e.g.
+// "[prefix=10.0.0.0/8]", with values hashed if configured
+func (d *Decoder) foldedSelector(elem *gnmi.PathElem) string {
+	if len(d.FoldKeys) == 0 || len(elem.Key) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(elem.Key))
+	for key := range elem.Key {
+		if d.folded(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	for _, key := range keys {
+		val := elem.Key[key]
+		if d.HashFoldedKeys {
+			hash := fnv.New64a()
+			hash.Write([]byte(val))
+			val = fmt.Sprintf("%016x", hash.Sum64())
+		}
+		builder.WriteRune('[')
+		builder.WriteString(key)
+		builder.WriteRune('=')
+		builder.WriteString(val)
+		builder.WriteRune(']')
+	}
+	return builder.String()
+}
+
+// AddKey of a path element by name as tag, or as field according to the key policy. Keys
+// become fields with integer values if they are numeric, e.g. queue IDs.
+func (d *Decoder) addKey(name string, key string, val string, tags map[string]string, fields map[string]interface{}) {
+	number, err := strconv.ParseInt(val, 10, 64)
+	numeric := err == nil
+
+	asField := d.KeyPolicy == NumericKeysAsFields && numeric
+	if contains(d.FieldKeys, key) {
+		asField = true
+	} else if contains(d.TagKeys, key) {
+		asField = false
+	}
+
+	switch {
+	case !asField:
+		tags[name] = val
+	case numeric:
+		fields[name] = number
+	default:
+		fields[name] = val
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
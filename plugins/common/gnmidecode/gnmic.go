@@ -0,0 +1,98 @@
+package gnmidecode
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// DecodeGnmic notification into measurement name, fields and tags matching gnmic's event format
+func (d *Decoder) decodeGnmic(notification *gnmi.Notification, subscription string) (string, map[string]interface{}, map[string]string, error) {
+	fields := make(map[string]interface{})
+	tags := make(map[string]string)
+
+	for key, val := range d.Tags {
+		tags[key] = val
+	}
+	tags["source"] = d.Producer
+	if len(subscription) > 0 {
+		tags["subscription-name"] = subscription
+	}
+
+	name := subscription
+	prefix := d.gnmicPath(notification.GetPrefix(), tags, fields)
+	if len(name) == 0 {
+		name = prefix
+	}
+
+	var err error
+	for _, update := range notification.Update {
+		path := prefix + d.gnmicPath(update.GetPath(), tags, fields)
+		if len(update.GetPath().GetOrigin()) > 0 {
+			path = update.Path.Origin + ":" + path
+		} else if len(notification.GetPrefix().GetOrigin()) > 0 {
+			path = notification.Prefix.Origin + ":" + path
+		}
+
+		if list := update.GetVal().GetLeaflistVal(); list != nil {
+			d.addLeafList(path, ".", list, fields)
+			continue
+		}
+		if value, ok := d.binaryValue(update.GetVal()); ok {
+			if value != nil {
+				fields[path] = value
+			}
+			continue
+		}
+
+		value, jsondata := DecodeTypedValue(update.GetVal())
+		if value != nil {
+			fields[path] = value
+		} else if jsondata != nil {
+			value, jsonErr := d.decodeJSON(notification, update, jsondata)
+			if jsonErr != nil {
+				err = jsonErr
+				continue
+			}
+			flattenGnmicJSON(path, value, fields)
+		}
+	}
+
+	return name, fields, tags, err
+}
+
+// GnmicPath returns the path with only folded keys and adds its other keys as <element>_<key> tags
+// or fields
+func (d *Decoder) gnmicPath(path *gnmi.Path, tags map[string]string, fields map[string]interface{}) string {
+	var builder strings.Builder
+	for _, elem := range PathElems(path) {
+		builder.WriteRune('/')
+		builder.WriteString(elem.Name)
+		builder.WriteString(d.foldedSelector(elem))
+
+		for key, val := range elem.Key {
+			if !d.folded(key) {
+				d.addKey(elem.Name+"_"+key, key, val, tags, fields)
+			}
+		}
+	}
+	return builder.String()
+}
+
+// FlattenGnmicJSON joins nested JSON object keys with slashes like gnmic
+func flattenGnmicJSON(path string, value interface{}, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			flattenGnmicJSON(path+"/"+key, val, fields)
+		}
+	case []interface{}:
+		for i, val := range v {
+			flattenGnmicJSON(path+"."+strconv.Itoa(i), val, fields)
+		}
+	case nil:
+	default:
+		fields[path] = v
+	}
+}
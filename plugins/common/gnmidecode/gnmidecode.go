@@ -0,0 +1,262 @@
+// Package gnmidecode converts gNMI notifications into measurements with the semantics of
+// the cisco_telemetry_gnmi input, so other collectors and tests can reuse its decoding.
+// The exported API is kept backwards compatible.
+package gnmidecode
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Naming of measurements, fields and tags
+const (
+	FormatTelegraf = "telegraf"
+	FormatGnmic    = "gnmic"
+)
+
+// Policies of emitting list keys as tags or fields
+const (
+	KeysAsTags          = "keys_as_tags"
+	NumericKeysAsFields = "numeric_keys_as_fields"
+)
+
+// Handling of RFC 7951 module prefixes of JSON keys, e.g. "openconfig-interfaces:state"
+const (
+	ModulePrefixesStrip = "strip"
+	ModulePrefixesKeep  = "keep"
+)
+
+// Decoder of notifications into measurements
+type Decoder struct {
+	// Naming of measurements, fields and tags, FormatTelegraf if empty
+	Format string
+
+	// Handling of module prefixes of JSON keys, keeping keys as received if empty
+	ModulePrefixes string
+
+	// Address of the device, added as "Producer" tag or as "source" tag in gnmic format
+	Producer string
+
+	// Tags added to all measurements, overriding keys of the notification prefix
+	Tags map[string]string
+
+	// List keys folded into field names as XPath selector instead of becoming tags, e.g.
+	// "route[prefix=10.0.0.0/8]/metric", for keys too high in cardinality to be indexed
+	FoldKeys []string
+
+	// Hash the values of folded keys in field names
+	HashFoldedKeys bool
+
+	// Policy of the other list keys, KeysAsTags if empty, and keys emitted as tags or as fields
+	// regardless of the policy
+	KeyPolicy string
+	TagKeys   []string
+	FieldKeys []string
+
+	// Name fields by the last element of their path only, keeping the full names of fields whose
+	// leaf name is claimed by another field of the measurement. Claims are kept in LeafNames
+	// across notifications, decided per notification if nil.
+	LeafNameOnly bool
+	LeafNames    *LeafNames
+
+	// Representation of leaf-lists, LeafListIndexed as one field per element if empty, or
+	// LeafListJoined into a string separated by LeafListSeparator, "," if empty
+	LeafList          string
+	LeafListSeparator string
+
+	// Representation of bytes and Any values, BinaryBase64 if empty
+	BinaryPolicy string
+
+	// Patterns of the names of JSON_IETF leaves whose numeric strings are converted into numbers,
+	// e.g. "*-octets" for 64-bit counters, "*" for all leaves
+	IETFNumbers []string
+}
+
+// Decode a notification into measurement name, fields and tags. The name of the subscription
+// is used as measurement name in gnmic format if given. Updates with invalid JSON values are
+// skipped, returning the last error after decoding all other updates.
+func (d *Decoder) Decode(notification *gnmi.Notification, subscription string) (string, map[string]interface{}, map[string]string, error) {
+	var name string
+	var fields map[string]interface{}
+	var tags map[string]string
+	var err error
+	if d.Format == FormatGnmic {
+		name, fields, tags, err = d.decodeGnmic(notification, subscription)
+	} else {
+		name, fields, tags, err = d.decodeTelegraf(notification)
+	}
+
+	if d.LeafNameOnly {
+		leafNames := d.LeafNames
+		if leafNames == nil {
+			leafNames = NewLeafNames()
+		}
+		leafNames.trim(name, fields)
+	}
+	return name, fields, tags, err
+}
+
+// DecodeTelegraf notification into measurement name, fields and tags
+func (d *Decoder) decodeTelegraf(notification *gnmi.Notification) (string, map[string]interface{}, map[string]string, error) {
+	fields := make(map[string]interface{})
+	tags := make(map[string]string)
+
+	var builder, folded bytes.Buffer
+
+	if len(notification.GetPrefix().GetOrigin()) > 0 {
+		builder.WriteString(notification.Prefix.Origin)
+		builder.WriteRune(':')
+	}
+	builder.WriteRune('/')
+
+	// Parse generic keys from prefix
+	for _, elem := range notification.GetPrefix().GetElem() {
+		builder.WriteString(elem.Name)
+		builder.WriteRune('/')
+
+		for key, val := range elem.Key {
+			if d.folded(key) {
+				continue
+			}
+
+			// Use short-form of key if possible
+			_, tagExists := tags[key]
+			_, fieldExists := fields[key]
+			if tagExists || fieldExists {
+				d.addKey(builder.String()+key, key, val, tags, fields)
+			} else {
+				d.addKey(key, key, val, tags, fields)
+			}
+		}
+
+		// Fields are named relative to the prefix, folded keys of the prefix lead their names
+		if selector := d.foldedSelector(elem); len(selector) > 0 {
+			folded.WriteString(elem.Name)
+			folded.WriteString(selector)
+			folded.WriteRune('/')
+		}
+	}
+
+	for key, val := range d.Tags {
+		tags[key] = val
+	}
+	tags["Producer"] = d.Producer
+	tags["Target"] = notification.GetPrefix().GetTarget()
+	builder.Truncate(builder.Len() - 1)
+	prefix := builder.String()
+
+	// Parse individual Update message and create measurement
+	var err error
+	for _, update := range notification.Update {
+		name := folded.String() + d.updatePath(update.GetPath(), tags, fields)
+
+		// Leaf-lists are indexed like arrays flattened from JSON values
+		if list := update.GetVal().GetLeaflistVal(); list != nil {
+			d.addLeafList(name, "_", list, fields)
+			continue
+		}
+		if value, ok := d.binaryValue(update.GetVal()); ok {
+			if value != nil {
+				fields[name] = value
+			}
+			continue
+		}
+
+		value, jsondata := DecodeTypedValue(update.GetVal())
+		if value != nil {
+			fields[name] = value
+		} else if jsondata != nil {
+			value, jsonErr := d.decodeJSON(notification, update, jsondata)
+			if jsonErr != nil {
+				err = jsonErr
+				continue
+			}
+
+			flattenJSON(name, value, fields)
+		}
+	}
+
+	return prefix, fields, tags, err
+}
+
+// DecodeTypedValue into a scalar value or JSON data, decimal and floating point values as float64
+func DecodeTypedValue(val *gnmi.TypedValue) (interface{}, []byte) {
+	switch val.GetValue().(type) {
+	case *gnmi.TypedValue_AsciiVal:
+		return val.GetAsciiVal(), nil
+	case *gnmi.TypedValue_BoolVal:
+		return val.GetBoolVal(), nil
+	case *gnmi.TypedValue_BytesVal:
+		return val.GetBytesVal(), nil
+	case *gnmi.TypedValue_DecimalVal:
+		return decimalValue(val.GetDecimalVal()), nil
+	case *gnmi.TypedValue_FloatVal:
+		return float64(val.GetFloatVal()), nil
+	case *gnmi.TypedValue_IntVal:
+		return val.GetIntVal(), nil
+	case *gnmi.TypedValue_StringVal:
+		return val.GetStringVal(), nil
+	case *gnmi.TypedValue_UintVal:
+		return val.GetUintVal(), nil
+	case *gnmi.TypedValue_JsonIetfVal:
+		return nil, val.GetJsonIetfVal()
+	case *gnmi.TypedValue_JsonVal:
+		return nil, val.GetJsonVal()
+	case nil:
+		if double, ok := doubleValue(val); ok {
+			return double, nil
+		}
+	}
+	return nil, nil
+}
+
+// DecodeJSON value of an update, converting JSON_IETF numbers given as strings and normalizing
+// module prefixes of its keys if configured
+func (d *Decoder) decodeJSON(notification *gnmi.Notification, update *gnmi.Update, data []byte) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	if update.GetVal().GetJsonIetfVal() != nil && len(d.IETFNumbers) > 0 {
+		// Values of leaves are named by the last element of the update path
+		elems := PathElems(update.GetPath())
+		if _, container := value.(map[string]interface{}); !container && len(elems) > 0 &&
+			matchLeaf(elems[len(elems)-1].Name, d.IETFNumbers) {
+			value = ietfLeafNumbers(value)
+		} else {
+			value = ietfNumbers(value, d.IETFNumbers)
+		}
+	}
+	if len(d.ModulePrefixes) > 0 {
+		value = normalizeModulePrefixes(value, d.ModulePrefixes, pathModule(notification.GetPrefix(), update.GetPath()))
+	}
+	return value, nil
+}
+
+// FlattenJSON joins nested JSON object keys and array indices with underscores like the JSON
+// parser, keeping the 64-bit integers of JSON_IETF numbers
+func flattenJSON(name string, value interface{}, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if len(name) > 0 {
+				key = name + "_" + key
+			}
+			flattenJSON(key, val, fields)
+		}
+	case []interface{}:
+		for i, val := range v {
+			key := strconv.Itoa(i)
+			if len(name) > 0 {
+				key = name + "_" + key
+			}
+			flattenJSON(key, val, fields)
+		}
+	case float64, int64, uint64, string, bool:
+		fields[name] = v
+	}
+}
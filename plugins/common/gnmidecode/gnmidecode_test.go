@@ -0,0 +1,299 @@
+package gnmidecode
+
+import (
+	"math"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	path := "/foo/bar/bla[shoo=woo][shoop=/woop/]/z"
+	parsed := ParsePath("theorigin", path, "thetarget")
+
+	assert.Equal(t, parsed.Origin, "theorigin")
+	assert.Equal(t, parsed.Target, "thetarget")
+	assert.Equal(t, parsed.Element, []string{"foo", "bar", "bla[shoo=woo][shoop=/woop/]", "z"})
+	assert.Equal(t, parsed.Elem, []*gnmi.PathElem{{Name: "foo"}, {Name: "bar"},
+		{Name: "bla", Key: map[string]string{"shoo": "woo", "shoop": "/woop/"}}, {Name: "z"}})
+
+	// Escaped characters do not structure the path
+	parsed = ParsePath("", `a\/b/c[name=x\]\/y]`, "")
+	assert.Equal(t, parsed.Elem, []*gnmi.PathElem{{Name: "a/b"}, {Name: "c", Key: map[string]string{"name": "x]/y"}}})
+
+	parsed = ParsePath("", "", "")
+	assert.Equal(t, *parsed, gnmi.Path{})
+}
+
+func mockNotification() *gnmi.Notification {
+	return &gnmi.Notification{
+		Prefix: ParsePath("type", "/model[foo=bar]", "subscription"),
+		Update: []*gnmi.Update{
+			{
+				Path: ParsePath("", "some/path[name=str][uint64=1234]", ""),
+				Val:  &gnmi.TypedValue{Value: &gnmi.TypedValue_IntVal{IntVal: 5678}},
+			},
+			{
+				Path: ParsePath("", "other/path", ""),
+				Val:  &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"in-octets": 5}`)}},
+			},
+		},
+	}
+}
+
+func TestCanonicalPath(t *testing.T) {
+	notification := mockNotification()
+	assert.Equal(t, "type:/model[foo=bar]", CanonicalPath(notification))
+
+	notification.Update[1].Path = ParsePath("", "some/path[uint64=1234][name=str]/other", "")
+	notification.Update[0].Path = ParsePath("", "some/path[name=str][uint64=1234]/leaf", "")
+	assert.Equal(t, "type:/model[foo=bar]/some/path[name=str][uint64=1234]", CanonicalPath(notification))
+
+	assert.Equal(t, "/", CanonicalPath(&gnmi.Notification{}))
+}
+
+func TestDecode(t *testing.T) {
+	decoder := &Decoder{Producer: "127.0.0.1:57500", Tags: map[string]string{"foo": "override", "site": "dc1"}}
+	name, fields, tags, err := decoder.Decode(mockNotification(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "type:/model", name)
+	assert.Equal(t, map[string]interface{}{"some/path": int64(5678), "other/path_in-octets": float64(5)}, fields)
+	assert.Equal(t, map[string]string{"foo": "override", "site": "dc1", "Producer": "127.0.0.1:57500",
+		"Target": "subscription", "some/path/name": "str", "some/path/uint64": "1234"}, tags)
+
+	decoder = &Decoder{Format: FormatGnmic, Producer: "127.0.0.1:57500"}
+	name, fields, tags, err = decoder.Decode(mockNotification(), "sub1")
+	assert.Nil(t, err)
+	assert.Equal(t, "sub1", name)
+	assert.Equal(t, map[string]interface{}{"type:/model/some/path": int64(5678), "type:/model/other/path/in-octets": float64(5)}, fields)
+	assert.Equal(t, map[string]string{"source": "127.0.0.1:57500", "subscription-name": "sub1", "model_foo": "bar",
+		"path_name": "str", "path_uint64": "1234"}, tags)
+
+	// Updates with invalid JSON are skipped
+	notification := mockNotification()
+	notification.Update[1].Val = &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonVal{JsonVal: []byte(`{"in-octets"`)}}
+	_, fields, _, err = (&Decoder{}).Decode(notification, "")
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string]interface{}{"some/path": int64(5678)}, fields)
+}
+
+func TestDecodeJSONIETF(t *testing.T) {
+	notification := mockNotification()
+	notification.Update[1].Val = &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(
+		`{"counters": {"in-octets": "18446744073709551615", "in-errors": "9007199254740993"}, "rate": "-0.25",
+		"description": "100", "name": "Gi0/0/0/0"}`)}}
+	_, fields, _, err := (&Decoder{IETFNumbers: []string{"in-*", "rate"}}).Decode(notification, "")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"some/path": int64(5678), "other/path_counters_in-octets": uint64(18446744073709551615),
+		"other/path_counters_in-errors": int64(9007199254740993), "other/path_rate": float64(-0.25),
+		"other/path_description": "100", "other/path_name": "Gi0/0/0/0"}, fields)
+
+	// Strings of leaves not listed are kept as they are
+	_, fields, _, _ = (&Decoder{}).Decode(notification, "")
+	assert.Equal(t, "18446744073709551615", fields["other/path_counters_in-octets"])
+
+	// Strings of JSON values are kept as they are
+	notification.Update[1].Val = &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonVal{JsonVal: []byte(`{"in-errors": "5"}`)}}
+	_, fields, _, _ = (&Decoder{}).Decode(notification, "")
+	assert.Equal(t, "5", fields["other/path_in-errors"])
+
+	// Leaves of update paths are matched by the last element of the path
+	notification.Update[1].Path = ParsePath("", "other/in-octets", "")
+	notification.Update[1].Val = &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`"42"`)}}
+	_, fields, _, _ = (&Decoder{IETFNumbers: []string{"*"}}).Decode(notification, "")
+	assert.Equal(t, int64(42), fields["other/in-octets"])
+}
+
+func TestFoldKeys(t *testing.T) {
+	decoder := &Decoder{Producer: "127.0.0.1:57500", FoldKeys: []string{"foo", "uint64"}}
+	_, fields, tags, err := decoder.Decode(mockNotification(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"model[foo=bar]/some/path[uint64=1234]": int64(5678),
+		"model[foo=bar]/other/path_in-octets": float64(5)}, fields)
+	assert.Equal(t, map[string]string{"Producer": "127.0.0.1:57500", "Target": "subscription",
+		"some/path/name": "str"}, tags)
+
+	decoder.HashFoldedKeys = true
+	_, fields, _, _ = decoder.Decode(mockNotification(), "")
+	assert.Contains(t, fields, "model[foo=003934191339461a]/some/path[uint64=1fabbdf10314a21d]")
+
+	decoder = &Decoder{Format: FormatGnmic, FoldKeys: []string{"foo"}}
+	_, fields, tags, _ = decoder.Decode(mockNotification(), "sub1")
+	assert.Contains(t, fields, "type:/model[foo=bar]/some/path")
+	assert.NotContains(t, tags, "model_foo")
+}
+
+func TestKeyPolicy(t *testing.T) {
+	decoder := &Decoder{Producer: "127.0.0.1:57500", KeyPolicy: NumericKeysAsFields}
+	_, fields, tags, err := decoder.Decode(mockNotification(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"some/path": int64(5678), "other/path_in-octets": float64(5),
+		"some/path/uint64": int64(1234)}, fields)
+	assert.Equal(t, map[string]string{"Producer": "127.0.0.1:57500", "Target": "subscription",
+		"foo": "bar", "some/path/name": "str"}, tags)
+
+	decoder = &Decoder{Producer: "127.0.0.1:57500", KeyPolicy: NumericKeysAsFields, TagKeys: []string{"uint64"},
+		FieldKeys: []string{"foo"}}
+	_, fields, tags, _ = decoder.Decode(mockNotification(), "")
+	assert.Equal(t, "bar", fields["foo"])
+	assert.Equal(t, "1234", tags["some/path/uint64"])
+	assert.NotContains(t, tags, "foo")
+
+	decoder = &Decoder{Format: FormatGnmic, KeyPolicy: NumericKeysAsFields}
+	_, fields, tags, _ = decoder.Decode(mockNotification(), "sub1")
+	assert.Equal(t, int64(1234), fields["path_uint64"])
+	assert.Equal(t, "str", tags["path_name"])
+}
+
+func TestLeafNameOnly(t *testing.T) {
+	notification := mockNotification()
+	notification.Update = append(notification.Update,
+		&gnmi.Update{Path: ParsePath("", "state/counters/in-octets", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 1}}},
+		&gnmi.Update{Path: ParsePath("", "state/counters/out-octets", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 2}}},
+		&gnmi.Update{Path: ParsePath("", "config/path", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: "colliding"}}})
+
+	decoder := &Decoder{LeafNameOnly: true}
+	_, fields, _, err := decoder.Decode(notification, "")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"some/path": int64(5678), "path": "colliding",
+		"path_in-octets": float64(5), "in-octets": uint64(1), "out-octets": uint64(2)}, fields)
+
+	decoder = &Decoder{Format: FormatGnmic, LeafNameOnly: true, FoldKeys: []string{"name"}}
+	_, fields, _, _ = decoder.Decode(mockNotification(), "sub1")
+	assert.Equal(t, map[string]interface{}{"type:/model/some/path[name=str]": int64(5678), "in-octets": float64(5)}, fields)
+
+	// Leaf names claimed by a field are kept across notifications
+	decoder = &Decoder{LeafNameOnly: true, LeafNames: NewLeafNames()}
+	prefix := ParsePath("openconfig", "/interfaces/interface", "")
+	for _, updates := range [][]string{{"state/counters/in-octets"}, {"state/counters/in-octets", "config/in-octets"}, {"config/in-octets"}} {
+		notification := &gnmi.Notification{Prefix: prefix}
+		for _, path := range updates {
+			notification.Update = append(notification.Update, &gnmi.Update{Path: ParsePath("", path, ""),
+				Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 1}}})
+		}
+		_, fields, _, _ = decoder.Decode(notification, "")
+		for _, path := range updates {
+			if path == "config/in-octets" {
+				assert.Contains(t, fields, path)
+			} else {
+				assert.Contains(t, fields, "in-octets")
+			}
+		}
+	}
+}
+
+func TestLeafList(t *testing.T) {
+	communities := &gnmi.ScalarArray{Element: []*gnmi.TypedValue{
+		{Value: &gnmi.TypedValue_StringVal{StringVal: "65000:100"}},
+		{Value: &gnmi.TypedValue_StringVal{StringVal: "65000:200"}},
+		{Value: &gnmi.TypedValue_UintVal{UintVal: 4259840100}},
+	}}
+	notification := &gnmi.Notification{Prefix: ParsePath("openconfig", "/bgp/rib/community", ""),
+		Update: []*gnmi.Update{{Path: ParsePath("", "state/community", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_LeaflistVal{LeaflistVal: communities}}}}}
+
+	decoder := &Decoder{}
+	_, fields, _, err := decoder.Decode(notification, "")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"state/community_0": "65000:100", "state/community_1": "65000:200",
+		"state/community_2": uint64(4259840100)}, fields)
+
+	decoder = &Decoder{LeafList: LeafListJoined}
+	_, fields, _, _ = decoder.Decode(notification, "")
+	assert.Equal(t, map[string]interface{}{"state/community": "65000:100,65000:200,4259840100"}, fields)
+
+	decoder = &Decoder{Format: FormatGnmic, LeafList: LeafListJoined, LeafListSeparator: " "}
+	_, fields, _, _ = decoder.Decode(notification, "")
+	assert.Equal(t, map[string]interface{}{"openconfig:/bgp/rib/community/state/community": "65000:100 65000:200 4259840100"}, fields)
+
+	decoder = &Decoder{Format: FormatGnmic}
+	_, fields, _, _ = decoder.Decode(notification, "")
+	assert.Equal(t, "65000:200", fields["openconfig:/bgp/rib/community/state/community.1"])
+}
+
+func TestDecodeNumericValues(t *testing.T) {
+	value, _ := DecodeTypedValue(&gnmi.TypedValue{Value: &gnmi.TypedValue_DecimalVal{
+		DecimalVal: &gnmi.Decimal64{Digits: -235, Precision: 2}}})
+	assert.Equal(t, -2.35, value)
+
+	value, _ = DecodeTypedValue(&gnmi.TypedValue{Value: &gnmi.TypedValue_DecimalVal{
+		DecimalVal: &gnmi.Decimal64{Digits: 42}}})
+	assert.Equal(t, float64(42), value)
+
+	value, _ = DecodeTypedValue(&gnmi.TypedValue{Value: &gnmi.TypedValue_FloatVal{FloatVal: 36.5}})
+	assert.Equal(t, 36.5, value)
+
+	// Targets of newer gNMI versions send double_val (field 14) unknown to the generated code
+	var double proto.Buffer
+	double.EncodeVarint(14<<3 | 1)
+	double.EncodeFixed64(math.Float64bits(-7.125))
+	value, _ = DecodeTypedValue(&gnmi.TypedValue{XXX_unrecognized: double.Bytes()})
+	assert.Equal(t, -7.125, value)
+
+	value, data := DecodeTypedValue(&gnmi.TypedValue{XXX_unrecognized: []byte{0xff}})
+	assert.Nil(t, value)
+	assert.Nil(t, data)
+}
+
+func TestBinaryPolicy(t *testing.T) {
+	notification := &gnmi.Notification{Prefix: ParsePath("", "/system/state", ""), Update: []*gnmi.Update{
+		{Path: ParsePath("", "serial", ""), Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_BytesVal{BytesVal: []byte{0xca, 0xfe}}}},
+		{Path: ParsePath("", "status", ""), Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_AnyVal{
+			AnyVal: &any.Any{TypeUrl: "type.googleapis.com/status", Value: []byte{0x08, 0x01}}}}},
+	}}
+
+	for policy, expected := range map[string]map[string]interface{}{
+		"":           {"serial": "yv4=", "status": "CAE="},
+		BinaryHex:    {"serial": "cafe", "status": "0801"},
+		BinaryLength: {"serial": int64(2), "status": int64(2)},
+		BinaryDrop:   {},
+	} {
+		decoder := &Decoder{BinaryPolicy: policy}
+		_, fields, _, err := decoder.Decode(notification, "")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, fields, policy)
+	}
+
+	decoder := &Decoder{Format: FormatGnmic, BinaryPolicy: BinaryHex}
+	_, fields, _, _ := decoder.Decode(notification, "")
+	assert.Equal(t, "cafe", fields["/system/state/serial"])
+}
+
+func TestNormalizePaths(t *testing.T) {
+	notification := mockNotification()
+	assert.True(t, NormalizePaths(notification) == notification)
+
+	notification.Prefix.Elem = append([]*gnmi.PathElem{{Name: ""}}, notification.Prefix.Elem...)
+	notification.Update[0].Path.Elem = []*gnmi.PathElem{{Name: "some/"}, {Name: "", Key: map[string]string{"name": "str"}},
+		{Name: "/path"}}
+	notification.Update[1].Path.Element = []string{"other", "", "path"}
+	notification.Update[1].Path.Elem = nil
+
+	normalized := NormalizePaths(notification)
+	assert.Equal(t, "", notification.Prefix.Elem[0].Name)
+	assert.Equal(t, "model", normalized.Prefix.Elem[0].Name)
+	assert.Equal(t, []*gnmi.PathElem{{Name: "some", Key: map[string]string{"name": "str"}}, {Name: "path"}},
+		normalized.Update[0].Path.Elem)
+	assert.Equal(t, []string{"other", "path"}, normalized.Update[1].Path.Element)
+
+	_, fields, tags, err := (&Decoder{}).Decode(normalized, "")
+	assert.Nil(t, err)
+	assert.Contains(t, fields, "some/path")
+	assert.Contains(t, fields, "other/path_in-octets")
+	assert.Equal(t, "str", tags["some/name"])
+
+	// Slashes within keys are no malformed paths
+	path := ParsePath("", "/interfaces/interface[name=GigabitEthernet0/0/0/0]/state", "")
+	notification = &gnmi.Notification{Prefix: path, Delete: []*gnmi.Path{path}}
+	assert.True(t, NormalizePaths(notification) == notification)
+
+	path.Element = []string{"interfaces/interface[name=GigabitEthernet0/0/0/0]", "", "state"}
+	normalized = NormalizePaths(notification)
+	assert.Equal(t, []string{"interfaces", "interface[name=GigabitEthernet0/0/0/0]", "state"}, normalized.Prefix.Element)
+}
@@ -0,0 +1,183 @@
+package gnmidecode
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+)
+
+// Regenerate the golden files of all fixtures, e.g. by "go test -run TestGolden -update"
+var update = flag.Bool("update", false, "update the golden files of the decoder fixtures")
+
+// Producer of the notifications of all fixtures
+const goldenProducer = "127.0.0.1:57400"
+
+// TestGolden decodes the notifications of the fixtures in testdata, given in protobuf text format
+// as "<name>.textproto", and compares the measurements to the line protocol of "<name>.golden".
+// Options of the decoder are given by leading comments of the fixture, e.g. "# format: gnmic".
+func TestGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.textproto"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, fixtures)
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".textproto")
+		t.Run(name, func(t *testing.T) {
+			actual, err := decodeFixture(fixture)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			golden := strings.TrimSuffix(fixture, ".textproto") + ".golden"
+			if *update {
+				assert.Nil(t, ioutil.WriteFile(golden, []byte(actual), 0644))
+				return
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			if !assert.Nil(t, err, "missing golden file, run with -update to create it") {
+				return
+			}
+			assert.Equal(t, string(expected), actual)
+		})
+	}
+}
+
+// DecodeFixture into line protocol, one line per notification. Fixtures may hold multiple
+// notifications separated by lines of "---".
+func decodeFixture(fixture string) (string, error) {
+	data, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		return "", err
+	}
+
+	decoder, subscription, err := fixtureDecoder(data)
+	if err != nil {
+		return "", err
+	}
+
+	var lines bytes.Buffer
+	for _, text := range strings.Split(string(data), "\n---\n") {
+		notification := &gnmi.Notification{}
+		if err := proto.UnmarshalText(text, notification); err != nil {
+			return "", err
+		}
+
+		measurement, fields, tags, err := decoder.Decode(notification, subscription)
+		if err != nil {
+			return "", err
+		}
+		lines.WriteString(lineProtocol(measurement, fields, tags, notification.Timestamp))
+	}
+	return lines.String(), nil
+}
+
+// FixtureDecoder configured by the "# <option>: <value>" comments leading a fixture, any of
+// format, module_prefixes, fold_keys, hash_folded_keys, key_policy, tag_keys, field_keys,
+// ietf_numbers (lists comma-separated) and subscription
+func fixtureDecoder(data []byte) (*Decoder, string, error) {
+	decoder := &Decoder{Producer: goldenProducer}
+	subscription := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+
+		parts := strings.SplitN(strings.TrimSpace(line[1:]), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "format":
+			decoder.Format = value
+		case "module_prefixes":
+			decoder.ModulePrefixes = value
+		case "fold_keys":
+			decoder.FoldKeys = strings.Split(value, ",")
+		case "hash_folded_keys":
+			decoder.HashFoldedKeys = value == "true"
+		case "key_policy":
+			decoder.KeyPolicy = value
+		case "tag_keys":
+			decoder.TagKeys = strings.Split(value, ",")
+		case "field_keys":
+			decoder.FieldKeys = strings.Split(value, ",")
+		case "ietf_numbers":
+			decoder.IETFNumbers = strings.Split(value, ",")
+		case "subscription":
+			subscription = value
+		default:
+			return nil, "", fmt.Errorf("unknown fixture option %q", parts[0])
+		}
+	}
+	return decoder, subscription, scanner.Err()
+}
+
+// LineProtocol of a measurement with sorted tags and fields, typed like the influx serializer
+func lineProtocol(measurement string, fields map[string]interface{}, tags map[string]string, timestamp int64) string {
+	escape := strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+	var line bytes.Buffer
+	line.WriteString(strings.NewReplacer(",", `\,`, " ", `\ `).Replace(measurement))
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// Empty tags are dropped by the serializer
+		if len(tags[key]) > 0 {
+			line.WriteString("," + escape.Replace(key) + "=" + escape.Replace(tags[key]))
+		}
+	}
+
+	keys = keys[:0]
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i == 0 {
+			line.WriteByte(' ')
+		} else {
+			line.WriteByte(',')
+		}
+		line.WriteString(escape.Replace(key) + "=")
+
+		switch value := fields[key].(type) {
+		case int64:
+			line.WriteString(strconv.FormatInt(value, 10) + "i")
+		case uint64:
+			line.WriteString(strconv.FormatUint(value, 10) + "u")
+		case float64:
+			line.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+		case float32:
+			line.WriteString(strconv.FormatFloat(float64(value), 'f', -1, 32))
+		case bool:
+			line.WriteString(strconv.FormatBool(value))
+		case string:
+			line.WriteString(strconv.Quote(value))
+		default:
+			line.WriteString(strconv.Quote(fmt.Sprint(value)))
+		}
+	}
+
+	line.WriteString(" " + strconv.FormatInt(timestamp, 10) + "\n")
+	return line.String()
+}
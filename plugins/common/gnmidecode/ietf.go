@@ -0,0 +1,68 @@
+package gnmidecode
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RFC 7951 string representation of 64-bit integers and decimal64 values
+var ietfNumber = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)
+
+// IETFNumbers converts the strings of a decoded JSON_IETF value that RFC 7951 uses for 64-bit
+// integers and decimal64, e.g. counters, into numbers for leaves whose name matches one of the
+// patterns, so string leaves such as descriptions keep their type
+func ietfNumbers(value interface{}, patterns []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if matchLeaf(key, patterns) {
+				v[key] = ietfLeafNumbers(val)
+			} else {
+				v[key] = ietfNumbers(val, patterns)
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = ietfNumbers(val, patterns)
+		}
+	}
+	return value
+}
+
+// IETFLeafNumbers converts the numeric strings of a leaf or leaf-list, keeping 64-bit integers
+// exact and parsing only decimal64 values as float
+func ietfLeafNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for i, val := range v {
+			v[i] = ietfLeafNumbers(val)
+		}
+	case string:
+		if !ietfNumber.MatchString(v) {
+			return value
+		}
+		if strings.IndexByte(v, '.') >= 0 {
+			if number, err := strconv.ParseFloat(v, 64); err == nil {
+				return number
+			}
+		} else if number, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return number
+		} else if number, err := strconv.ParseUint(strings.TrimPrefix(v, "+"), 10, 64); err == nil {
+			return number
+		}
+	}
+	return value
+}
+
+// MatchLeaf checks the name of a leaf without module prefix against the patterns
+func matchLeaf(name string, patterns []string) bool {
+	name = name[strings.LastIndexByte(name, ':')+1:]
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package gnmidecode
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LeafNames claimed by the fields of each measurement. Shared by the decoders of a subscription,
+// a field keeps its name across notifications regardless of the siblings it comes along with.
+type LeafNames struct {
+	mutex  sync.Mutex
+	claims map[string]map[string]string
+}
+
+// NewLeafNames creates an empty set of claimed leaf names
+func NewLeafNames() *LeafNames {
+	return &LeafNames{claims: make(map[string]map[string]string)}
+}
+
+// Trim field names of a measurement to the last element of their path, e.g.
+// "state/counters/in-octets" to "in-octets". The first field naming a leaf claims it, so later
+// fields of the same leaf name, e.g. "config/in-octets", keep their full names. Fields naming
+// folded keys keep their full names as well.
+func (l *LeafNames) trim(measurement string, fields map[string]interface{}) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if !strings.ContainsRune(name, '[') {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	claims, ok := l.claims[measurement]
+	if !ok {
+		claims = make(map[string]string)
+		l.claims[measurement] = claims
+	}
+
+	// Fields without path are named by their leaf already
+	for _, name := range names {
+		if _, claimed := claims[name]; !claimed && !strings.ContainsRune(name, '/') {
+			claims[name] = name
+		}
+	}
+
+	for _, name := range names {
+		i := strings.LastIndexByte(name, '/')
+		if i < 0 || i == len(name)-1 {
+			continue
+		}
+
+		leaf := name[i+1:]
+		claimer, claimed := claims[leaf]
+		if !claimed {
+			claims[leaf], claimer = name, name
+		}
+		if _, exists := fields[leaf]; claimer != name || exists {
+			continue
+		}
+		fields[leaf] = fields[name]
+		delete(fields, name)
+	}
+}
@@ -0,0 +1,50 @@
+package gnmidecode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Representations of leaf-list values
+const (
+	LeafListIndexed = "indexed"
+	LeafListJoined  = "joined"
+)
+
+// Separator of the elements of joined leaf-lists unless configured
+const defaultLeafListSeparator = ","
+
+// AddLeafList adds the scalar elements of a leaf-list as one field per element, named by the field
+// name, the separator and the index of the element, or as a single string field if joined
+func (d *Decoder) addLeafList(name string, separator string, list *gnmi.ScalarArray, fields map[string]interface{}) {
+	var elements []interface{}
+	for _, element := range list.GetElement() {
+		if value, ok := d.binaryValue(element); ok {
+			if value != nil {
+				elements = append(elements, value)
+			}
+		} else if value, _ := DecodeTypedValue(element); value != nil {
+			elements = append(elements, value)
+		}
+	}
+
+	if d.LeafList != LeafListJoined {
+		for i, value := range elements {
+			fields[name+separator+strconv.Itoa(i)] = value
+		}
+		return
+	}
+
+	joined := make([]string, len(elements))
+	for i, value := range elements {
+		joined[i] = fmt.Sprint(value)
+	}
+	if len(d.LeafListSeparator) > 0 {
+		fields[name] = strings.Join(joined, d.LeafListSeparator)
+	} else {
+		fields[name] = strings.Join(joined, defaultLeafListSeparator)
+	}
+}
@@ -0,0 +1,47 @@
+package gnmidecode
+
+import (
+	"strings"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// NormalizeModulePrefixes of the keys of a decoded JSON value. Strip removes all module prefixes,
+// keep qualifies every key by the module inherited from its parent, as RFC 7951 only prefixes
+// keys whose module differs from the one of their parent.
+func normalizeModulePrefixes(value interface{}, mode string, module string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			name, keyModule := key, module
+			if i := strings.IndexByte(key, ':'); i > 0 {
+				keyModule, name = key[:i], key[i+1:]
+			}
+			if mode == ModulePrefixesKeep && len(keyModule) > 0 {
+				name = keyModule + ":" + name
+			}
+			result[name] = normalizeModulePrefixes(val, mode, keyModule)
+		}
+		return result
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeModulePrefixes(val, mode, module)
+		}
+		return v
+	}
+	return value
+}
+
+// PathModule returns the module of the last module-prefixed element of the given paths
+func pathModule(paths ...*gnmi.Path) string {
+	module := ""
+	for _, path := range paths {
+		for _, elem := range PathElems(path) {
+			if i := strings.IndexByte(elem.Name, ':'); i > 0 {
+				module = elem.Name[:i]
+			}
+		}
+	}
+	return module
+}
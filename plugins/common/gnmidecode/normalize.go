@@ -0,0 +1,122 @@
+package gnmidecode
+
+import (
+	"strings"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// NormalizePaths of a notification sent by targets with empty path elements or element names with
+// leading, trailing or inner slashes. Empty elements are removed and names are split at slashes,
+// the keys of an element staying with its last part. The notification is returned as is if all its
+// paths are well-formed, otherwise a copy with normalized paths.
+func NormalizePaths(notification *gnmi.Notification) *gnmi.Notification {
+	malformed := !wellFormed(notification.GetPrefix())
+	for _, update := range notification.GetUpdate() {
+		malformed = malformed || !wellFormed(update.GetPath())
+	}
+	for _, path := range notification.GetDelete() {
+		malformed = malformed || !wellFormed(path)
+	}
+	if !malformed {
+		return notification
+	}
+
+	normalized := *notification
+	normalized.Prefix = normalizePath(notification.GetPrefix())
+	normalized.Update = make([]*gnmi.Update, len(notification.GetUpdate()))
+	for i, update := range notification.GetUpdate() {
+		copied := *update
+		copied.Path = normalizePath(update.GetPath())
+		normalized.Update[i] = &copied
+	}
+	normalized.Delete = make([]*gnmi.Path, len(notification.GetDelete()))
+	for i, path := range notification.GetDelete() {
+		normalized.Delete[i] = normalizePath(path)
+	}
+	return &normalized
+}
+
+// WellFormed checks that no element of a path is empty or contains a slash
+func wellFormed(path *gnmi.Path) bool {
+	for _, elem := range path.GetElem() {
+		if len(elem.Name) == 0 || strings.IndexByte(elem.Name, '/') >= 0 {
+			return false
+		}
+	}
+	for _, element := range path.GetElement() {
+		name := elementName(element)
+		if len(name) == 0 || strings.IndexByte(name, '/') >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ElementName of a deprecated string element without its keys, whose values may contain slashes
+func elementName(element string) string {
+	if i := strings.IndexByte(element, '['); i >= 0 {
+		return element[:i]
+	}
+	return element
+}
+
+// NormalizePath returns a copy of a path without empty elements and with names split at slashes
+func normalizePath(path *gnmi.Path) *gnmi.Path {
+	if path == nil || wellFormed(path) {
+		return path
+	}
+
+	normalized := *path
+	normalized.Elem = nil
+	for _, elem := range path.Elem {
+		parts := splitName(elem.Name)
+
+		// Keys of empty elements are kept by the preceding element
+		if len(parts) == 0 && len(elem.Key) > 0 && len(normalized.Elem) > 0 {
+			last := normalized.Elem[len(normalized.Elem)-1]
+			keys := make(map[string]string, len(last.Key)+len(elem.Key))
+			for key, val := range last.Key {
+				keys[key] = val
+			}
+			for key, val := range elem.Key {
+				keys[key] = val
+			}
+			normalized.Elem[len(normalized.Elem)-1] = &gnmi.PathElem{Name: last.Name, Key: keys}
+		}
+
+		for i, part := range parts {
+			if i < len(parts)-1 {
+				normalized.Elem = append(normalized.Elem, &gnmi.PathElem{Name: part})
+			} else {
+				normalized.Elem = append(normalized.Elem, &gnmi.PathElem{Name: part, Key: elem.Key})
+			}
+		}
+	}
+
+	// Deprecated string elements hold keys in their names, slashes within keys are kept
+	normalized.Element = nil
+	for _, element := range path.Element {
+		name := elementName(element)
+		parts := splitName(name)
+		if keys := element[len(name):]; len(keys) > 0 {
+			if len(parts) == 0 {
+				parts = []string{""}
+			}
+			parts[len(parts)-1] += keys
+		}
+		normalized.Element = append(normalized.Element, parts...)
+	}
+	return &normalized
+}
+
+// SplitName of an element at slashes, without empty parts
+func splitName(name string) []string {
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		if len(part) > 0 {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,182 @@
+package gnmidecode
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// ParsePath from XPath-like string to gNMI path structure, setting both elem and the
+// deprecated element field. A backslash escapes the next character, e.g. "\/" or "\]".
+func ParsePath(origin string, path string, target string) *gnmi.Path {
+	gnmiPath := gnmi.Path{Origin: origin, Target: target}
+
+	elem := &gnmi.PathElem{}
+	start, name, value, end := 0, -1, -1, -1
+
+	path = path + "/"
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			if i < len(path)-2 {
+				i++
+			}
+
+		case '[':
+			if end < 0 {
+				end = i
+				elem.Key = make(map[string]string)
+			}
+			if name < 0 {
+				name = i + 1
+			}
+
+		case '=':
+			if name > 0 && value < 0 {
+				value = i + 1
+			}
+
+		case ']':
+			if name > 0 && value > name {
+				elem.Key[unescape(path[name:value-1])] = strings.Trim(unescape(path[value:i]), "'\"")
+			}
+			name, value = -1, -1
+
+		case '/':
+			if name < 0 {
+				if end < 0 {
+					end = i
+				}
+
+				if end > start {
+					elem.Name = unescape(path[start:end])
+					gnmiPath.Elem = append(gnmiPath.Elem, elem)
+					gnmiPath.Element = append(gnmiPath.Element, unescape(path[start:i]))
+				}
+
+				start, name, value, end = i+1, -1, -1, -1
+				elem = &gnmi.PathElem{}
+			}
+		}
+	}
+
+	return &gnmiPath
+}
+
+// Unescape characters escaped by a backslash in path strings
+func unescape(s string) string {
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i < len(s)-1 {
+			i++
+		}
+		builder.WriteByte(s[i])
+	}
+	return builder.String()
+}
+
+// PathElems of a path including compatibility with old gNMI only setting element
+func PathElems(path *gnmi.Path) []*gnmi.PathElem {
+	elems := path.GetElem()
+	if len(elems) == 0 && len(path.GetElement()) > 0 {
+		elems = make([]*gnmi.PathElem, len(path.Element))
+		for i, part := range path.Element {
+			elems[i] = &gnmi.PathElem{Name: part}
+		}
+	}
+	return elems
+}
+
+// CanonicalPath returns the XPath including keys common to all updates of a notification
+func CanonicalPath(notification *gnmi.Notification) string {
+	var common []*gnmi.PathElem
+	for i, update := range notification.Update {
+		elems := PathElems(update.GetPath())
+		if len(elems) > 0 {
+			// Leaf names are represented by fields
+			elems = elems[:len(elems)-1]
+		}
+
+		if i == 0 {
+			common = elems
+			continue
+		}
+
+		n := 0
+		for n < len(common) && n < len(elems) && proto.Equal(common[n], elems[n]) {
+			n++
+		}
+		common = common[:n]
+	}
+
+	var builder bytes.Buffer
+	if len(notification.GetPrefix().GetOrigin()) > 0 {
+		builder.WriteString(notification.Prefix.Origin)
+		builder.WriteRune(':')
+	}
+
+	for _, elems := range [][]*gnmi.PathElem{PathElems(notification.GetPrefix()), common} {
+		for _, elem := range elems {
+			builder.WriteRune('/')
+			builder.WriteString(elem.Name)
+
+			keys := make([]string, 0, len(elem.Key))
+			for key := range elem.Key {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				builder.WriteRune('[')
+				builder.WriteString(key)
+				builder.WriteRune('=')
+				builder.WriteString(elem.Key[key])
+				builder.WriteRune(']')
+			}
+		}
+	}
+
+	if builder.Len() == 0 {
+		builder.WriteRune('/')
+	}
+
+	return builder.String()
+}
+
+// UpdatePath returns the field name of an update path and adds its keys as tags, except
+// for folded keys becoming part of the field name
+func (d *Decoder) updatePath(path *gnmi.Path, tags map[string]string, fields map[string]interface{}) string {
+	var builder, name bytes.Buffer
+
+	if len(path.GetOrigin()) > 0 {
+		builder.WriteString(path.Origin)
+		builder.WriteRune(':')
+	}
+	name.Write(builder.Bytes())
+
+	for i, elem := range PathElems(path) {
+		if i > 0 {
+			builder.WriteRune('/')
+			name.WriteRune('/')
+		}
+		builder.WriteString(elem.Name)
+		name.WriteString(elem.Name)
+		name.WriteString(d.foldedSelector(elem))
+
+		for key, val := range elem.Key {
+			if !d.folded(key) {
+				d.addKey(builder.String()+"/"+key, key, val, tags, fields)
+			}
+		}
+	}
+
+	return name.String()
+}
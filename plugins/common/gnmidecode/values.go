@@ -0,0 +1,63 @@
+package gnmidecode
+
+import (
+	"math"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Field number and wire type of double_val, added to TypedValue by gNMI 0.7.0 and thus not known
+// to the generated code, which keeps it as unrecognized field
+const (
+	doubleValField = 14
+	wireFixed64    = 1
+)
+
+// DecimalValue of the digits and precision of a decimal, e.g. optical power in dBm
+func decimalValue(decimal *gnmi.Decimal64) float64 {
+	return float64(decimal.GetDigits()) / math.Pow10(int(decimal.GetPrecision()))
+}
+
+// NewDoubleValue of a float64 as double_val, encoded as unrecognized field of the typed value
+// like received ones, so doubles keep their precision with the generated code of this version
+func NewDoubleValue(value float64) *gnmi.TypedValue {
+	buffer := proto.NewBuffer(nil)
+	buffer.EncodeVarint(doubleValField<<3 | wireFixed64)
+	buffer.EncodeFixed64(math.Float64bits(value))
+	return &gnmi.TypedValue{XXX_unrecognized: buffer.Bytes()}
+}
+
+// DoubleValue of a double_val kept as unrecognized field of a typed value without known value
+func doubleValue(val *gnmi.TypedValue) (float64, bool) {
+	if val == nil || len(val.XXX_unrecognized) == 0 {
+		return 0, false
+	}
+
+	buffer := proto.NewBuffer(val.XXX_unrecognized)
+	for {
+		key, err := buffer.DecodeVarint()
+		if err != nil {
+			return 0, false
+		}
+
+		switch key & 7 {
+		case 0:
+			_, err = buffer.DecodeVarint()
+		case wireFixed64:
+			var bits uint64
+			if bits, err = buffer.DecodeFixed64(); err == nil && key>>3 == doubleValField {
+				return math.Float64frombits(bits), true
+			}
+		case 2:
+			_, err = buffer.DecodeRawBytes(false)
+		case 5:
+			_, err = buffer.DecodeFixed32()
+		default:
+			return 0, false
+		}
+		if err != nil {
+			return 0, false
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Package inventory provides the devices to collect from, obtained from a source of truth
+// such as NetBox instead of the configuration.
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Device of an inventory, with the address to dial and the tags of its metrics
+type Device struct {
+	Address string
+	Tags    map[string]string
+}
+
+// Provider of the devices of an inventory
+type Provider interface {
+	Devices(ctx context.Context) ([]Device, error)
+}
+
+// Source of devices selecting and configuring an inventory provider
+type Source struct {
+	InventoryProvider string `toml:"inventory_provider"`
+
+	// Port of the gNMI or gRPC service of the devices, appended to their addresses
+	InventoryPort int `toml:"inventory_port"`
+
+	// NetBox API and filters of the devices, e.g. role and status
+	NetBoxURL     string            `toml:"netbox_url"`
+	NetBoxToken   string            `toml:"netbox_token"`
+	NetBoxFilters map[string]string `toml:"netbox_filters"`
+}
+
+// Provider configured, nil if devices are not obtained from an inventory
+func (s *Source) Provider() (Provider, error) {
+	switch s.InventoryProvider {
+	case "":
+		return nil, nil
+	case "netbox":
+		if len(s.NetBoxURL) == 0 {
+			return nil, fmt.Errorf("missing netbox_url")
+		}
+		return &netboxProvider{config: s, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("invalid inventory provider: %s", s.InventoryProvider)
+	}
+}
@@ -0,0 +1,55 @@
+package inventory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoProvider(t *testing.T) {
+	provider, err := (&Source{}).Provider()
+	assert.Nil(t, err)
+	assert.Nil(t, provider)
+
+	_, err = (&Source{InventoryProvider: "netbox"}).Provider()
+	assert.NotNil(t, err)
+
+	_, err = (&Source{InventoryProvider: "ansible"}).Provider()
+	assert.NotNil(t, err)
+}
+
+func TestNetBoxProvider(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/dcim/devices/", r.URL.Path)
+		assert.Equal(t, "Token secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "router", r.URL.Query().Get("role"))
+		assert.Equal(t, "active", r.URL.Query().Get("status"))
+
+		// Devices are paginated
+		if r.URL.Query().Get("offset") != "1" {
+			w.Write([]byte(`{"count": 3, "next": "` + server.URL + `/api/dcim/devices/?role=router&status=active&offset=1",
+				"results": [{"name": "pe1", "primary_ip": {"address": "10.0.0.1/32"}, "site": {"slug": "fra1"},
+				"role": {"slug": "router"}, "platform": {"slug": "iosxr"}},
+				{"name": "pe2", "primary_ip": null, "site": {"slug": "fra1"}}]}`))
+			return
+		}
+		w.Write([]byte(`{"count": 3, "next": null, "results": [{"name": "pe3", "primary_ip": {"address": "2001:db8::3/128"},
+			"device_role": {"slug": "router"}}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := (&Source{InventoryProvider: "netbox", InventoryPort: 57400, NetBoxURL: server.URL + "/",
+		NetBoxToken: "secret", NetBoxFilters: map[string]string{"role": "router", "status": "active"}}).Provider()
+	assert.Nil(t, err)
+
+	devices, err := provider.Devices(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []Device{
+		{Address: "10.0.0.1:57400", Tags: map[string]string{"device": "pe1", "site": "fra1", "role": "router", "platform": "iosxr"}},
+		{Address: "[2001:db8::3]:57400", Tags: map[string]string{"device": "pe3", "role": "router"}},
+	}, devices)
+}
@@ -0,0 +1,118 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Devices of the NetBox DCIM API matching the filters, using their primary IP as address
+type netboxProvider struct {
+	config *Source
+	client *http.Client
+}
+
+// Page of the device list of the NetBox API
+type netboxDevices struct {
+	Next    string         `json:"next"`
+	Results []netboxDevice `json:"results"`
+}
+
+// Device of the NetBox API, the role being named device_role before NetBox 3.6
+type netboxDevice struct {
+	Name       string        `json:"name"`
+	PrimaryIP  *netboxIP     `json:"primary_ip"`
+	Site       *netboxObject `json:"site"`
+	Role       *netboxObject `json:"role"`
+	DeviceRole *netboxObject `json:"device_role"`
+	Platform   *netboxObject `json:"platform"`
+}
+
+type netboxIP struct {
+	Address string `json:"address"`
+}
+
+type netboxObject struct {
+	Slug string `json:"slug"`
+}
+
+func (p *netboxProvider) Devices(ctx context.Context) ([]Device, error) {
+	query := url.Values{}
+	for key, val := range p.config.NetBoxFilters {
+		query.Set(key, val)
+	}
+	next := strings.TrimRight(p.config.NetBoxURL, "/") + "/api/dcim/devices/?" + query.Encode()
+
+	var devices []Device
+	for len(next) > 0 {
+		var page netboxDevices
+		if err := p.get(ctx, next, &page); err != nil {
+			return nil, err
+		}
+
+		for _, device := range page.Results {
+			// Devices without primary IP cannot be dialed
+			if device.PrimaryIP == nil || len(device.PrimaryIP.Address) == 0 {
+				continue
+			}
+			devices = append(devices, Device{Address: p.address(device.PrimaryIP.Address), Tags: device.tags()})
+		}
+		next = page.Next
+	}
+	return devices, nil
+}
+
+// Get a page of the API
+func (p *netboxProvider) get(ctx context.Context, url string, page *netboxDevices) error {
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if len(p.config.NetBoxToken) > 0 {
+		request.Header.Set("Authorization", "Token "+p.config.NetBoxToken)
+	}
+
+	response, err := p.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("NetBox request failed: %s", response.Status)
+	}
+	return json.NewDecoder(response.Body).Decode(page)
+}
+
+// Address of a primary IP in CIDR notation, e.g. "10.0.0.1/32", with the configured port
+func (p *netboxProvider) address(cidr string) string {
+	ip := strings.SplitN(cidr, "/", 2)[0]
+	if p.config.InventoryPort > 0 {
+		return net.JoinHostPort(ip, strconv.Itoa(p.config.InventoryPort))
+	}
+	return ip
+}
+
+// Tags of a device by name, site, role and platform
+func (d *netboxDevice) tags() map[string]string {
+	tags := make(map[string]string)
+	if len(d.Name) > 0 {
+		tags["device"] = d.Name
+	}
+	role := d.Role
+	if role == nil {
+		role = d.DeviceRole
+	}
+	for key, object := range map[string]*netboxObject{"site": d.Site, "role": role, "platform": d.Platform} {
+		if object != nil && len(object.Slug) > 0 {
+			tags[key] = object.Slug
+		}
+	}
+	return tags
+}
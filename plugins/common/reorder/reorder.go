@@ -0,0 +1,92 @@
+// Package reorder buffers metrics for a bounded time to emit bursts of metrics
+// in non-decreasing timestamp order.
+package reorder
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Emit function receiving the reordered metrics, e.g. telegraf.Accumulator.AddFields
+type Emit func(string, map[string]interface{}, map[string]string, ...time.Time)
+
+// Buffer holding metrics for up to a maximum latency
+type Buffer struct {
+	latency time.Duration
+	emit    Emit
+	mutex   sync.Mutex
+	entries []*entry
+}
+
+type entry struct {
+	name      string
+	fields    map[string]interface{}
+	tags      map[string]string
+	timestamp time.Time
+	arrival   time.Time
+}
+
+// New buffer holding metrics for up to latency
+func New(latency time.Duration, emit Emit) *Buffer {
+	return &Buffer{latency: latency, emit: emit}
+}
+
+// Add a metric to the buffer
+func (b *Buffer) Add(name string, fields map[string]interface{}, tags map[string]string, timestamp time.Time) {
+	b.mutex.Lock()
+	b.entries = append(b.entries, &entry{name: name, fields: fields, tags: tags, timestamp: timestamp, arrival: time.Now()})
+	b.mutex.Unlock()
+}
+
+// Run flushing metrics whose latency expired until the context is done
+func (b *Buffer) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.latency / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			b.flush(now.Add(-b.latency))
+		}
+	}
+}
+
+// Flush all buffered metrics, e.g. when stopping
+func (b *Buffer) Flush() {
+	b.flush(time.Time{})
+}
+
+// Flush metrics that arrived before a deadline in timestamp order, together with all
+// metrics not newer than them, or all metrics if the deadline is zero
+func (b *Buffer) flush(deadline time.Time) {
+	b.mutex.Lock()
+
+	var horizon time.Time
+	for _, e := range b.entries {
+		if (deadline.IsZero() || !e.arrival.After(deadline)) && e.timestamp.After(horizon) {
+			horizon = e.timestamp
+		}
+	}
+
+	// Stable sort keeps the arrival order of metrics with equal timestamps
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		return b.entries[i].timestamp.Before(b.entries[j].timestamp)
+	})
+
+	n := 0
+	for n < len(b.entries) && (deadline.IsZero() || !b.entries[n].timestamp.After(horizon)) {
+		n++
+	}
+	flushed := b.entries[:n]
+	b.entries = append([]*entry(nil), b.entries[n:]...)
+
+	b.mutex.Unlock()
+
+	for _, e := range flushed {
+		b.emit(e.name, e.fields, e.tags, e.timestamp)
+	}
+}
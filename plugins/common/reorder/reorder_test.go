@@ -0,0 +1,34 @@
+package reorder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuffer(t *testing.T) {
+	var emitted []string
+	b := New(time.Second, func(name string, _ map[string]interface{}, _ map[string]string, _ ...time.Time) {
+		emitted = append(emitted, name)
+	})
+
+	b.Add("b", nil, nil, time.Unix(20, 0))
+	b.Add("a", nil, nil, time.Unix(10, 0))
+	b.Add("d", nil, nil, time.Unix(40, 0))
+
+	// Nothing has been buffered long enough yet
+	b.flush(time.Now().Add(-time.Second))
+	assert.Empty(t, emitted)
+
+	// Metrics not newer than the expired ones are flushed with them
+	deadline := time.Now()
+	b.Add("c", nil, nil, time.Unix(30, 0))
+	b.Add("e", nil, nil, time.Unix(15, 0))
+	b.entries[2].arrival = deadline.Add(time.Second)
+	b.flush(deadline)
+	assert.Equal(t, []string{"a", "e", "b"}, emitted)
+
+	b.Flush()
+	assert.Equal(t, []string{"a", "e", "b", "c", "d"}, emitted)
+}
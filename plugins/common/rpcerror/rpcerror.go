@@ -0,0 +1,181 @@
+// Package rpcerror decodes the detailed error information IOS XR attaches to gRPC errors,
+// i.e. status details, trailers and JSON encoded error lists, into readable errors.
+package rpcerror
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Error of a gRPC call with the details provided by the device
+type Error struct {
+	Code    codes.Code
+	Message string
+	// Tag of the first error of a JSON encoded error list, e.g. "access-denied"
+	Tag     string
+	Details []string
+
+	err error
+}
+
+// Error formats the code, message and details without the "rpc error: code = ... desc" prefix
+func (e *Error) Error() string {
+	message := e.Code.String()
+	if len(e.Message) > 0 {
+		message += ": " + e.Message
+	}
+	if len(e.Details) > 0 {
+		message += " (" + strings.Join(e.Details, "; ") + ")"
+	}
+	return message
+}
+
+// GRPCStatus returns the original status, so status.Code keeps working on decoded errors
+func (e *Error) GRPCStatus() *status.Status {
+	return status.Convert(e.err)
+}
+
+// Decode a gRPC error and the trailers of its call, returning other errors unchanged
+func Decode(err error, trailer metadata.MD) error {
+	s, ok := status.FromError(err)
+	if !ok || s == nil {
+		return err
+	}
+
+	result := &Error{Code: s.Code(), err: err}
+	result.Message, result.Tag = ParseMessage(s.Message())
+
+	for _, detail := range s.Details() {
+		result.Details = append(result.Details, describeDetail(detail)...)
+	}
+
+	// XR reports some errors only as trailer, skip those of gRPC itself
+	keys := make([]string, 0, len(trailer))
+	for key := range trailer {
+		if !strings.HasPrefix(key, "grpc-") && !strings.HasSuffix(key, "-bin") && key != "content-type" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Details = append(result.Details, key+"="+strings.Join(trailer[key], ","))
+	}
+
+	return result
+}
+
+// ParseMessage decodes JSON encoded error lists used by XR (e.g. {"cisco-grpc:errors": {"error":
+// [...]}}), returning the readable messages and the tag of the first error. Other messages are
+// returned unchanged.
+func ParseMessage(message string) (string, string) {
+	message = strings.TrimSpace(message)
+	if !strings.HasPrefix(message, "{") {
+		return message, ""
+	}
+
+	var root map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &root); err != nil {
+		return message, ""
+	}
+
+	var errors []string
+	var tag string
+	for _, entry := range findErrors(root) {
+		text := fmt.Sprint(entry["error-message"])
+		if _, ok := entry["error-message"]; !ok {
+			text = "unspecified error"
+		}
+		if errorTag, ok := entry["error-tag"].(string); ok {
+			text = errorTag + ": " + text
+			if len(tag) == 0 {
+				tag = errorTag
+			}
+		}
+		if path, ok := entry["error-path"].(string); ok {
+			text += " at " + path
+		}
+		errors = append(errors, text)
+	}
+
+	if len(errors) == 0 {
+		return message, ""
+	}
+	return strings.Join(errors, "; "), tag
+}
+
+// FindErrors returns the entries of the first "error" list nested within a JSON object
+func findErrors(object map[string]interface{}) []map[string]interface{} {
+	if list, ok := object["error"].([]interface{}); ok {
+		var entries []map[string]interface{}
+		for _, item := range list {
+			if entry, ok := item.(map[string]interface{}); ok {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+
+	for _, value := range object {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if entries := findErrors(nested); len(entries) > 0 {
+				return entries
+			}
+		}
+	}
+	return nil
+}
+
+// DescribeDetail formats the standard error details of a status
+func describeDetail(detail interface{}) []string {
+	var result []string
+	switch d := detail.(type) {
+	case *errdetails.ErrorInfo:
+		info := "reason=" + d.Reason
+		if len(d.Domain) > 0 {
+			info += " domain=" + d.Domain
+		}
+		keys := make([]string, 0, len(d.Metadata))
+		for key := range d.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			info += " " + key + "=" + d.Metadata[key]
+		}
+		result = append(result, info)
+	case *errdetails.DebugInfo:
+		result = append(result, d.Detail)
+	case *errdetails.LocalizedMessage:
+		result = append(result, d.Message)
+	case *errdetails.BadRequest:
+		for _, violation := range d.FieldViolations {
+			result = append(result, violation.Field+": "+violation.Description)
+		}
+	case *errdetails.PreconditionFailure:
+		for _, violation := range d.Violations {
+			result = append(result, violation.Subject+": "+violation.Description)
+		}
+	case *errdetails.QuotaFailure:
+		for _, violation := range d.Violations {
+			result = append(result, violation.Subject+": "+violation.Description)
+		}
+	case *errdetails.ResourceInfo:
+		result = append(result, d.ResourceType+" "+d.ResourceName+": "+d.Description)
+	case *errdetails.Help:
+		for _, link := range d.Links {
+			result = append(result, "see "+link.Url)
+		}
+	case error:
+		// Details of types unknown to this binary cannot be decoded
+	default:
+		result = append(result, fmt.Sprint(d))
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package rpcerror
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestDecode(t *testing.T) {
+	s, err := status.New(codes.InvalidArgument, "invalid subscription").WithDetails(
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "path", Description: "unknown sensor path"}}},
+		&errdetails.ErrorInfo{Reason: "SENSOR_UNKNOWN", Domain: "cisco.com", Metadata: map[string]string{"sensor": "bfd"}})
+	assert.Nil(t, err)
+
+	trailer := metadata.Pairs("content-type", "application/grpc", "xr-error", "sensor group not configured")
+	decoded := Decode(s.Err(), trailer)
+	assert.Equal(t, "InvalidArgument: invalid subscription (path: unknown sensor path; "+
+		"reason=SENSOR_UNKNOWN domain=cisco.com sensor=bfd; xr-error=sensor group not configured)", decoded.Error())
+	assert.Equal(t, codes.InvalidArgument, status.Code(decoded))
+
+	other := errors.New("testerror")
+	assert.Equal(t, other, Decode(other, nil))
+}
+
+func TestParseMessage(t *testing.T) {
+	message, tag := ParseMessage(`{"cisco-grpc:errors": {"error": [{"error-type": "application",
+		"error-tag": "operation-failed", "error-severity": "error", "error-path": "/interfaces",
+		"error-message": "'YANG framework' detected the 'fatal' condition 'Operation failed'"},
+		{"error-tag": "invalid-value"}]}}`)
+	assert.Equal(t, "operation-failed: 'YANG framework' detected the 'fatal' condition 'Operation failed' "+
+		"at /interfaces; invalid-value: unspecified error", message)
+	assert.Equal(t, "operation-failed", tag)
+
+	message, tag = ParseMessage("subscription not found")
+	assert.Equal(t, "subscription not found", message)
+	assert.Empty(t, tag)
+
+	message, _ = ParseMessage(`{"unrelated": true}`)
+	assert.Equal(t, `{"unrelated": true}`, message)
+}
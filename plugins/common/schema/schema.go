@@ -0,0 +1,102 @@
+// Package schema detects changes of the fields and field types reported for a
+// measurement, e.g. after an OS upgrade of a device.
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Change of the schema of a measurement
+type Change struct {
+	Fingerprint string
+	Previous    string
+	Fields      int
+	Changed     []string
+}
+
+// Tracker of the schemas observed per target and measurement
+type Tracker struct {
+	mutex   sync.Mutex
+	schemas map[string]*schema
+}
+
+type schema struct {
+	types       map[string]string
+	fingerprint string
+}
+
+// NewTracker creates an empty schema tracker
+func NewTracker() *Tracker {
+	return &Tracker{schemas: make(map[string]*schema)}
+}
+
+// Observe the fields of a measurement of a target and return the change of its
+// schema or nil if all fields have been observed with the same type before
+func (t *Tracker) Observe(target string, measurement string, fields map[string]interface{}) *Change {
+	key := target + "|" + measurement
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s, ok := t.schemas[key]
+	if !ok {
+		s = &schema{types: make(map[string]string, len(fields))}
+		t.schemas[key] = s
+	}
+
+	var changed []string
+	for name, value := range fields {
+		valueType := typeName(value)
+		if s.types[name] != valueType {
+			s.types[name] = valueType
+			changed = append(changed, name)
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	sort.Strings(changed)
+	change := &Change{Previous: s.fingerprint, Fields: len(s.types), Changed: changed}
+	s.fingerprint = fingerprint(s.types)
+	change.Fingerprint = s.fingerprint
+	return change
+}
+
+// Fingerprint of the sorted field names and types
+func fingerprint(types map[string]string) string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s:%s\n", name, types[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+// TypeName of a field value in line protocol terms
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case int, int8, int16, int32, int64:
+		return "integer"
+	case uint, uint8, uint16, uint32, uint64:
+		return "unsigned"
+	case float32, float64:
+		return "float"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
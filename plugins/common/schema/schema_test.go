@@ -0,0 +1,32 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+
+	change := tracker.Observe("router1", "interfaces", map[string]interface{}{"in-octets": uint64(1), "name": "eth0"})
+	assert.NotNil(t, change)
+	assert.Equal(t, "", change.Previous)
+	assert.Equal(t, 2, change.Fields)
+	assert.Equal(t, []string{"in-octets", "name"}, change.Changed)
+	initial := change.Fingerprint
+
+	// Same schema and subsets of it are no change
+	assert.Nil(t, tracker.Observe("router1", "interfaces", map[string]interface{}{"in-octets": uint64(2), "name": "eth1"}))
+	assert.Nil(t, tracker.Observe("router1", "interfaces", map[string]interface{}{"name": "eth1"}))
+
+	// Schemas are tracked per target
+	change = tracker.Observe("router2", "interfaces", map[string]interface{}{"in-octets": uint64(1), "name": "eth0"})
+	assert.Equal(t, initial, change.Fingerprint)
+
+	// Changed field types are detected
+	change = tracker.Observe("router1", "interfaces", map[string]interface{}{"in-octets": int64(1)})
+	assert.Equal(t, initial, change.Previous)
+	assert.NotEqual(t, initial, change.Fingerprint)
+	assert.Equal(t, []string{"in-octets"}, change.Changed)
+}
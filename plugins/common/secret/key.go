@@ -0,0 +1,44 @@
+package secret
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+)
+
+// ResolveKeyFile resolves a configuration value naming a PEM encoded private key like ResolveFile,
+// decrypting the key by the passphrase if given, which may reference a secret as well. Decrypted
+// keys are written to a private temporary file removed by the returned function.
+func ResolveKeyFile(value string, passphrase string) (string, func(), error) {
+	path, cleanup, err := ResolveFile(value)
+	if err != nil || len(passphrase) == 0 {
+		return path, cleanup, err
+	}
+	defer cleanup()
+
+	if passphrase, err = Resolve(passphrase); err != nil {
+		return "", nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", nil, fmt.Errorf("no PEM data in key %s", path)
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return "", nil, fmt.Errorf("PKCS#8 encrypted keys are not supported, convert with \"openssl rsa -aes256\"")
+	}
+	if !x509.IsEncryptedPEMBlock(block) {
+		return "", nil, fmt.Errorf("key %s is not encrypted", path)
+	}
+
+	der, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decrypt key: %v", err)
+	}
+	return writePrivateFile(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}))
+}
@@ -0,0 +1,222 @@
+// Package secret resolves configuration values referencing secrets stored in
+// HashiCorp Vault, encrypted with AWS KMS, or held by environment variables and files,
+// e.g. passwords and TLS keys.
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	vaultPrefix  = "vault:"
+	awsKMSPrefix = "aws-kms:"
+	envPrefix    = "env:"
+	filePrefix   = "file:"
+
+	// Timeout of resolving a single secret
+	timeout = 30 * time.Second
+)
+
+// IsReference checks whether a configuration value references a secret
+func IsReference(value string) bool {
+	for _, prefix := range []string{vaultPrefix, awsKMSPrefix, envPrefix, filePrefix} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve a configuration value, returning values not referencing a secret unchanged.
+// References are of the form "vault:<path>#<key>", "aws-kms:<base64 ciphertext>",
+// "env:<variable>" or "file:<path>".
+func Resolve(value string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch {
+	case strings.HasPrefix(value, vaultPrefix):
+		return resolveVault(ctx, strings.TrimPrefix(value, vaultPrefix))
+	case strings.HasPrefix(value, awsKMSPrefix):
+		return resolveAWSKMS(ctx, strings.TrimPrefix(value, awsKMSPrefix))
+	case strings.HasPrefix(value, envPrefix):
+		return resolveEnv(strings.TrimPrefix(value, envPrefix))
+	case strings.HasPrefix(value, filePrefix):
+		return resolveFileContent(strings.TrimPrefix(value, filePrefix))
+	}
+	return value, nil
+}
+
+// ResolveFile resolves a configuration value naming a file, e.g. a TLS key. Secrets are
+// written to a private temporary file whose path is returned along with a function removing it.
+func ResolveFile(value string) (string, func(), error) {
+	if !IsReference(value) {
+		return value, func() {}, nil
+	}
+
+	content, err := Resolve(value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return writePrivateFile([]byte(content))
+}
+
+// WritePrivateFile writes content to a temporary file only readable by the owner, returning its
+// path and a function removing it
+func writePrivateFile(content []byte) (string, func(), error) {
+	file, err := ioutil.TempFile("", "telegraf-secret-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	cleanup := func() { os.Remove(file.Name()) }
+	if err = file.Chmod(0600); err == nil {
+		_, err = file.Write(content)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return file.Name(), cleanup, nil
+}
+
+// ResolveEnv reads a secret from an environment variable, which must be set
+func resolveEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", name)
+	}
+	return value, nil
+}
+
+// ResolveFileContent reads a secret from a file, e.g. mounted by a container orchestrator,
+// without the trailing line break
+func resolveFileContent(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// ResolveVault reads a key of a secret from Vault, addressed by VAULT_ADDR and
+// authenticated by VAULT_TOKEN or the token file of the Vault CLI
+func resolveVault(ctx context.Context, reference string) (string, error) {
+	i := strings.LastIndexByte(reference, '#')
+	if i < 0 {
+		return "", fmt.Errorf("vault reference %s without #key", reference)
+	}
+	path, key := strings.Trim(reference[:i], "/"), reference[i+1:]
+
+	address := os.Getenv("VAULT_ADDR")
+	if len(address) == 0 {
+		return "", fmt.Errorf("VAULT_ADDR not set for vault reference %s", reference)
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if len(token) == 0 {
+		data, err := ioutil.ReadFile(filepath.Join(os.Getenv("HOME"), ".vault-token"))
+		if err != nil {
+			return "", fmt.Errorf("VAULT_TOKEN not set for vault reference %s", reference)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	data, status, err := readVault(ctx, address, token, path)
+
+	// Secrets of KV version 2 engines are read below data/ of the mount as by the Vault CLI
+	if err == nil && status == http.StatusNotFound && strings.ContainsRune(path, '/') {
+		j := strings.IndexByte(path, '/')
+		data, status, err = readVault(ctx, address, token, path[:j]+"/data"+path[j:])
+	}
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %d for %s", status, path)
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// ReadVault reads the data of a secret, unwrapping data of KV version 2 secrets
+func readVault(ctx context.Context, address string, token string, path string) (map[string]interface{}, int, error) {
+	request, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(address, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	request.Header.Set("X-Vault-Token", token)
+
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, response.StatusCode, nil
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&secret); err != nil {
+		return nil, 0, fmt.Errorf("invalid vault response: %v", err)
+	}
+
+	if nested, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, ok = secret.Data["metadata"]; ok {
+			return nested, response.StatusCode, nil
+		}
+	}
+	return secret.Data, response.StatusCode, nil
+}
+
+// ResolveAWSKMS decrypts a ciphertext using the AWS CLI and its credential chain
+func resolveAWSKMS(ctx context.Context, ciphertext string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid aws-kms ciphertext: %v", err)
+	}
+
+	// Pass the ciphertext as file as the CLI versions disagree on the encoding of blob arguments
+	file, err := ioutil.TempFile("", "telegraf-kms-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(blob)
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	output, err := exec.CommandContext(ctx, "aws", "kms", "decrypt", "--ciphertext-blob", "fileb://"+file.Name(),
+		"--query", "Plaintext", "--output", "text").Output()
+	if err != nil {
+		return "", fmt.Errorf("aws kms decrypt failed: %v", err)
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(output)))
+	if err != nil {
+		return "", fmt.Errorf("invalid aws kms plaintext: %v", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,133 @@
+package secret
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "thetoken" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/telemetry":
+			w.Write([]byte(`{"data": {"password": "v1secret"}}`))
+		case "/v1/kv/data/telemetry":
+			w.Write([]byte(`{"data": {"data": {"password": "v2secret"}, "metadata": {"version": 3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	os.Setenv("VAULT_ADDR", server.URL)
+	os.Setenv("VAULT_TOKEN", "thetoken")
+	defer os.Unsetenv("VAULT_ADDR")
+	defer os.Unsetenv("VAULT_TOKEN")
+
+	value, err := Resolve("vault:secret/telemetry#password")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1secret", value)
+
+	value, err = Resolve("vault:kv/telemetry#password")
+	assert.Nil(t, err)
+	assert.Equal(t, "v2secret", value)
+
+	_, err = Resolve("vault:kv/telemetry#username")
+	assert.NotNil(t, err)
+	_, err = Resolve("vault:kv/missing#password")
+	assert.NotNil(t, err)
+	_, err = Resolve("vault:kv/telemetry")
+	assert.NotNil(t, err)
+
+	path, cleanup, err := ResolveFile("vault:kv/telemetry#password")
+	assert.Nil(t, err)
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "v2secret", string(content))
+	cleanup()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestResolvePlain(t *testing.T) {
+	assert.False(t, IsReference("cisco"))
+	value, err := Resolve("cisco")
+	assert.Nil(t, err)
+	assert.Equal(t, "cisco", value)
+
+	path, cleanup, err := ResolveFile("/etc/telegraf/key.pem")
+	assert.Nil(t, err)
+	assert.Equal(t, "/etc/telegraf/key.pem", path)
+	cleanup()
+
+	_, err = Resolve("aws-kms:not base64")
+	assert.NotNil(t, err)
+}
+
+func TestResolveEnvFile(t *testing.T) {
+	os.Setenv("TELEGRAF_TEST_PASSWORD", "envsecret")
+	defer os.Unsetenv("TELEGRAF_TEST_PASSWORD")
+
+	value, err := Resolve("env:TELEGRAF_TEST_PASSWORD")
+	assert.Nil(t, err)
+	assert.Equal(t, "envsecret", value)
+	_, err = Resolve("env:TELEGRAF_TEST_MISSING")
+	assert.NotNil(t, err)
+
+	file, _ := ioutil.TempFile("", "password")
+	defer os.Remove(file.Name())
+	file.WriteString("filesecret\n")
+	file.Close()
+
+	value, err = Resolve("file:" + file.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, "filesecret", value)
+	_, err = Resolve("file:/nonexistent/password")
+	assert.NotNil(t, err)
+}
+
+func TestResolveKeyFile(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(key)
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", der, []byte("thepassphrase"), x509.PEMCipherAES256)
+	assert.Nil(t, err)
+
+	file, _ := ioutil.TempFile("", "key")
+	defer os.Remove(file.Name())
+	pem.Encode(file, block)
+	file.Close()
+
+	os.Setenv("TELEGRAF_TEST_PASSPHRASE", "thepassphrase")
+	defer os.Unsetenv("TELEGRAF_TEST_PASSPHRASE")
+
+	path, cleanup, err := ResolveKeyFile(file.Name(), "env:TELEGRAF_TEST_PASSPHRASE")
+	assert.Nil(t, err)
+	content, _ := ioutil.ReadFile(path)
+	decrypted, _ := pem.Decode(content)
+	assert.Equal(t, der, decrypted.Bytes)
+	cleanup()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	_, _, err = ResolveKeyFile(file.Name(), "env:TELEGRAF_TEST_MISSING")
+	assert.NotNil(t, err)
+
+	// Keys are used as they are without passphrase
+	path, cleanup, err = ResolveKeyFile(file.Name(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, file.Name(), path)
+	cleanup()
+}
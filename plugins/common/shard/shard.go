@@ -0,0 +1,47 @@
+// Package shard assigns targets of a shared inventory to collector instances
+// by consistent hashing, so each instance collects only its share of targets.
+package shard
+
+import (
+	"hash/fnv"
+	"strconv"
+)
+
+// Owner returns the index of the shard out of count shards owning a key.
+// Rendezvous hashing is used, so changing the number of shards only moves the
+// keys of added or removed shards.
+func Owner(key string, count int) int {
+	owner := 0
+	var highest uint64
+	for i := 0; i < count; i++ {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(i)))
+
+		// Mix the bits as FNV alone distributes similar keys poorly
+		weight := mix(h.Sum64())
+		if i == 0 || weight > highest {
+			owner, highest = i, weight
+		}
+	}
+	return owner
+}
+
+// Owns checks whether the shard with the given index owns a key
+func Owns(key string, index int, count int) bool {
+	if count <= 1 {
+		return true
+	}
+	return Owner(key, count) == index
+}
+
+// Mix the bits of a hash using the finalizer of MurmurHash3
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,47 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwner(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("10.0.%d.%d:57400", i/256, i%256)
+	}
+
+	// Keys are distributed evenly and owned by exactly one shard
+	counts := make([]int, 4)
+	owners := make(map[string]int, len(keys))
+	for _, key := range keys {
+		owners[key] = Owner(key, 4)
+		counts[owners[key]]++
+
+		owned := 0
+		for i := 0; i < 4; i++ {
+			if Owns(key, i, 4) {
+				owned++
+			}
+		}
+		assert.Equal(t, 1, owned)
+	}
+	for _, count := range counts {
+		assert.InDelta(t, 250, count, 50)
+	}
+
+	// Adding a shard only moves keys to the new shard
+	moved := 0
+	for _, key := range keys {
+		if owner := Owner(key, 5); owner != owners[key] {
+			assert.Equal(t, 4, owner)
+			moved++
+		}
+	}
+	assert.InDelta(t, 200, moved, 50)
+
+	assert.True(t, Owns("any", 0, 0))
+	assert.True(t, Owns("any", 0, 1))
+}
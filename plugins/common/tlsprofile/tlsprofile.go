@@ -0,0 +1,78 @@
+// Package tlsprofile loads named TLS client profiles from a shared file, so many plugins and
+// device entries can reference a common CA and client certificate by name.
+package tlsprofile
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	internaltls "github.com/influxdata/telegraf/internal/tls"
+	"gopkg.in/yaml.v3"
+)
+
+// Profile of TLS client settings, the key may reference a secret and be encrypted by the passphrase
+type Profile struct {
+	TLSCA              string `yaml:"tls_ca"`
+	TLSCert            string `yaml:"tls_cert"`
+	TLSKey             string `yaml:"tls_key"`
+	TLSKeyPassphrase   string `yaml:"tls_key_passphrase"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Profiles by name
+type Profiles map[string]*Profile
+
+// Load the profiles of a YAML file mapping profile names to their settings
+func Load(path string) (Profiles, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var profiles Profiles
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&profiles); err != nil {
+		return nil, fmt.Errorf("invalid TLS profiles in %s: %v", path, err)
+	}
+	for name, profile := range profiles {
+		if profile == nil {
+			return nil, fmt.Errorf("empty TLS profile %q in %s", name, path)
+		}
+	}
+	return profiles, nil
+}
+
+// Apply the named profile to a client configuration, returning the passphrase of its key. Settings
+// given by the configuration take precedence over those of the profile.
+func (p Profiles) Apply(name string, config *internaltls.ClientConfig, passphrase string) (string, error) {
+	profile, ok := p[name]
+	if !ok {
+		return "", fmt.Errorf("unknown TLS profile %q, known profiles: %s", name, strings.Join(p.names(), ", "))
+	}
+
+	if len(config.TLSCA) == 0 {
+		config.TLSCA = profile.TLSCA
+	}
+	if len(config.TLSCert) == 0 {
+		config.TLSCert = profile.TLSCert
+	}
+	if len(config.TLSKey) == 0 {
+		config.TLSKey = profile.TLSKey
+		passphrase = profile.TLSKeyPassphrase
+	}
+	config.InsecureSkipVerify = config.InsecureSkipVerify || profile.InsecureSkipVerify
+	return passphrase, nil
+}
+
+func (p Profiles) names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
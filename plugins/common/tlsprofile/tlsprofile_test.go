@@ -0,0 +1,59 @@
+package tlsprofile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	internaltls "github.com/influxdata/telegraf/internal/tls"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsprofile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "profiles.yaml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`
+internal-ca:
+  tls_ca: /etc/telegraf/ca.pem
+  tls_cert: /etc/telegraf/cert.pem
+  tls_key: /etc/telegraf/key.pem
+  tls_key_passphrase: env:TLS_KEY_PASSPHRASE
+lab:
+  insecure_skip_verify: true
+`), 0600))
+
+	profiles, err := Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, profiles, 2)
+
+	config := internaltls.ClientConfig{}
+	passphrase, err := profiles.Apply("internal-ca", &config, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "env:TLS_KEY_PASSPHRASE", passphrase)
+	assert.Equal(t, internaltls.ClientConfig{TLSCA: "/etc/telegraf/ca.pem", TLSCert: "/etc/telegraf/cert.pem",
+		TLSKey: "/etc/telegraf/key.pem"}, config)
+
+	// Settings of the configuration override those of the profile
+	config = internaltls.ClientConfig{TLSKey: "/etc/telegraf/device.pem"}
+	passphrase, err = profiles.Apply("internal-ca", &config, "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", passphrase)
+	assert.Equal(t, "/etc/telegraf/device.pem", config.TLSKey)
+	assert.Equal(t, "/etc/telegraf/ca.pem", config.TLSCA)
+
+	config = internaltls.ClientConfig{}
+	_, err = profiles.Apply("lab", &config, "")
+	assert.NoError(t, err)
+	assert.True(t, config.InsecureSkipVerify)
+
+	_, err = profiles.Apply("unknown", &config, "")
+	assert.EqualError(t, err, `unknown TLS profile "unknown", known profiles: internal-ca, lab`)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("internal-ca:\n  tls_crt: /etc/telegraf/cert.pem\n"), 0600))
+	_, err = Load(path)
+	assert.Error(t, err)
+}
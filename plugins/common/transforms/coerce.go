@@ -0,0 +1,141 @@
+package transforms
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Coercion converts a field value to a fixed type, returning false if it cannot be converted
+type Coercion func(value interface{}) (interface{}, bool)
+
+// Types fields can be coerced to
+var coercions = map[string]Coercion{
+	"string": toString,
+	"int":    toInt,
+	"uint":   toUint,
+	"float": func(value interface{}) (interface{}, bool) {
+		if text, ok := value.(string); ok {
+			number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			return number, err == nil
+		}
+		return toFloat(value)
+	},
+	"bool": toBool,
+}
+
+// Coercions mapping field names to the types their values are coerced to
+type Coercions map[string]Coercion
+
+// NewCoercions from a map of field names to type names
+func NewCoercions(config map[string]string) (Coercions, error) {
+	rules := make(Coercions, len(config))
+	for field, name := range config {
+		coercion, ok := coercions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q for field %q", name, field)
+		}
+		rules[field] = coercion
+	}
+	return rules, nil
+}
+
+// Apply coercions to fields matching a rule by full name or by last path element. Fields that
+// cannot be coerced are removed, so the series never changes its type.
+func (c Coercions) Apply(fields map[string]interface{}) {
+	if len(c) == 0 {
+		return
+	}
+
+	for name, value := range fields {
+		coercion, ok := c[name]
+		if !ok {
+			coercion, ok = c[name[strings.LastIndexByte(name, '/')+1:]]
+		}
+		if !ok {
+			continue
+		}
+
+		if coerced, ok := coercion(value); ok {
+			fields[name] = coerced
+		} else {
+			delete(fields, name)
+		}
+	}
+}
+
+func toString(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case []byte:
+		return string(v), true
+	}
+	return fmt.Sprint(value), true
+}
+
+func toInt(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case bool:
+		if v {
+			return int64(1), true
+		}
+		return int64(0), true
+	case string:
+		number, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return number, err == nil
+	}
+
+	number, ok := toFloat(value)
+	return int64(number), ok && number >= math.MinInt64 && number <= math.MaxInt64
+}
+
+func toUint(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case uint64:
+		return v, true
+	case uint32:
+		return uint64(v), true
+	case int64:
+		return uint64(v), v >= 0
+	case int32:
+		return uint64(v), v >= 0
+	case bool:
+		if v {
+			return uint64(1), true
+		}
+		return uint64(0), true
+	case string:
+		number, err := strconv.ParseUint(strings.TrimSpace(v), 0, 64)
+		return number, err == nil
+	}
+
+	number, ok := toFloat(value)
+	return uint64(number), ok && number >= 0 && number <= math.MaxUint64
+}
+
+func toBool(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		flag, err := strconv.ParseBool(strings.TrimSpace(v))
+		return flag, err == nil
+	}
+
+	number, ok := toFloat(value)
+	return number != 0, ok
+}
@@ -0,0 +1,105 @@
+package transforms
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Suffix of the availability flags of fields with sentinels
+const availableSuffix = "_available"
+
+// Sentinel value reported by a device for "not available", e.g. 0xFFFFFFFFFFFFFFFF or "NULL"
+type Sentinel struct {
+	text     string
+	unsigned uint64
+	signed   int64
+	float    float64
+
+	isUnsigned, isSigned, isFloat bool
+}
+
+// Sentinels mapping field names to their sentinel values
+type Sentinels map[string]Sentinel
+
+// NewSentinels from a map of field names to sentinel values, given as decimal or hexadecimal
+// numbers or as text
+func NewSentinels(config map[string]string) (Sentinels, error) {
+	sentinels := make(Sentinels, len(config))
+	for field, text := range config {
+		if len(text) == 0 {
+			return nil, fmt.Errorf("empty sentinel for field %q", field)
+		}
+		sentinels[field] = parseSentinel(text)
+	}
+	return sentinels, nil
+}
+
+func parseSentinel(text string) Sentinel {
+	s := Sentinel{text: text}
+	var err error
+	if s.unsigned, err = strconv.ParseUint(text, 0, 64); err == nil {
+		s.isUnsigned, s.float, s.isFloat = true, float64(s.unsigned), true
+	}
+	if s.signed, err = strconv.ParseInt(text, 0, 64); err == nil {
+		s.isSigned, s.float, s.isFloat = true, float64(s.signed), true
+	}
+	if !s.isFloat {
+		s.float, err = strconv.ParseFloat(text, 64)
+		s.isFloat = err == nil
+	}
+	return s
+}
+
+// Matches returns true if the value equals the sentinel, comparing numbers by value
+func (s Sentinel) Matches(value interface{}) bool {
+	switch v := value.(type) {
+	case uint64:
+		return s.isUnsigned && v == s.unsigned
+	case uint32:
+		return s.isUnsigned && uint64(v) == s.unsigned
+	case int64:
+		return s.isSigned && v == s.signed
+	case int32:
+		return s.isSigned && int64(v) == s.signed
+	case float64:
+		return s.isFloat && v == s.float
+	case float32:
+		return s.isFloat && float64(v) == s.float
+	case string:
+		return v == s.text
+	}
+	return false
+}
+
+// Apply removes fields holding their sentinel, matching by full name or by last path element.
+// With flag, a "<field>_available" field is added for each field with a sentinel, so that the
+// availability can be tracked as a series of its own.
+func (s Sentinels) Apply(fields map[string]interface{}, flag bool) {
+	if len(s) == 0 {
+		return
+	}
+
+	available := make(map[string]interface{})
+	for name, value := range fields {
+		sentinel, ok := s[name]
+		if !ok {
+			sentinel, ok = s[name[strings.LastIndexByte(name, '/')+1:]]
+		}
+		if !ok {
+			continue
+		}
+
+		matches := sentinel.Matches(value)
+		if matches {
+			delete(fields, name)
+		}
+		if flag {
+			available[name+availableSuffix] = !matches
+		}
+	}
+
+	for name, value := range available {
+		fields[name] = value
+	}
+}
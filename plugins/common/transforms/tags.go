@@ -0,0 +1,82 @@
+package transforms
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+)
+
+// Sanitizer normalizes a tag value
+type Sanitizer func(value string) string
+
+// Library of built-in sanitizers selectable by name
+var sanitizers = map[string]Sanitizer{
+	"lowercase":  strings.ToLower,
+	"uppercase":  strings.ToUpper,
+	"trim_space": strings.TrimSpace,
+
+	// Runs of whitespace replaced by a single underscore, e.g. "GigabitEthernet 0/0/0/0"
+	"replace_spaces": func(value string) string {
+		return strings.Join(strings.FieldsFunc(value, unicode.IsSpace), "_")
+	},
+
+	// Domain suffixes of host names removed, keeping ports and IP addresses
+	"trim_domain": func(value string) string {
+		host, port, err := net.SplitHostPort(value)
+		if err != nil {
+			host, port = value, ""
+		}
+		if net.ParseIP(host) != nil {
+			return value
+		}
+		if i := strings.IndexByte(host, '.'); i > 0 {
+			host = host[:i]
+		}
+		if len(port) > 0 {
+			return net.JoinHostPort(host, port)
+		}
+		return host
+	},
+}
+
+// TagRules mapping tag names to sanitizers applied in order, with rules of "*" applying to all tags
+type TagRules map[string][]Sanitizer
+
+// NewTagRules from a map of tag names to lists of sanitizer names
+func NewTagRules(config map[string][]string) (TagRules, error) {
+	rules := make(TagRules, len(config))
+	for tag, names := range config {
+		for _, name := range names {
+			sanitizer, ok := sanitizers[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown sanitizer %q for tag %q", name, tag)
+			}
+			rules[tag] = append(rules[tag], sanitizer)
+		}
+	}
+	return rules, nil
+}
+
+// Apply sanitizers of all tags followed by those of tags matching a rule by full name or by last
+// path element
+func (r TagRules) Apply(tags map[string]string) {
+	if len(r) == 0 {
+		return
+	}
+
+	for name, value := range tags {
+		for _, sanitize := range r["*"] {
+			value = sanitize(value)
+		}
+
+		rule, ok := r[name]
+		if !ok {
+			rule = r[name[strings.LastIndexByte(name, '/')+1:]]
+		}
+		for _, sanitize := range rule {
+			value = sanitize(value)
+		}
+		tags[name] = value
+	}
+}
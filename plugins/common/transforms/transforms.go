@@ -0,0 +1,111 @@
+// Package transforms provides well-known unit conversions for telemetry fields
+// reported by Cisco platforms in non-base units.
+package transforms
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transform converts a field value, returning false if the value is not numeric
+type Transform func(value interface{}) (interface{}, bool)
+
+// Library of built-in transforms selectable by name
+var library = map[string]Transform{
+	// Rates reported in kilobits or megabits per second
+	"kbps_to_bps": scale(1000),
+	"mbps_to_bps": scale(1000000),
+
+	// Counters reported in bytes
+	"bytes_to_bits": scale(8),
+
+	// Loads and reliabilities reported in units of 1/255
+	"load_to_percent": func(value interface{}) (interface{}, bool) {
+		number, ok := toFloat(value)
+		return number * 100 / 255, ok
+	},
+
+	// Values reported in hundredths or thousandths of their unit
+	"hundredths": func(value interface{}) (interface{}, bool) {
+		number, ok := toFloat(value)
+		return number / 100, ok
+	},
+	"thousandths": func(value interface{}) (interface{}, bool) {
+		number, ok := toFloat(value)
+		return number / 1000, ok
+	},
+}
+
+// Rules mapping field names to transforms
+type Rules map[string]Transform
+
+// NewRules from a map of field names to transform names
+func NewRules(config map[string]string) (Rules, error) {
+	rules := make(Rules, len(config))
+	for field, name := range config {
+		transform, ok := library[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q for field %q", name, field)
+		}
+		rules[field] = transform
+	}
+	return rules, nil
+}
+
+// Apply transforms to fields matching a rule by full name or by last path element
+func (r Rules) Apply(fields map[string]interface{}) {
+	if len(r) == 0 {
+		return
+	}
+
+	for name, value := range fields {
+		transform, ok := r[name]
+		if !ok {
+			transform, ok = r[name[strings.LastIndexByte(name, '/')+1:]]
+		}
+		if !ok {
+			continue
+		}
+
+		if converted, ok := transform(value); ok {
+			fields[name] = converted
+		}
+	}
+}
+
+// Scale integer values preserving their type and floats as float64
+func scale(factor uint64) Transform {
+	return func(value interface{}) (interface{}, bool) {
+		switch v := value.(type) {
+		case uint64:
+			return v * factor, true
+		case uint32:
+			return uint64(v) * factor, true
+		case int64:
+			return v * int64(factor), true
+		case int32:
+			return int64(v) * int64(factor), true
+		}
+
+		number, ok := toFloat(value)
+		return number * float64(factor), ok
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+	return 0, false
+}
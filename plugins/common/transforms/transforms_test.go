@@ -0,0 +1,133 @@
+package transforms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRules(t *testing.T) {
+	_, err := NewRules(map[string]string{"rate": "furlongs"})
+	assert.NotNil(t, err)
+
+	rules, err := NewRules(map[string]string{
+		"input-data-rate": "kbps_to_bps",
+		"load":            "load_to_percent",
+		"temperature":     "hundredths",
+	})
+	assert.Nil(t, err)
+
+	fields := map[string]interface{}{
+		"data-rate/input-data-rate": uint64(12),
+		"load":                      uint32(51),
+		"temperature":               int64(4250),
+		"description":               "uplink",
+		"output-data-rate":          uint64(7),
+	}
+	rules.Apply(fields)
+
+	assert.Equal(t, map[string]interface{}{
+		"data-rate/input-data-rate": uint64(12000),
+		"load":                      float64(20),
+		"temperature":               float64(42.5),
+		"description":               "uplink",
+		"output-data-rate":          uint64(7),
+	}, fields)
+}
+
+func TestSentinels(t *testing.T) {
+	_, err := NewSentinels(map[string]string{"rtt": ""})
+	assert.NotNil(t, err)
+
+	sentinels, err := NewSentinels(map[string]string{
+		"rtt":         "0xFFFFFFFFFFFFFFFF",
+		"offset":      "-1",
+		"temperature": "0xFFFFFFFF",
+		"peer":        "NULL",
+	})
+	assert.Nil(t, err)
+
+	fields := map[string]interface{}{
+		"probe/rtt":   uint64(0xFFFFFFFFFFFFFFFF),
+		"offset":      int64(-1),
+		"temperature": uint32(42),
+		"peer":        "NULL",
+		"description": "uplink",
+	}
+	sentinels.Apply(fields, false)
+	assert.Equal(t, map[string]interface{}{
+		"temperature": uint32(42),
+		"description": "uplink",
+	}, fields)
+
+	fields = map[string]interface{}{
+		"probe/rtt":   uint64(12),
+		"temperature": uint32(0xFFFFFFFF),
+		"offset":      float64(-1),
+	}
+	sentinels.Apply(fields, true)
+	assert.Equal(t, map[string]interface{}{
+		"probe/rtt":             uint64(12),
+		"probe/rtt_available":   true,
+		"temperature_available": false,
+		"offset_available":      false,
+	}, fields)
+}
+
+func TestTagRules(t *testing.T) {
+	rules, err := NewTagRules(map[string][]string{
+		"*":        {"trim_space"},
+		"Producer": {"trim_domain", "lowercase"},
+		"name":     {"replace_spaces", "uppercase"},
+	})
+	assert.Nil(t, err)
+
+	tags := map[string]string{
+		"Producer":       "Router1.Example.com ",
+		"interface/name": "gig  0/0/0/0",
+		"Target":         " sub1",
+		"address":        "10.0.0.1:57400",
+		"node":           "rp0.dc1.example.com",
+	}
+	rules.Apply(tags)
+	assert.Equal(t, map[string]string{"Producer": "router1", "interface/name": "GIG_0/0/0/0", "Target": "sub1",
+		"address": "10.0.0.1:57400", "node": "rp0.dc1.example.com"}, tags)
+
+	for value, expected := range map[string]string{
+		"router1.example.com:57400": "router1:57400",
+		"[2001:db8::1]:57400":       "[2001:db8::1]:57400",
+		"2001:db8::1":               "2001:db8::1",
+		"router1":                   "router1",
+	} {
+		assert.Equal(t, expected, sanitizers["trim_domain"](value))
+	}
+
+	_, err = NewTagRules(map[string][]string{"Producer": {"titlecase"}})
+	assert.NotNil(t, err)
+}
+
+func TestCoercions(t *testing.T) {
+	coercions, err := NewCoercions(map[string]string{"mac-address": "string", "last-change": "uint",
+		"state/offset": "int", "power": "float", "enabled": "bool"})
+	assert.Nil(t, err)
+
+	fields := map[string]interface{}{
+		"interface/mac-address": uint64(0x0050568a1b2c),
+		"last-change":           "1543236577",
+		"state/offset":          "-12",
+		"power":                 "-2.35",
+		"enabled":               "true",
+		"other":                 uint64(1),
+	}
+	coercions.Apply(fields)
+	assert.Equal(t, map[string]interface{}{"interface/mac-address": "345049275180", "last-change": uint64(1543236577),
+		"state/offset": int64(-12), "power": -2.35, "enabled": true, "other": uint64(1)}, fields)
+
+	// Values of the wrong type never reach the series
+	fields = map[string]interface{}{"last-change": "never", "state/offset": uint64(1 << 63), "power": 1.5}
+	coercions.Apply(fields)
+	assert.Equal(t, map[string]interface{}{"power": 1.5}, fields)
+
+	_, err = NewCoercions(map[string]string{"mac-address": "mac"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,472 @@
+// Package wal persists metrics in a write-ahead log on disk before handing them to
+// the agent and trims them once the outputs acknowledged their delivery, so metrics
+// not yet written by the outputs are replayed after a crash or restart.
+package wal
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+const (
+	// Number of records per segment file, a segment is removed once all its records are delivered
+	segmentRecords = 1000
+
+	// Records persisted by a single sync at most, and the longest time records wait for it
+	syncRecords  = 1000
+	syncInterval = 100 * time.Millisecond
+
+	segmentSuffix = ".wal"
+)
+
+var (
+	// Number of metrics handed over and not yet acknowledged, bounded by the delivery
+	// notifications buffered by the agent
+	maxUndelivered = 10000
+
+	// Time after which metrics rejected by the outputs are handed over again
+	retryInterval = 10 * time.Second
+)
+
+// Record of a metric as persisted in the log
+type record struct {
+	Name   string
+	Tags   map[string]string
+	Fields map[string]interface{}
+	Time   int64
+	Type   telegraf.ValueType
+
+	seq int
+}
+
+// Segment file holding a bounded number of records
+type segment struct {
+	path        string
+	file        *os.File
+	writer      *bufio.Writer
+	encoder     *gob.Encoder
+	written     int
+	undelivered map[*record]struct{}
+}
+
+type entry struct {
+	record  *record
+	segment *segment
+}
+
+// Log accumulating metrics with delivery tracking after persisting them. Records are synced
+// to disk in groups and handed over once synced. Metrics rejected by the outputs are handed
+// over again after the retry interval, and replayed when the log is opened again.
+type Log struct {
+	telegraf.TrackingAccumulator
+
+	dir      string
+	next     int
+	current  *segment
+	segments map[*segment]bool
+	pending  map[telegraf.TrackingID]*entry
+	queued   []*entry
+	unsynced int
+	rejected []*entry
+	closed   bool
+	slots    chan struct{}
+	full     chan struct{}
+	mutex    sync.Mutex
+	space    *sync.Cond
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Open the log in dir, creating it if necessary, and replay all undelivered metrics to acc
+func Open(dir string, acc telegraf.Accumulator) (*Log, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	l := &Log{
+		TrackingAccumulator: acc.WithTracking(maxUndelivered),
+		dir:                 dir,
+		segments:            make(map[*segment]bool),
+		pending:             make(map[telegraf.TrackingID]*entry),
+		slots:               make(chan struct{}, maxUndelivered),
+		full:                make(chan struct{}, 1),
+		done:                make(chan struct{}),
+	}
+	l.space = sync.NewCond(&l.mutex)
+
+	l.mutex.Lock()
+	for _, path := range paths {
+		id, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(path), segmentSuffix))
+		if err != nil {
+			continue
+		}
+		if id >= l.next {
+			l.next = id + 1
+		}
+
+		records, err := readSegment(path)
+		if err != nil {
+			l.mutex.Unlock()
+			return nil, err
+		}
+
+		s := &segment{path: path, written: len(records), undelivered: make(map[*record]struct{})}
+		l.segments[s] = true
+		for _, r := range records {
+			s.undelivered[r] = struct{}{}
+			l.queued = append(l.queued, &entry{record: r, segment: s})
+		}
+		l.trim(s)
+
+		if len(records) > 0 {
+			log.Printf("I! Replayed %d metrics from write-ahead log %s", len(records), path)
+		}
+	}
+	err = l.rotate()
+	l.mutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	l.wg.Add(2)
+	go l.commit()
+	go l.acknowledge()
+
+	return l, nil
+}
+
+// AddFields persists a metric and adds it to the accumulator
+func (l *Log) AddFields(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	l.append(measurement, fields, tags, telegraf.Untyped, t...)
+}
+
+// AddGauge persists a gauge and adds it to the accumulator
+func (l *Log) AddGauge(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	l.append(measurement, fields, tags, telegraf.Gauge, t...)
+}
+
+// AddCounter persists a counter and adds it to the accumulator
+func (l *Log) AddCounter(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	l.append(measurement, fields, tags, telegraf.Counter, t...)
+}
+
+// AddMetric persists a metric and adds it to the accumulator
+func (l *Log) AddMetric(m telegraf.Metric) {
+	l.append(m.Name(), m.Fields(), m.Tags(), m.Type(), m.Time())
+}
+
+// Close the log, compacting segments of which only some metrics were delivered. Undelivered
+// metrics remain on disk to be replayed once the log is opened again.
+func (l *Log) Close() {
+	close(l.done)
+	l.mutex.Lock()
+	l.closed = true
+	l.space.Broadcast()
+	l.mutex.Unlock()
+	l.wg.Wait()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.close(l.current)
+	for s := range l.segments {
+		if len(s.undelivered) > 0 && len(s.undelivered) < s.written {
+			if err := l.compact(s); err != nil {
+				log.Printf("W! Failed to compact write-ahead log %s: %v", s.path, err)
+			}
+		}
+	}
+}
+
+func (l *Log) append(name string, fields map[string]interface{}, tags map[string]string, tp telegraf.ValueType, t ...time.Time) {
+	timestamp := time.Now()
+	if len(t) > 0 {
+		timestamp = t[0]
+	}
+
+	// The caller may reuse its maps once the metric is added
+	r := &record{Name: name, Tags: make(map[string]string, len(tags)),
+		Fields: make(map[string]interface{}, len(fields)), Time: timestamp.UnixNano(), Type: tp}
+	for key, val := range tags {
+		r.Tags[key] = val
+	}
+	for key, val := range fields {
+		r.Fields[key] = val
+	}
+
+	l.mutex.Lock()
+	for len(l.queued) >= maxUndelivered && !l.closed {
+		l.space.Wait()
+	}
+
+	// Metrics failing to persist are still handed over, but not replayed
+	s, err := l.write(r)
+	if err != nil {
+		l.AddError(fmt.Errorf("E! Failed to write metric to write-ahead log: %v", err))
+	} else {
+		s.undelivered[r] = struct{}{}
+	}
+	l.queued = append(l.queued, &entry{record: r, segment: s})
+	l.unsynced++
+	full := l.unsynced >= syncRecords
+	l.mutex.Unlock()
+
+	if full {
+		select {
+		case l.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Write a record to the buffer of the current segment, rotating it when full
+func (l *Log) write(r *record) (*segment, error) {
+	if l.current.written >= segmentRecords {
+		if err := l.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	s := l.current
+	if s.file == nil {
+		return nil, fmt.Errorf("segment %s not open", s.path)
+	}
+	if err := s.encoder.Encode(r); err != nil {
+		return nil, err
+	}
+	r.seq = s.written
+	s.written++
+	return s, nil
+}
+
+// Sync the buffered records of a segment to disk
+func (l *Log) sync(s *segment) error {
+	if s.file == nil {
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Commit queued records with a single sync once enough records are written or the sync interval
+// passed, and hand them over to the accumulator
+func (l *Log) commit() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+	retry := time.NewTicker(retryInterval)
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+		case <-l.full:
+		case <-retry.C:
+			l.mutex.Lock()
+			l.queued = append(l.queued, l.rejected...)
+			l.rejected = nil
+			l.mutex.Unlock()
+		}
+
+		l.mutex.Lock()
+		if err := l.sync(l.current); err != nil {
+			l.AddError(fmt.Errorf("E! Failed to sync write-ahead log: %v", err))
+		}
+		queued := l.queued
+		l.queued, l.unsynced = nil, 0
+		l.space.Broadcast()
+		l.mutex.Unlock()
+
+		for _, e := range queued {
+			if !l.add(e) {
+				return
+			}
+		}
+	}
+}
+
+// Add a record to the accumulator tracking its delivery, waiting for a slot of the undelivered
+// metrics. Returns false if the log was closed meanwhile.
+func (l *Log) add(e *entry) bool {
+	r := e.record
+	m, err := metric.New(r.Name, r.Tags, r.Fields, time.Unix(0, r.Time), r.Type)
+	if err != nil {
+		l.AddError(fmt.Errorf("E! Failed to create metric from write-ahead log: %v", err))
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-l.done:
+		return false
+	}
+
+	l.mutex.Lock()
+	id := l.AddTrackingMetricGroup([]telegraf.Metric{m})
+	l.pending[id] = e
+	l.mutex.Unlock()
+	return true
+}
+
+// Acknowledge delivered metrics, removing segments of which all metrics were delivered
+func (l *Log) acknowledge() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case <-l.done:
+			// Process notifications received before closing
+			for {
+				select {
+				case info := <-l.Delivered():
+					l.delivered(info)
+				default:
+					return
+				}
+			}
+		case info := <-l.Delivered():
+			l.delivered(info)
+		}
+	}
+}
+
+func (l *Log) delivered(info telegraf.DeliveryInfo) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	e, ok := l.pending[info.ID()]
+	if !ok {
+		return
+	}
+	delete(l.pending, info.ID())
+	<-l.slots
+
+	if !info.Delivered() {
+		log.Printf("W! Metric %s rejected by outputs, retrying in %s", e.record.Name, retryInterval)
+		l.rejected = append(l.rejected, e)
+	} else if e.segment != nil {
+		delete(e.segment.undelivered, e.record)
+		l.trim(e.segment)
+	}
+}
+
+// Rotate to a new segment, closing the current one
+func (l *Log) rotate() error {
+	if l.current != nil {
+		l.close(l.current)
+	}
+
+	path := filepath.Join(l.dir, fmt.Sprintf("%020d%s", l.next, segmentSuffix))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0640)
+	if err != nil {
+		return err
+	}
+	l.next++
+
+	writer := bufio.NewWriter(file)
+	l.current = &segment{path: path, file: file, writer: writer, encoder: gob.NewEncoder(writer), undelivered: make(map[*record]struct{})}
+	l.segments[l.current] = true
+	return nil
+}
+
+func (l *Log) close(s *segment) {
+	if s.file != nil {
+		if err := l.sync(s); err != nil {
+			log.Printf("W! Failed to sync write-ahead log %s: %v", s.path, err)
+		}
+		s.file.Close()
+		s.file = nil
+	}
+	l.trim(s)
+}
+
+// Trim a closed segment once all its metrics were delivered
+func (l *Log) trim(s *segment) {
+	if s.file != nil || len(s.undelivered) > 0 {
+		return
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("W! Failed to remove write-ahead log %s: %v", s.path, err)
+	}
+	delete(l.segments, s)
+}
+
+// Compact a closed segment to its undelivered records
+func (l *Log) compact(s *segment) error {
+	records := make([]*record, 0, len(s.undelivered))
+	for r := range s.undelivered {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	file, err := os.OpenFile(s.path+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+
+	encoder := gob.NewEncoder(file)
+	for _, r := range records {
+		if err = encoder.Encode(r); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), s.path)
+}
+
+// ReadSegment returns the records of a segment file. A record torn by a crash ends the segment.
+func readSegment(path string) ([]*record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []*record
+	decoder := gob.NewDecoder(file)
+	for {
+		r := &record{seq: len(records)}
+		if err = decoder.Decode(r); err == io.EOF {
+			break
+		} else if err != nil {
+			log.Printf("W! Write-ahead log %s truncated after %d metrics: %v", path, len(records), err)
+			break
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
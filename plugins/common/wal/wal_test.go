@@ -0,0 +1,176 @@
+package wal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type deliveryInfo struct {
+	id        telegraf.TrackingID
+	delivered bool
+}
+
+func (d deliveryInfo) ID() telegraf.TrackingID { return d.id }
+func (d deliveryInfo) Delivered() bool         { return d.delivered }
+
+type trackingAccumulator struct {
+	testutil.Accumulator
+	ids       telegraf.TrackingID
+	delivered chan telegraf.DeliveryInfo
+}
+
+func (a *trackingAccumulator) WithTracking(maxTracked int) telegraf.TrackingAccumulator {
+	a.delivered = make(chan telegraf.DeliveryInfo, maxTracked)
+	return a
+}
+
+func (a *trackingAccumulator) AddTrackingMetricGroup(group []telegraf.Metric) telegraf.TrackingID {
+	for _, m := range group {
+		a.AddMetric(m)
+	}
+	a.ids++
+	return a.ids
+}
+
+func (a *trackingAccumulator) Delivered() <-chan telegraf.DeliveryInfo {
+	return a.delivered
+}
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	acc := &trackingAccumulator{}
+	l, err := Open(dir, acc)
+	assert.Nil(t, err)
+
+	timestamp := time.Unix(1543236571, 123456789)
+	l.AddFields("a", map[string]interface{}{"value": int64(1)}, map[string]string{"source": "r1"}, timestamp)
+	l.AddFields("b", map[string]interface{}{"value": "up"}, nil, timestamp)
+	l.AddGauge("c", map[string]interface{}{"value": 2.5}, nil, timestamp)
+	acc.Wait(3)
+
+	// Delivered metrics are trimmed, rejected and undelivered ones replayed
+	acc.delivered <- deliveryInfo{id: 1, delivered: true}
+	acc.delivered <- deliveryInfo{id: 2, delivered: false}
+	l.Close()
+
+	replayed := &trackingAccumulator{}
+	l, err = Open(dir, replayed)
+	assert.Nil(t, err)
+	replayed.Wait(2)
+	assert.Len(t, replayed.Metrics, 2)
+	replayed.AssertContainsFields(t, "b", map[string]interface{}{"value": "up"})
+	replayed.AssertContainsFields(t, "c", map[string]interface{}{"value": 2.5})
+	assert.Equal(t, "b", replayed.Metrics[0].Measurement)
+	assert.Equal(t, timestamp, replayed.Metrics[0].Time)
+
+	// Segments are removed once all their metrics are delivered
+	replayed.delivered <- deliveryInfo{id: 1, delivered: true}
+	replayed.delivered <- deliveryInfo{id: 2, delivered: true}
+	l.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Empty(t, segments)
+}
+
+func TestLogCopiesMaps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	acc := &trackingAccumulator{}
+	l, err := Open(dir, acc)
+	assert.Nil(t, err)
+	defer l.Close()
+
+	// Callers reuse their maps once the metric is added
+	tags := map[string]string{"source": "r1"}
+	fields := map[string]interface{}{"value": int64(1)}
+	l.AddFields("a", fields, tags)
+	tags["source"] = "r2"
+	fields["value"] = int64(2)
+
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "a", map[string]interface{}{"value": int64(1)}, map[string]string{"source": "r1"})
+}
+
+func TestLogRetriesRejected(t *testing.T) {
+	defer func(interval time.Duration) { retryInterval = interval }(retryInterval)
+	retryInterval = 50 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "wal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	acc := &trackingAccumulator{}
+	l, err := Open(dir, acc)
+	assert.Nil(t, err)
+	defer l.Close()
+
+	// Rejected metrics are handed over again without restarting
+	l.AddFields("a", map[string]interface{}{"value": int64(1)}, nil)
+	acc.Wait(1)
+	acc.delivered <- deliveryInfo{id: 1, delivered: false}
+	acc.Wait(2)
+	assert.Equal(t, "a", acc.Metrics[1].Measurement)
+}
+
+func TestLogBoundsUndelivered(t *testing.T) {
+	defer func(max int) { maxUndelivered = max }(maxUndelivered)
+	maxUndelivered = 2
+
+	dir, err := ioutil.TempDir("", "wal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	acc := &trackingAccumulator{}
+	l, err := Open(dir, acc)
+	assert.Nil(t, err)
+	defer l.Close()
+
+	// Metrics beyond the undelivered ones tracked wait for a delivery
+	l.AddFields("a", map[string]interface{}{"value": int64(1)}, nil)
+	l.AddFields("b", map[string]interface{}{"value": int64(2)}, nil)
+	l.AddFields("c", map[string]interface{}{"value": int64(3)}, nil)
+	acc.Wait(2)
+	time.Sleep(3 * syncInterval)
+	assert.Equal(t, uint64(2), acc.NMetrics())
+
+	acc.delivered <- deliveryInfo{id: 1, delivered: true}
+	acc.Wait(3)
+	acc.AssertContainsFields(t, "c", map[string]interface{}{"value": int64(3)})
+}
+
+func TestReadSegmentTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	acc := &trackingAccumulator{}
+	l, err := Open(dir, acc)
+	assert.Nil(t, err)
+	l.AddFields("a", map[string]interface{}{"value": int64(1)}, nil)
+	l.AddFields("b", map[string]interface{}{"value": int64(2)}, nil)
+	path := l.current.path
+	l.Close()
+
+	// A record torn by a crash ends the segment
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Nil(t, os.Truncate(path, info.Size()-1))
+
+	records, err := readSegment(path)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "a", records[0].Name)
+	assert.Equal(t, int64(1), records[0].Fields["value"])
+}
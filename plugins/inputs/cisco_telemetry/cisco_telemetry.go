@@ -0,0 +1,582 @@
+/**
+ * Copyright (c) 2018 Cisco Systems
+ * Author: Steven Barth <stbarth@cisco.com>
+ */
+
+package cisco_telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	internaltls "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/common/shard"
+	"github.com/influxdata/telegraf/plugins/common/wal"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_gnmi"
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	transportAuto      = "auto"
+	transportGNMI      = "gnmi"
+	transportMDTDialin = "mdt-dialin"
+	transportNETCONF   = "netconf"
+
+	// Timeout of probing a target for gNMI support
+	detectTimeout = 10 * time.Second
+
+	// Shortest delay of retrying to detect transports and of NETCONF redials
+	minRedial = time.Second
+)
+
+// CiscoTelemetry plugin configuring gNMI and MDT dial-in targets uniformly
+type CiscoTelemetry struct {
+	// Settings shared by all targets
+	Username       string
+	Password       string
+	Paths          []string
+	Subscription   string
+	SampleInterval cisco_telemetry_gnmi.Interval `toml:"sample_interval"`
+	Redial         internal.Duration
+
+	// Measurement names by path and tags added to all measurements
+	Aliases map[string]string
+	Tags    map[string]string
+
+	// Emit the health of each target every gather interval
+	HealthMetrics bool `toml:"health_metrics"`
+
+	// Directory of the write-ahead log persisting metrics until delivered by the outputs
+	WALDirectory string `toml:"wal_directory"`
+
+	// GRPC TLS settings
+	TLS bool
+	internaltls.ClientConfig
+
+	// File of the SSH host keys of NETCONF targets
+	KnownHosts string `toml:"known_hosts"`
+
+	Targets []Target `toml:"target"`
+
+	// Share of the targets collected by this instance
+	ShardIndex int `toml:"shard_index"`
+	ShardCount int `toml:"shard_count"`
+
+	// Internal state
+	acc     telegraf.Accumulator
+	wal     *wal.Log
+	inputs  []targetInput
+	pending map[*Target]string
+	aliases map[string]string
+	cancel  context.CancelFunc
+	ctx     context.Context
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+}
+
+// Target device and the transport used to collect its telemetry
+type Target struct {
+	Address   string
+	Transport string
+
+	// Vendor of gNMI targets enabling workarounds for their implementation
+	Vendor string
+
+	// Overrides of the shared settings
+	Username     string
+	Password     string
+	Subscription string
+	Tags         map[string]string
+}
+
+// Plugin started for a target and the tags added to its measurements
+type targetInput struct {
+	telegraf.ServiceInput
+	tags map[string]string
+}
+
+// Start the plugins collecting telemetry from all targets
+func (c *CiscoTelemetry) Start(acc telegraf.Accumulator) error {
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.acc = acc
+	c.pending = make(map[*Target]string)
+
+	c.aliases = make(map[string]string, len(c.Aliases))
+	for path, alias := range c.Aliases {
+		c.aliases[normalizePath(path)] = alias
+	}
+
+	if c.ShardCount < 0 || c.ShardIndex < 0 || (c.ShardCount > 0 && c.ShardIndex >= c.ShardCount) {
+		return fmt.Errorf("E! Invalid Cisco telemetry shard %d of %d", c.ShardIndex, c.ShardCount)
+	}
+
+	for _, t := range c.Targets {
+		if len(t.Address) == 0 {
+			return fmt.Errorf("E! Cisco telemetry target without address")
+		}
+
+		switch t.Transport {
+		case "", transportAuto, transportGNMI, transportMDTDialin:
+		case transportNETCONF:
+			if len(c.KnownHosts) == 0 && !c.InsecureSkipVerify {
+				return fmt.Errorf("E! Cisco telemetry transport netconf of %s requires known_hosts or insecure_skip_verify", t.Address)
+			}
+		default:
+			return fmt.Errorf("E! Invalid Cisco telemetry transport %s of %s", t.Transport, t.Address)
+		}
+	}
+
+	// Metrics are persisted after applying aliases and target tags
+	if len(c.WALDirectory) > 0 {
+		var err error
+		if c.wal, err = wal.Open(c.WALDirectory, acc); err != nil {
+			return fmt.Errorf("E! Failed to open Cisco telemetry write-ahead log: %v", err)
+		}
+		c.acc = c.wal
+	}
+
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if !shard.Owns(t.Address, c.ShardIndex, c.ShardCount) {
+			log.Printf("D! Cisco telemetry target %s belongs to another shard", t.Address)
+			continue
+		}
+
+		if len(t.Transport) == 0 || t.Transport == transportAuto {
+			c.setPending(t, "")
+			c.wg.Add(1)
+			go c.detectTransport(t)
+		} else if err := c.startTarget(t, t.Transport); err != nil {
+			c.Stop()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StartTarget creates and starts the plugin for a target using the given transport
+func (c *CiscoTelemetry) startTarget(t *Target, transport string) error {
+	var input telegraf.ServiceInput
+
+	switch transport {
+	case transportGNMI:
+		input = c.newGNMI(t)
+	case transportMDTDialin:
+		input = c.newMDTDialin(t)
+	case transportNETCONF:
+		netconf, err := c.newNETCONF(t)
+		if err != nil {
+			return fmt.Errorf("E! Invalid Cisco telemetry NETCONF target %s: %v", t.Address, err)
+		}
+		input = netconf
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.ctx.Err() != nil {
+		return nil
+	}
+
+	tags := c.tags(t)
+	if err := input.Start(&accumulator{Accumulator: c.acc, aliases: c.aliases, tags: tags}); err != nil {
+		return err
+	}
+	c.inputs = append(c.inputs, targetInput{ServiceInput: input, tags: tags})
+	delete(c.pending, t)
+
+	log.Printf("I! Collecting Cisco telemetry from %s using %s", t.Address, transport)
+	return nil
+}
+
+// NewGNMI creates the gNMI plugin subscribing to the shared paths of a target
+func (c *CiscoTelemetry) newGNMI(t *Target) *cisco_telemetry_gnmi.CiscoTelemetryGNMI {
+	username, password := c.credentials(t)
+	input := &cisco_telemetry_gnmi.CiscoTelemetryGNMI{
+		ServiceAddress: t.Address,
+		Vendor:         t.Vendor,
+		Encoding:       "proto",
+		Username:       username,
+		Password:       password,
+		Redial:         c.Redial,
+		HealthMetrics:  c.HealthMetrics,
+		TLS:            c.TLS,
+		ClientConfig:   c.ClientConfig,
+	}
+
+	for _, path := range c.Paths {
+		subscription := cisco_telemetry_gnmi.Subscription{SampleInterval: c.SampleInterval}
+
+		// Paths are given in the form origin:path as used by MDT encoding paths
+		if i := strings.IndexRune(path, ':'); i > 0 && !strings.ContainsRune(path[:i], '/') {
+			subscription.Origin, subscription.Path = path[:i], path[i+1:]
+		} else {
+			subscription.Path = path
+		}
+		if !strings.HasPrefix(subscription.Path, "/") {
+			subscription.Path = "/" + subscription.Path
+		}
+
+		input.Subscriptions = append(input.Subscriptions, subscription)
+	}
+
+	return input
+}
+
+// NewMDTDialin creates the MDT plugin dialing in to the subscription of a target
+func (c *CiscoTelemetry) newMDTDialin(t *Target) *cisco_telemetry_mdt.CiscoTelemetryMDT {
+	username, password := c.credentials(t)
+	subscription := c.Subscription
+	if len(t.Subscription) > 0 {
+		subscription = t.Subscription
+	}
+
+	return &cisco_telemetry_mdt.CiscoTelemetryMDT{
+		Transport:      "grpc-dialin",
+		ServiceAddress: t.Address,
+		Username:       username,
+		Password:       password,
+		Subscription:   subscription,
+		Redial:         c.Redial,
+		HealthMetrics:  c.HealthMetrics,
+		TLS:            c.TLS,
+		ClientConfig:   c.ClientConfig,
+	}
+}
+
+// NewNETCONF creates the NETCONF input polling the shared paths of a target
+func (c *CiscoTelemetry) newNETCONF(t *Target) (*netconfInput, error) {
+	username, password := c.credentials(t)
+	input := &netconfInput{
+		address:  t.Address,
+		username: username,
+		password: password,
+		interval: c.SampleInterval.Duration,
+		redial:   c.redial(),
+		health:   c.HealthMetrics,
+		hostKey:  ssh.InsecureIgnoreHostKey(),
+	}
+	if input.interval <= 0 {
+		input.interval = 10 * time.Second
+	}
+
+	if len(c.KnownHosts) > 0 {
+		var err error
+		if input.hostKey, err = knownhosts.New(c.KnownHosts); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range c.Paths {
+		p, err := parseNETCONFPath(path)
+		if err != nil {
+			return nil, err
+		}
+		input.paths = append(input.paths, p)
+	}
+	return input, nil
+}
+
+// DetectTransport probes a target for gNMI support and falls back to MDT dial-in, retrying
+// until the plugin of the detected transport started
+func (c *CiscoTelemetry) detectTransport(t *Target) {
+	defer c.wg.Done()
+
+	for c.ctx.Err() == nil {
+		transport, err := c.probe(t)
+		if err != nil {
+			c.setPending(t, err.Error())
+			c.acc.AddError(fmt.Errorf("E! Failed to detect Cisco telemetry transport of %s: %v", t.Address, err))
+		} else if err = c.startTarget(t, transport); err != nil {
+			c.setPending(t, err.Error())
+			c.acc.AddError(err)
+		} else {
+			return
+		}
+
+		select {
+		case <-c.ctx.Done():
+		case <-time.After(c.redial()):
+		}
+	}
+}
+
+// Redial delay of at least minRedial, so retries never spin
+func (c *CiscoTelemetry) redial() time.Duration {
+	if c.Redial.Duration < minRedial {
+		return minRedial
+	}
+	return c.Redial.Duration
+}
+
+// SetPending records the last error of a target whose plugin is not started
+func (c *CiscoTelemetry) setPending(t *Target, lastError string) {
+	c.mutex.Lock()
+	c.pending[t] = lastError
+	c.mutex.Unlock()
+}
+
+// Probe whether a target implements gNMI by requesting its capabilities
+func (c *CiscoTelemetry) probe(t *Target) (string, error) {
+	var opt grpc.DialOption
+	if c.TLS {
+		tlsConfig, err := c.ClientConfig.TLSConfig()
+		if err != nil {
+			return "", err
+		}
+		opt = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	} else {
+		opt = grpc.WithInsecure()
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, detectTimeout)
+	defer cancel()
+
+	client, err := grpc.DialContext(ctx, t.Address, opt)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	username, password := c.credentials(t)
+	ctx = metadata.AppendToOutgoingContext(ctx, "username", username, "password", password)
+	_, err = gnmi.NewGNMIClient(client).Capabilities(ctx, &gnmi.CapabilityRequest{}, grpc.WaitForReady(true))
+	if status.Code(err) == codes.Unimplemented {
+		return transportMDTDialin, nil
+	} else if err != nil {
+		return "", err
+	}
+	return transportGNMI, nil
+}
+
+// Credentials of a target falling back to the shared ones
+func (c *CiscoTelemetry) credentials(t *Target) (string, string) {
+	if len(t.Username) > 0 {
+		return t.Username, t.Password
+	}
+	return c.Username, c.Password
+}
+
+// Tags of a target merged with the shared ones
+func (c *CiscoTelemetry) tags(t *Target) map[string]string {
+	tags := make(map[string]string, len(c.Tags)+len(t.Tags))
+	for key, val := range c.Tags {
+		tags[key] = val
+	}
+	for key, val := range t.Tags {
+		tags[key] = val
+	}
+	return tags
+}
+
+// NormalizePath strips the leading slash of gNMI paths to match MDT encoding paths
+func normalizePath(path string) string {
+	path = strings.Replace(path, ":/", ":", 1)
+	return strings.TrimPrefix(path, "/")
+}
+
+// Accumulator renaming measurements by alias and adding the tags of a target
+type accumulator struct {
+	telegraf.Accumulator
+	aliases map[string]string
+	tags    map[string]string
+}
+
+// Measurement name and tags after applying aliases and target tags, leaving the tags of the
+// caller unmodified
+func (a *accumulator) measurement(measurement string, tags map[string]string) (string, map[string]string) {
+	if alias, ok := a.aliases[normalizePath(measurement)]; ok {
+		measurement = alias
+	}
+	if len(a.tags) > 0 {
+		merged := make(map[string]string, len(tags)+len(a.tags))
+		for key, val := range tags {
+			merged[key] = val
+		}
+		for key, val := range a.tags {
+			merged[key] = val
+		}
+		tags = merged
+	}
+	return measurement, tags
+}
+
+// AddFields of a measurement after applying aliases and target tags
+func (a *accumulator) AddFields(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	measurement, tags = a.measurement(measurement, tags)
+	a.Accumulator.AddFields(measurement, fields, tags, t...)
+}
+
+// AddGauge of a measurement after applying aliases and target tags
+func (a *accumulator) AddGauge(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	measurement, tags = a.measurement(measurement, tags)
+	a.Accumulator.AddGauge(measurement, fields, tags, t...)
+}
+
+// AddCounter of a measurement after applying aliases and target tags
+func (a *accumulator) AddCounter(measurement string, fields map[string]interface{}, tags map[string]string, t ...time.Time) {
+	measurement, tags = a.measurement(measurement, tags)
+	a.Accumulator.AddCounter(measurement, fields, tags, t...)
+}
+
+// Stop the plugins of all targets
+func (c *CiscoTelemetry) Stop() {
+	c.mutex.Lock()
+	c.cancel()
+	c.mutex.Unlock()
+
+	c.wg.Wait()
+	for _, input := range c.inputs {
+		input.Stop()
+	}
+	c.inputs = nil
+
+	if c.wal != nil {
+		c.wal.Close()
+		c.wal = nil
+	}
+}
+
+const sampleConfig = `
+  ## Settings shared by all targets
+  username = "cisco"
+  password = "cisco"
+
+  ## gnmi and netconf: paths subscribed to or polled in the form <origin>:<path> as used by MDT
+  ## encoding paths, netconf requires the YANG module as origin
+  paths = ["Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface/latest/generic-counters"]
+  ## (sub-second intervals may be given in milliseconds, e.g. "100ms", or as fraction, e.g. 0.1)
+  sample_interval = "10s"
+
+  ## mdt-dialin: name of the telemetry subscription configured on the device
+  subscription = "subscription"
+
+  ## redial in case of failures after
+  redial = "10s"
+
+  ## emit the health of each target every interval as "gnmi_health" or
+  ## "cisco_telemetry_mdt_health" measurement, e.g. for "telegraf --test" and health checks,
+  ## and targets whose transport is not yet detected as unhealthy "cisco_telemetry_health"
+  # health_metrics = false
+
+  ## persist metrics of all targets in a write-ahead log in the given directory before handing
+  ## them to the outputs, replaying metrics not yet delivered after a crash or restart
+  # wal_directory = "/var/lib/telegraf/wal/cisco_telemetry"
+
+  ## enable client-side TLS and define CA to authenticate the device
+  # tls = true
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # insecure_skip_verify = true
+
+  ## netconf: SSH host keys of the targets in OpenSSH known_hosts format, required unless
+  ## insecure_skip_verify is set
+  # known_hosts = "/etc/telegraf/known_hosts"
+
+  ## measurement names by path, matching gNMI and MDT data alike
+  # [inputs.cisco_telemetry.aliases]
+  #   "Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface/latest/generic-counters" = "ifcounters"
+
+  ## tags added to all measurements
+  # [inputs.cisco_telemetry.tags]
+  #   site = "dc1"
+
+  ## collect only the share of the targets of this instance out of shard_count instances
+  ## sharing the same targets, assigned by consistent hashing of the target addresses
+  # shard_index = 0
+  # shard_count = 1
+
+  ## Targets and their transport (one of: "gnmi", "mdt-dialin", "netconf" or "auto" probing
+  ## for gNMI support and falling back to MDT dial-in), overriding credentials, subscription
+  ## and tags
+  [[inputs.cisco_telemetry.target]]
+    address = "10.49.234.114:57777"
+    transport = "gnmi"
+    ## vendor of the target enabling workarounds for its gNMI implementation
+    ## (one of: "cisco", "arista", "juniper", "nokia")
+    # vendor = "cisco"
+
+  [[inputs.cisco_telemetry.target]]
+    address = "10.49.234.115:57500"
+    transport = "auto"
+    # username = "admin"
+    # password = "admin"
+    # subscription = "legacy"
+    # [inputs.cisco_telemetry.target.tags]
+    #   role = "edge"
+
+  [[inputs.cisco_telemetry.target]]
+    address = "10.49.234.116:830"
+    transport = "netconf"
+`
+
+// SampleConfig of plugin
+func (c *CiscoTelemetry) SampleConfig() string {
+	return sampleConfig
+}
+
+// Description of plugin
+func (c *CiscoTelemetry) Description() string {
+	return "Cisco telemetry input plugin collecting from gNMI, MDT dial-in and NETCONF targets alike"
+}
+
+// Gather measurements of the plugins of all targets, applying aliases and target tags and
+// persisting them in the write-ahead log like streamed ones
+func (c *CiscoTelemetry) Gather(acc telegraf.Accumulator) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.wal != nil {
+		acc = c.wal
+	}
+
+	for _, input := range c.inputs {
+		if err := input.Gather(&accumulator{Accumulator: acc, aliases: c.aliases, tags: input.tags}); err != nil {
+			return err
+		}
+	}
+
+	if c.HealthMetrics {
+		c.gatherPending(acc, time.Now())
+	}
+	return nil
+}
+
+// GatherPending emits targets whose transport is not detected or whose plugin failed to start as unhealthy
+func (c *CiscoTelemetry) gatherPending(acc telegraf.Accumulator, now time.Time) {
+	for t, lastError := range c.pending {
+		tags := c.tags(t)
+		tags["address"] = t.Address
+		tags["transport"] = transportAuto
+
+		fields := map[string]interface{}{"healthy": false}
+		if len(lastError) > 0 {
+			fields["last_error"] = lastError
+		}
+		acc.AddFields("cisco_telemetry_health", fields, tags, now)
+	}
+}
+
+func init() {
+	inputs.Add("cisco_telemetry", func() telegraf.Input {
+		return &CiscoTelemetry{
+			SampleInterval: cisco_telemetry_gnmi.Interval{Duration: 10 * time.Second},
+			Redial:         internal.Duration{Duration: 10 * time.Second},
+		}
+	})
+}
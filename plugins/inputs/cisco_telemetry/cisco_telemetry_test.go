@@ -0,0 +1,222 @@
+/**
+ * Copyright (c) 2018 Cisco Systems
+ * Author: Steven Barth <stbarth@cisco.com>
+ */
+
+package cisco_telemetry
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_gnmi"
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestInvalidTargets(t *testing.T) {
+	for _, target := range []Target{
+		{Transport: "gnmi"},
+		{Address: "127.0.0.1:57500", Transport: "netconf"},
+		{Address: "127.0.0.1:57500", Transport: "snmp"},
+	} {
+		c := &CiscoTelemetry{Targets: []Target{target}}
+		assert.NotNil(t, c.Start(&testutil.Accumulator{}), target.Transport)
+	}
+}
+
+func TestNewTargets(t *testing.T) {
+	c := &CiscoTelemetry{
+		Username:       "cisco",
+		Password:       "secret",
+		Paths:          []string{"Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces", "/interfaces/interface"},
+		Subscription:   "shared",
+		SampleInterval: cisco_telemetry_gnmi.Interval{Duration: 5 * time.Second},
+	}
+	target := &Target{Address: "127.0.0.1:57500", Username: "admin", Password: "admin", Subscription: "legacy", Vendor: "nokia"}
+
+	gnmi := c.newGNMI(target)
+	assert.Equal(t, "admin", gnmi.Username)
+	assert.Equal(t, "nokia", gnmi.Vendor)
+	assert.Equal(t, []cisco_telemetry_gnmi.Subscription{
+		{Origin: "Cisco-IOS-XR-infra-statsd-oper", Path: "/infra-statistics/interfaces", SampleInterval: c.SampleInterval},
+		{Path: "/interfaces/interface", SampleInterval: c.SampleInterval},
+	}, gnmi.Subscriptions)
+
+	mdt := c.newMDTDialin(&Target{Address: "127.0.0.1:57500"})
+	assert.Equal(t, "grpc-dialin", mdt.Transport)
+	assert.Equal(t, "cisco", mdt.Username)
+	assert.Equal(t, "shared", mdt.Subscription)
+	assert.Equal(t, "legacy", c.newMDTDialin(target).Subscription)
+}
+
+func TestAccumulatorAliases(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	c := &CiscoTelemetry{
+		Aliases: map[string]string{"Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces": "ifcounters"},
+		Tags:    map[string]string{"site": "dc1", "role": "core"},
+	}
+	c.Start(acc)
+	defer c.Stop()
+
+	wrapped := &accumulator{Accumulator: acc, aliases: c.aliases, tags: c.tags(&Target{Tags: map[string]string{"role": "edge"}})}
+	fields := map[string]interface{}{"value": int64(1)}
+	wrapped.AddFields("Cisco-IOS-XR-infra-statsd-oper:/infra-statistics/interfaces", fields, map[string]string{"name": "gnmi"})
+	wrapped.AddFields("Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces", fields, map[string]string{"name": "mdt"})
+	wrapped.AddFields("other", fields, nil)
+
+	acc.AssertContainsTaggedFields(t, "ifcounters", fields, map[string]string{"name": "gnmi", "site": "dc1", "role": "edge"})
+	acc.AssertContainsTaggedFields(t, "ifcounters", fields, map[string]string{"name": "mdt", "site": "dc1", "role": "edge"})
+	acc.AssertContainsTaggedFields(t, "other", fields, map[string]string{"site": "dc1", "role": "edge"})
+
+	// Tags of the caller are left unmodified
+	tags := map[string]string{"name": "gnmi"}
+	wrapped.AddFields("other", fields, tags)
+	assert.Equal(t, map[string]string{"name": "gnmi"}, tags)
+}
+
+func TestDetectTransport(t *testing.T) {
+	// A gRPC server without gNMI service responds Unimplemented
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := grpc.NewServer()
+	go server.Serve(listener)
+	defer server.Stop()
+
+	c := &CiscoTelemetry{
+		Redial:  internal.Duration{Duration: time.Second},
+		Targets: []Target{{Address: listener.Addr().String()}},
+	}
+	assert.Nil(t, c.Start(&testutil.Accumulator{}))
+
+	for i := 0; i < 100; i++ {
+		c.mutex.Lock()
+		started := len(c.inputs)
+		c.mutex.Unlock()
+		if started > 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	c.mutex.Lock()
+	assert.Len(t, c.inputs, 1)
+	if len(c.inputs) > 0 {
+		_, ok := c.inputs[0].ServiceInput.(*cisco_telemetry_mdt.CiscoTelemetryMDT)
+		assert.True(t, ok)
+	}
+	c.mutex.Unlock()
+	c.Stop()
+}
+
+func TestDetectTransportRetriesStart(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := grpc.NewServer()
+	go server.Serve(listener)
+	defer server.Stop()
+
+	// Starting MDT dial-in fails until its password resolves, detection is retried without
+	// spinning despite redial being unset
+	defer os.Unsetenv("CISCO_TELEMETRY_TEST_PASSWORD")
+	acc := &testutil.Accumulator{}
+	c := &CiscoTelemetry{
+		Username: "cisco",
+		Password: "env:CISCO_TELEMETRY_TEST_PASSWORD",
+		Targets:  []Target{{Address: listener.Addr().String()}},
+	}
+	assert.Nil(t, c.Start(acc))
+	defer c.Stop()
+
+	for i := 0; i < 100; i++ {
+		acc.Lock()
+		failed := len(acc.Errors)
+		acc.Unlock()
+		if failed > 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	acc.Lock()
+	assert.Len(t, acc.Errors, 1)
+	acc.Unlock()
+
+	os.Setenv("CISCO_TELEMETRY_TEST_PASSWORD", "secret")
+	for i := 0; i < 100; i++ {
+		c.mutex.Lock()
+		started := len(c.inputs)
+		c.mutex.Unlock()
+		if started > 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	c.mutex.Lock()
+	assert.Len(t, c.inputs, 1)
+	c.mutex.Unlock()
+}
+
+func TestShardTargets(t *testing.T) {
+	targets := []Target{
+		{Address: "127.0.0.1:1", Transport: "mdt-dialin"},
+		{Address: "127.0.0.1:2", Transport: "mdt-dialin"},
+		{Address: "127.0.0.1:3", Transport: "mdt-dialin"},
+		{Address: "127.0.0.1:4", Transport: "mdt-dialin"},
+	}
+
+	started := 0
+	for index := 0; index < 2; index++ {
+		c := &CiscoTelemetry{Targets: targets, ShardIndex: index, ShardCount: 2}
+		assert.Nil(t, c.Start(&testutil.Accumulator{}))
+		started += len(c.inputs)
+		c.Stop()
+	}
+	assert.Equal(t, len(targets), started)
+
+	c := &CiscoTelemetry{Targets: targets, ShardIndex: 2, ShardCount: 2}
+	assert.NotNil(t, c.Start(&testutil.Accumulator{}))
+}
+
+func TestGatherTargetTags(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	c := &CiscoTelemetry{
+		HealthMetrics: true,
+		Tags:          map[string]string{"site": "dc1"},
+		Targets:       []Target{{Address: "127.0.0.1:1", Transport: "mdt-dialin", Tags: map[string]string{"role": "edge"}}},
+	}
+	assert.Nil(t, c.Start(acc))
+	defer c.Stop()
+
+	assert.Nil(t, c.Gather(acc))
+	assert.True(t, acc.HasMeasurement("cisco_telemetry_mdt_health"))
+	for _, m := range acc.Metrics {
+		if m.Measurement == "cisco_telemetry_mdt_health" {
+			assert.Equal(t, "dc1", m.Tags["site"])
+			assert.Equal(t, "edge", m.Tags["role"])
+		}
+	}
+}
+
+func TestGatherPendingTargets(t *testing.T) {
+	// Nothing listens on the target, so probing its transport fails
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	address := listener.Addr().String()
+	listener.Close()
+
+	acc := &testutil.Accumulator{}
+	c := &CiscoTelemetry{
+		HealthMetrics: true,
+		Redial:        internal.Duration{Duration: time.Minute},
+		Targets:       []Target{{Address: address, Tags: map[string]string{"role": "edge"}}},
+	}
+	assert.Nil(t, c.Start(acc))
+	defer c.Stop()
+
+	assert.Nil(t, c.Gather(acc))
+	acc.AssertContainsTaggedFields(t, "cisco_telemetry_health", map[string]interface{}{"healthy": false},
+		map[string]string{"address": address, "transport": "auto", "role": "edge"})
+}
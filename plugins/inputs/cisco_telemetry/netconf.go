@@ -0,0 +1,531 @@
+package cisco_telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	"github.com/influxdata/telegraf/plugins/common/secret"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	netconfNamespace = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	netconfBase10    = "urn:ietf:params:netconf:base:1.0"
+	netconfBase11    = "urn:ietf:params:netconf:base:1.1"
+
+	// End of messages framed by NETCONF 1.0
+	netconfEOM = "]]>]]>"
+
+	// Timeout of connecting to NETCONF targets and of each of their replies
+	netconfTimeout = 10 * time.Second
+)
+
+// NETCONF input polling the shared paths of a target with get requests over SSH
+type netconfInput struct {
+	address  string
+	username string
+	password string
+	paths    []netconfPath
+	interval time.Duration
+	redial   time.Duration
+	health   bool
+	hostKey  ssh.HostKeyCallback
+
+	acc          telegraf.Accumulator
+	session      *netconfSession
+	dialed       bool
+	responses    uint64
+	errors       uint64
+	redials      uint64
+	lastResponse time.Time
+	lastError    string
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	mutex        sync.Mutex
+}
+
+// Path polled by NETCONF, the module given as origin selects the namespace of the filter
+type netconfPath struct {
+	measurement string
+	module      string
+	elems       []*gnmi.PathElem
+}
+
+// Session with a NETCONF server on an SSH channel
+type netconfSession struct {
+	client     *ssh.Client
+	stdin      io.Writer
+	stdout     *bufio.Reader
+	chunked    bool
+	namespaces map[string]string
+	messageID  int
+}
+
+// Hello message of a NETCONF server
+type netconfHello struct {
+	Capabilities []string `xml:"capabilities>capability"`
+}
+
+// Reply to a NETCONF get request
+type netconfReply struct {
+	Errors []struct {
+		Tag     string `xml:"error-tag"`
+		Message string `xml:"error-message"`
+	} `xml:"rpc-error"`
+	Data xmlNode `xml:"data"`
+}
+
+// XML element of the data of a reply
+type xmlNode struct {
+	XMLName xml.Name
+	Content string    `xml:",chardata"`
+	Nodes   []xmlNode `xml:",any"`
+}
+
+// ParseNETCONFPath from the <origin>:<path> form, the origin naming the YANG module
+func parseNETCONFPath(path string) (netconfPath, error) {
+	i := strings.IndexRune(path, ':')
+	if i <= 0 || strings.ContainsRune(path[:i], '/') {
+		return netconfPath{}, fmt.Errorf("path %s lacks the module as origin", path)
+	}
+
+	p := netconfPath{module: path[:i], elems: gnmidecode.ParsePath("", path[i+1:], "").Elem}
+	if len(p.elems) == 0 {
+		return netconfPath{}, fmt.Errorf("path %s is empty", path)
+	}
+
+	names := make([]string, len(p.elems))
+	for j, elem := range p.elems {
+		names[j] = elem.Name
+	}
+	p.measurement = p.module + ":" + strings.Join(names, "/")
+	return p, nil
+}
+
+// Filter selecting the path as subtree in the namespace of its module
+func (p *netconfPath) filter(namespace string) string {
+	var builder strings.Builder
+	for i, elem := range p.elems {
+		builder.WriteString("<" + elem.Name)
+		if i == 0 {
+			builder.WriteString(` xmlns="`)
+			xml.EscapeText(&builder, []byte(namespace))
+			builder.WriteString(`"`)
+		}
+		builder.WriteString(">")
+
+		keys := make([]string, 0, len(elem.Key))
+		for key := range elem.Key {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			builder.WriteString("<" + key + ">")
+			xml.EscapeText(&builder, []byte(elem.Key[key]))
+			builder.WriteString("</" + key + ">")
+		}
+	}
+	for i := len(p.elems) - 1; i >= 0; i-- {
+		builder.WriteString("</" + p.elems[i].Name + ">")
+	}
+	return builder.String()
+}
+
+// Start polling the target, connecting in the background
+func (n *netconfInput) Start(acc telegraf.Accumulator) error {
+	var err error
+	if n.username, err = secret.Resolve(n.username); err != nil {
+		return fmt.Errorf("E! Failed to resolve NETCONF username: %v", err)
+	}
+	if n.password, err = secret.Resolve(n.password); err != nil {
+		return fmt.Errorf("E! Failed to resolve NETCONF password: %v", err)
+	}
+
+	n.acc = acc
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+
+	n.wg.Add(1)
+	go n.run(ctx)
+	return nil
+}
+
+// Stop polling and close the session
+func (n *netconfInput) Stop() {
+	n.mutex.Lock()
+	n.cancel()
+	if n.session != nil {
+		n.session.client.Close()
+		n.session = nil
+	}
+	n.mutex.Unlock()
+
+	n.wg.Wait()
+}
+
+// Run polls the paths every interval, reconnecting after redial on failures
+func (n *netconfInput) run(ctx context.Context) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		wait := ticker.C
+		if err := n.poll(ctx); err != nil && ctx.Err() == nil {
+			n.failed(err)
+			wait = time.After(n.redial)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-wait:
+		}
+	}
+}
+
+// Poll all paths, connecting first if necessary
+func (n *netconfInput) poll(ctx context.Context) error {
+	s, err := n.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range n.paths {
+		p := &n.paths[i]
+		namespace, ok := s.namespaces[p.module]
+		if !ok {
+			return fmt.Errorf("module %s not supported by the device", p.module)
+		}
+
+		data, err := s.get(p.filter(namespace))
+		if err != nil {
+			return err
+		}
+
+		timestamp := time.Now()
+		n.mutex.Lock()
+		n.responses++
+		n.lastResponse = timestamp
+		n.mutex.Unlock()
+
+		n.emit(p, data.Nodes, p.elems, map[string]string{"Producer": n.address}, timestamp)
+	}
+	return nil
+}
+
+// Connect to the target unless a session is established already
+func (n *netconfInput) connect(ctx context.Context) (*netconfSession, error) {
+	n.mutex.Lock()
+	s, dialed := n.session, n.dialed
+	n.mutex.Unlock()
+	if s != nil {
+		return s, nil
+	}
+
+	config := &ssh.ClientConfig{
+		User:            n.username,
+		Auth:            []ssh.AuthMethod{ssh.Password(n.password)},
+		HostKeyCallback: n.hostKey,
+		Timeout:         netconfTimeout,
+	}
+	s, err := dialNETCONF(n.address, config)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if dialed {
+		n.redials++
+	}
+	n.dialed = true
+	if err != nil {
+		return nil, err
+	}
+
+	// Stopped while connecting
+	if ctx.Err() != nil {
+		s.client.Close()
+		return nil, ctx.Err()
+	}
+
+	n.session = s
+	n.lastError = ""
+	return s, nil
+}
+
+// Failed closes the session and records the error
+func (n *netconfInput) failed(err error) {
+	n.mutex.Lock()
+	if n.session != nil {
+		n.session.client.Close()
+		n.session = nil
+	}
+	n.errors++
+	n.lastError = err.Error()
+	n.mutex.Unlock()
+
+	n.acc.AddError(fmt.Errorf("E! NETCONF get from %s failed: %v", n.address, err))
+}
+
+// Emit a metric for each instance of the element a path points to, tagged by the leaves of the
+// list entries along the path, e.g. their keys
+func (n *netconfInput) emit(p *netconfPath, nodes []xmlNode, elems []*gnmi.PathElem, tags map[string]string, timestamp time.Time) {
+	for i := range nodes {
+		node := &nodes[i]
+		if node.XMLName.Local != elems[0].Name {
+			continue
+		}
+
+		if len(elems) == 1 {
+			fields := make(map[string]interface{})
+			flattenXML(node.Nodes, "", fields)
+			if len(fields) > 0 {
+				n.acc.AddFields(p.measurement, fields, tags, timestamp)
+			}
+			continue
+		}
+
+		nodeTags := make(map[string]string, len(tags))
+		for key, val := range tags {
+			nodeTags[key] = val
+		}
+		for _, child := range node.Nodes {
+			if len(child.Nodes) == 0 && child.XMLName.Local != elems[1].Name {
+				nodeTags[child.XMLName.Local] = strings.TrimSpace(child.Content)
+			}
+		}
+		n.emit(p, node.Nodes, elems[1:], nodeTags, timestamp)
+	}
+}
+
+// FlattenXML adds the leaves below an element as fields named by their relative path
+func flattenXML(nodes []xmlNode, prefix string, fields map[string]interface{}) {
+	for _, node := range nodes {
+		name := prefix + node.XMLName.Local
+		if len(node.Nodes) > 0 {
+			flattenXML(node.Nodes, name+"/", fields)
+		} else {
+			fields[name] = xmlValue(strings.TrimSpace(node.Content))
+		}
+	}
+}
+
+// XMLValue converts the text of a leaf to an integer, float or boolean if possible. Empty leaves
+// are set, e.g. of YANG type empty.
+func xmlValue(text string) interface{} {
+	if len(text) == 0 {
+		return true
+	}
+	if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return value
+	}
+	if value, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return value
+	}
+	if strings.Trim(text, "0123456789.eE+-") == "" {
+		if value, err := strconv.ParseFloat(text, 64); err == nil {
+			return value
+		}
+	}
+	if text == "true" || text == "false" {
+		return text == "true"
+	}
+	return text
+}
+
+// Gather the health of the target
+func (n *netconfInput) Gather(acc telegraf.Accumulator) error {
+	if !n.health {
+		return nil
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	now := time.Now()
+	fields := map[string]interface{}{
+		"healthy":   n.session != nil,
+		"responses": n.responses,
+		"errors":    n.errors,
+		"redials":   n.redials,
+	}
+	if !n.lastResponse.IsZero() {
+		fields["last_response_age"] = now.Sub(n.lastResponse).Seconds()
+	}
+	if len(n.lastError) > 0 {
+		fields["last_error"] = n.lastError
+	}
+	acc.AddFields("netconf_health", fields, map[string]string{"Producer": n.address}, now)
+	return nil
+}
+
+// SampleConfig of the internal NETCONF input configured by the plugin
+func (n *netconfInput) SampleConfig() string {
+	return ""
+}
+
+// Description of the internal NETCONF input
+func (n *netconfInput) Description() string {
+	return "NETCONF input polling paths of a target"
+}
+
+// DialNETCONF opens a NETCONF session over SSH and exchanges the hello messages
+func dialNETCONF(address string, config *ssh.ClientConfig) (*netconfSession, error) {
+	client, err := ssh.Dial("tcp", address, config)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := openNETCONF(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func openNETCONF(client *ssh.Client) (*netconfSession, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = session.RequestSubsystem("netconf"); err != nil {
+		return nil, err
+	}
+
+	s := &netconfSession{client: client, stdin: stdin, stdout: bufio.NewReader(stdout), namespaces: make(map[string]string)}
+	timer := time.AfterFunc(netconfTimeout, func() { client.Close() })
+	defer timer.Stop()
+
+	hello := `<hello xmlns="` + netconfNamespace + `"><capabilities><capability>` + netconfBase10 +
+		`</capability><capability>` + netconfBase11 + `</capability></capabilities></hello>`
+	if err = s.write(hello); err != nil {
+		return nil, err
+	}
+
+	data, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	var serverHello netconfHello
+	if err = xml.Unmarshal(data, &serverHello); err != nil {
+		return nil, fmt.Errorf("invalid hello: %v", err)
+	}
+
+	// Modules are advertised as <namespace>?module=<module>&revision=<revision>
+	for _, capability := range serverHello.Capabilities {
+		capability = strings.TrimSpace(capability)
+		if capability == netconfBase11 {
+			s.chunked = true
+		}
+		if i := strings.IndexRune(capability, '?'); i > 0 {
+			if query, err := url.ParseQuery(capability[i+1:]); err == nil && len(query.Get("module")) > 0 {
+				s.namespaces[query.Get("module")] = capability[:i]
+			}
+		}
+	}
+	return s, nil
+}
+
+// Get the data selected by a subtree filter
+func (s *netconfSession) get(filter string) (*xmlNode, error) {
+	timer := time.AfterFunc(netconfTimeout, func() { s.client.Close() })
+	defer timer.Stop()
+
+	s.messageID++
+	request := fmt.Sprintf(`<rpc message-id="%d" xmlns="%s"><get><filter type="subtree">%s</filter></get></rpc>`,
+		s.messageID, netconfNamespace, filter)
+	if err := s.write(request); err != nil {
+		return nil, err
+	}
+
+	data, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	var reply netconfReply
+	if err = xml.Unmarshal(data, &reply); err != nil {
+		return nil, fmt.Errorf("invalid reply: %v", err)
+	}
+	if len(reply.Errors) > 0 {
+		return nil, fmt.Errorf("%s: %s", reply.Errors[0].Tag, strings.TrimSpace(reply.Errors[0].Message))
+	}
+	return &reply.Data, nil
+}
+
+// Write a message framed by end of message marker or in chunks as negotiated
+func (s *netconfSession) write(message string) error {
+	var err error
+	if s.chunked {
+		_, err = fmt.Fprintf(s.stdin, "\n#%d\n%s\n##\n", len(message), message)
+	} else {
+		_, err = io.WriteString(s.stdin, message+netconfEOM)
+	}
+	return err
+}
+
+// Read a message framed by end of message marker or in chunks as negotiated
+func (s *netconfSession) read() ([]byte, error) {
+	if !s.chunked {
+		var message []byte
+		for !bytes.HasSuffix(message, []byte(netconfEOM)) {
+			data, err := s.stdout.ReadBytes('>')
+			if err != nil {
+				return nil, err
+			}
+			message = append(message, data...)
+		}
+		return message[:len(message)-len(netconfEOM)], nil
+	}
+
+	// Chunks are framed as "\n#<size>\n<data>", the message ends with "\n##\n"
+	var message []byte
+	for {
+		header, err := s.stdout.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if header == "\n" {
+			if header, err = s.stdout.ReadString('\n'); err != nil {
+				return nil, err
+			}
+		}
+		if header == "##\n" {
+			return message, nil
+		}
+		if !strings.HasPrefix(header, "#") {
+			return nil, fmt.Errorf("invalid chunk header %q", header)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid chunk header %q", header)
+		}
+
+		chunk := make([]byte, size)
+		if _, err = io.ReadFull(s.stdout, chunk); err != nil {
+			return nil, err
+		}
+		message = append(message, chunk...)
+	}
+}
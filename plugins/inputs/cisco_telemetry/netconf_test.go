@@ -0,0 +1,195 @@
+package cisco_telemetry
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_gnmi"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const netconfTestData = `<infra-statistics xmlns="http://cisco.com/ns/yang/Cisco-IOS-XR-infra-statsd-oper"><interfaces>
+  <interface><interface-name>Gi0/0/0/0</interface-name><latest><generic-counters>
+    <bytes-received>100</bytes-received><packets-received>2</packets-received>
+    <last-discontinuity-time-stamp>-1</last-discontinuity-time-stamp><availability-flag/>
+  </generic-counters></latest></interface>
+  <interface><interface-name>Gi0/0/0/1</interface-name><latest><generic-counters>
+    <bytes-received>18446744073709551615</bytes-received><packets-received>0</packets-received>
+    <last-data-time>never</last-data-time>
+  </generic-counters></latest></interface>
+</interfaces></infra-statistics>`
+
+// NETCONF server answering get requests with fixed data over SSH
+type mockNETCONFServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.PublicKey
+	chunked  bool
+	data     string
+	filters  []string
+	mutex    sync.Mutex
+}
+
+func newMockNETCONFServer(t *testing.T, chunked bool) *mockNETCONFServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.Nil(t, err)
+
+	m := &mockNETCONFServer{chunked: chunked, data: netconfTestData}
+	m.config = &ssh.ServerConfig{PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+		if conn.User() != "cisco" || string(password) != "secret" {
+			return nil, fmt.Errorf("access denied")
+		}
+		return nil, nil
+	}}
+	m.config.AddHostKey(signer)
+	m.hostKey = signer.PublicKey()
+
+	m.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		for {
+			conn, err := m.listener.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return m
+}
+
+func (m *mockNETCONFServer) serve(conn net.Conn) {
+	_, channels, requests, err := ssh.NewServerConn(conn, m.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for request := range requests {
+				ok := request.Type == "subsystem" && strings.HasSuffix(string(request.Payload), "netconf")
+				request.Reply(ok, nil)
+				if ok {
+					go m.session(&netconfSession{stdin: channel, stdout: bufio.NewReader(channel)})
+				}
+			}
+		}()
+	}
+}
+
+func (m *mockNETCONFServer) session(s *netconfSession) {
+	capabilities := "<capability>" + netconfBase10 + "</capability>"
+	if m.chunked {
+		capabilities += "<capability>" + netconfBase11 + "</capability>"
+	}
+	capabilities += "<capability>http://cisco.com/ns/yang/Cisco-IOS-XR-infra-statsd-oper?module=Cisco-IOS-XR-infra-statsd-oper&amp;revision=2015-11-09</capability>"
+	if s.write(`<hello xmlns="`+netconfNamespace+`"><capabilities>`+capabilities+`</capabilities></hello>`) != nil {
+		return
+	}
+	if _, err := s.read(); err != nil {
+		return
+	}
+	s.chunked = m.chunked
+
+	for {
+		request, err := s.read()
+		if err != nil {
+			return
+		}
+
+		m.mutex.Lock()
+		m.filters = append(m.filters, string(request))
+		m.mutex.Unlock()
+
+		if s.write(`<rpc-reply message-id="1" xmlns="`+netconfNamespace+`"><data>`+m.data+`</data></rpc-reply>`) != nil {
+			return
+		}
+	}
+}
+
+func TestParseNETCONFPath(t *testing.T) {
+	p, err := parseNETCONFPath("Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface[interface-name=Gi0/0/0/0]/latest")
+	assert.Nil(t, err)
+	assert.Equal(t, "Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface/latest", p.measurement)
+	assert.Equal(t, `<infra-statistics xmlns="urn:a&amp;b"><interfaces><interface><interface-name>Gi0/0/0/0</interface-name>`+
+		`<latest></latest></interface></interfaces></infra-statistics>`, p.filter("urn:a&b"))
+
+	_, err = parseNETCONFPath("/interfaces/interface")
+	assert.NotNil(t, err)
+}
+
+func TestNETCONF(t *testing.T) {
+	for _, chunked := range []bool{false, true} {
+		m := newMockNETCONFServer(t, chunked)
+		address := m.listener.Addr().String()
+
+		// Host keys are verified against the known hosts
+		dir, _ := ioutil.TempDir("", "cisco_telemetry")
+		defer os.RemoveAll(dir)
+		knownHosts := filepath.Join(dir, "known_hosts")
+		assert.Nil(t, ioutil.WriteFile(knownHosts, []byte(knownhosts.Line([]string{address}, m.hostKey)+"\n"), 0600))
+
+		acc := &testutil.Accumulator{}
+		c := &CiscoTelemetry{
+			Username:       "cisco",
+			Password:       "secret",
+			Paths:          []string{"Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface/latest/generic-counters"},
+			SampleInterval: cisco_telemetry_gnmi.Interval{Duration: time.Minute},
+			Aliases:        map[string]string{"Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface/latest/generic-counters": "ifcounters"},
+			HealthMetrics:  true,
+			KnownHosts:     knownHosts,
+			Targets:        []Target{{Address: address, Transport: "netconf", Tags: map[string]string{"role": "edge"}}},
+		}
+		assert.Nil(t, c.Start(acc))
+		acc.Wait(2)
+
+		acc.AssertContainsTaggedFields(t, "ifcounters", map[string]interface{}{
+			"bytes-received":                int64(100),
+			"packets-received":              int64(2),
+			"last-discontinuity-time-stamp": int64(-1),
+			"availability-flag":             true,
+		}, map[string]string{"Producer": address, "interface-name": "Gi0/0/0/0", "role": "edge"})
+		acc.AssertContainsTaggedFields(t, "ifcounters", map[string]interface{}{
+			"bytes-received":   uint64(18446744073709551615),
+			"packets-received": int64(0),
+			"last-data-time":   "never",
+		}, map[string]string{"Producer": address, "interface-name": "Gi0/0/0/1", "role": "edge"})
+
+		assert.Nil(t, c.Gather(acc))
+		health := acc.Metrics[len(acc.Metrics)-1]
+		assert.Equal(t, "netconf_health", health.Measurement)
+		assert.Equal(t, map[string]string{"Producer": address, "role": "edge"}, health.Tags)
+		assert.Equal(t, true, health.Fields["healthy"])
+		assert.Equal(t, uint64(1), health.Fields["responses"])
+		assert.Equal(t, uint64(0), health.Fields["errors"])
+		c.Stop()
+
+		m.mutex.Lock()
+		assert.Len(t, m.filters, 1)
+		assert.Contains(t, m.filters[0], `<filter type="subtree"><infra-statistics xmlns="http://cisco.com/ns/yang/Cisco-IOS-XR-infra-statsd-oper">`+
+			`<interfaces><interface><latest><generic-counters></generic-counters></latest></interface></interfaces></infra-statistics></filter>`)
+		m.mutex.Unlock()
+		m.listener.Close()
+	}
+}
@@ -0,0 +1,218 @@
+package cisco_telemetry_gnmi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Subscription as represented by the admin endpoint
+type adminSubscription struct {
+	Name             string `json:"name,omitempty"`
+	Origin           string `json:"origin,omitempty"`
+	Path             string `json:"path"`
+	SubscriptionMode string `json:"subscription_mode,omitempty"`
+	SampleInterval   string `json:"sample_interval,omitempty"`
+	Runtime          bool   `json:"runtime"`
+}
+
+// StartAdmin serves the admin endpoint for managing subscriptions at runtime
+func (c *CiscoTelemetryGNMI) startAdmin() error {
+	listener, err := net.Listen("tcp", c.AdminAddress)
+	if err != nil {
+		return fmt.Errorf("E! Failed to listen on GNMI admin address: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/subscriptions", c.handleAdminSubscriptions)
+	mux.HandleFunc("/statistics", c.handleAdminStatistics)
+	mux.HandleFunc("/series", c.handleAdminSeries)
+	c.admin = &http.Server{Handler: mux}
+
+	go func() {
+		if err := c.admin.Serve(listener); err != nil && err != http.ErrServerClosed {
+			c.acc.AddError(fmt.Errorf("E! GNMI admin endpoint failed: %v", err))
+		}
+	}()
+
+	log.Printf("I! Started GNMI admin endpoint on %s", listener.Addr())
+	return nil
+}
+
+// HandleAdminSubscriptions lists, adds or removes subscriptions
+func (c *CiscoTelemetryGNMI) handleAdminSubscriptions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		c.listSubscriptions(w)
+	case http.MethodPost:
+		var request adminSubscription
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if status, err := c.addSubscription(&request); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		query := r.URL.Query()
+		if status, err := c.removeSubscription(query.Get("origin"), query.Get("path")); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAdminStatistics returns statistics of all targets as JSON
+func (c *CiscoTelemetryGNMI) handleAdminStatistics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Statistics())
+}
+
+// ListSubscriptions configured and added at runtime as JSON
+func (c *CiscoTelemetryGNMI) listSubscriptions(w http.ResponseWriter) {
+	list := make([]adminSubscription, 0, len(c.Subscriptions))
+	for _, subscription := range c.Subscriptions {
+		list = append(list, toAdminSubscription(&subscription, false))
+	}
+
+	c.mutex.Lock()
+	for _, subscription := range c.runtimeSubscriptions {
+		list = append(list, toAdminSubscription(subscription, true))
+	}
+	c.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// AddSubscription and start streaming it from all targets
+func (c *CiscoTelemetryGNMI) addSubscription(request *adminSubscription) (int, error) {
+	if len(request.Path) == 0 {
+		return http.StatusBadRequest, fmt.Errorf("missing path")
+	}
+
+	subscription := &Subscription{
+		Name:             request.Name,
+		Origin:           request.Origin,
+		Path:             request.Path,
+		SubscriptionMode: request.SubscriptionMode,
+	}
+
+	switch strings.ToLower(subscription.SubscriptionMode) {
+	case "", "target_defined", "sample", "on_change", "poll":
+	default:
+		return http.StatusBadRequest, fmt.Errorf("invalid subscription mode: %s", request.SubscriptionMode)
+	}
+
+	if len(request.SampleInterval) > 0 {
+		interval, err := time.ParseDuration(request.SampleInterval)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid sample interval: %v", err)
+		}
+		subscription.SampleInterval = Interval{Duration: interval}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.ctx.Err() != nil {
+		return http.StatusServiceUnavailable, fmt.Errorf("plugin stopped")
+	}
+
+	if c.findSubscription(subscription.Origin, subscription.Path) >= 0 ||
+		c.configuredSubscription(subscription.Origin, subscription.Path) {
+		return http.StatusConflict, fmt.Errorf("subscription exists: %s", subscription.Path)
+	}
+
+	c.runtimeSubscriptions = append(c.runtimeSubscriptions, subscription)
+	c.indexSubscriptions()
+	c.startStreams(subscription)
+
+	log.Printf("I! Added GNMI subscription %s:%s at runtime", subscription.Origin, subscription.Path)
+	return http.StatusCreated, nil
+}
+
+// RemoveSubscription added at runtime and stop streaming it
+func (c *CiscoTelemetryGNMI) removeSubscription(origin string, path string) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	i := c.findSubscription(origin, path)
+	if i < 0 {
+		return http.StatusNotFound, fmt.Errorf("no subscription added at runtime: %s", path)
+	}
+	subscription := c.runtimeSubscriptions[i]
+	c.runtimeSubscriptions = append(c.runtimeSubscriptions[:i], c.runtimeSubscriptions[i+1:]...)
+	c.indexSubscriptions()
+	c.stopStreams(subscription)
+
+	log.Printf("I! Removed GNMI subscription %s:%s at runtime", origin, path)
+	return http.StatusNoContent, nil
+}
+
+// StartStreams of a subscription added at runtime on all targets, the caller must hold the mutex
+func (c *CiscoTelemetryGNMI) startStreams(subscription *Subscription) {
+	for _, t := range c.targets {
+		s := c.newStream(t, subscription)
+		t.streams = append(t.streams, s)
+
+		c.wg.Add(1)
+		c.trackGoroutine(1)
+		go c.subscribeGNMI(s)
+	}
+}
+
+// StopStreams of a subscription removed at runtime on all targets, the caller must hold the mutex
+func (c *CiscoTelemetryGNMI) stopStreams(subscription *Subscription) {
+	for _, t := range c.targets {
+		streams := t.streams[:0]
+		for _, s := range t.streams {
+			if len(s.subscriptions) > 0 && s.subscriptions[0] == subscription {
+				s.cancel()
+				continue
+			}
+			streams = append(streams, s)
+		}
+		t.streams = streams
+	}
+}
+
+// FindSubscription added at runtime by origin and path, the caller must hold the mutex
+func (c *CiscoTelemetryGNMI) findSubscription(origin string, path string) int {
+	for i, subscription := range c.runtimeSubscriptions {
+		if subscription.Origin == origin && subscription.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// ToAdminSubscription converts a subscription for the admin endpoint
+func toAdminSubscription(subscription *Subscription, runtime bool) adminSubscription {
+	result := adminSubscription{
+		Name:             subscription.Name,
+		Origin:           subscription.Origin,
+		Path:             subscription.Path,
+		SubscriptionMode: subscription.SubscriptionMode,
+		Runtime:          runtime,
+	}
+	if subscription.SampleInterval.Duration > 0 {
+		result.SampleInterval = subscription.SampleInterval.Duration.String()
+	}
+	return result
+}
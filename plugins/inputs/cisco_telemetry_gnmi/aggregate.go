@@ -0,0 +1,153 @@
+package cisco_telemetry_gnmi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported aggregation functions for sub-second sensors
+var aggregateFunctions = map[string]bool{"min": true, "max": true, "mean": true, "last": true}
+
+// Aggregator accumulating samples of a subscription and emitting statistics once per period
+type aggregator struct {
+	functions []string
+	series    map[string]*aggregate
+	mutex     sync.Mutex
+}
+
+// Aggregate of a single series (measurement and tag set) within the current period
+type aggregate struct {
+	name      string
+	tags      map[string]string
+	fields    map[string]*fieldAggregate
+	timestamp time.Time
+}
+
+// Statistics of a single field within the current period
+type fieldAggregate struct {
+	min, max, sum float64
+	count         int64
+	last          interface{}
+}
+
+func newAggregator(functions []string) (*aggregator, error) {
+	for _, function := range functions {
+		if !aggregateFunctions[function] {
+			return nil, fmt.Errorf("E! Invalid aggregation function: %s", function)
+		}
+	}
+
+	return &aggregator{functions: functions, series: make(map[string]*aggregate)}, nil
+}
+
+// Add sample to its series aggregate
+func (a *aggregator) add(name string, fields map[string]interface{}, tags map[string]string, timestamp time.Time) {
+	key := seriesKey(name, tags)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	series, ok := a.series[key]
+	if !ok {
+		series = &aggregate{name: name, tags: tags, fields: make(map[string]*fieldAggregate)}
+		a.series[key] = series
+	}
+	series.timestamp = timestamp
+
+	for field, value := range fields {
+		stats, ok := series.fields[field]
+		if !ok {
+			stats = &fieldAggregate{}
+			series.fields[field] = stats
+		}
+		stats.last = value
+
+		number, ok := toFloat(value)
+		if !ok {
+			continue
+		}
+
+		if stats.count == 0 || number < stats.min {
+			stats.min = number
+		}
+		if stats.count == 0 || number > stats.max {
+			stats.max = number
+		}
+		stats.sum += number
+		stats.count++
+	}
+}
+
+// Flush all series aggregates of the current period and start a new period
+func (a *aggregator) flush(emit func(string, map[string]interface{}, map[string]string, ...time.Time)) {
+	a.mutex.Lock()
+	series := a.series
+	a.series = make(map[string]*aggregate)
+	a.mutex.Unlock()
+
+	for _, aggregate := range series {
+		fields := make(map[string]interface{}, len(aggregate.fields)*len(a.functions))
+		for field, stats := range aggregate.fields {
+			// Non-numeric values can only be represented by their last value
+			if stats.count == 0 {
+				fields[field] = stats.last
+				continue
+			}
+
+			for _, function := range a.functions {
+				switch function {
+				case "min":
+					fields[field+"_min"] = stats.min
+				case "max":
+					fields[field+"_max"] = stats.max
+				case "mean":
+					fields[field+"_mean"] = stats.sum / float64(stats.count)
+				case "last":
+					fields[field+"_last"] = stats.last
+				}
+			}
+		}
+		emit(aggregate.name, fields, aggregate.tags, aggregate.timestamp)
+	}
+}
+
+// SeriesKey uniquely identifying a measurement name and tag set
+func seriesKey(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	builder.WriteString(name)
+	for _, key := range keys {
+		builder.WriteRune(',')
+		builder.WriteString(key)
+		builder.WriteRune('=')
+		builder.WriteString(tags[key])
+	}
+	return builder.String()
+}
+
+// ToFloat converts numeric field values for aggregation
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+	return 0, false
+}
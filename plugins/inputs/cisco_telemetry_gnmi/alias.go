@@ -0,0 +1,95 @@
+package cisco_telemetry_gnmi
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Alias of the paths matching a pattern, "*" matching any element or any part of an element and
+// "**" any number of elements. Aliases of paths without wildcards also apply to their descendants.
+type aliasPattern struct {
+	origin   string
+	elements []string
+	alias    string
+
+	// Number of elements other than "**", more specific patterns having more
+	specificity int
+}
+
+// SetupAliases normalizes the configured aliases and orders the patterns by specificity
+func (c *CiscoTelemetryGNMI) setupAliases() {
+	c.aliases = make(map[string]string, len(c.Aliases))
+	c.aliasPatterns = make([]aliasPattern, 0, len(c.Aliases))
+	for key, alias := range c.Aliases {
+		name := aliasPath(key)
+		c.aliases[name] = alias
+
+		pattern := aliasPattern{alias: alias}
+		if i := strings.Index(name, ":/"); i > 0 {
+			pattern.origin, name = name[:i], name[i+1:]
+		}
+		pattern.elements = pathElements(name)
+		if !strings.Contains(name, "*") {
+			pattern.elements = append(pattern.elements, "**")
+		}
+		for _, element := range pattern.elements {
+			if element != "**" {
+				pattern.specificity++
+			}
+		}
+		c.aliasPatterns = append(c.aliasPatterns, pattern)
+	}
+
+	// Ties are broken by the pattern itself to be independent of the configuration order
+	sort.Slice(c.aliasPatterns, func(i, j int) bool {
+		a, b := c.aliasPatterns[i], c.aliasPatterns[j]
+		if a.specificity != b.specificity {
+			return a.specificity > b.specificity
+		}
+		if len(a.origin) != len(b.origin) {
+			return len(a.origin) > len(b.origin)
+		}
+		return strings.Join(a.elements, "/") < strings.Join(b.elements, "/")
+	})
+}
+
+// Matches returns true if the pattern matches the elements of a path of the origin
+func (p *aliasPattern) matches(origin string, elements []string) bool {
+	if len(p.origin) > 0 && p.origin != origin {
+		return false
+	}
+	return matchElements(p.elements, elements)
+}
+
+// MatchElements of a path against the elements of a pattern
+func matchElements(pattern []string, elements []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elements); i++ {
+				if matchElements(pattern[1:], elements[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(elements) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elements[0]); !ok {
+			return false
+		}
+		pattern, elements = pattern[1:], elements[1:]
+	}
+	return len(elements) == 0
+}
+
+// PathElements of a path without origin and keys, e.g. "/interfaces/interface"
+func pathElements(name string) []string {
+	name = strings.Trim(name, "/")
+	if len(name) == 0 {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
@@ -0,0 +1,128 @@
+package cisco_telemetry_gnmi
+
+import (
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+const (
+	// Period of measuring the bandwidth of targets and adapting their sample intervals
+	bandwidthPeriod = 10 * time.Second
+
+	// Upper bound of the factor sample intervals are widened by to stay below the bandwidth cap
+	maxIntervalScale = 64
+)
+
+// Connection metering the bytes received from a target
+type meteredConn struct {
+	net.Conn
+	received *uint64
+	stat     selfstat.Stat
+}
+
+func (m *meteredConn) Read(b []byte) (int, error) {
+	n, err := m.Conn.Read(b)
+	atomic.AddUint64(m.received, uint64(n))
+	m.stat.Incr(int64(n))
+	return n, err
+}
+
+// Dialer for connections to a target, metering the received bytes and counting open
+// connections if resource accounting is enabled
+func (c *CiscoTelemetryGNMI) dialer(t *target) func(string, time.Duration) (net.Conn, error) {
+	return func(address string, timeout time.Duration) (net.Conn, error) {
+		var conn net.Conn
+		var err error
+		if t.tunnel != nil {
+			conn, err = t.tunnel.session(timeout)
+		} else {
+			conn, err = net.DialTimeout("tcp", address, timeout)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		conn = &meteredConn{Conn: conn, received: &t.bytesReceived, stat: c.bytesReceived}
+		if c.connections != nil {
+			c.connections.Incr(1)
+			conn = &countedConn{Conn: conn, stat: c.connections}
+		}
+		return conn, nil
+	}
+}
+
+// MonitorBandwidth measures the bandwidth of all targets periodically
+func (c *CiscoTelemetryGNMI) monitorBandwidth() {
+	defer c.wg.Done()
+	defer c.trackGoroutine(-1)
+
+	ticker := time.NewTicker(bandwidthPeriod)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.mutex.Lock()
+			targets := append([]*target(nil), c.targets...)
+			c.mutex.Unlock()
+
+			for _, t := range targets {
+				c.updateBandwidth(t, now.Sub(last))
+			}
+			last = now
+		}
+	}
+}
+
+// UpdateBandwidth of a target from the bytes received within the elapsed time. If the bandwidth
+// exceeds the cap, the sample intervals of the target are doubled and its streams resubscribed.
+// Intervals are narrowed again once the bandwidth dropped below a quarter of the cap.
+func (c *CiscoTelemetryGNMI) updateBandwidth(t *target, elapsed time.Duration) {
+	received := atomic.LoadUint64(&t.bytesReceived)
+	bandwidth := float64(received-t.bytesMeasured) / elapsed.Seconds()
+	t.bytesMeasured = received
+	atomic.StoreUint64(&t.bandwidth, uint64(bandwidth))
+
+	limit := float64(c.MaxBandwidth.Size)
+	if limit <= 0 {
+		return
+	}
+
+	scale := atomic.LoadInt64(&t.intervalScale)
+	switch {
+	case bandwidth > limit && scale < maxIntervalScale:
+		scale *= 2
+	case bandwidth < limit/4 && scale > 1:
+		scale /= 2
+	default:
+		return
+	}
+
+	log.Printf("I! GNMI bandwidth of %s at %.0f B/s with cap of %.0f B/s, sampling at %dx the intervals",
+		t.address, bandwidth, limit, scale)
+	atomic.StoreInt64(&t.intervalScale, scale)
+
+	c.mutex.Lock()
+	for _, s := range t.streams {
+		s.resubscribe()
+	}
+	c.mutex.Unlock()
+}
+
+// ScaleIntervals widens the sample intervals of a subscribe request by the scale of its target
+func scaleIntervals(request *gnmi.SubscribeRequest, scale int64) {
+	if scale <= 1 {
+		return
+	}
+	for _, subscription := range request.GetSubscribe().GetSubscription() {
+		subscription.SampleInterval *= uint64(scale)
+	}
+}
@@ -0,0 +1,78 @@
+package cisco_telemetry_gnmi
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Sample interval of the canary unless configured, and the number of intervals it may stay silent
+const (
+	canarySampleInterval = 10 * time.Second
+	canaryMissedSamples  = 3
+)
+
+// SetupCanary validates the canary subscription and defaults its interval and timeout
+func (c *CiscoTelemetryGNMI) setupCanary() error {
+	if c.Canary == nil {
+		return nil
+	}
+	if len(c.Canary.Path) == 0 {
+		return fmt.Errorf("E! GNMI canary requires a path")
+	}
+
+	switch strings.ToLower(c.Canary.SubscriptionMode) {
+	case "":
+		c.Canary.SubscriptionMode = "sample"
+	case "sample":
+	default:
+		return fmt.Errorf("E! GNMI canary can only be subscribed in sample mode")
+	}
+	if len(c.Canary.Name) == 0 {
+		c.Canary.Name = "canary"
+	}
+	if c.Canary.SampleInterval.Duration <= 0 {
+		c.Canary.SampleInterval.Duration = canarySampleInterval
+	}
+	if c.CanaryTimeout.Duration <= 0 {
+		c.CanaryTimeout.Duration = canaryMissedSamples * c.Canary.SampleInterval.Duration
+	}
+	return nil
+}
+
+// GatherCanaries emits the liveness of the canary of each target, logging when it goes silent
+// for longer than the timeout and when it recovers
+func (c *CiscoTelemetryGNMI) gatherCanaries(acc telegraf.Accumulator, targets []*target, now time.Time) {
+	for _, t := range targets {
+		if t.canary == nil {
+			continue
+		}
+
+		since := t.canaryStarted
+		fields := make(map[string]interface{}, 2)
+		if last := atomic.LoadInt64(&t.canary.lastUpdate); last > 0 {
+			since = time.Unix(0, last)
+			fields["last_update"] = last
+		}
+		silent := now.Sub(since)
+		alive := silent <= c.CanaryTimeout.Duration
+		fields["alive"] = alive
+		fields["silent"] = silent.Seconds()
+
+		if !alive && atomic.CompareAndSwapInt32(&t.canaryStale, 0, 1) {
+			log.Printf("W! GNMI canary of %s silent for %s", t.address, silent.Round(time.Second))
+		} else if alive && atomic.CompareAndSwapInt32(&t.canaryStale, 1, 0) {
+			log.Printf("I! GNMI canary of %s alive again", t.address)
+		}
+
+		tags := map[string]string{"Producer": c.producer(t)}
+		for key, val := range t.tags {
+			tags[key] = val
+		}
+		acc.AddFields("gnmi_canary", fields, tags, now)
+	}
+}
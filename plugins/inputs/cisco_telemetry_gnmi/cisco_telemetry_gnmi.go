@@ -8,358 +8,1729 @@ package cisco_telemetry_gnmi
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/http"
+	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	internaltls "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/common/auth"
+	"github.com/influxdata/telegraf/plugins/common/backoff"
+	"github.com/influxdata/telegraf/plugins/common/cpulimit"
+	"github.com/influxdata/telegraf/plugins/common/exporter"
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	"github.com/influxdata/telegraf/plugins/common/inventory"
+	"github.com/influxdata/telegraf/plugins/common/reorder"
+	"github.com/influxdata/telegraf/plugins/common/rpcerror"
+	"github.com/influxdata/telegraf/plugins/common/schema"
+	"github.com/influxdata/telegraf/plugins/common/secret"
+	"github.com/influxdata/telegraf/plugins/common/tlsprofile"
+	"github.com/influxdata/telegraf/plugins/common/transforms"
+	"github.com/influxdata/telegraf/plugins/common/wal"
 	"github.com/influxdata/telegraf/plugins/inputs"
-	jsonparser "github.com/influxdata/telegraf/plugins/parsers/json"
+	"github.com/influxdata/telegraf/selfstat"
 	"github.com/openconfig/gnmi/proto/gnmi"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // CiscoTelemetryGNMI plugin instance
 type CiscoTelemetryGNMI struct {
-	ServiceAddress string         `toml:"service_address"`
-	Subscriptions  []Subscription `toml:"subscription"`
+	ServiceAddress   string         `toml:"service_address"`
+	Addresses        []string       `toml:"addresses"`
+	ResolveAddresses bool           `toml:"resolve_addresses"`
+	Subscriptions    []Subscription `toml:"subscription"`
+
+	// Address to accept devices dialing out through gRPC tunnels on, and its TLS settings
+	TunnelAddress           string   `toml:"tunnel_address"`
+	TunnelTLSCert           string   `toml:"tunnel_tls_cert"`
+	TunnelTLSKey            string   `toml:"tunnel_tls_key"`
+	TunnelTLSAllowedCACerts []string `toml:"tunnel_tls_allowed_cacerts"`
 
 	// Optional subscription configuration
-	Encoding    string
-	Origin      string
-	Prefix      string
-	Target      string
-	UpdatesOnly bool `toml:"updates_only"`
+	Encoding         string
+	EncodingFallback []string `toml:"encoding_fallback"`
+	Origin           string
+	Prefix           string
+	Target           string
+	UpdatesOnly      bool `toml:"updates_only"`
 
 	// Cisco IOS XR credentials
 	Username string
 	Password string
+	auth.Config
+
+	// Devices obtained from an inventory such as NetBox and the interval of fetching them
+	inventory.Source
+	InventoryInterval internal.Duration `toml:"inventory_interval"`
+
+	// Tags describing the target, also usable in username templates
+	TargetTags map[string]string `toml:"target_tags"`
+
+	// Naming of emitted measurements, fields and tags
+	OutputFormat   string `toml:"output_format"`
+	IncludePathTag bool   `toml:"include_path_tag"`
+
+	// Measurement names by path, e.g. "ifcounters" for "openconfig-interfaces:/interfaces/interface/state/counters"
+	Aliases map[string]string
+
+	// Strip or keep RFC 7951 module prefixes of JSON keys consistently
+	ModulePrefixes string `toml:"module_prefixes"`
+
+	// List keys folded into field names instead of tags, optionally hashed
+	FoldKeys       []string `toml:"fold_keys"`
+	HashFoldedKeys bool     `toml:"hash_folded_keys"`
+
+	// Policy of emitting the other list keys as tags or fields, and keys overriding it
+	KeyPolicy string   `toml:"key_policy"`
+	TagKeys   []string `toml:"tag_keys"`
+	FieldKeys []string `toml:"field_keys"`
+
+	// Name fields by the last element of their path only
+	UseLeafNameOnly bool `toml:"use_leaf_name_only"`
+
+	// Representation of leaf-lists, one field per element or a single joined string field
+	LeafList          string `toml:"leaf_list"`
+	LeafListSeparator string `toml:"leaf_list_separator"`
+
+	// Representation of bytes and Any values
+	BinaryPolicy string `toml:"binary_policy"`
+
+	// Patterns of JSON_IETF leaf names whose numeric strings are converted into numbers
+	JSONIETFNumbers []string `toml:"json_ietf_numbers"`
+
+	// Handling of paths with empty elements or names with slashes, normalized by default
+	MalformedPaths string `toml:"malformed_paths"`
+
+	// Emit a tombstone with the marker field for each deleted path
+	DeleteEvents bool   `toml:"delete_events"`
+	DeleteMarker string `toml:"delete_marker"`
+
+	// Sanitizers normalizing tag values by tag name, "*" for all tags
+	TagSanitizers map[string][]string `toml:"tag_sanitizers"`
+
+	// Vendor of the target enabling workarounds for its gNMI implementation
+	Vendor string
+
+	// Merge the updates of a notification into a single measurement
+	MergeUpdates *bool `toml:"merge_updates"`
+
+	// Record encoding, subscription, collector and reception time of each measurement
+	Provenance bool
+
+	// Unit of the timestamps of the targets, detected by their magnitude by default
+	TimestampUnit string `toml:"timestamp_unit"`
+
+	// Redial, backing off exponentially up to the maximum until streams stay established
+	Redial           internal.Duration
+	RedialMax        internal.Duration `toml:"redial_max"`
+	RedialResetAfter internal.Duration `toml:"redial_reset_after"`
+	RedialTLSExpired internal.Duration `toml:"redial_tls_expired"`
+
+	// Waiting for ready connections and retries of RPCs by gRPC itself
+	WaitForReady        bool              `toml:"wait_for_ready"`
+	RetryMaxAttempts    int               `toml:"retry_max_attempts"`
+	RetryInitialBackoff internal.Duration `toml:"retry_initial_backoff"`
+	RetryMaxBackoff     internal.Duration `toml:"retry_max_backoff"`
+
+	// Keepalive pings detecting broken connections, e.g. through NAT or firewalls
+	KeepaliveTime                internal.Duration `toml:"keepalive_time"`
+	KeepaliveTimeout             internal.Duration `toml:"keepalive_timeout"`
+	KeepalivePermitWithoutStream bool              `toml:"keepalive_permit_without_stream"`
+
+	// Past time range requested once instead of streaming live data
+	BackfillStart string `toml:"backfill_start"`
+	BackfillEnd   string `toml:"backfill_end"`
+
+	// Values set on the device before each gather cycle
+	Triggers []Trigger `toml:"trigger"`
+
+	// Paths polled with Get requests every gather cycle instead of subscribed to
+	Gets []Subscription `toml:"get"`
+
+	// Cheap path subscribed on every target as liveness canary, alarmed once silent for the timeout
+	Canary        *Subscription
+	CanaryTimeout internal.Duration `toml:"canary_timeout"`
+
+	// Interval for emitting supported models of the device
+	CapabilitiesInterval internal.Duration `toml:"capabilities_interval"`
+
+	// Emit connectivity state changes of the gRPC connection
+	ConnectionEvents bool `toml:"connection_events"`
+
+	// Emit the duration and last error of outages of targets once they recover
+	OutageEvents bool `toml:"outage_events"`
+
+	// Emit changes of the fields and field types of measurements
+	SchemaEvents bool `toml:"schema_events"`
+
+	// Emit the health of each target every gather interval
+	HealthMetrics bool `toml:"health_metrics"`
+
+	// Emit updates, errors and last update of each subscription per target every gather interval
+	SubscriptionMetrics bool `toml:"subscription_metrics"`
 
-	// Redial
-	Redial internal.Duration
+	// Address of the HTTP endpoint for adding subscriptions at runtime
+	AdminAddress string `toml:"admin_address"`
 
-	// GRPC TLS settings
+	// Address serving the statistics of the plugin as read-only gNMI target
+	SelfAddress string `toml:"self_address"`
+
+	// Track when each series of each target was first and last seen for the admin endpoint
+	TrackSeries bool `toml:"track_series"`
+
+	// Maximum number of series created per target and gather interval, rejecting any beyond
+	MaxNewSeries int `toml:"max_new_series"`
+
+	// Pattern of YAML files with additional subscriptions, reloaded on changes
+	PathsFile string `toml:"paths_file"`
+
+	// Prometheus endpoint for the last values
+	PrometheusAddress    string            `toml:"prometheus_address"`
+	PrometheusExpiration internal.Duration `toml:"prometheus_expiration"`
+
+	// Maximum latency for emitting telemetry data in timestamp order
+	ReorderLatency internal.Duration `toml:"reorder_latency"`
+
+	// Directory of the write-ahead log persisting metrics until delivered by the outputs
+	WALDirectory string `toml:"wal_directory"`
+
+	// Export goroutine and connection counts as internal statistics
+	ResourceAccounting bool `toml:"resource_accounting"`
+
+	// Maximum number of notifications decoded concurrently, by default the CPUs of the container
+	DecodeWorkers int `toml:"decode_workers"`
+
+	// Soft cap of the bandwidth per target in bytes per second, enforced by widening sample intervals
+	MaxBandwidth internal.Size `toml:"max_bandwidth"`
+
+	// GRPC TLS settings, with the passphrase of encrypted keys
 	TLS bool
 	internaltls.ClientConfig
+	TLSKeyPassphrase string `toml:"tls_key_passphrase"`
+
+	// File of named TLS profiles shared with other plugins, and the profile of the plugin
+	TLSProfiles string `toml:"tls_profiles"`
+	TLSProfile  string `toml:"tls_profile"`
+
+	// Maximum number of concurrent TLS handshakes with the targets
+	MaxTLSHandshakes int `toml:"max_tls_handshakes"`
+
+	// Devices overriding the credentials and TLS settings of the plugin
+	DeviceConfigs []DeviceConfig `toml:"device"`
 
 	// Internal state
-	acc    telegraf.Accumulator
-	cancel context.CancelFunc
-	ctx    context.Context
-	wg     sync.WaitGroup
+	acc       telegraf.Accumulator
+	targets   []*target
+	encodings []string
+	schemas   *schema.Tracker
+	exporter  *exporter.Exporter
+	reorder   *reorder.Buffer
+	wal       *wal.Log
+	auth      auth.Provider
+	cancel    context.CancelFunc
+	ctx       context.Context
+	wg        sync.WaitGroup
+	admin     *http.Server
+	mutex     sync.Mutex
+
+	// Workarounds for the vendor of the target
+	quirks vendorQuirks
+
+	// Slots of the workers decoding notifications of all targets, nil if unlimited
+	decoders chan struct{}
+
+	// Leaf names claimed by fields with use_leaf_name_only, shared by all targets
+	leafNames *gnmidecode.LeafNames
+
+	// Dial options of all targets and server of devices dialing out through gRPC tunnels
+	dialOpts []grpc.DialOption
+	tunnel   *grpc.Server
+
+	// Transport of the targets, unless overridden by the settings of their device, and the
+	// TLS handshake slots shared by all of them
+	transport      grpc.DialOption
+	deviceSettings map[string]*deviceSettings
+	handshakes     chan struct{}
+	tlsProfiles    tlsprofile.Profiles
+
+	// Nanoseconds per timestamp unit of the targets, zero if detected
+	timestampScale int64
+
+	// Server of the statistics of the plugin as gNMI target
+	self *grpc.Server
+
+	// Provider of devices in addition to the configured ones
+	inventory inventory.Provider
+
+	// Aliases by normalized path, and the aliases of the descendants of paths and of globs
+	aliases       map[string]string
+	aliasPatterns []aliasPattern
+
+	// Sanitizers of tag values
+	tagRules transforms.TagRules
+
+	// Name of this collector instance for provenance tags
+	collector string
+
+	// Parsed backfill range
+	backfillStart time.Time
+	backfillEnd   time.Time
+
+	// Subscriptions added at runtime by the admin endpoint
+	runtimeSubscriptions []*Subscription
+
+	// Subscriptions by their parsed paths, replaced whenever subscriptions change
+	subscriptionPaths atomic.Value
+
+	// Internal statistics
+	tlsExpiredErrors selfstat.Stat
+	bytesReceived    selfstat.Stat
+	goroutines       selfstat.Stat
+	connections      selfstat.Stat
 }
 
 // Subscription for a GNMI client
 type Subscription struct {
+	Name   string
 	Origin string
 	Path   string
 	Target string
 
 	// Subscription mode and interval
-	SubscriptionMode string            `toml:"subscription_mode"`
-	SampleInterval   internal.Duration `toml:"sample_interval"`
+	SubscriptionMode string   `toml:"subscription_mode"`
+	SampleInterval   Interval `toml:"sample_interval"`
 
 	// Duplicate suppression
-	SuppressRedundant bool              `toml:"suppress_redundant"`
-	HeartbeatInterval internal.Duration `toml:"heartbeat_interval"`
+	SuppressRedundant bool     `toml:"suppress_redundant"`
+	HeartbeatInterval Interval `toml:"heartbeat_interval"`
+
+	// Reconcile on-change subscriptions periodically with the state returned by a Get request
+	ReconcileInterval Interval `toml:"reconcile_interval"`
+
+	// Round timestamps down to the sample interval boundary
+	AlignTimestamps bool `toml:"align_timestamps"`
+
+	// Aggregate samples and emit statistics once per period
+	Aggregate       []string
+	AggregatePeriod Interval `toml:"aggregate_period"`
+
+	// Push event-like data to the accumulator as received, bypassing reordering
+	Immediate bool
+
+	// Unit conversions of fields by field name
+	Transforms map[string]string
+
+	// Tags of list keys renamed to merge measurements with subscriptions of other origins
+	MergeKeys map[string]string `toml:"merge_keys"`
+
+	aggregator *aggregator
+	transforms transforms.Rules
+
+	// Measurement name and paths file of subscriptions loaded from paths files
+	alias string
+	file  string
 }
 
 // Start the http listener service
-func (c *CiscoTelemetryGNMI) Start(acc telegraf.Accumulator) error {
-	var err error
+func (c *CiscoTelemetryGNMI) Start(acc telegraf.Accumulator) (err error) {
 	var opts []grpc.DialOption
 	c.acc = acc
+	if len(c.WALDirectory) > 0 {
+		if c.wal, err = wal.Open(c.WALDirectory, acc); err != nil {
+			return fmt.Errorf("E! Failed to open GNMI write-ahead log: %v", err)
+		}
+		c.acc = c.wal
+	}
+
 	c.ctx, c.cancel = context.WithCancel(context.Background())
 
-	if c.TLS {
-		tlsConfig, err := c.ClientConfig.TLSConfig()
+	// Servers, routines, targets and the write-ahead log started before a failure are stopped
+	// again, e.g. when a reload fails
+	defer func() {
 		if err != nil {
+			c.stopRoutines()
+			for _, t := range c.targets {
+				t.client.Close()
+			}
+			c.targets = nil
+
+			if c.wal != nil {
+				c.wal.Close()
+				c.wal = nil
+			}
+		}
+	}()
+
+	tags := map[string]string{"address": c.ServiceAddress}
+	c.tlsExpiredErrors = selfstat.Register("cisco_telemetry_gnmi", "tls_expired_errors", tags)
+	c.bytesReceived = selfstat.Register("cisco_telemetry_gnmi", "bytes_received", tags)
+	if c.ResourceAccounting {
+		c.goroutines = selfstat.Register("cisco_telemetry_gnmi", "goroutines", tags)
+		c.connections = selfstat.Register("cisco_telemetry_gnmi", "open_connections", tags)
+	}
+
+	subSecond := false
+	for i := range c.Subscriptions {
+		subscription := &c.Subscriptions[i]
+		subSecond = subSecond || subscription.SampleInterval.subSecond()
+
+		// Unknown modes would silently fall back to target defined
+		switch strings.ToLower(subscription.SubscriptionMode) {
+		case "", "target_defined", "sample", "on_change", "poll":
+		default:
+			return fmt.Errorf("E! Invalid GNMI subscription mode: %s", subscription.SubscriptionMode)
+		}
+
+		if subscription.ReconcileInterval.Duration > 0 && !strings.EqualFold(subscription.SubscriptionMode, "on_change") {
+			return fmt.Errorf("E! GNMI subscription %s:%s can only be reconciled in on_change mode",
+				subscription.Origin, subscription.Path)
+		}
+
+		if len(subscription.MergeKeys) > 0 && len(subscription.Name) == 0 {
+			return fmt.Errorf("E! GNMI subscription %s:%s merging keys requires a name",
+				subscription.Origin, subscription.Path)
+		}
+
+		if subscription.transforms, err = transforms.NewRules(subscription.Transforms); err != nil {
+			return fmt.Errorf("E! Invalid GNMI subscription transforms: %v", err)
+		}
+
+		// Setup input-side aggregation for subscriptions
+		if len(subscription.Aggregate) == 0 {
+			continue
+		}
+
+		if subscription.Immediate {
+			return fmt.Errorf("E! GNMI subscription %s:%s cannot be both immediate and aggregated",
+				subscription.Origin, subscription.Path)
+		}
+
+		if subscription.aggregator, err = newAggregator(subscription.Aggregate); err != nil {
 			return err
 		}
 
-		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
-	} else {
-		opts = append(opts, grpc.WithInsecure())
+		if subscription.AggregatePeriod.Duration <= 0 {
+			subscription.AggregatePeriod.Duration = 10 * time.Second
+		}
+	}
+
+	if err = c.setupGets(); err != nil {
+		return err
+	}
+
+	if err = c.setupCanary(); err != nil {
+		return err
+	}
+
+	// The agent rounds timestamps to seconds by default, merging samples of sub-second intervals
+	if subSecond {
+		log.Printf("I! GNMI sub-second sample intervals configured, keeping timestamps in nanosecond precision")
+		c.acc.SetPrecision(time.Nanosecond)
+	}
+
+	if c.SchemaEvents {
+		c.schemas = schema.NewTracker()
+	}
+
+	if c.ReorderLatency.Duration > 0 {
+		c.reorder = reorder.New(c.ReorderLatency.Duration, c.acc.AddFields)
+		c.wg.Add(1)
+		c.trackGoroutine(1)
+		go func() {
+			c.reorder.Run(c.ctx)
+			c.trackGoroutine(-1)
+			c.wg.Done()
+		}()
+	}
+
+	if err = c.setupVendor(); err != nil {
+		return err
+	}
+
+	c.leafNames = gnmidecode.NewLeafNames()
+	if workers := c.decodeWorkers(); workers > 0 {
+		c.decoders = make(chan struct{}, workers)
+		log.Printf("D! Decoding GNMI notifications with up to %d workers", workers)
+	}
+	for _, encoding := range c.encodings {
+		if _, ok := gnmi.Encoding_value[strings.ToUpper(encoding)]; !ok && len(encoding) > 0 {
+			return fmt.Errorf("E! Invalid GNMI encoding: %s", encoding)
+		}
+	}
+
+	if err = c.checkTriggers(); err != nil {
+		return err
 	}
 
-	if len(c.Username) > 0 {
-		c.ctx = metadata.AppendToOutgoingContext(c.ctx, "username", c.Username, "password", c.Password)
+	if err = c.parseBackfill(); err != nil {
+		return err
 	}
 
-	client, err := grpc.Dial(c.ServiceAddress, opts...)
+	if c.Provenance {
+		if c.collector, err = os.Hostname(); err != nil {
+			return fmt.Errorf("E! Failed to determine GNMI collector hostname: %v", err)
+		}
+	}
+
+	switch c.OutputFormat {
+	case "", gnmidecode.FormatTelegraf, gnmidecode.FormatGnmic:
+	default:
+		return fmt.Errorf("E! Invalid GNMI output format: %s", c.OutputFormat)
+	}
+
+	c.setupAliases()
+
+	if c.tagRules, err = transforms.NewTagRules(c.TagSanitizers); err != nil {
+		return fmt.Errorf("E! Invalid GNMI tag sanitizers: %v", err)
+	}
+
+	switch c.KeyPolicy {
+	case "", gnmidecode.KeysAsTags, gnmidecode.NumericKeysAsFields:
+	default:
+		return fmt.Errorf("E! Invalid GNMI key policy: %s", c.KeyPolicy)
+	}
+
+	switch c.LeafList {
+	case "", gnmidecode.LeafListIndexed, gnmidecode.LeafListJoined:
+	default:
+		return fmt.Errorf("E! Invalid GNMI leaf-list representation: %s", c.LeafList)
+	}
+
+	switch c.BinaryPolicy {
+	case "", gnmidecode.BinaryBase64, gnmidecode.BinaryHex, gnmidecode.BinaryLength, gnmidecode.BinaryDrop:
+	default:
+		return fmt.Errorf("E! Invalid GNMI binary policy: %s", c.BinaryPolicy)
+	}
+
+	switch c.MalformedPaths {
+	case "", malformedPathsNormalize, malformedPathsKeep, malformedPathsDrop:
+	default:
+		return fmt.Errorf("E! Invalid GNMI malformed paths handling: %s", c.MalformedPaths)
+	}
+
+	switch c.ModulePrefixes {
+	case "", gnmidecode.ModulePrefixesStrip, gnmidecode.ModulePrefixesKeep:
+	default:
+		return fmt.Errorf("E! Invalid GNMI module prefixes handling: %s", c.ModulePrefixes)
+	}
+
+	if err = c.loadTLSProfiles(); err != nil {
+		return err
+	}
+	enabled, clientConfig, passphrase, err := c.tlsSettings()
 	if err != nil {
-		return fmt.Errorf("E! Failed to dial GNMI: %v", err)
+		return fmt.Errorf("E! Invalid GNMI TLS settings: %v", err)
+	}
+	c.handshakes = nil
+	if c.transport, err = c.transportOption(enabled, clientConfig, passphrase); err != nil {
+		return fmt.Errorf("E! Invalid GNMI TLS settings: %v", err)
+	}
+
+	username, err := renderTemplate(c.Username, c.TargetTags)
+	if err != nil {
+		return fmt.Errorf("E! Invalid GNMI username template: %v", err)
+	}
+
+	// Secrets are resolved on each start, i.e. also when Telegraf reloads its configuration
+	if username, err = secret.Resolve(username); err != nil {
+		return fmt.Errorf("E! Failed to resolve GNMI username: %v", err)
+	}
+	password, err := secret.Resolve(c.Password)
+	if err != nil {
+		return fmt.Errorf("E! Failed to resolve GNMI password: %v", err)
+	}
+	config := c.Config
+	if config.OAuth2ClientSecret, err = secret.Resolve(config.OAuth2ClientSecret); err != nil {
+		return fmt.Errorf("E! Failed to resolve GNMI OAuth2 client secret: %v", err)
+	}
+
+	if c.auth, err = config.Provider(username, password); err != nil {
+		return fmt.Errorf("E! Invalid GNMI auth provider: %v", err)
 	}
 
-	// Dialin client telemetry stream reading routine
+	if c.timestampScale, err = timestampScale(c.TimestampUnit); err != nil {
+		return fmt.Errorf("E! Invalid GNMI timestamp unit: %s", c.TimestampUnit)
+	}
+
+	// Devices overriding the credentials and TLS settings of the plugin
+	if err = c.setupDevices(config, username, password); err != nil {
+		return err
+	}
+
+	// One target per device, or per address the device resolves to
+	var targets []*target
+	for _, device := range c.devices() {
+		addresses := []string{device}
+		if c.ResolveAddresses {
+			if addresses, err = resolveAddresses(device); err != nil {
+				return fmt.Errorf("E! Failed to resolve GNMI service address: %v", err)
+			}
+		}
+
+		for _, address := range addresses {
+			targets = append(targets, &target{address: address, producer: device, tags: make(map[string]string), intervalScale: 1})
+		}
+	}
+
+	c.indexSubscriptions()
+
+	var pathsFileState string
+	if len(c.PathsFile) > 0 {
+		var subscriptions []*Subscription
+		if subscriptions, pathsFileState, err = c.loadPathsFiles(); err != nil {
+			return fmt.Errorf("E! Failed to load GNMI paths files: %v", err)
+		}
+		c.updateFileSubscriptions(subscriptions)
+	}
+
+	if c.KeepaliveTime.Duration > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                c.KeepaliveTime.Duration,
+			Timeout:             c.KeepaliveTimeout.Duration,
+			PermitWithoutStream: c.KeepalivePermitWithoutStream,
+		}))
+	}
+	if c.WaitForReady {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.WaitForReady(true)))
+	}
+	if c.RetryMaxAttempts > 1 {
+		opts = append(opts, grpc.WithDefaultServiceConfig(c.retryServiceConfig()))
+	}
+
+	// Aggregation routines of the subscriptions, started once all settings are validated
+	for i := range c.Subscriptions {
+		if c.Subscriptions[i].aggregator != nil {
+			c.wg.Add(1)
+			c.trackGoroutine(1)
+			go c.flushAggregates(&c.Subscriptions[i])
+		}
+	}
+
+	c.dialOpts = opts
+	for _, t := range targets {
+		// Keep the service address as authority, e.g. for TLS server name verification
+		if t.address != t.producer {
+			t.tags["address"], _, _ = net.SplitHostPort(t.address)
+		}
+
+		if err = c.startTarget(t); err != nil {
+			return err
+		}
+	}
+
+	// Inventory routine adding and removing the targets of its devices
+	if c.inventory == nil {
+		source := c.Source
+		if source.NetBoxToken, err = secret.Resolve(source.NetBoxToken); err != nil {
+			return fmt.Errorf("E! Failed to resolve GNMI NetBox token: %v", err)
+		}
+		if c.inventory, err = source.Provider(); err != nil {
+			return fmt.Errorf("E! Invalid GNMI inventory provider: %v", err)
+		}
+	}
+	if c.inventory != nil {
+		c.wg.Add(1)
+		c.trackGoroutine(1)
+		go c.syncInventory()
+	}
+
+	// Listener of devices dialing out through gRPC tunnels
+	if len(c.TunnelAddress) > 0 {
+		if err = c.startTunnel(); err != nil {
+			return err
+		}
+	}
+
+	// Read-only gNMI target of the statistics of the plugin
+	if len(c.SelfAddress) > 0 {
+		if err = c.startSelf(); err != nil {
+			return err
+		}
+	}
+
+	// Paths file reload routine
+	if len(c.PathsFile) > 0 {
+		c.wg.Add(1)
+		c.trackGoroutine(1)
+		go c.watchPathsFiles(pathsFileState)
+	}
+
+	// Bandwidth measurement and enforcement routine
 	c.wg.Add(1)
-	go c.subscribeGNMI(client)
+	c.trackGoroutine(1)
+	go c.monitorBandwidth()
+
+	if len(c.AdminAddress) > 0 {
+		if err = c.startAdmin(); err != nil {
+			return err
+		}
+	}
 
-	log.Printf("I! Started Cisco GNMI service for %s", c.ServiceAddress)
+	if len(c.PrometheusAddress) > 0 {
+		if c.PrometheusExpiration.Duration <= 0 {
+			c.PrometheusExpiration.Duration = 10 * time.Minute
+		}
+
+		c.exporter = exporter.New(c.PrometheusExpiration.Duration)
+		if err = c.exporter.Start(c.PrometheusAddress); err != nil {
+			return fmt.Errorf("E! Failed to start GNMI Prometheus endpoint: %v", err)
+		}
+	}
+
+	log.Printf("I! Started Cisco GNMI service for %s", strings.Join(c.devices(), ", "))
 
 	return nil
 }
 
-// SubscribeGNMI and extract telemetry data
-func (c *CiscoTelemetryGNMI) subscribeGNMI(client *grpc.ClientConn) {
-	for c.ctx.Err() == nil {
-		// Create subscription objects
-		subscriptions := make([]*gnmi.Subscription, len(c.Subscriptions))
-		for i, subscription := range c.Subscriptions {
-			subscriptions[i] = &gnmi.Subscription{
-				Path:              parsePath(subscription.Origin, subscription.Path, subscription.Target),
-				Mode:              gnmi.SubscriptionMode(gnmi.SubscriptionMode_value[strings.ToUpper(subscription.SubscriptionMode)]),
-				SampleInterval:    uint64(subscription.SampleInterval.Duration.Nanoseconds()),
-				SuppressRedundant: subscription.SuppressRedundant,
-				HeartbeatInterval: uint64(subscription.HeartbeatInterval.Duration.Nanoseconds()),
-			}
+// StartTarget dials a target and starts its streams and routines
+func (c *CiscoTelemetryGNMI) startTarget(t *target) error {
+	var err error
+	transport := c.transport
+	t.timestampScale = c.timestampScale
+	if device, ok := c.deviceSettings[c.producer(t)]; ok {
+		t.auth, transport, t.timestampScale = device.auth, device.transport, device.timestampScale
+	}
+
+	opts := append(append([]grpc.DialOption{}, c.dialOpts...), transport, grpc.WithDialer(c.dialer(t)))
+	if len(t.producer) > 0 && t.address != t.producer {
+		opts = append(opts, grpc.WithAuthority(t.producer))
+	}
+
+	t.client, err = grpc.Dial(t.address, opts...)
+	if err != nil {
+		return fmt.Errorf("E! Failed to dial GNMI: %v", err)
+	}
+
+	// Dialin client telemetry stream reading routines, one per subscription
+	c.mutex.Lock()
+	if c.ctx.Err() != nil {
+		c.mutex.Unlock()
+		t.client.Close()
+		return c.ctx.Err()
+	}
+	t.ctx, t.cancel = context.WithCancel(c.ctx)
+	if c.TrackSeries || c.MaxNewSeries > 0 {
+		t.series = newSeriesTracker()
+	}
+	if c.backfillStart.IsZero() {
+		t.streams = c.newStreams(t)
+	} else {
+		t.streams = []*stream{c.newBackfillStream(t)}
+	}
+	c.targets = append(c.targets, t)
+	for _, s := range t.streams {
+		c.wg.Add(1)
+		c.trackGoroutine(1)
+		if c.backfillStart.IsZero() {
+			go c.subscribeGNMI(s)
+		} else {
+			go c.backfillGNMI(s)
 		}
+	}
 
-		// Construct subscribe request
-		request := &gnmi.SubscribeRequest{
-			Request: &gnmi.SubscribeRequest_Subscribe{
-				Subscribe: &gnmi.SubscriptionList{
-					Prefix:       parsePath(c.Origin, c.Prefix, c.Target),
-					Mode:         gnmi.SubscriptionList_STREAM,
-					Encoding:     gnmi.Encoding(gnmi.Encoding_value[strings.ToUpper(c.Encoding)]),
-					Subscription: subscriptions,
-					UpdatesOnly:  c.UpdatesOnly,
-				},
-			},
+	// Reconciliation routines of on-change subscriptions, sharing the state of the target
+	for i := range c.Subscriptions {
+		if c.Subscriptions[i].ReconcileInterval.Duration <= 0 || !c.backfillStart.IsZero() {
+			continue
+		}
+		if t.state == nil {
+			t.state = newStateCache()
 		}
+		c.wg.Add(1)
+		c.trackGoroutine(1)
+		go c.reconcileGNMI(t, &c.Subscriptions[i])
+	}
 
-		subscribeClient, err := gnmi.NewGNMIClient(client).Subscribe(c.ctx)
-		if err != nil {
-			c.acc.AddError(fmt.Errorf("E! GNMI subscription setup failed: %v", err))
-		} else {
-			err = subscribeClient.Send(request)
+	// Device capability inventory routine
+	if c.CapabilitiesInterval.Duration > 0 {
+		c.wg.Add(1)
+		c.trackGoroutine(1)
+		go c.gatherCapabilities(t)
+	}
+
+	// Connectivity state event routine
+	if c.ConnectionEvents {
+		c.wg.Add(1)
+		c.trackGoroutine(1)
+		go c.watchConnectivity(t)
+	}
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// StopTarget cancels the streams and routines of a target and closes its connection
+func (c *CiscoTelemetryGNMI) stopTarget(t *target) {
+	c.mutex.Lock()
+	for i, other := range c.targets {
+		if other == t {
+			c.targets = append(c.targets[:i], c.targets[i+1:]...)
+			break
 		}
+	}
+	c.mutex.Unlock()
 
-		if err != nil {
-			c.acc.AddError(fmt.Errorf("E! GNMI subscription setup failed: %v", err))
-		} else {
-			log.Printf("D! Connection to GNMI device %s established", c.ServiceAddress)
-			for {
-				reply, err := subscribeClient.Recv()
-
-				if err != nil {
-					if err != io.EOF && c.ctx.Err() == nil {
-						c.acc.AddError(fmt.Errorf("E! GNMI subscription aborted: %v", err))
-					}
-					break
-				}
-
-				// Check for Update message, if not skip (e.g. Sync message)
-				response, ok := reply.Response.(*gnmi.SubscribeResponse_Update)
-				if !ok {
-					continue
-				}
-
-				timestamp := time.Unix(0, response.Update.Timestamp)
-				fields := make(map[string]interface{})
-				tags := make(map[string]string)
-
-				var builder bytes.Buffer
-
-				if len(response.Update.Prefix.Origin) > 0 {
-					builder.WriteString(response.Update.Prefix.Origin)
-					builder.WriteRune(':')
-				}
-				builder.WriteRune('/')
-
-				// Parse generic keys from prefix
-				for _, elem := range response.Update.Prefix.Elem {
-					builder.WriteString(elem.Name)
-					builder.WriteRune('/')
-
-					for key, val := range elem.Key {
-						// Use short-form of key if possible
-						if _, exists := tags[key]; exists {
-							tags[builder.String()+key] = val
-						} else {
-							tags[key] = val
-						}
-					}
-				}
-
-				tags["Producer"] = c.ServiceAddress
-				tags["Target"] = response.Update.Prefix.Target
-				builder.Truncate(builder.Len() - 1)
-				prefix := builder.String()
-
-				// Parse individual Update message and create measurement
-				for _, update := range response.Update.Update {
-					builder.Reset()
-					if len(update.Path.Origin) > 0 {
-						builder.WriteString(update.Path.Origin)
-						builder.WriteRune(':')
-					}
-
-					parts := update.Path.Elem
-
-					// Compatibility with old GNMI
-					if len(parts) == 0 {
-						parts = make([]*gnmi.PathElem, len(update.Path.Element))
-						for i, part := range update.Path.Element {
-							parts[i] = &gnmi.PathElem{Name: part}
-						}
-					}
-
-					for i, elem := range parts {
-						builder.WriteString(elem.Name)
-
-						for key, val := range elem.Key {
-							builder.WriteRune('[')
-							builder.WriteString(key)
-							builder.WriteRune('=')
-							builder.WriteString(val)
-							builder.WriteRune(']')
-						}
-
-						if i < len(parts)-1 {
-							builder.WriteRune('/')
-						}
-					}
-
-					var value interface{}
-					var jsondata []byte
-
-					switch update.Val.Value.(type) {
-					case *gnmi.TypedValue_AsciiVal:
-						value = update.Val.Value.(*gnmi.TypedValue_AsciiVal).AsciiVal
-					case *gnmi.TypedValue_BoolVal:
-						value = update.Val.Value.(*gnmi.TypedValue_BoolVal).BoolVal
-					case *gnmi.TypedValue_BytesVal:
-						value = update.Val.Value.(*gnmi.TypedValue_BytesVal).BytesVal
-					case *gnmi.TypedValue_DecimalVal:
-						value = update.Val.Value.(*gnmi.TypedValue_DecimalVal).DecimalVal
-					case *gnmi.TypedValue_FloatVal:
-						value = update.Val.Value.(*gnmi.TypedValue_FloatVal).FloatVal
-					case *gnmi.TypedValue_IntVal:
-						value = update.Val.Value.(*gnmi.TypedValue_IntVal).IntVal
-					case *gnmi.TypedValue_StringVal:
-						value = update.Val.Value.(*gnmi.TypedValue_StringVal).StringVal
-					case *gnmi.TypedValue_UintVal:
-						value = update.Val.Value.(*gnmi.TypedValue_UintVal).UintVal
-					case *gnmi.TypedValue_JsonIetfVal:
-						jsondata = update.Val.Value.(*gnmi.TypedValue_JsonIetfVal).JsonIetfVal
-					case *gnmi.TypedValue_JsonVal:
-						jsondata = update.Val.Value.(*gnmi.TypedValue_JsonVal).JsonVal
-					}
-
-					if value != nil {
-						fields[builder.String()] = value
-					} else if jsondata != nil {
-						if err = json.Unmarshal(jsondata, &value); err != nil {
-							c.acc.AddError(fmt.Errorf("W! GNMI JSON data is invalid: %v", err))
-							continue
-						}
-
-						flattener := jsonparser.JSONFlattener{Fields: fields}
-						flattener.FullFlattenJSON(builder.String(), value, true, true)
-					}
-				}
-
-				// Finally add measurements
-				c.acc.AddFields(prefix, fields, tags, timestamp)
+	t.cancel()
+	t.client.Close()
+}
+
+// Devices returns the service address and the addresses of all devices to subscribe to
+func (c *CiscoTelemetryGNMI) devices() []string {
+	if len(c.ServiceAddress) == 0 {
+		return c.Addresses
+	}
+	return append([]string{c.ServiceAddress}, c.Addresses...)
+}
+
+// Producer of a target, the address of the device it was configured by
+func (c *CiscoTelemetryGNMI) producer(t *target) string {
+	if len(t.producer) > 0 {
+		return t.producer
+	}
+	return c.ServiceAddress
+}
+
+// SubscribeGNMI and extract telemetry data of a stream, redialing it on failures
+func (c *CiscoTelemetryGNMI) subscribeGNMI(s *stream) {
+	if strings.EqualFold(c.Encoding, encodingAuto) {
+		c.negotiateEncoding(s.target)
+	}
+
+	b := &backoff.Backoff{Min: c.Redial.Duration, Max: c.RedialMax.Duration, ResetAfter: c.RedialResetAfter.Duration}
+	for s.ctx.Err() == nil {
+		s.setState(streamConnecting)
+		encoding := atomic.LoadInt32(&s.target.encoding)
+		err := c.subscribe(s, c.encodings[encoding])
+		if err == errResubscribe {
+			continue
+		}
+
+		// Retry immediately with the next encoding if the device does not implement this one
+		if status.Code(err) == codes.Unimplemented && int(encoding)+1 < len(c.encodings) {
+			if atomic.CompareAndSwapInt32(&s.target.encoding, encoding, encoding+1) {
+				log.Printf("I! GNMI device %s does not implement encoding %s, using %s", s.target.address,
+					c.encodings[encoding], c.encodings[encoding+1])
 			}
+			continue
+		}
+
+		class := ""
+		if err != nil {
+			class = classifyError(err)
+			atomic.AddUint64(&s.errors, 1)
+			atomic.AddUint64(&s.target.errors, 1)
+			s.target.lastError.Store(targetError{message: err.Error(), class: class})
+			atomic.CompareAndSwapInt64(&s.target.downSince, 0, time.Now().UnixNano())
+		}
 
-			log.Printf("D! Connection to GNMI device %s closed", c.ServiceAddress)
+		if established := atomic.SwapInt64(&s.established, 0); established > 0 {
+			b.Established(time.Since(time.Unix(0, established)))
 		}
 
-		if c.Redial.Duration.Nanoseconds() <= 0 {
+		redial := b.Next()
+		if class == errorClassTLSExpired {
+			c.tlsExpiredErrors.Incr(1)
+			if c.RedialTLSExpired.Duration > 0 {
+				redial = c.RedialTLSExpired.Duration
+			}
+		}
+
+		if redial.Nanoseconds() <= 0 {
 			break
 		}
 
+		s.setState(streamBackoff)
+		atomic.AddUint64(&s.target.redials, 1)
 		select {
-		case <-c.ctx.Done():
-		case <-time.After(c.Redial.Duration):
+		case <-s.ctx.Done():
+		case <-time.After(redial):
 		}
 	}
 
-	client.Close()
+	s.setState(streamStopped)
+	c.trackGoroutine(-1)
 	c.wg.Done()
 }
 
-//ParsePath from XPath-like string to GNMI path structure
-func parsePath(origin string, path string, target string) *gnmi.Path {
-	gnmiPath := gnmi.Path{Origin: origin, Target: target}
+// Subscribe to the device and handle telemetry data until the subscription ends
+func (c *CiscoTelemetryGNMI) subscribe(s *stream, encoding string) error {
+	t := s.target
 
-	elem := &gnmi.PathElem{}
-	start, name, value, end := 0, -1, -1, -1
+	// Streams are resubscribed on their own, e.g. to apply widened sample intervals
+	subscriptionCtx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	s.setRestart(cancel)
 
-	path = path + "/"
+	ctx, err := c.authContext(subscriptionCtx, t)
+	if err != nil {
+		c.acc.AddError(err)
+		return err
+	}
+
+	request := c.subscribeRequest(encoding, s.subscriptions...)
+	scaleIntervals(request, atomic.LoadInt64(&t.intervalScale))
+
+	subscribeClient, err := gnmi.NewGNMIClient(t.client).Subscribe(ctx)
+	if err == nil {
+		err = subscribeClient.Send(request)
+	}
+
+	if err != nil {
+		err = rpcerror.Decode(err, nil)
+		c.acc.AddError(fmt.Errorf("E! GNMI subscription setup failed: %v", err))
+		return err
+	}
 
-	for i := 0; i < len(path); i++ {
-		switch path[i] {
-		case '[':
-			if end < 0 {
-				end = i
-				elem.Key = make(map[string]string)
+	s.setState(streamEstablished)
+	atomic.StoreInt64(&s.established, time.Now().UnixNano())
+	log.Printf("D! Connection to GNMI device %s established", t.address)
+	if down := atomic.SwapInt64(&t.downSince, 0); down > 0 && c.OutageEvents {
+		c.handleOutage(t, time.Unix(0, down), time.Now())
+	}
+	defer log.Printf("D! Connection to GNMI device %s closed", t.address)
+
+	// Polled streams request their data on every gather interval
+	if s.polls != nil {
+		c.trackGoroutine(1)
+		go c.sendPolls(subscriptionCtx, s, subscribeClient)
+	}
+
+	// Updates of on-change streams before the sync response are the initial state of the paths,
+	// held back and emitted at once when complete
+	var initial []*gnmi.SubscribeResponse
+	syncing := s.onChange() && !c.UpdatesOnly
+
+	for {
+		reply, err := subscribeClient.Recv()
+		if err != nil {
+			if err == io.EOF || s.ctx.Err() != nil {
+				return nil
 			}
-			if name < 0 {
-				name = i + 1
+			if subscriptionCtx.Err() != nil {
+				return errResubscribe
 			}
 
-		case '=':
-			if name > 0 && value < 0 {
-				value = i + 1
+			// Devices provide details of the error in the status and trailers
+			err = rpcerror.Decode(err, subscribeClient.Trailer())
+			c.acc.AddError(fmt.Errorf("E! GNMI subscription aborted: %v", err))
+			return err
+		}
+
+		atomic.AddUint64(&t.responses, 1)
+		atomic.StoreInt64(&t.lastResponse, time.Now().UnixNano())
+		if update, ok := reply.Response.(*gnmi.SubscribeResponse_Update); ok {
+			atomic.AddUint64(&t.notifications, 1)
+			atomic.AddUint64(&t.updates, uint64(len(update.Update.GetUpdate())))
+			atomic.AddUint64(&s.updates, uint64(len(update.Update.GetUpdate())))
+			atomic.StoreInt64(&s.lastUpdate, time.Now().UnixNano())
+		}
+
+		// Data of the canary only proves the target alive
+		if s == t.canary {
+			continue
+		}
+
+		if syncing && !reply.GetSyncResponse() {
+			initial = append(initial, reply)
+			continue
+		} else if syncing {
+			log.Printf("D! GNMI stream %s on %s synced with %d initial responses", s.name(), t.address, len(initial))
+			for _, response := range initial {
+				c.handleSubscribeResponse(t, response)
 			}
+			initial, syncing = nil, false
+		}
+		c.handleSubscribeResponse(t, reply)
+	}
+}
+
+// SubscribeRequest for the given subscriptions
+func (c *CiscoTelemetryGNMI) subscribeRequest(encoding string, configured ...*Subscription) *gnmi.SubscribeRequest {
+	// Create subscription objects
+	subscriptions := make([]*gnmi.Subscription, len(configured))
+	for i, subscription := range configured {
+		subscriptions[i] = &gnmi.Subscription{
+			Path:              c.requestPath(subscription.Origin, subscription.Path, subscription.Target),
+			Mode:              gnmi.SubscriptionMode(gnmi.SubscriptionMode_value[strings.ToUpper(subscription.SubscriptionMode)]),
+			SampleInterval:    uint64(subscription.SampleInterval.Duration.Nanoseconds()),
+			SuppressRedundant: subscription.SuppressRedundant,
+			HeartbeatInterval: uint64(subscription.HeartbeatInterval.Duration.Nanoseconds()),
+		}
+	}
+
+	// Subscriptions are polled only if all of them are in poll mode
+	mode := gnmi.SubscriptionList_STREAM
+	if polled(configured) {
+		mode = gnmi.SubscriptionList_POLL
+	}
+
+	// Construct subscribe request
+	return &gnmi.SubscribeRequest{
+		Request: &gnmi.SubscribeRequest_Subscribe{
+			Subscribe: &gnmi.SubscriptionList{
+				Prefix:       c.requestPath(c.Origin, c.Prefix, c.Target),
+				Mode:         mode,
+				Encoding:     gnmi.Encoding(gnmi.Encoding_value[strings.ToUpper(encoding)]),
+				Subscription: subscriptions,
+				UpdatesOnly:  c.UpdatesOnly,
+			},
+		},
+	}
+}
+
+// AuthContext returns a context carrying the current credentials of the auth provider of a target
+func (c *CiscoTelemetryGNMI) authContext(ctx context.Context, t *target) (context.Context, error) {
+	provider := c.auth
+	if t.auth != nil {
+		provider = t.auth
+	}
+	if provider == nil {
+		return ctx, nil
+	}
+
+	credentials, err := provider.Credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("E! GNMI auth provider failed: %v", err)
+	}
 
-		case ']':
-			if name > 0 && value > name {
-				elem.Key[path[name:value-1]] = strings.Trim(path[value:i], "'\"")
+	for key, value := range credentials {
+		ctx = metadata.AppendToOutgoingContext(ctx, key, value)
+	}
+	return ctx, nil
+}
+
+// GatherCapabilities periodically and emit supported models of the device
+func (c *CiscoTelemetryGNMI) gatherCapabilities(t *target) {
+	ticker := time.NewTicker(c.CapabilitiesInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		ctx, err := c.authContext(t.ctx, t)
+		var response *gnmi.CapabilityResponse
+		if err == nil {
+			response, err = gnmi.NewGNMIClient(t.client).Capabilities(ctx, &gnmi.CapabilityRequest{})
+		}
+
+		if err != nil {
+			if t.ctx.Err() == nil {
+				c.acc.AddError(fmt.Errorf("E! GNMI capability request failed: %v", rpcerror.Decode(err, nil)))
 			}
-			name, value = -1, -1
-
-		case '/':
-			if name < 0 {
-				if end < 0 {
-					end = i
-				}
-
-				if end > start {
-					elem.Name = path[start:end]
-					gnmiPath.Elem = append(gnmiPath.Elem, elem)
-					gnmiPath.Element = append(gnmiPath.Element, path[start:i])
-				}
-
-				start, name, value, end = i+1, -1, -1, -1
-				elem = &gnmi.PathElem{}
+		} else {
+			c.handleCapabilityResponse(t, response, time.Now())
+		}
+
+		select {
+		case <-t.ctx.Done():
+			c.trackGoroutine(-1)
+			c.wg.Done()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// WatchConnectivity of the gRPC connection and emit state changes as events
+func (c *CiscoTelemetryGNMI) watchConnectivity(t *target) {
+	previous := ""
+	state := t.client.GetState()
+	for {
+		c.handleConnectivityState(t, state.String(), previous, time.Now())
+
+		if !t.client.WaitForStateChange(t.ctx, state) {
+			break
+		}
+		previous, state = state.String(), t.client.GetState()
+	}
+
+	c.trackGoroutine(-1)
+	c.wg.Done()
+}
+
+// HandleConnectivityState and add a measurement for the state change
+func (c *CiscoTelemetryGNMI) handleConnectivityState(t *target, state string, previous string, timestamp time.Time) {
+	tags := map[string]string{"Producer": c.producer(t)}
+	for key, val := range t.tags {
+		tags[key] = val
+	}
+
+	fields := map[string]interface{}{"state": state}
+	if len(previous) > 0 {
+		fields["previous_state"] = previous
+	}
+
+	c.acc.AddFields("gnmi_connection", fields, tags, timestamp)
+}
+
+// HandleOutage of a target that recovered and add a measurement with its duration and last error
+func (c *CiscoTelemetryGNMI) handleOutage(t *target, down time.Time, timestamp time.Time) {
+	tags := map[string]string{"Producer": c.producer(t)}
+	for key, val := range t.tags {
+		tags[key] = val
+	}
+
+	fields := map[string]interface{}{"duration": timestamp.Sub(down).Seconds(), "down_since": down.UnixNano()}
+	if last, ok := t.lastError.Load().(targetError); ok {
+		fields["last_error"], fields["last_error_class"] = last.message, last.class
+	}
+
+	c.acc.AddFields("gnmi_outage", fields, tags, timestamp)
+}
+
+// HandleSchemaChange and add a measurement if the fields of a measurement changed
+func (c *CiscoTelemetryGNMI) handleSchemaChange(t *target, name string, fields map[string]interface{}, timestamp time.Time) {
+	change := c.schemas.Observe(t.address, name, fields)
+	if change == nil {
+		return
+	}
+
+	tags := map[string]string{"Producer": c.producer(t), "measurement": name}
+	for key, val := range t.tags {
+		tags[key] = val
+	}
+
+	event := map[string]interface{}{
+		"fingerprint":    change.Fingerprint,
+		"fields":         change.Fields,
+		"changed_fields": strings.Join(change.Changed, ","),
+	}
+	if len(change.Previous) > 0 {
+		event["previous_fingerprint"] = change.Previous
+	}
+
+	c.acc.AddFields("gnmi_schema", event, tags, timestamp)
+}
+
+// HandleCapabilityResponse and add one measurement per supported model
+func (c *CiscoTelemetryGNMI) handleCapabilityResponse(t *target, response *gnmi.CapabilityResponse, timestamp time.Time) {
+	for _, model := range response.SupportedModels {
+		tags := map[string]string{
+			"Producer":     c.producer(t),
+			"model":        model.Name,
+			"organization": model.Organization,
+		}
+		for key, val := range t.tags {
+			tags[key] = val
+		}
+		fields := map[string]interface{}{
+			"version":      model.Version,
+			"gnmi_version": response.GNMIVersion,
+		}
+		c.acc.AddFields("gnmi_models", fields, tags, timestamp)
+	}
+}
+
+// TrackGoroutine adjusts the number of running goroutines of the target if accounting is enabled
+func (c *CiscoTelemetryGNMI) trackGoroutine(delta int64) {
+	if c.goroutines != nil {
+		c.goroutines.Incr(delta)
+	}
+}
+
+// Connection decrementing its statistic once closed
+type countedConn struct {
+	net.Conn
+	stat selfstat.Stat
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.stat.Incr(-1) })
+	return c.Conn.Close()
+}
+
+// DecodeWorkers configured, or the CPUs available to the container if not configured
+func (c *CiscoTelemetryGNMI) decodeWorkers() int {
+	if c.DecodeWorkers != 0 {
+		return c.DecodeWorkers
+	}
+	return cpulimit.CPUs()
+}
+
+// HandleSubscribeResponse message from GNMI and parse contained telemetry data
+func (c *CiscoTelemetryGNMI) handleSubscribeResponse(t *target, reply *gnmi.SubscribeResponse) {
+	// Check for Update message, if not skip (e.g. Sync message)
+	response, ok := reply.Response.(*gnmi.SubscribeResponse_Update)
+	if !ok {
+		return
+	}
+
+	// Notifications of all targets are decoded by at most as many workers as CPUs are available
+	if c.decoders != nil {
+		c.decoders <- struct{}{}
+		defer func() { <-c.decoders }()
+	}
+
+	notification := response.Update
+	if c.MergeUpdates != nil && !*c.MergeUpdates {
+		// Handle each update separately to never co-locate fields of different updates
+		for _, update := range notification.Update {
+			single := *notification
+			single.Update = []*gnmi.Update{update}
+			c.handleNotification(t, &single)
+		}
+		return
+	}
+
+	c.handleNotification(t, notification)
+}
+
+// Handling of paths with empty elements or names with slashes
+const (
+	malformedPathsNormalize = "normalize"
+	malformedPathsKeep      = "keep"
+	malformedPathsDrop      = "drop"
+)
+
+// HandleNotification decodes a notification and adds its measurements
+func (c *CiscoTelemetryGNMI) handleNotification(t *target, notification *gnmi.Notification) {
+	// Paths with empty elements of buggy targets are normalized, or dropped with their data
+	if c.MalformedPaths != malformedPathsKeep {
+		normalized := gnmidecode.NormalizePaths(notification)
+		if normalized != notification && c.MalformedPaths == malformedPathsDrop {
+			atomic.AddUint64(&t.decodeErrors, 1)
+			log.Printf("D! Dropped GNMI notification of %s with malformed path %s", t.address,
+				gnmidecode.CanonicalPath(notification))
+			return
+		}
+		notification = normalized
+	}
+
+	timestamp := c.notificationTime(t, notification.Timestamp)
+
+	var subscription *Subscription
+	if len(notification.Update) > 0 {
+		subscription = c.lookupSubscription(notification.GetPrefix(), notification.Update[0].GetPath())
+	}
+
+	// New series beyond the limit of the gather interval are rejected
+	if t.series != nil && !t.series.admit(gnmidecode.CanonicalPath(notification), time.Now(), c.MaxNewSeries) {
+		return
+	}
+
+	// Track the state of reconciled subscriptions, including deletes of any path
+	if t.state != nil && (subscription == nil || subscription.ReconcileInterval.Duration > 0) {
+		t.state.update(notification)
+	}
+
+	if c.DeleteEvents && len(notification.Delete) > 0 {
+		c.handleDeletes(t, notification, timestamp)
+		if len(notification.Update) == 0 {
+			return
+		}
+	}
+
+	name, fields, tags := c.decodeNotification(t, notification, subscription)
+	name = c.measurementName(name, subscription)
+
+	if c.IncludePathTag {
+		tags["path"] = gnmidecode.CanonicalPath(notification)
+	}
+
+	if c.Provenance {
+		c.addProvenance(t, subscription, fields, tags)
+	}
+
+	// Atomic notifications represent a single consistent state of the device
+	if notification.Atomic {
+		tags["atomic"] = "true"
+	}
+
+	if subscription != nil {
+		subscription.transforms.Apply(fields)
+		subscription.renameMergeKeys(tags)
+	}
+	c.tagRules.Apply(tags)
+
+	if c.schemas != nil {
+		c.handleSchemaChange(t, name, fields, timestamp)
+	}
+
+	// Align timestamp to the sample interval of the subscription if requested
+	if subscription != nil && subscription.AlignTimestamps {
+		timestamp = alignTimestamp(timestamp, subscription.SampleInterval.Duration)
+	}
+
+	// Values of a single update may still be flattened into several fields
+	if c.MergeUpdates != nil && !*c.MergeUpdates && len(fields) > 1 {
+		for key, value := range fields {
+			c.addNotificationMetric(subscription, notification, name, map[string]interface{}{key: value}, tags, timestamp)
+		}
+		return
+	}
+
+	c.addNotificationMetric(subscription, notification, name, fields, tags, timestamp)
+}
+
+// AddProvenance of a measurement as tags and its reception time as field
+func (c *CiscoTelemetryGNMI) addProvenance(t *target, subscription *Subscription, fields map[string]interface{}, tags map[string]string) {
+	fields["provenance_received"] = time.Now().UnixNano()
+	if len(c.encodings) > 0 {
+		encoding := gnmi.Encoding_value[strings.ToUpper(c.encodings[atomic.LoadInt32(&t.encoding)])]
+		tags["provenance_encoding"] = strings.ToLower(gnmi.Encoding(encoding).String())
+	}
+	if subscription != nil {
+		if len(subscription.Name) > 0 {
+			tags["provenance_subscription"] = subscription.Name
+		} else {
+			tags["provenance_subscription"] = subscription.Origin + ":" + subscription.Path
+		}
+	}
+	if len(c.collector) > 0 {
+		tags["provenance_collector"] = c.collector
+	}
+}
+
+// AddNotificationMetric to the aggregator of the subscription or emit it directly
+func (c *CiscoTelemetryGNMI) addNotificationMetric(subscription *Subscription, notification *gnmi.Notification,
+	name string, fields map[string]interface{}, tags map[string]string, timestamp time.Time) {
+	// Never mix atomic notifications into aggregates
+	if subscription != nil && subscription.aggregator != nil && !notification.Atomic {
+		subscription.aggregator.add(name, fields, tags, timestamp)
+	} else if subscription != nil && subscription.Immediate {
+		c.addImmediateMetric(name, fields, tags, timestamp)
+	} else {
+		c.addMetric(name, fields, tags, timestamp)
+	}
+}
+
+// AddMetric to the accumulator and the Prometheus endpoint
+func (c *CiscoTelemetryGNMI) addMetric(name string, fields map[string]interface{}, tags map[string]string, timestamp ...time.Time) {
+	if c.reorder != nil && len(timestamp) > 0 {
+		c.reorder.Add(name, fields, tags, timestamp[0])
+	} else {
+		c.acc.AddFields(name, fields, tags, timestamp...)
+	}
+
+	if c.exporter != nil {
+		c.exporter.Update(name, fields, tags)
+	}
+}
+
+// AddImmediateMetric to the accumulator without holding it back in the reorder buffer
+func (c *CiscoTelemetryGNMI) addImmediateMetric(name string, fields map[string]interface{}, tags map[string]string, timestamp time.Time) {
+	c.acc.AddFields(name, fields, tags, timestamp)
+
+	if c.exporter != nil {
+		c.exporter.Update(name, fields, tags)
+	}
+}
+
+// DecodeNotification into measurement name, fields and tags
+func (c *CiscoTelemetryGNMI) decodeNotification(t *target, notification *gnmi.Notification, subscription *Subscription) (string, map[string]interface{}, map[string]string) {
+	tags := make(map[string]string, len(c.TargetTags)+len(t.tags))
+	for key, val := range c.TargetTags {
+		tags[key] = val
+	}
+	for key, val := range t.tags {
+		tags[key] = val
+	}
+
+	name := ""
+	if subscription != nil {
+		name = subscription.Name
+	}
+
+	decoder := gnmidecode.Decoder{Format: c.OutputFormat, ModulePrefixes: c.ModulePrefixes,
+		Producer: c.producer(t), Tags: tags, FoldKeys: c.FoldKeys, HashFoldedKeys: c.HashFoldedKeys,
+		KeyPolicy: c.KeyPolicy, TagKeys: c.TagKeys, FieldKeys: c.FieldKeys, LeafNameOnly: c.UseLeafNameOnly,
+		LeafNames: c.leafNames, LeafList: c.LeafList, LeafListSeparator: c.LeafListSeparator,
+		BinaryPolicy: c.BinaryPolicy, IETFNumbers: c.JSONIETFNumbers}
+	measurement, fields, tags, err := decoder.Decode(notification, name)
+	if err != nil {
+		atomic.AddUint64(&t.decodeErrors, 1)
+		c.acc.AddError(fmt.Errorf("W! GNMI JSON data is invalid: %v", err))
+	}
+	return measurement, fields, tags
+}
+
+// FlushAggregates of a subscription once per aggregation period
+func (c *CiscoTelemetryGNMI) flushAggregates(subscription *Subscription) {
+	ticker := time.NewTicker(subscription.AggregatePeriod.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			subscription.aggregator.flush(c.addMetric)
+			c.trackGoroutine(-1)
+			c.wg.Done()
+			return
+		case <-ticker.C:
+			subscription.aggregator.flush(c.addMetric)
+		}
+	}
+}
+
+// PathNames returns the slash-separated element names of paths without keys
+func pathNames(paths ...*gnmi.Path) string {
+	var builder bytes.Buffer
+	for _, path := range paths {
+		for _, elem := range gnmidecode.PathElems(path) {
+			builder.WriteRune('/')
+			builder.WriteString(elem.Name)
+		}
+	}
+	return builder.String()
+}
+
+// Subscription by the element names of its path including the prefix
+type subscriptionPath struct {
+	subscription *Subscription
+	names        string
+}
+
+// IndexSubscriptions parses the paths of all subscriptions for looking them up, the caller must
+// hold the mutex once the plugin is started
+func (c *CiscoTelemetryGNMI) indexSubscriptions() {
+	prefix := gnmidecode.ParsePath("", c.Prefix, "")
+	paths := make([]subscriptionPath, 0, len(c.Subscriptions)+len(c.Gets)+len(c.runtimeSubscriptions))
+	add := func(subscription *Subscription) {
+		names := pathNames(prefix, gnmidecode.ParsePath("", subscription.Path, ""))
+		paths = append(paths, subscriptionPath{subscription: subscription, names: names})
+	}
+
+	for i := range c.Subscriptions {
+		add(&c.Subscriptions[i])
+	}
+	for i := range c.Gets {
+		add(&c.Gets[i])
+	}
+	for _, subscription := range c.runtimeSubscriptions {
+		add(subscription)
+	}
+	c.subscriptionPaths.Store(paths)
+}
+
+// LookupSubscription returns the configured subscription with the longest path matching an update
+func (c *CiscoTelemetryGNMI) lookupSubscription(prefix *gnmi.Path, path *gnmi.Path) *Subscription {
+	origin := prefix.GetOrigin()
+	if len(path.GetOrigin()) > 0 {
+		origin = path.Origin
+	}
+	name := pathNames(prefix, path)
+
+	paths, ok := c.subscriptionPaths.Load().([]subscriptionPath)
+	if !ok {
+		c.mutex.Lock()
+		c.indexSubscriptions()
+		c.mutex.Unlock()
+		paths = c.subscriptionPaths.Load().([]subscriptionPath)
+	}
+
+	var match *Subscription
+	length := -1
+	for _, p := range paths {
+		if len(p.subscription.Origin) > 0 && len(origin) > 0 && p.subscription.Origin != origin {
+			continue
+		}
+		if len(p.names) > length && (name == p.names || strings.HasPrefix(name, p.names+"/")) {
+			match, length = p.subscription, len(p.names)
+		}
+	}
+
+	return match
+}
+
+// MeasurementName of a notification renamed by its subscription or by the alias of its path
+func (c *CiscoTelemetryGNMI) measurementName(name string, subscription *Subscription) string {
+	if subscription != nil && len(subscription.alias) > 0 {
+		return subscription.alias
+	}
+	if subscription != nil && len(subscription.Name) > 0 {
+		return subscription.Name
+	}
+
+	// Aliases may be given with or without origin
+	if alias, ok := c.aliases[name]; ok {
+		return alias
+	}
+	origin, path := "", name
+	if i := strings.Index(name, ":/"); i > 0 {
+		origin, path = name[:i], name[i+1:]
+		if alias, ok := c.aliases[path]; ok {
+			return alias
+		}
+	}
+
+	// Otherwise the most specific pattern matching the path wins
+	if len(c.aliasPatterns) > 0 {
+		elements := pathElements(path)
+		for _, pattern := range c.aliasPatterns {
+			if pattern.matches(origin, elements) {
+				return pattern.alias
 			}
 		}
 	}
+	return name
+}
+
+// AliasPath normalizes the path of an alias to the form of measurement names, i.e. with
+// leading slash and without keys, e.g. "openconfig-interfaces:/interfaces/interface"
+func aliasPath(path string) string {
+	origin := ""
+	if i := strings.IndexRune(path, ':'); i > 0 && !strings.ContainsRune(path[:i], '/') {
+		origin, path = path[:i]+":", path[i+1:]
+	}
+
+	name := pathNames(gnmidecode.ParsePath("", path, ""))
+	if len(name) == 0 {
+		name = "/"
+	}
+	return origin + name
+}
+
+// RenderTemplate with target tags accessible by their name or title-cased name, e.g. {{ .Site }}
+func renderTemplate(text string, tags map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	data := make(map[string]string, 2*len(tags))
+	for key, val := range tags {
+		data[strings.Title(key)] = val
+		data[key] = val
+	}
+
+	var builder bytes.Buffer
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", err
+	}
+
+	return builder.String(), nil
+}
 
-	return &gnmiPath
+// AlignTimestamp rounds a timestamp down to a multiple of the given interval
+func alignTimestamp(timestamp time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		return timestamp
+	}
+	nanos := timestamp.UnixNano()
+	return time.Unix(0, nanos-nanos%int64(interval))
 }
 
 // Stop listener and cleanup
 func (c *CiscoTelemetryGNMI) Stop() {
+	c.stopRoutines()
+
+	if c.reorder != nil {
+		c.reorder.Flush()
+	}
+	if c.wal != nil {
+		c.wal.Close()
+	}
+
+	for _, t := range c.targets {
+		t.client.Close()
+	}
+
+	log.Println("I! Stopped GNMI service on ", strings.Join(c.devices(), ", "))
+}
+
+// StopRoutines closes the servers of the plugin, cancels its routines and waits for them to return
+func (c *CiscoTelemetryGNMI) stopRoutines() {
+	if c.admin != nil {
+		c.admin.Close()
+	}
+	if c.exporter != nil {
+		c.exporter.Stop()
+	}
+
+	c.mutex.Lock()
 	c.cancel()
+	c.mutex.Unlock()
+	if c.tunnel != nil {
+		c.tunnel.Stop()
+	}
+	if c.self != nil {
+		c.self.Stop()
+	}
 	c.wg.Wait()
-
-	log.Println("I! Stopped GNMI service on ", c.ServiceAddress)
 }
 
 const sampleConfig = `
   ## Address and port of the GNMI GRPC server
   service_address = "10.49.234.114:57777"
+
+  ## addresses of further devices sharing all settings and subscriptions, each metric is tagged
+  ## by the address of its device as "Producer"
+  # addresses = ["10.49.234.115:57777", "10.49.234.116:57777"]
+
+  ## subscribe to each address the service address resolves to separately (e.g. for anycast
+  ## gateways), adding the resolved IP as "address" tag
+  # resolve_addresses = false
+
+  ## accept devices dialing out through gRPC tunnels (openconfig/grpctunnel) and subscribe to
+  ## the gNMI targets they register, tagged by target name as "Producer" and the address of
+  ## the device as "address"; the service address may be omitted if all devices dial out
+  # tunnel_address = ":57401"
+
+  ## enable server-side TLS of the tunnel listener and authenticate devices by client certificate
+  # tunnel_tls_cert = "/etc/telegraf/cert.pem"
+  # tunnel_tls_key = "/etc/telegraf/key.pem"
+  # tunnel_tls_allowed_cacerts = ["/etc/telegraf/clientca.pem"]
   
-  ## define credentials
+  ## define credentials, the username may be a template referencing target tags, both may
+  ## reference a secret as "vault:<path>#<key>", "aws-kms:<base64 ciphertext>", "env:<variable>"
+  ## or "file:<path>"
   username = "cisco"
   password = "cisco"
-
-  ## redial in case of failures after
+  # username = "{{ .Site }}-telemetry"
+
+  ## obtain credentials before each (re)dial instead (one of: "static" for the above, "file"
+  ## or "exec" reading a JSON object of metadata, e.g. {"username": "u", "password": "p"},
+  ## "oauth2" for bearer tokens of the client credentials flow)
+  # auth_provider = "static"
+  # auth_file = "/etc/telegraf/gnmi-credentials.json"
+  # auth_command = ["/usr/local/bin/fetch-tacacs-credentials", "--device", "router1"]
+  # oauth2_token_url = "https://auth.example.com/oauth2/token"
+  # oauth2_client_id = "telegraf"
+  # oauth2_client_secret = "secret"
+  # oauth2_scopes = ["telemetry"]
+
+  ## subscribe to the devices of an inventory in addition to the configured addresses, adding
+  ## and removing targets as devices are added to or removed from it (one of: "netbox")
+  # inventory_provider = "netbox"
+  # inventory_interval = "5m"
+  ## port of the gNMI service of the devices of the inventory
+  # inventory_port = 57400
+  ## NetBox API, token (may reference a secret as "vault:<path>#<key>" or "env:<variable>") and device filters
+  # netbox_url = "https://netbox.example.com"
+  # netbox_token = "0123456789abcdef0123456789abcdef01234567"
+  # netbox_filters = {role = "router", status = "active"}
+
+  ## vendor of the device enabling workarounds for its gNMI implementation (one of: "cisco",
+  ## "arista", "juniper", "nokia")
+  # vendor = "cisco"
+
+  ## encoding of the data (one of: "proto", "json", "json_ietf", "bytes", "ascii", or "auto" for
+  ## the most preferred of "proto", "json_ietf" and "json" supported by the device according to
+  ## its capabilities), followed by encodings to fall back to if the device does not implement it
+  # encoding = "proto"
+  # encoding_fallback = ["json_ietf", "json"]
+
+  ## tags describing the target added to all metrics
+  # [inputs.cisco_telemetry_gnmi.target_tags]
+  #   site = "ams1"
+
+  ## redial a subscription in case of failures after, other subscriptions are not affected
   redial = "10s"
 
+  ## back off exponentially with jitter from redial up to redial_max while the subscription keeps
+  ## failing, starting over once it stayed established for redial_reset_after
+  # redial_max = "5m"
+  # redial_reset_after = "1m"
+
+  ## redial after TLS handshake failures due to expired or not yet valid device
+  ## certificates, e.g. after PKI incidents (defaults to redial)
+  # redial_tls_expired = "5m"
+
+  ## wait for the connection to become ready instead of failing RPCs while it is down, e.g.
+  ## during route processor switchovers
+  # wait_for_ready = false
+
+  ## transparently retry RPCs failing with UNAVAILABLE before the device responds, with
+  ## exponential backoff between the initial and maximum backoff (1 to disable)
+  # retry_max_attempts = 1
+  # retry_initial_backoff = "1s"
+  # retry_max_backoff = "5s"
+
+  ## send keepalive pings after keepalive_time without activity and close the connection if
+  ## not acknowledged within keepalive_timeout (default 20s), also without active subscriptions
+  ## if permitted; devices may close connections pinging more often than they allow
+  # keepalive_time = "30s"
+  # keepalive_timeout = "10s"
+  # keepalive_permit_without_stream = false
+
+  ## backfill mode: instead of streaming live data, request the data of the subscriptions
+  ## within a past time range (RFC 3339, end defaults to now) once using the gNMI history
+  ## extension, e.g. to fill gaps after collector outages
+  # backfill_start = "2019-03-12T10:00:00Z"
+  # backfill_end = "2019-03-12T12:00:00Z"
+
+  ## emit supported models of the device as "gnmi_models" measurement every interval
+  # capabilities_interval = "1h"
+
+  ## emit gRPC connectivity state changes as "gnmi_connection" measurement
+  # connection_events = false
+
+  ## emit the duration and the last error of outages of targets as "gnmi_outage" measurement
+  ## once they recover, e.g. for availability SLOs
+  # outage_events = false
+
+  ## emit new fields or changed field types of measurements as "gnmi_schema" measurement
+  # schema_events = false
+
+  ## emit the health of each target as "gnmi_health" measurement every interval, e.g. for
+  ## "telegraf --test" and health checks
+  # health_metrics = false
+
+  ## emit updates received, errors and age of the last update of each subscribed path on each
+  ## target as "gnmi_subscription" measurement every interval
+  # subscription_metrics = false
+
+  ## serve an HTTP endpoint to list, add and remove subscriptions at runtime, e.g. to
+  ## temporarily enable debug sensors (runtime subscriptions are not persisted), and to
+  ## retrieve statistics of all targets
+  # admin_address = "127.0.0.1:57400"
+
+  ## serve the statistics of all targets as read-only gNMI target, e.g. for monitoring the
+  ## collector with gNMI tooling (unauthenticated, listen on a local address only)
+  # self_address = "127.0.0.1:57401"
+
+  ## track when each series (path and keys) of each target was first and last seen, listed by
+  ## the admin endpoint to find series which silently stopped updating
+  # track_series = false
+
+  ## maximum number of new series (path and keys) accepted per target and gather interval,
+  ## rejecting data of further new series as a guardrail against cardinality explosions
+  # max_new_series = 0
+
+  ## load additional subscriptions from YAML files matching the pattern, each with its own
+  ## origin, mode, interval and aliases, reloaded on changes
+  # paths_file = "/etc/telegraf/gnmi-paths.d/*.yaml"
+
+  ## serve the last numeric values on a Prometheus /metrics endpoint, dropping values not
+  ## updated within the expiration
+  # prometheus_address = ":9273"
+  # prometheus_expiration = "10m"
+
+  ## hold back telemetry data for up to the given latency to emit bursts in non-decreasing
+  ## timestamp order, as required by some stream processors
+  # reorder_latency = "0s"
+
+  ## persist metrics in a write-ahead log in the given directory before handing them to the
+  ## outputs, replaying metrics not yet delivered after a crash or restart
+  # wal_directory = "/var/lib/telegraf/wal/gnmi"
+
+  ## export goroutine and open connection counts as internal statistics
+  # resource_accounting = false
+
+  ## maximum number of notifications decoded concurrently across all targets, by default the
+  ## CPUs available to the container according to its cgroup CPU limit (-1 for unlimited)
+  # decode_workers = 0
+
+  ## soft cap of the bandwidth received from each target in bytes per second, enforced by
+  ## widening the sample intervals of the target while the cap is exceeded
+  # max_bandwidth = "1MB"
+
   ## enable client-side TLS and define CA to authenticate the device
   # tls = true
   # tls_ca = "/etc/telegraf/ca.pem"
@@ -368,12 +1739,107 @@ const sampleConfig = `
   ## define client-side TLS certificate & key to authenticate to the device
   # tls_cert = "/etc/telegraf/cert.pem"
   # tls_key = "/etc/telegraf/key.pem"
+  # tls_key_passphrase = "env:TELEGRAF_TLS_KEY_PASSPHRASE"
+
+  ## YAML file of named TLS profiles shared with other plugins, e.g. for a common internal CA, and
+  ## the profile enabling TLS for the devices; the settings above override those of the profile
+  # tls_profiles = "/etc/telegraf/tls_profiles.yaml"
+  # tls_profile = "internal-ca"
+
+  ## maximum number of concurrent TLS handshakes, bounding the CPU spent on reconnecting many
+  ## targets at once, e.g. after network outages (0 for unlimited)
+  # max_tls_handshakes = 0
+
+  ## naming of measurements, fields and tags (one of: "telegraf", "gnmic")
+  # output_format = "telegraf"
+
+  ## measurement names of data received for paths and their descendants, given with or without
+  ## origin, instead of the path of the notification prefix ("*" matches any element and "**"
+  ## any number of elements, the most specific alias wins)
+  # [inputs.cisco_telemetry_gnmi.aliases]
+  #   "openconfig-interfaces:/interfaces/interface/state/counters" = "ifcounters"
+  #   "/interfaces/**" = "interfaces"
+
+  ## add the complete XPath including keys as "path" tag
+  # include_path_tag = false
+
+  ## handling of RFC 7951 module prefixes of JSON keys, e.g. "openconfig-interfaces:state" (one
+  ## of: "strip" to remove all prefixes, "keep" to prefix every key by its module, unset to keep
+  ## keys as received)
+  # module_prefixes = "strip"
+
+  ## list keys folded into field names instead of tags, e.g. "route[prefix=10.0.0.0/8]/metric",
+  ## for keys too high in cardinality to be indexed; folded values may be hashed to shorten names
+  # fold_keys = ["prefix"]
+  # hash_folded_keys = false
+
+  ## emit the other list keys as tags ("keys_as_tags"), or keys with integer values such as
+  ## queue IDs or SNMP indices as integer fields ("numeric_keys_as_fields"); keys listed in
+  ## tag_keys or field_keys are emitted as tags or fields regardless of the policy
+  # key_policy = "keys_as_tags"
+  # tag_keys = ["name"]
+  # field_keys = ["queue-id"]
+
+  ## name fields by the last element of their path only, e.g. "in-octets" instead of
+  ## "state/counters/in-octets", keeping the full names of fields whose leaf name is claimed by
+  ## a field of another path first
+  # use_leaf_name_only = false
+
+  ## emit leaf-lists, e.g. BGP communities, as one field per element suffixed by its index
+  ## ("indexed") or as a single string field of the elements joined by leaf_list_separator ("joined")
+  # leaf_list = "indexed"
+  # leaf_list_separator = ","
+
+  ## emit bytes and Any values as "base64" or "hex" string, as their "length" in bytes, or "drop" them
+  # binary_policy = "base64"
+
+  ## convert the numeric strings RFC 7951 uses for 64-bit integers and decimal64 values of
+  ## json_ietf data into numbers for the leaves matching these name patterns, e.g. counters
+  # json_ietf_numbers = ["*-octets", "*-pkts", "*-errors", "*-discards"]
+
+  ## handling of paths with empty elements or trailing slashes sent by some targets: "normalize"
+  ## them by removing empty elements, "keep" them as received, or "drop" their notifications
+  # malformed_paths = "normalize"
+
+  ## emit a tombstone metric with the marker field set to true for each path deleted by the target,
+  ## e.g. removed interfaces or BGP neighbors, so consumers can expire their series
+  # delete_events = false
+  # delete_marker = "_deleted"
+
+  ## normalize tag values by tag name, or "*" for all tags, with sanitizers applied in order
+  ## (any of: "lowercase", "uppercase", "trim_space", "replace_spaces", "trim_domain")
+  # [inputs.cisco_telemetry_gnmi.tag_sanitizers]
+  #   "*" = ["trim_space"]
+  #   Producer = ["trim_domain", "lowercase"]
+
+  ## merge the updates of a notification sharing the same tags into one measurement, disable
+  ## to emit exactly one field per measurement
+  # merge_updates = true
+
+  ## record the encoding, subscription and collector hostname of each measurement as
+  ## "provenance_*" tags and its reception time as "provenance_received" field
+  # provenance = false
+
+  ## unit of the timestamps of the targets (one of: "auto", "ns", "us", "ms", "s"), "auto" detects
+  ## targets sending milliseconds or microseconds instead of nanoseconds by their magnitude
+  # timestamp_unit = "auto"
+
+  ## maximum time the canary of a target may stay silent before it is reported as not alive,
+  ## by default three sample intervals of the canary
+  # canary_timeout = "30s"
 
   [[inputs.cisco_telemetry_gnmi.subscription]]
+	## name of the subscription, used as measurement name of its data instead of the path
+	# name = "ifcounters"
+
 	origin = "Cisco-IOS-XR-infra-statsd-oper"
 	path = "infra-statistics/interfaces/interface/latest/generic-counters"
 
-	# Subscription mode (one of: "target_defined", "sample", "on_change") and interval
+	# Subscription mode (one of: "target_defined", "sample", "on_change", "poll") and interval, sub-second
+	# intervals may be given in milliseconds (e.g. "100ms") or as fraction of seconds (e.g. 0.1)
+	# On-change subscriptions, e.g. of oper-status, emit the full initial state once the device
+	# signals sync, and changes only afterwards
+	# Poll subscriptions are polled on their stream every gather interval instead
 	subscription_mode = "sample"
 	sample_interval = "10s"
 
@@ -382,6 +1848,72 @@ const sampleConfig = `
 
 	## If suppression is enabled, send updates at least every X seconds anyway
 	# heartbeat_interval = "60s"
+
+	## In on_change mode, periodically Get the state of the path and emit values the
+	## subscription missed, e.g. changes silently dropped by the device
+	# reconcile_interval = "10m"
+
+	## Round timestamps down to the sample interval boundary to align samples across devices
+	# align_timestamps = false
+
+	## Aggregate samples of fast sensors and emit statistics once per period instead
+	## (any of: "min", "max", "mean", "last")
+	# aggregate = ["min", "max", "mean", "last"]
+	# aggregate_period = "10s"
+
+	## Push data of event-like paths, e.g. "on_change", to the accumulator as soon as received
+	## instead of holding it back for reordering (not combinable with aggregate)
+	# immediate = false
+
+	## Convert fields reported in non-base units by field name (any of: "kbps_to_bps",
+	## "mbps_to_bps", "bytes_to_bits", "load_to_percent", "hundredths", "thousandths")
+	# [inputs.cisco_telemetry_gnmi.subscription.transforms]
+	#   input-data-rate = "kbps_to_bps"
+	#   input-load = "load_to_percent"
+
+	## merge measurements with subscriptions of the same name but another origin, e.g. XR native
+	## and OpenConfig interface counters, renaming tags of list keys to those of the other origin
+	# [inputs.cisco_telemetry_gnmi.subscription.merge_keys]
+	#   interface-name = "name"
+
+  ## set values on the device every gather interval before reading, e.g. to start on-demand
+  ## diagnostics (value is JSON encoded, requests not processed by the device are retried)
+  # [[inputs.cisco_telemetry_gnmi.trigger]]
+  #   origin = "Cisco-IOS-XR-ethernet-cfm-oper"
+  #   path = "cfm/global/run-diagnostics"
+  #   value = "true"
+  #   retries = 2
+  #   min_interval = "5m"
+
+  ## poll paths with a Get request every gather interval instead of subscribing to them, e.g.
+  ## rarely changing inventory leaves; name, transforms and merge_keys apply as to subscriptions
+  # [[inputs.cisco_telemetry_gnmi.get]]
+  #   name = "platform"
+  #   origin = "openconfig"
+  #   path = "/components/component/state"
+
+  ## subscribe to a cheap path on every target as liveness canary, independent of the data
+  ## subscriptions; its data is not emitted, only its liveness as "gnmi_canary" every interval
+  # [inputs.cisco_telemetry_gnmi.canary]
+  #   origin = "openconfig-system"
+  #   path = "/system/state/boot-time"
+  #   sample_interval = "10s"
+
+  ## override the credentials, TLS settings and timestamp unit for the device with the address,
+  ## e.g. lab routers not sharing the credentials of production ones; settings not given are
+  ## those above
+  # [[inputs.cisco_telemetry_gnmi.device]]
+  #   address = "10.49.234.115:57777"
+  #   username = "lab"
+  #   password = "env:LAB_GNMI_PASSWORD"
+  #   tls = true
+  #   tls_ca = "/etc/telegraf/lab-ca.pem"
+  #   tls_cert = "/etc/telegraf/lab-cert.pem"
+  #   tls_key = "/etc/telegraf/lab-key.pem"
+  #   tls_key_passphrase = "env:LAB_TLS_KEY_PASSPHRASE"
+  #   insecure_skip_verify = false
+  #   tls_profile = "lab-ca"
+  #   timestamp_unit = "ms"
 `
 
 // SampleConfig of plugin
@@ -394,8 +1926,31 @@ func (c *CiscoTelemetryGNMI) Description() string {
 	return "Cisco GNMI telemetry input plugin based on GNMI telemetry data produced in IOS XR"
 }
 
-// Gather plugin measurements (unused)
-func (c *CiscoTelemetryGNMI) Gather(_ telegraf.Accumulator) error {
+// Gather runs the configured triggers on all targets and emits their health if enabled
+func (c *CiscoTelemetryGNMI) Gather(acc telegraf.Accumulator) error {
+	c.mutex.Lock()
+	targets := append([]*target(nil), c.targets...)
+	c.mutex.Unlock()
+
+	for _, t := range targets {
+		c.runTriggers(t)
+	}
+	if len(c.Gets) > 0 {
+		c.pollTargets(targets)
+	}
+	c.pollStreams(targets)
+	c.nextSeriesWindows(targets)
+
+	now := time.Now()
+	if c.Canary != nil {
+		c.gatherCanaries(acc, targets, now)
+	}
+	if c.HealthMetrics {
+		c.gatherHealth(acc, now)
+	}
+	if c.SubscriptionMetrics {
+		c.gatherSubscriptions(acc, now)
+	}
 	return nil
 }
 
@@ -7,51 +7,93 @@ package cisco_telemetry_gnmi
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc/metadata"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	"github.com/influxdata/telegraf/plugins/common/inventory"
+	"github.com/influxdata/telegraf/plugins/common/reorder"
+	"github.com/influxdata/telegraf/plugins/common/rpcerror"
+	"github.com/influxdata/telegraf/plugins/common/schema"
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_gnmi/grpctunnel"
 	"github.com/influxdata/telegraf/testutil"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
 
 	"github.com/openconfig/gnmi/proto/gnmi"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestParsePath(t *testing.T) {
-	path := "/foo/bar/bla[shoo=woo][shoop=/woop/]/z"
-	parsed := parsePath("theorigin", path, "thetarget")
-
-	assert.Equal(t, parsed.Origin, "theorigin")
-	assert.Equal(t, parsed.Target, "thetarget")
-	assert.Equal(t, parsed.Element, []string{"foo", "bar", "bla[shoo=woo][shoop=/woop/]", "z"})
-	assert.Equal(t, parsed.Elem, []*gnmi.PathElem{{Name: "foo"}, {Name: "bar"},
-		{Name: "bla", Key: map[string]string{"shoo": "woo", "shoop": "/woop/"}}, {Name: "z"}})
-
-	parsed = parsePath("", "", "")
-	assert.Equal(t, *parsed, gnmi.Path{})
-}
-
 type mockGNMIServer struct {
 	t        *testing.T
 	scenario int
+	sets     []*gnmi.SetRequest
+
+	// History extensions received in backfill mode
+	extensions chan []byte
+
+	// Releases the sync response of on-change subscriptions
+	sync chan struct{}
+
+	// Encodings of the subscriptions received in the encoding scenario
+	encodings []gnmi.Encoding
 }
 
 func (m *mockGNMIServer) Capabilities(context.Context, *gnmi.CapabilityRequest) (*gnmi.CapabilityResponse, error) {
+	if m.scenario == 6 {
+		return &gnmi.CapabilityResponse{SupportedEncodings: []gnmi.Encoding{gnmi.Encoding_JSON_IETF, gnmi.Encoding_ASCII},
+			SupportedModels: []*gnmi.ModelData{{Name: "openconfig-interfaces"}}}, nil
+	}
 	return nil, nil
 }
 
-func (m *mockGNMIServer) Get(context.Context, *gnmi.GetRequest) (*gnmi.GetResponse, error) {
+func (m *mockGNMIServer) Get(_ context.Context, request *gnmi.GetRequest) (*gnmi.GetResponse, error) {
+	if m.scenario == 10 {
+		if len(request.Path) != 1 || request.Path[0].Elem[0].Name != "model" || request.Encoding != gnmi.Encoding_PROTO {
+			return nil, status.Error(codes.InvalidArgument, "unexpected request")
+		}
+		return &gnmi.GetResponse{Notification: []*gnmi.Notification{mockGNMINotification()}}, nil
+	}
+	if m.scenario == 9 {
+		if request.Encoding != gnmi.Encoding_JSON_IETF {
+			return nil, status.Error(codes.InvalidArgument, "unexpected encoding")
+		}
+
+		// The device missed sending the change of the first update and answers with JSON values
+		notification := mockGNMINotification()
+		notification.Update[0].Val = &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`"9999"`)}}
+		notification.Update[1] = &gnmi.Update{Path: &gnmi.Path{},
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"type:other":{"path":"foobar"}}`)}}}
+		return &gnmi.GetResponse{Notification: []*gnmi.Notification{notification}}, nil
+	}
 	return nil, nil
 }
 
-func (m *mockGNMIServer) Set(context.Context, *gnmi.SetRequest) (*gnmi.SetResponse, error) {
-	return nil, nil
+func (m *mockGNMIServer) Set(_ context.Context, request *gnmi.SetRequest) (*gnmi.SetResponse, error) {
+	m.sets = append(m.sets, request)
+	if m.scenario == 4 && len(m.sets) == 1 {
+		return nil, status.Error(codes.Unavailable, "busy")
+	}
+	return &gnmi.SetResponse{}, nil
 }
 
 func (m *mockGNMIServer) Subscribe(server gnmi.GNMI_SubscribeServer) error {
@@ -81,6 +123,89 @@ func (m *mockGNMIServer) Subscribe(server gnmi.GNMI_SubscribeServer) error {
 		notification.Update[0].Val = &gnmi.TypedValue{Value: &gnmi.TypedValue_BoolVal{BoolVal: false}}
 		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
 		return nil
+	case 5:
+		request, err := server.Recv()
+		if err != nil {
+			return err
+		}
+		if request.GetSubscribe().Subscription[0].Path.Elem[0].Name == "broken" {
+			return fmt.Errorf("unsupported path")
+		}
+		notification := mockGNMINotification()
+		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+		<-server.Context().Done()
+		return nil
+	case 6:
+		request, err := server.Recv()
+		if err != nil {
+			return err
+		}
+		m.encodings = append(m.encodings, request.GetSubscribe().Encoding)
+		if request.GetSubscribe().Encoding != gnmi.Encoding_JSON_IETF {
+			return status.Error(codes.Unimplemented, "unsupported encoding")
+		}
+		notification := mockGNMINotification()
+		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+		<-server.Context().Done()
+		return nil
+	case 7:
+		request, err := server.Recv()
+		if err != nil {
+			return err
+		}
+		if len(request.Extension) != 1 {
+			return status.Error(codes.InvalidArgument, "history extension missing")
+		}
+		m.extensions <- request.Extension[0].XXX_unrecognized
+		notification := mockGNMINotification()
+		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}})
+		<-server.Context().Done()
+		return nil
+	case 8:
+		request, err := server.Recv()
+		if err != nil {
+			return err
+		}
+		if request.GetSubscribe().Subscription[0].Mode != gnmi.SubscriptionMode_ON_CHANGE {
+			return status.Error(codes.InvalidArgument, "on-change mode expected")
+		}
+		notification := mockGNMINotification()
+		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+		<-m.sync
+		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}})
+		notification.Update[0].Path.Elem[1].Key["name"] = "str2"
+		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+		<-server.Context().Done()
+		return nil
+	case 10:
+		<-server.Context().Done()
+		return nil
+	case 11:
+		request, err := server.Recv()
+		if err != nil {
+			return err
+		}
+		if request.GetSubscribe().Mode != gnmi.SubscriptionList_POLL {
+			return status.Error(codes.InvalidArgument, "poll mode expected")
+		}
+		for {
+			request, err = server.Recv()
+			if err != nil {
+				return err
+			}
+			if request.GetPoll() == nil {
+				return status.Error(codes.InvalidArgument, "poll expected")
+			}
+			server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: mockGNMINotification()}})
+			server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}})
+		}
+	case 9:
+		notification := mockGNMINotification()
+		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+		server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}})
+		<-server.Context().Done()
+		return nil
 	default:
 		return fmt.Errorf("test not implemented ;)")
 	}
@@ -105,7 +230,7 @@ func TestGNMIError(t *testing.T) {
 	server.Stop()
 	c.Stop()
 
-	assert.Equal(t, acc.Errors, []error{errors.New("E! GNMI subscription aborted: rpc error: code = Unknown desc = testerror")})
+	assert.Equal(t, acc.Errors, []error{errors.New("E! GNMI subscription aborted: Unknown: testerror")})
 }
 
 func mockGNMINotification() *gnmi.Notification {
@@ -215,3 +340,1615 @@ func TestGNMIMultipleRedial(t *testing.T) {
 	fields = map[string]interface{}{"some/path": false, "other/path": "foobar"}
 	acc.AssertContainsTaggedFields(t, "type:/model", fields, tags)
 }
+
+func TestAlignTimestamp(t *testing.T) {
+	timestamp := time.Unix(1543236577, 300000000)
+	assert.Equal(t, time.Unix(1543236570, 0), alignTimestamp(timestamp, 10*time.Second))
+	assert.Equal(t, time.Unix(1543236577, 0), alignTimestamp(timestamp, time.Second))
+	assert.Equal(t, timestamp, alignTimestamp(timestamp, 0))
+	assert.Equal(t, time.Unix(1543236577, 300000000), alignTimestamp(timestamp.Add(50*time.Millisecond), 100*time.Millisecond))
+}
+
+func TestNotificationTime(t *testing.T) {
+	c := &CiscoTelemetryGNMI{}
+	expected := time.Unix(1543236577, 300000000)
+
+	detected := &target{address: "127.0.0.1:57000"}
+	for _, timestamp := range []int64{1543236577300000000, 1543236577300000, 1543236577300} {
+		assert.Equal(t, expected, c.notificationTime(detected, timestamp))
+	}
+	assert.Equal(t, time.Unix(1543236577, 0), c.notificationTime(detected, 1543236577))
+	assert.Equal(t, int32(1), detected.timestampScaled)
+
+	// Configured units apply to timestamps of any magnitude
+	scale, err := timestampScale("ms")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Unix(0, 5000000), c.notificationTime(&target{timestampScale: scale}, 5))
+
+	_, err = timestampScale("minutes")
+	assert.EqualError(t, err, "invalid timestamp unit: minutes")
+}
+
+func TestSubSecondInterval(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		`"100ms"`: 100 * time.Millisecond,
+		`"1.5s"`:  1500 * time.Millisecond,
+		`0.1`:     100 * time.Millisecond,
+		`0.25`:    250 * time.Millisecond,
+		`10`:      10 * time.Second,
+	} {
+		var interval Interval
+		assert.Nil(t, interval.UnmarshalTOML([]byte(value)), value)
+		assert.Equal(t, expected, interval.Duration, value)
+	}
+
+	var interval Interval
+	assert.NotNil(t, interval.UnmarshalTOML([]byte(`"-1s"`)))
+	assert.NotNil(t, interval.UnmarshalTOML([]byte(`"fast"`)))
+
+	c := &CiscoTelemetryGNMI{}
+	request := c.subscribeRequest("proto", &Subscription{SampleInterval: Interval{Duration: 100 * time.Millisecond}})
+	assert.Equal(t, uint64(100000000), request.GetSubscribe().Subscription[0].SampleInterval)
+}
+
+func TestVendorQuirks(t *testing.T) {
+	c := &CiscoTelemetryGNMI{Vendor: "Arista", Encoding: "proto"}
+	assert.Nil(t, c.setupVendor())
+	assert.Equal(t, []string{"proto", "json", "json_ietf"}, c.encodings)
+
+	path := c.requestPath("", "/interfaces/interface[name=Ethernet1]", "")
+	assert.Equal(t, "openconfig", path.Origin)
+	assert.Nil(t, path.Element)
+	assert.Len(t, path.Elem, 2)
+
+	c = &CiscoTelemetryGNMI{Vendor: "nokia", Encoding: "json", EncodingFallback: []string{"bytes"}}
+	assert.Nil(t, c.setupVendor())
+	assert.Equal(t, []string{"json", "bytes"}, c.encodings)
+	assert.Equal(t, "", c.requestPath("", "/state/port", "").Origin)
+
+	c = &CiscoTelemetryGNMI{Encoding: "proto"}
+	assert.Nil(t, c.setupVendor())
+	assert.Equal(t, []string{"proto"}, c.encodings)
+	assert.Len(t, c.requestPath("", "/interfaces/interface", "").Element, 2)
+
+	c = &CiscoTelemetryGNMI{Vendor: "huawei"}
+	assert.NotNil(t, c.setupVendor())
+}
+
+func TestModulePrefixes(t *testing.T) {
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005", ModulePrefixes: "strip"}
+	notification := &gnmi.Notification{
+		Prefix: gnmidecode.ParsePath("", "/openconfig-interfaces:interfaces/interface[name=Gi0/0/0/0]", ""),
+		Update: []*gnmi.Update{{
+			Path: gnmidecode.ParsePath("", "state", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"type": "iana-if-type:ethernetCsmacd",
+				"counters": {"in-octets": 5}, "openconfig-if-ethernet:ethernet": {"in-crc-errors": 1}}`)}},
+		}},
+	}
+
+	_, fields, _ := c.decodeNotification(&target{}, notification, nil)
+	assert.Equal(t, map[string]interface{}{"state_type": "iana-if-type:ethernetCsmacd",
+		"state_counters_in-octets": float64(5), "state_ethernet_in-crc-errors": float64(1)}, fields)
+
+	c.ModulePrefixes = "keep"
+	_, fields, _ = c.decodeNotification(&target{}, notification, nil)
+	assert.Equal(t, map[string]interface{}{"state_openconfig-interfaces:type": "iana-if-type:ethernetCsmacd",
+		"state_openconfig-interfaces:counters_openconfig-interfaces:in-octets":       float64(5),
+		"state_openconfig-if-ethernet:ethernet_openconfig-if-ethernet:in-crc-errors": float64(1)}, fields)
+}
+
+func TestImmediateSubscription(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	c := &CiscoTelemetryGNMI{acc: acc, reorder: reorder.New(time.Hour, acc.AddFields)}
+	fields := map[string]interface{}{"oper-status": "DOWN"}
+	tags := map[string]string{"name": "Gi0/0/0/0"}
+
+	c.addNotificationMetric(&Subscription{}, &gnmi.Notification{}, "counters", fields, tags, time.Now())
+	assert.Len(t, acc.Metrics, 0)
+
+	c.addNotificationMetric(&Subscription{Immediate: true}, &gnmi.Notification{}, "events", fields, tags, time.Now())
+	acc.AssertContainsTaggedFields(t, "events", fields, tags)
+	assert.Len(t, acc.Metrics, 1)
+
+	c.reorder.Flush()
+	acc.AssertContainsTaggedFields(t, "counters", fields, tags)
+}
+
+func TestLookupSubscription(t *testing.T) {
+	c := &CiscoTelemetryGNMI{Subscriptions: []Subscription{
+		{Origin: "type", Path: "/model"},
+		{Origin: "type", Path: "/model/some"},
+		{Origin: "other", Path: "/model/some/path"},
+	}}
+
+	prefix := gnmidecode.ParsePath("type", "/model[foo=bar]", "")
+	assert.Equal(t, &c.Subscriptions[1], c.lookupSubscription(prefix, gnmidecode.ParsePath("", "some/path[name=str]", "")))
+	assert.Equal(t, &c.Subscriptions[0], c.lookupSubscription(prefix, gnmidecode.ParsePath("", "other/path", "")))
+	assert.Nil(t, c.lookupSubscription(gnmidecode.ParsePath("type", "/unknown", ""), gnmidecode.ParsePath("", "path", "")))
+
+	// Subscriptions added at runtime are found once indexed
+	runtime := &Subscription{Origin: "type", Path: "/unknown"}
+	c.runtimeSubscriptions = append(c.runtimeSubscriptions, runtime)
+	c.indexSubscriptions()
+	assert.Equal(t, runtime, c.lookupSubscription(gnmidecode.ParsePath("type", "/unknown", ""), gnmidecode.ParsePath("", "path", "")))
+}
+
+func TestMeasurementName(t *testing.T) {
+	c := &CiscoTelemetryGNMI{Aliases: map[string]string{
+		"openconfig-interfaces:interfaces/interface[name=*]/state/counters": "ifcounters",
+		"/model":                  "model",
+		"/interfaces/**":          "interfaces",
+		"/interfaces/*/state":     "ifstate",
+		"/system/*-stats/**/cpu*": "cpu",
+	}}
+	c.setupAliases()
+
+	assert.Equal(t, "ifcounters", c.measurementName("openconfig-interfaces:/interfaces/interface/state/counters", nil))
+	assert.Equal(t, "model", c.measurementName("type:/model", nil))
+	assert.Equal(t, "type:/other", c.measurementName("type:/other", nil))
+	assert.Equal(t, "sub1", c.measurementName("type:/model", &Subscription{Name: "sub1"}))
+	assert.Equal(t, "file", c.measurementName("type:/model", &Subscription{Name: "sub1", alias: "file"}))
+
+	// Paths inherit the aliases of their ancestors and globs, the most specific one winning
+	assert.Equal(t, "model", c.measurementName("type:/model/sub/path", nil))
+	assert.Equal(t, "interfaces", c.measurementName("openconfig-interfaces:/interfaces", nil))
+	assert.Equal(t, "interfaces", c.measurementName("openconfig-interfaces:/interfaces/interface/config", nil))
+	assert.Equal(t, "ifstate", c.measurementName("openconfig-interfaces:/interfaces/interface/state", nil))
+	assert.Equal(t, "ifcounters", c.measurementName("openconfig-interfaces:/interfaces/interface/state/counters", nil))
+	assert.Equal(t, "interfaces", c.measurementName("other:/interfaces/interface/state/counters", nil))
+	assert.Equal(t, "cpu", c.measurementName("/system/process-stats/cpus/cpu0", nil))
+	assert.Equal(t, "/system/process-stats/memory", c.measurementName("/system/process-stats/memory", nil))
+}
+
+func TestHandleCapabilityResponse(t *testing.T) {
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005"}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+
+	response := &gnmi.CapabilityResponse{
+		GNMIVersion: "0.7.0",
+		SupportedModels: []*gnmi.ModelData{
+			{Name: "openconfig-interfaces", Organization: "OpenConfig working group", Version: "2.0.0"},
+			{Name: "Cisco-IOS-XR-infra-statsd-oper", Organization: "Cisco Systems, Inc.", Version: "2017-09-07"},
+		},
+	}
+	c.handleCapabilityResponse(&target{}, response, time.Unix(0, 0))
+
+	assert.Empty(t, acc.Errors)
+
+	tags := map[string]string{"Producer": "127.0.0.1:57005", "model": "openconfig-interfaces", "organization": "OpenConfig working group"}
+	fields := map[string]interface{}{"version": "2.0.0", "gnmi_version": "0.7.0"}
+	acc.AssertContainsTaggedFields(t, "gnmi_models", fields, tags)
+
+	tags = map[string]string{"Producer": "127.0.0.1:57005", "model": "Cisco-IOS-XR-infra-statsd-oper", "organization": "Cisco Systems, Inc."}
+	fields = map[string]interface{}{"version": "2017-09-07", "gnmi_version": "0.7.0"}
+	acc.AssertContainsTaggedFields(t, "gnmi_models", fields, tags)
+}
+
+func TestAggregator(t *testing.T) {
+	_, err := newAggregator([]string{"median"})
+	assert.Equal(t, errors.New("E! Invalid aggregation function: median"), err)
+
+	a, err := newAggregator([]string{"min", "max", "mean", "last"})
+	assert.Nil(t, err)
+
+	tags := map[string]string{"name": "str"}
+	a.add("type:/model", map[string]interface{}{"value": int64(1), "state": "up"}, tags, time.Unix(0, 100))
+	a.add("type:/model", map[string]interface{}{"value": int64(5), "state": "down"}, tags, time.Unix(0, 200))
+	a.add("type:/model", map[string]interface{}{"value": int64(3)}, tags, time.Unix(0, 300))
+
+	acc := &testutil.Accumulator{}
+	a.flush(acc.AddFields)
+
+	fields := map[string]interface{}{"value_min": 1.0, "value_max": 5.0, "value_mean": 3.0, "value_last": int64(3), "state": "down"}
+	acc.AssertContainsTaggedFields(t, "type:/model", fields, tags)
+
+	acc.ClearMetrics()
+	a.flush(acc.AddFields)
+	assert.Empty(t, acc.Metrics)
+}
+
+func TestStartFailureStopsAggregators(t *testing.T) {
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57030", OutputFormat: "unknown", ResourceAccounting: true,
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model", Aggregate: []string{"max"}}}}
+	assert.EqualError(t, c.Start(&testutil.Accumulator{}), "E! Invalid GNMI output format: unknown")
+	assert.NotNil(t, c.ctx.Err())
+	assert.Equal(t, int64(0), c.goroutines.Get())
+}
+
+func TestStartFailureStopsServers(t *testing.T) {
+	// The self target cannot listen on an address in use
+	occupied, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer occupied.Close()
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57030", ResourceAccounting: true,
+		TunnelAddress: "127.0.0.1:0", SelfAddress: occupied.Addr().String(),
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model"}}}
+
+	started := make(chan error)
+	go func() { started <- c.Start(&testutil.Accumulator{}) }()
+
+	select {
+	case err := <-started:
+		assert.NotNil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("start did not return after failing")
+	}
+	assert.Equal(t, int64(0), c.goroutines.Get())
+	assert.Empty(t, c.targets)
+}
+
+// Accumulator tracking deliveries as required by the write-ahead log
+type trackingAccumulator struct {
+	testutil.Accumulator
+	delivered chan telegraf.DeliveryInfo
+}
+
+func (a *trackingAccumulator) WithTracking(maxTracked int) telegraf.TrackingAccumulator {
+	a.delivered = make(chan telegraf.DeliveryInfo, maxTracked)
+	return a
+}
+
+func (a *trackingAccumulator) AddTrackingMetricGroup(group []telegraf.Metric) telegraf.TrackingID {
+	for _, m := range group {
+		a.AddMetric(m)
+	}
+	return telegraf.TrackingID(len(a.Metrics))
+}
+
+func (a *trackingAccumulator) Delivered() <-chan telegraf.DeliveryInfo {
+	return a.delivered
+}
+
+func TestStartFailureClosesWAL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnmi-wal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57030", WALDirectory: dir, OutputFormat: "unknown"}
+	assert.EqualError(t, c.Start(&trackingAccumulator{}), "E! Invalid GNMI output format: unknown")
+	assert.Nil(t, c.wal)
+
+	// The log is reopened by the next start
+	c.OutputFormat = ""
+	assert.Nil(t, c.Start(&trackingAccumulator{}))
+	assert.NotNil(t, c.wal)
+	c.Stop()
+}
+
+func TestClassifyError(t *testing.T) {
+	expired := status.Error(codes.Unavailable, "all SubConns are in TransientFailure, latest connection error: "+
+		"connection error: desc = \"transport: authentication handshake failed: x509: certificate has expired or is not yet valid\"")
+	assert.Equal(t, errorClassTLSExpired, classifyError(expired))
+
+	authority := status.Error(codes.Unavailable, "transport: authentication handshake failed: x509: certificate signed by unknown authority")
+	assert.Equal(t, errorClassTLS, classifyError(authority))
+
+	assert.Equal(t, errorClassNetwork, classifyError(status.Error(codes.Unavailable, "connection refused")))
+	assert.Equal(t, errorClassAuth, classifyError(status.Error(codes.Unauthenticated, "bad credentials")))
+	assert.Equal(t, errorClassOther, classifyError(errors.New("testerror")))
+	assert.Equal(t, errorClassInvalid, classifyError(status.Error(codes.NotFound, "unknown path")))
+	assert.Equal(t, errorClassUnsupported, classifyError(status.Error(codes.Unimplemented, "unknown service")))
+
+	// Errors of XR reported with code Unknown are classified by their error tag
+	xrError := status.Error(codes.Unknown, `{"cisco-grpc:errors": {"error": [{"error-type": "application",
+		"error-tag": "access-denied", "error-message": "Authorization failed"}]}}`)
+	assert.Equal(t, errorClassOther, classifyError(xrError))
+	assert.Equal(t, errorClassAuth, classifyError(rpcerror.Decode(xrError, nil)))
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tags := map[string]string{"site": "ams1"}
+
+	username, err := renderTemplate("{{ .Site }}-telemetry", tags)
+	assert.Nil(t, err)
+	assert.Equal(t, "ams1-telemetry", username)
+
+	username, err = renderTemplate("telemetry-{{ .site }}", tags)
+	assert.Nil(t, err)
+	assert.Equal(t, "telemetry-ams1", username)
+
+	username, err = renderTemplate("cisco", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "cisco", username)
+
+	_, err = renderTemplate("{{ .Region }}-telemetry", tags)
+	assert.NotNil(t, err)
+}
+
+func TestDecodeGnmicNotification(t *testing.T) {
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005", OutputFormat: "gnmic",
+		Subscriptions: []Subscription{{Name: "sub1", Origin: "type", Path: "/model"}}}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+
+	notification := mockGNMINotification()
+	notification.Update[1].Val = &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"in-octets": 5, "state": {"oper-status": "UP"}}`)}}
+	c.handleSubscribeResponse(&target{}, &gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+
+	assert.Empty(t, acc.Errors)
+
+	tags := map[string]string{"source": "127.0.0.1:57005", "subscription-name": "sub1", "model_foo": "bar", "path_name": "str", "path_uint64": "1234"}
+	fields := map[string]interface{}{
+		"type:/model/some/path":                    int64(5678),
+		"type:/model/other/path/in-octets":         float64(5),
+		"type:/model/other/path/state/oper-status": "UP",
+	}
+	acc.AssertContainsTaggedFields(t, "sub1", fields, tags)
+}
+
+func TestMergeKeys(t *testing.T) {
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005", Subscriptions: []Subscription{
+		{Name: "interfaces", Origin: "openconfig", Path: "/interfaces/interface"},
+		{Name: "interfaces", Origin: "Cisco-IOS-XR-infra-statsd-oper", Path: "/infra-statistics/interfaces/interface",
+			MergeKeys: map[string]string{"interface-name": "name"}}}}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+
+	c.handleNotification(&target{}, &gnmi.Notification{
+		Prefix: gnmidecode.ParsePath("openconfig", "/interfaces/interface[name=Gi0/0/0/0]", ""),
+		Update: []*gnmi.Update{{Path: gnmidecode.ParsePath("", "state/counters/in-octets", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 5}}}}})
+	c.handleNotification(&target{}, &gnmi.Notification{
+		Prefix: gnmidecode.ParsePath("Cisco-IOS-XR-infra-statsd-oper", "/infra-statistics/interfaces", ""),
+		Update: []*gnmi.Update{{Path: gnmidecode.ParsePath("", "interface[interface-name=Gi0/0/0/0]/latest/generic-counters/crc-errors", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 1}}}}})
+
+	tags := map[string]string{"Producer": "127.0.0.1:57005", "Target": "", "name": "Gi0/0/0/0"}
+	assert.Len(t, acc.Metrics, 2)
+	for _, metric := range acc.Metrics {
+		assert.Equal(t, "interfaces", metric.Measurement)
+		assert.Equal(t, tags, metric.Tags)
+	}
+	assert.Equal(t, map[string]interface{}{"interface/latest/generic-counters/crc-errors": uint64(1)}, acc.Metrics[1].Fields)
+
+	c = &CiscoTelemetryGNMI{Subscriptions: []Subscription{{Path: "/interfaces", MergeKeys: map[string]string{"interface-name": "name"}}}}
+	assert.EqualError(t, c.Start(acc), "E! GNMI subscription :/interfaces merging keys requires a name")
+}
+
+func TestTagSanitizers(t *testing.T) {
+	c := &CiscoTelemetryGNMI{ServiceAddress: "Router1.example.com:57400",
+		TagSanitizers: map[string][]string{"Producer": {"trim_domain", "lowercase"}, "name": {"uppercase"}}}
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	c.Stop()
+
+	c.handleNotification(&target{}, mockGNMINotification())
+	tags := map[string]string{"Producer": "router1:57400", "Target": "subscription", "foo": "bar",
+		"some/path/name": "STR", "some/path/uint64": "1234"}
+	acc.AssertContainsTaggedFields(t, "type:/model", map[string]interface{}{"some/path": int64(5678), "other/path": "foobar"}, tags)
+}
+
+func TestResolveAddresses(t *testing.T) {
+	addresses, err := resolveAddresses("127.0.0.1:57777")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:57777"}, addresses)
+
+	_, err = resolveAddresses("localhost")
+	assert.NotNil(t, err)
+}
+
+func TestGNMIResolveAddresses(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 2}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57005")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "localhost:57005", ResolveAddresses: true,
+		Username: "theuser", Password: "thepassword"}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	time.Sleep(1 * time.Second)
+
+	server.Stop()
+	c.Stop()
+
+	// localhost may also resolve to an IPv6 address nobody listens on
+	tags := map[string]string{"some/path/name": "str", "some/path/uint64": "1234", "Producer": "localhost:57005",
+		"Target": "subscription", "foo": "bar", "address": "127.0.0.1"}
+	fields := map[string]interface{}{"some/path": int64(5678), "other/path": "foobar"}
+	acc.AssertContainsTaggedFields(t, "type:/model", fields, tags)
+}
+
+func TestGNMIAddresses(t *testing.T) {
+	var servers []*grpc.Server
+	for _, address := range []string{"127.0.0.1:57013", "127.0.0.1:57014"} {
+		listener, _ := net.Listen("tcp", address)
+		server := grpc.NewServer()
+		gnmi.RegisterGNMIServer(server, &mockGNMIServer{t: t, scenario: 2})
+		go server.Serve(listener)
+		servers = append(servers, server)
+	}
+
+	c := &CiscoTelemetryGNMI{Addresses: []string{"127.0.0.1:57013", "127.0.0.1:57014"},
+		Username: "theuser", Password: "thepassword"}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	time.Sleep(1 * time.Second)
+
+	for _, server := range servers {
+		server.Stop()
+	}
+	c.Stop()
+
+	assert.Len(t, c.targets, 2)
+	fields := map[string]interface{}{"some/path": int64(5678), "other/path": "foobar"}
+	for _, address := range c.Addresses {
+		tags := map[string]string{"some/path/name": "str", "some/path/uint64": "1234", "Producer": address,
+			"Target": "subscription", "foo": "bar"}
+		acc.AssertContainsTaggedFields(t, "type:/model", fields, tags)
+	}
+}
+
+func TestGNMIDeviceCredentials(t *testing.T) {
+	os.Setenv("GNMI_TEST_DEVICE_PASSWORD", "labpassword")
+	defer os.Unsetenv("GNMI_TEST_DEVICE_PASSWORD")
+
+	c := &CiscoTelemetryGNMI{Addresses: []string{"127.0.0.1:57027", "127.0.0.1:57028"},
+		Username: "theuser", Password: "thepassword", Redial: internal.Duration{Duration: time.Second},
+		DeviceConfigs: []DeviceConfig{{Address: "127.0.0.1:57028", Username: "labuser", Password: "env:GNMI_TEST_DEVICE_PASSWORD"}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	defer c.Stop()
+
+	credentials := make(map[string][]string)
+	for _, target := range c.targets {
+		ctx, err := c.authContext(context.Background(), target)
+		assert.Nil(t, err)
+		md, _ := metadata.FromOutgoingContext(ctx)
+		credentials[target.address] = append(md.Get("username"), md.Get("password")...)
+	}
+	assert.Equal(t, map[string][]string{
+		"127.0.0.1:57027": {"theuser", "thepassword"},
+		"127.0.0.1:57028": {"labuser", "labpassword"},
+	}, credentials)
+
+	c = &CiscoTelemetryGNMI{Addresses: []string{"127.0.0.1:57027"},
+		DeviceConfigs: []DeviceConfig{{Address: "127.0.0.1:57027"}, {Address: "127.0.0.1:57027"}}}
+	assert.EqualError(t, c.Start(acc), "E! Duplicate GNMI device settings for 127.0.0.1:57027")
+
+	c = &CiscoTelemetryGNMI{Addresses: []string{"127.0.0.1:57027"},
+		DeviceConfigs: []DeviceConfig{{Address: "127.0.0.1:57027", TLSProfile: "lab-ca"}}}
+	assert.EqualError(t, c.Start(acc), `E! Invalid GNMI TLS settings of 127.0.0.1:57027: TLS profile "lab-ca" requires a TLS profiles file`)
+}
+
+func TestHandleAtomicNotification(t *testing.T) {
+	aggregator, err := newAggregator([]string{"max"})
+	assert.Nil(t, err)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005",
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model", aggregator: aggregator}}}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+
+	notification := mockGNMINotification()
+	notification.Atomic = true
+	c.handleSubscribeResponse(&target{}, &gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+
+	assert.Empty(t, aggregator.series)
+
+	tags := map[string]string{"some/path/name": "str", "some/path/uint64": "1234", "Producer": "127.0.0.1:57005",
+		"Target": "subscription", "foo": "bar", "atomic": "true"}
+	fields := map[string]interface{}{"some/path": int64(5678), "other/path": "foobar"}
+	acc.AssertContainsTaggedFields(t, "type:/model", fields, tags)
+}
+
+func TestHandleProvenance(t *testing.T) {
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005", Provenance: true, collector: "collector1",
+		encodings:     []string{"proto", "json_ietf"},
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model"}}}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+
+	before := time.Now().UnixNano()
+	c.handleSubscribeResponse(&target{encoding: 1},
+		&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: mockGNMINotification()}})
+
+	assert.Len(t, acc.Metrics, 1)
+	metric := acc.Metrics[0]
+	assert.Equal(t, "json_ietf", metric.Tags["provenance_encoding"])
+	assert.Equal(t, "type:/model", metric.Tags["provenance_subscription"])
+	assert.Equal(t, "collector1", metric.Tags["provenance_collector"])
+	assert.True(t, metric.Fields["provenance_received"].(int64) >= before)
+}
+
+func TestHandleMalformedPaths(t *testing.T) {
+	notification := mockGNMINotification()
+	notification.Update[0].Path.Elem = append(notification.Update[0].Path.Elem, &gnmi.PathElem{})
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005"}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+	c.handleNotification(&target{}, notification)
+	assert.Len(t, acc.Metrics, 1)
+	assert.Contains(t, acc.Metrics[0].Fields, "some/path")
+
+	c = &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005", MalformedPaths: malformedPathsDrop}
+	acc = &testutil.Accumulator{}
+	c.acc = acc
+	target := &target{}
+	c.handleNotification(target, notification)
+	assert.Empty(t, acc.Metrics)
+	assert.Equal(t, uint64(1), target.decodeErrors)
+
+	// Interface names with slashes are kept
+	notification = mockGNMINotification()
+	notification.Update[0].Path = gnmidecode.ParsePath("", "interface[name=GigabitEthernet0/0/0/0]/state", "")
+	c.handleNotification(target, notification)
+	assert.Len(t, acc.Metrics, 1)
+	assert.Equal(t, uint64(1), target.decodeErrors)
+}
+
+func TestHandleDeletes(t *testing.T) {
+	notification := &gnmi.Notification{
+		Timestamp: 1543236572000000000,
+		Prefix:    &gnmi.Path{Origin: "type", Elem: []*gnmi.PathElem{{Name: "model"}}, Target: "subscription"},
+		Delete:    []*gnmi.Path{{Elem: []*gnmi.PathElem{{Name: "interface", Key: map[string]string{"name": "Gi0"}}}}},
+	}
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005"}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+	c.handleNotification(&target{}, notification)
+	assert.Empty(t, acc.Metrics)
+
+	c = &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005", DeleteEvents: true, DeleteMarker: "gone"}
+	acc = &testutil.Accumulator{}
+	c.acc = acc
+	c.handleNotification(&target{}, notification)
+
+	tags := map[string]string{"Producer": "127.0.0.1:57005", "Target": "subscription", "interface/name": "Gi0"}
+	acc.AssertContainsTaggedFields(t, "type:/model", map[string]interface{}{"gone": true}, tags)
+	assert.Len(t, acc.Metrics, 1)
+}
+
+func TestHandleUnmergedUpdates(t *testing.T) {
+	merge := false
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005", MergeUpdates: &merge}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+
+	notification := mockGNMINotification()
+	notification.Update = append(notification.Update, &gnmi.Update{
+		Path: &gnmi.Path{Elem: []*gnmi.PathElem{{Name: "json"}}},
+		Val:  &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonVal{JsonVal: []byte(`{"a": 1, "b": 2}`)}},
+	})
+	c.handleSubscribeResponse(&target{}, &gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+
+	assert.Empty(t, acc.Errors)
+	assert.Len(t, acc.Metrics, 4)
+	for _, metric := range acc.Metrics {
+		assert.Len(t, metric.Fields, 1)
+	}
+
+	tags := map[string]string{"Producer": "127.0.0.1:57005", "Target": "subscription", "foo": "bar"}
+	acc.AssertContainsTaggedFields(t, "type:/model", map[string]interface{}{"other/path": "foobar"}, tags)
+
+	tags["some/path/name"] = "str"
+	tags["some/path/uint64"] = "1234"
+	acc.AssertContainsTaggedFields(t, "type:/model", map[string]interface{}{"some/path": int64(5678)}, tags)
+}
+
+func TestGNMITriggers(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 4}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57006")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	client, err := grpc.Dial("127.0.0.1:57006", grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer client.Close()
+
+	c := &CiscoTelemetryGNMI{Triggers: []Trigger{{Path: "/diag/run", Value: "true", Retries: 1,
+		MinInterval: internal.Duration{Duration: time.Hour}}}}
+	assert.Nil(t, c.checkTriggers())
+
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+	c.ctx = context.Background()
+	c.targets = []*target{{address: "127.0.0.1:57006", client: client}}
+
+	// The second gather cycle is within the minimum interval
+	assert.Nil(t, c.Gather(acc))
+	assert.Nil(t, c.Gather(acc))
+
+	assert.Empty(t, acc.Errors)
+	assert.Len(t, m.sets, 2)
+	assert.Equal(t, []byte("true"), m.sets[1].Replace[0].Val.GetJsonVal())
+
+	c.Triggers[0].Value = "{invalid"
+	assert.NotNil(t, c.checkTriggers())
+}
+
+func TestGNMIIndependentStreams(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 5}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57007")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57007",
+		Username: "theuser", Password: "thepassword",
+		Redial:        internal.Duration{Duration: 100 * time.Millisecond},
+		HealthMetrics: true, SubscriptionMetrics: true,
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model"}, {Origin: "type", Path: "/broken"}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	time.Sleep(1 * time.Second)
+
+	// The healthy stream stays established while the broken one is redialed
+	streams := c.targets[0].streams
+	assert.Len(t, streams, 2)
+	assert.Equal(t, "established", streams[0].getState())
+	assert.NotEqual(t, "established", streams[1].getState())
+
+	// Gather reports the target as unhealthy as long as one of its streams is down
+	health := &testutil.Accumulator{}
+	assert.Nil(t, c.Gather(health))
+	assert.Len(t, health.Metrics, 3)
+	assert.Equal(t, "gnmi_health", health.Metrics[0].Measurement)
+	assert.Equal(t, false, health.Metrics[0].Fields["healthy"])
+	assert.Equal(t, 2, health.Metrics[0].Fields["streams"])
+	assert.Equal(t, 1, health.Metrics[0].Fields["streams_established"])
+	assert.Equal(t, uint64(1), health.Metrics[0].Fields["notifications"])
+	assert.Equal(t, uint64(2), health.Metrics[0].Fields["updates"])
+	assert.Equal(t, uint64(0), health.Metrics[0].Fields["decode_errors"])
+	assert.NotEqual(t, uint64(0), health.Metrics[0].Fields["bytes_received"])
+
+	// The scoreboard tells which path stopped streaming
+	tags := map[string]string{"Producer": "127.0.0.1:57007", "path": "type:/model"}
+	assert.Equal(t, "gnmi_subscription", health.Metrics[1].Measurement)
+	assert.Equal(t, tags, health.Metrics[1].Tags)
+	assert.Equal(t, uint64(2), health.Metrics[1].Fields["updates_received"])
+	assert.Equal(t, uint64(0), health.Metrics[1].Fields["errors"])
+	assert.Contains(t, health.Metrics[1].Fields, "last_update_age")
+	assert.Contains(t, health.Metrics[1].Fields, "uptime")
+	assert.Equal(t, "type:/broken", health.Metrics[2].Tags["path"])
+	assert.Equal(t, uint64(0), health.Metrics[2].Fields["updates_received"])
+	assert.NotEqual(t, uint64(0), health.Metrics[2].Fields["errors"])
+	assert.NotContains(t, health.Metrics[2].Fields, "uptime")
+
+	c.Stop()
+	server.Stop()
+
+	assert.NotEmpty(t, acc.Errors)
+	assert.Equal(t, 1, len(acc.Metrics))
+	assert.Equal(t, "stopped", streams[0].getState())
+}
+
+func TestGNMIBandwidthCap(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 5}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57012")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57012",
+		Username: "theuser", Password: "thepassword",
+		Redial:        internal.Duration{Duration: 100 * time.Millisecond},
+		MaxBandwidth:  internal.Size{Size: 1000},
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model", SampleInterval: Interval{Duration: time.Second}}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	time.Sleep(500 * time.Millisecond)
+	target := c.targets[0]
+	received := c.Statistics()[0].BytesReceived
+	assert.True(t, received > 0)
+
+	// Exceeding the cap widens the sample intervals and resubscribes the streams
+	target.bytesMeasured = atomic.LoadUint64(&target.bytesReceived)
+	atomic.AddUint64(&target.bytesReceived, 4000)
+	c.updateBandwidth(target, time.Second)
+	assert.True(t, c.Statistics()[0].Bandwidth >= 4000)
+	assert.Equal(t, int64(2), c.Statistics()[0].IntervalScale)
+
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, "established", target.streams[0].getState())
+	assert.Equal(t, uint64(2), acc.NMetrics())
+
+	request := c.subscribeRequest("proto", &c.Subscriptions[0])
+	scaleIntervals(request, atomic.LoadInt64(&target.intervalScale))
+	assert.Equal(t, uint64(2*time.Second), request.GetSubscribe().Subscription[0].SampleInterval)
+
+	// Intervals are narrowed again well below the cap
+	target.bytesMeasured = atomic.LoadUint64(&target.bytesReceived)
+	c.updateBandwidth(target, time.Second)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&target.intervalScale))
+
+	c.Stop()
+	server.Stop()
+	assert.Empty(t, acc.Errors)
+}
+
+func TestPathsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gnmi-paths")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "interfaces.yaml"), []byte(`
+origin: openconfig-interfaces
+sample_interval: 0.5
+aliases:
+  /interfaces/interface/state/counters: ifcounters
+paths: |
+  /interfaces/interface/state/counters
+  # comment
+  Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces
+`), 0640))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "system.yaml"), []byte(`
+origin: openconfig
+subscription_mode: on_change
+paths:
+  - /system
+  - /components
+`), 0640))
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005", PathsFile: filepath.Join(dir, "*.yaml"),
+		Subscriptions: []Subscription{{Origin: "openconfig", Path: "/system"}}}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	defer c.cancel()
+
+	subscriptions, state, err := c.loadPathsFiles()
+	assert.Nil(t, err)
+	assert.NotEmpty(t, state)
+	assert.Len(t, subscriptions, 4)
+	assert.Equal(t, "openconfig-interfaces", subscriptions[0].Origin)
+	assert.Equal(t, 500*time.Millisecond, subscriptions[0].SampleInterval.Duration)
+	assert.Equal(t, "ifcounters", subscriptions[0].alias)
+	assert.Equal(t, "Cisco-IOS-XR-infra-statsd-oper", subscriptions[1].Origin)
+	assert.Equal(t, "infra-statistics/interfaces", subscriptions[1].Path)
+
+	// Configured paths take precedence
+	c.updateFileSubscriptions(subscriptions)
+	assert.Len(t, c.runtimeSubscriptions, 3)
+	counters := c.runtimeSubscriptions[0]
+
+	// Only changed subscriptions are replaced on reload
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "system.yaml"), []byte(`
+origin: openconfig
+sample_interval: 30s
+paths: ["/components"]
+`), 0640))
+	subscriptions, _, err = c.loadPathsFiles()
+	assert.Nil(t, err)
+	c.updateFileSubscriptions(subscriptions)
+	assert.Len(t, c.runtimeSubscriptions, 3)
+	assert.True(t, counters == c.runtimeSubscriptions[0])
+	assert.Equal(t, 30*time.Second, c.runtimeSubscriptions[2].SampleInterval.Duration)
+
+	// Invalid files are reported
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("paths: {"), 0640))
+	_, _, err = c.loadPathsFiles()
+	assert.NotNil(t, err)
+
+	// Data of aliased paths is renamed
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+	c.handleNotification(&target{}, &gnmi.Notification{
+		Prefix: gnmidecode.ParsePath("openconfig-interfaces", "/interfaces/interface[name=Gi0/0/0/0]/state/counters", ""),
+		Update: []*gnmi.Update{{Path: gnmidecode.ParsePath("", "in-octets", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 5}}}},
+	})
+	assert.True(t, acc.HasMeasurement("ifcounters"))
+}
+
+func TestGNMIAdminSubscriptions(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 5}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57008")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57008", AdminAddress: "127.0.0.1:57409",
+		Username: "theuser", Password: "thepassword",
+		Subscriptions: []Subscription{{Origin: "type", Path: "/broken"}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	defer server.Stop()
+	defer c.Stop()
+
+	url := "http://127.0.0.1:57409/subscriptions"
+	body := `{"origin": "type", "path": "/model", "subscription_mode": "sample", "sample_interval": "1s"}`
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, err = http.Post(url, "application/json", strings.NewReader(body))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	time.Sleep(500 * time.Millisecond)
+
+	tags := map[string]string{"some/path/name": "str", "some/path/uint64": "1234", "Producer": "127.0.0.1:57008",
+		"Target": "subscription", "foo": "bar"}
+	fields := map[string]interface{}{"some/path": int64(5678), "other/path": "foobar"}
+	acc.AssertContainsTaggedFields(t, "type:/model", fields, tags)
+
+	resp, err = http.Get(url)
+	assert.Nil(t, err)
+	var list []adminSubscription
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&list))
+	assert.Equal(t, []adminSubscription{{Origin: "type", Path: "/broken"},
+		{Origin: "type", Path: "/model", SubscriptionMode: "sample", SampleInterval: "1s", Runtime: true}}, list)
+
+	resp, err = http.Get("http://127.0.0.1:57409/statistics")
+	assert.Nil(t, err)
+	var statistics []TargetStatistics
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&statistics))
+	assert.Len(t, statistics, 1)
+	assert.Equal(t, "127.0.0.1:57008", statistics[0].Address)
+	assert.Len(t, statistics[0].Streams, 2)
+	assert.Equal(t, "type:/broken", statistics[0].Streams[0].Path)
+	assert.Equal(t, "stopped", statistics[0].Streams[0].State)
+	assert.True(t, statistics[0].Streams[0].Errors > 0)
+	assert.Equal(t, "type:/model", statistics[0].Streams[1].Path)
+	assert.Equal(t, "established", statistics[0].Streams[1].State)
+	assert.True(t, statistics[0].Streams[1].Updates > 0)
+	assert.NotNil(t, statistics[0].Streams[1].LastUpdate)
+	assert.True(t, statistics[0].Responses > 0)
+	assert.True(t, statistics[0].Errors > 0)
+	assert.NotNil(t, statistics[0].LastResponse)
+
+	request, _ := http.NewRequest(http.MethodDelete, url+"?origin=type&path=/model", nil)
+	resp, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Len(t, c.targets[0].streams, 1)
+
+	// Only subscriptions added at runtime can be removed
+	request, _ = http.NewRequest(http.MethodDelete, url+"?origin=type&path=/broken", nil)
+	resp, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestGNMISeries(t *testing.T) {
+	c := &CiscoTelemetryGNMI{TrackSeries: true}
+	c.acc = &testutil.Accumulator{}
+	device := &target{address: "127.0.0.1:57400", producer: "127.0.0.1:57400", series: newSeriesTracker()}
+	c.targets = []*target{device}
+
+	counters := func(name string) *gnmi.Notification {
+		return &gnmi.Notification{
+			Prefix: gnmidecode.ParsePath("openconfig", "/interfaces/interface[name="+name+"]/state/counters", ""),
+			Update: []*gnmi.Update{{Path: gnmidecode.ParsePath("", "in-octets", ""),
+				Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 5}}}},
+		}
+	}
+	c.handleNotification(device, counters("Gi0/0/0/0"))
+	c.handleNotification(device, counters("Gi0/0/0/1"))
+	cutoff := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	c.handleNotification(device, counters("Gi0/0/0/1"))
+
+	series := c.Series(time.Time{})
+	assert.Len(t, series, 2)
+	assert.Equal(t, "openconfig:/interfaces/interface[name=Gi0/0/0/0]/state/counters", series[0].Path)
+	assert.Equal(t, uint64(1), series[0].Updates)
+	assert.Equal(t, series[0].FirstSeen, series[0].LastSeen)
+	assert.Equal(t, uint64(2), series[1].Updates)
+	assert.True(t, series[1].LastSeen.After(series[1].FirstSeen))
+
+	// Only series not seen since the cutoff are stale
+	series = c.Series(cutoff)
+	assert.Len(t, series, 1)
+	assert.Equal(t, "openconfig:/interfaces/interface[name=Gi0/0/0/0]/state/counters", series[0].Path)
+	assert.Equal(t, "127.0.0.1:57400", series[0].Address)
+}
+
+func TestGNMIMaxNewSeries(t *testing.T) {
+	c := &CiscoTelemetryGNMI{MaxNewSeries: 2, HealthMetrics: true}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+	device := &target{address: "127.0.0.1:57400", producer: "127.0.0.1:57400", series: newSeriesTracker()}
+	c.targets = []*target{device}
+
+	counters := func(name string) *gnmi.Notification {
+		return &gnmi.Notification{
+			Prefix: gnmidecode.ParsePath("openconfig", "/interfaces/interface[name="+name+"]/state/counters", ""),
+			Update: []*gnmi.Update{{Path: gnmidecode.ParsePath("", "in-octets", ""),
+				Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 5}}}},
+		}
+	}
+	for _, name := range []string{"Gi0/0/0/0", "Gi0/0/0/1", "Gi0/0/0/2", "Gi0/0/0/0"} {
+		c.handleNotification(device, counters(name))
+	}
+	assert.Len(t, acc.Metrics, 3)
+
+	// Rejected series are accepted once a later interval has room for them
+	health := &testutil.Accumulator{}
+	assert.Nil(t, c.Gather(health))
+	c.handleNotification(device, counters("Gi0/0/0/2"))
+	assert.Len(t, acc.Metrics, 4)
+	assert.Equal(t, "Gi0/0/0/2", acc.Metrics[3].Tags["name"])
+
+	assert.Equal(t, 2, health.Metrics[0].Fields["series"])
+	assert.Equal(t, uint64(2), health.Metrics[0].Fields["series_created"])
+	assert.Equal(t, uint64(1), health.Metrics[0].Fields["series_rejected"])
+}
+
+func TestGNMIConnectionEvents(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 5}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57009")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57009", ConnectionEvents: true,
+		Username: "theuser", Password: "thepassword",
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model"}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	time.Sleep(500 * time.Millisecond)
+
+	server.Stop()
+	c.Stop()
+
+	var states []interface{}
+	for _, metric := range acc.Metrics {
+		if metric.Measurement == "gnmi_connection" {
+			assert.Equal(t, map[string]string{"Producer": "127.0.0.1:57009"}, metric.Tags)
+			states = append(states, metric.Fields["state"])
+		}
+	}
+	assert.Contains(t, states, "CONNECTING")
+	assert.Contains(t, states, "READY")
+}
+
+func TestGNMIOutageEvents(t *testing.T) {
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57019", OutageEvents: true, Redial: internal.Duration{Duration: 100 * time.Millisecond},
+		Username: "theuser", Password: "thepassword",
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model"}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	// The device only comes up after the first subscriptions failed
+	time.Sleep(300 * time.Millisecond)
+	m := &mockGNMIServer{t: t, scenario: 5}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57019")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	acc.Wait(2)
+	server.Stop()
+	c.Stop()
+
+	var outages int
+	for _, metric := range acc.Metrics {
+		if metric.Measurement == "gnmi_outage" {
+			assert.Equal(t, map[string]string{"Producer": "127.0.0.1:57019"}, metric.Tags)
+			assert.True(t, metric.Fields["duration"].(float64) >= 0.3)
+			assert.Equal(t, "network", metric.Fields["last_error_class"])
+			outages++
+		}
+	}
+	assert.Equal(t, 1, outages)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&c.targets[0].downSince))
+}
+
+// Inventory of devices replaced by tests
+type mockInventory struct {
+	devices []inventory.Device
+	mutex   sync.Mutex
+}
+
+func (i *mockInventory) Devices(_ context.Context) ([]inventory.Device, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.devices, nil
+}
+
+func (i *mockInventory) set(devices ...inventory.Device) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.devices = devices
+}
+
+func TestGNMIInventory(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 5}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57020")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	devices := &mockInventory{}
+	devices.set(inventory.Device{Address: "127.0.0.1:57020", Tags: map[string]string{"device": "pe1", "site": "fra1"}})
+
+	c := &CiscoTelemetryGNMI{InventoryInterval: internal.Duration{Duration: 100 * time.Millisecond},
+		Username: "theuser", Password: "thepassword",
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model"}}}
+	c.SetInventory(devices)
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	defer c.Stop()
+
+	acc.Wait(1)
+	tags := map[string]string{"some/path/name": "str", "some/path/uint64": "1234", "Producer": "127.0.0.1:57020",
+		"Target": "subscription", "foo": "bar", "device": "pe1", "site": "fra1"}
+	fields := map[string]interface{}{"some/path": int64(5678), "other/path": "foobar"}
+	acc.AssertContainsTaggedFields(t, "type:/model", fields, tags)
+
+	// Targets of devices removed from the inventory are stopped
+	devices.set()
+	time.Sleep(300 * time.Millisecond)
+	assert.Empty(t, c.Statistics())
+}
+
+// Credentials tracking the number of concurrent handshakes
+type mockCredentials struct {
+	credentials.TransportCredentials
+	active  int32
+	maximum int32
+}
+
+func (m *mockCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	active := atomic.AddInt32(&m.active, 1)
+	defer atomic.AddInt32(&m.active, -1)
+	for maximum := atomic.LoadInt32(&m.maximum); active > maximum; maximum = atomic.LoadInt32(&m.maximum) {
+		if atomic.CompareAndSwapInt32(&m.maximum, maximum, active) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return conn, nil, nil
+}
+
+func TestLimitedCredentials(t *testing.T) {
+	creds := &mockCredentials{}
+	limited := newLimitedCredentials(creds, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := limited.ClientHandshake(context.Background(), "device", nil)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), creds.maximum)
+
+	// Handshakes waiting for a slot give up with the dial
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	blocked := &limitedCredentials{TransportCredentials: creds, handshakes: make(chan struct{})}
+	_, _, err := blocked.ClientHandshake(ctx, "device", nil)
+	assert.Equal(t, context.Canceled, err)
+
+	// Unlimited handshakes use the credentials as they are
+	assert.True(t, newLimitedCredentials(creds, 0) == creds)
+}
+
+func TestHandleSchemaChange(t *testing.T) {
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57005", schemas: schema.NewTracker()}
+	acc := &testutil.Accumulator{}
+	c.acc = acc
+
+	notification := mockGNMINotification()
+	response := &gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}}
+	c.handleSubscribeResponse(&target{}, response)
+	c.handleSubscribeResponse(&target{}, response)
+
+	notification.Update[0].Val = &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: "foo"}}
+	c.handleSubscribeResponse(&target{}, response)
+
+	var events []map[string]interface{}
+	for _, metric := range acc.Metrics {
+		if metric.Measurement == "gnmi_schema" {
+			assert.Equal(t, map[string]string{"Producer": "127.0.0.1:57005", "measurement": "type:/model"}, metric.Tags)
+			events = append(events, metric.Fields)
+		}
+	}
+
+	assert.Len(t, events, 2)
+	assert.Equal(t, "other/path,some/path", events[0]["changed_fields"])
+	assert.Equal(t, "some/path", events[1]["changed_fields"])
+	assert.Equal(t, events[0]["fingerprint"], events[1]["previous_fingerprint"])
+}
+
+func TestGNMIEncodingFallback(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 6}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57010")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57010",
+		Encoding: "proto", EncodingFallback: []string{"json", "json_ietf"},
+		Username: "theuser", Password: "thepassword",
+		Redial: internal.Duration{Duration: 10 * time.Second}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	time.Sleep(500 * time.Millisecond)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&c.targets[0].encoding))
+
+	server.Stop()
+	c.Stop()
+
+	tags := map[string]string{"some/path/name": "str", "some/path/uint64": "1234", "Producer": "127.0.0.1:57010",
+		"Target": "subscription", "foo": "bar"}
+	fields := map[string]interface{}{"some/path": int64(5678), "other/path": "foobar"}
+	acc.AssertContainsTaggedFields(t, "type:/model", fields, tags)
+
+	// Negotiated encodings are used right away
+	m = &mockGNMIServer{t: t, scenario: 6}
+	listener, _ = net.Listen("tcp", "127.0.0.1:57010")
+	server = grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c = &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57010", Encoding: "auto",
+		Username: "theuser", Password: "thepassword",
+		Redial: internal.Duration{Duration: 10 * time.Second}}
+	assert.Nil(t, c.Start(acc))
+	assert.Equal(t, []string{"proto", "json_ietf", "json"}, c.encodings)
+
+	time.Sleep(500 * time.Millisecond)
+
+	server.Stop()
+	c.Stop()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&c.targets[0].encoding))
+	assert.Equal(t, []gnmi.Encoding{gnmi.Encoding_JSON_IETF}, m.encodings)
+
+	c = &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57010", Encoding: "xml"}
+	assert.NotNil(t, c.Start(acc))
+
+	c = &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57010", Subscriptions: []Subscription{{Path: "/model", SubscriptionMode: "once"}}}
+	assert.NotNil(t, c.Start(acc))
+}
+
+func TestGNMIBackfill(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 7, extensions: make(chan []byte, 1)}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57011")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57011", Username: "theuser", Password: "thepassword",
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model"}},
+		BackfillStart: "2018-11-26T12:00:00Z", BackfillEnd: "2018-11-26T13:00:00Z",
+		Redial: internal.Duration{Duration: 1 * time.Second}}
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	defer c.Stop()
+
+	select {
+	case extension := <-m.extensions:
+		assert.Equal(t, historyExtension(time.Unix(1543233600, 0), time.Unix(1543237200, 0)).XXX_unrecognized, extension)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no history extension received")
+	}
+
+	acc.Wait(1)
+	assert.Equal(t, time.Unix(0, 1543236572000000000), acc.Metrics[0].Time)
+
+	for i := 0; i < 100 && c.targets[0].streams[0].getState() != "stopped"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, "stopped", c.targets[0].streams[0].getState())
+
+	c = &CiscoTelemetryGNMI{BackfillStart: "2018-11-26T13:00:00Z", BackfillEnd: "2018-11-26T12:00:00Z"}
+	assert.NotNil(t, c.parseBackfill())
+}
+
+func TestGNMIOnChangeSync(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 8, sync: make(chan struct{})}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57016")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57016",
+		Username: "theuser", Password: "thepassword", Encoding: "proto",
+		Redial:        internal.Duration{Duration: 1 * time.Second},
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model", SubscriptionMode: "on_change"}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	// The initial state is held back until the device signals sync
+	time.Sleep(500 * time.Millisecond)
+	assert.Empty(t, acc.Metrics)
+
+	close(m.sync)
+	acc.Wait(2)
+	c.Stop()
+	server.Stop()
+
+	assert.Empty(t, acc.Errors)
+	assert.Equal(t, "str", acc.Metrics[0].Tags["some/path/name"])
+	assert.Equal(t, "str2", acc.Metrics[1].Tags["some/path/name"])
+}
+
+func TestGNMIReconcile(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 9}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57022")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57022",
+		Username: "theuser", Password: "thepassword", Encoding: "proto",
+		Redial: internal.Duration{Duration: 1 * time.Second},
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model", SubscriptionMode: "on_change",
+			ReconcileInterval: Interval{Duration: 200 * time.Millisecond}}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	acc.Wait(2)
+	time.Sleep(500 * time.Millisecond)
+	statistics := c.Statistics()
+	c.Stop()
+	server.Stop()
+
+	// Only the missed change is emitted, and only once
+	assert.Empty(t, acc.Errors)
+	assert.Len(t, acc.Metrics, 2)
+	assert.Equal(t, map[string]interface{}{"some/path": int64(5678), "other/path": "foobar"}, acc.Metrics[0].Fields)
+	assert.Equal(t, map[string]interface{}{"some/path": int64(9999)}, acc.Metrics[1].Fields)
+	assert.Equal(t, uint64(1), statistics[0].Corrections)
+
+	c = &CiscoTelemetryGNMI{Subscriptions: []Subscription{{Path: "/model", SubscriptionMode: "sample",
+		ReconcileInterval: Interval{Duration: time.Minute}}}}
+	assert.EqualError(t, c.Start(acc), "E! GNMI subscription :/model can only be reconciled in on_change mode")
+}
+
+func TestStateCacheJSONIETF(t *testing.T) {
+	state := newStateCache()
+	prefix := gnmidecode.ParsePath("openconfig", "/interfaces", "")
+	state.update(&gnmi.Notification{Prefix: prefix, Update: []*gnmi.Update{
+		{Path: gnmidecode.ParsePath("", "interface[name=Gi0/0/0/0]/state/counters/in-octets", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 1}}},
+		{Path: gnmidecode.ParsePath("", "interface[name=Gi0/0/0/1]/state/counters/in-octets", ""),
+			Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 2}}},
+	}})
+
+	// Get responses hold a single container, with 64-bit integers as strings
+	corrected := state.mismatches(&gnmi.Notification{Prefix: prefix, Update: []*gnmi.Update{{Path: &gnmi.Path{},
+		Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"openconfig-interfaces:interface": [
+			{"name": "Gi0/0/0/0", "state": {"counters": {"in-octets": "1"}}},
+			{"name": "Gi0/0/0/1", "state": {"counters": {"in-octets": "3"}}}]}`)}}}}})
+
+	assert.Len(t, corrected.Update, 1)
+	assert.Equal(t, gnmidecode.ParsePath("", "interface[name=Gi0/0/0/1]/state/counters/in-octets", "").Elem, corrected.Update[0].Path.Elem)
+	assert.Equal(t, uint64(3), corrected.Update[0].Val.GetUintVal())
+
+	state.update(corrected)
+	assert.Nil(t, state.mismatches(&gnmi.Notification{Prefix: prefix, Update: []*gnmi.Update{{Path: &gnmi.Path{},
+		Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"interface": [
+			{"name": "Gi0/0/0/1", "state": {"counters": {"in-octets": "3"}}}]}`)}}}}}))
+}
+
+func TestGNMICanary(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:57029")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, &mockGNMIServer{t: t, scenario: 5})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	// The canary keeps streaming while the data subscription fails
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57029",
+		Username: "theuser", Password: "thepassword", Encoding: "proto",
+		Redial:        internal.Duration{Duration: 10 * time.Second},
+		Subscriptions: []Subscription{{Path: "/broken"}},
+		Canary:        &Subscription{Origin: "type", Path: "/model"}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	time.Sleep(500 * time.Millisecond)
+	assert.Nil(t, c.Gather(acc))
+	c.Stop()
+
+	assert.Equal(t, 30*time.Second, c.CanaryTimeout.Duration)
+	assert.False(t, acc.HasMeasurement("type:/model"))
+	assert.True(t, acc.HasMeasurement("gnmi_canary"))
+	for _, metric := range acc.Metrics {
+		if metric.Measurement == "gnmi_canary" {
+			assert.Equal(t, true, metric.Fields["alive"])
+			assert.Contains(t, metric.Fields, "last_update")
+		}
+	}
+
+	// The canary is stale once silent for the timeout, while the data subscription streams
+	c = &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57029",
+		Username: "theuser", Password: "thepassword", Encoding: "proto",
+		Redial:        internal.Duration{Duration: 10 * time.Second},
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model"}},
+		Canary:        &Subscription{Path: "/broken"},
+		CanaryTimeout: internal.Duration{Duration: 100 * time.Millisecond}}
+
+	acc = &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	time.Sleep(500 * time.Millisecond)
+	assert.Nil(t, c.Gather(acc))
+	c.Stop()
+
+	assert.True(t, acc.HasMeasurement("type:/model"))
+	for _, metric := range acc.Metrics {
+		if metric.Measurement == "gnmi_canary" {
+			assert.Equal(t, false, metric.Fields["alive"])
+			assert.NotContains(t, metric.Fields, "last_update")
+		}
+	}
+	assert.Equal(t, int32(1), c.targets[0].canaryStale)
+
+	c = &CiscoTelemetryGNMI{Canary: &Subscription{Path: "/system/state/boot-time", SubscriptionMode: "on_change"}}
+	assert.EqualError(t, c.Start(acc), "E! GNMI canary can only be subscribed in sample mode")
+}
+
+func TestGNMIGet(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 10}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57023")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57023",
+		Username: "theuser", Password: "thepassword", Encoding: "proto",
+		Redial: internal.Duration{Duration: 1 * time.Second},
+		Gets: []Subscription{{Name: "polled", Origin: "type", Path: "/model",
+			Transforms: map[string]string{"some/path": "hundredths"}}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	assert.Nil(t, c.Gather(acc))
+	statistics := c.Statistics()
+	c.Stop()
+	server.Stop()
+
+	assert.Empty(t, acc.Errors)
+	tags := map[string]string{"some/path/name": "str", "some/path/uint64": "1234", "Producer": "127.0.0.1:57023", "Target": "subscription", "foo": "bar"}
+	fields := map[string]interface{}{"some/path": float64(56.78), "other/path": "foobar"}
+	acc.AssertContainsTaggedFields(t, "polled", fields, tags)
+	assert.Equal(t, uint64(1), statistics[0].Notifications)
+}
+
+func TestGNMIPoll(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 11}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57024")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57024",
+		Username: "theuser", Password: "thepassword", Encoding: "proto",
+		Redial:        internal.Duration{Duration: 1 * time.Second},
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model", SubscriptionMode: "poll"}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	// Nothing is received until the stream is polled
+	time.Sleep(500 * time.Millisecond)
+	assert.Empty(t, acc.Metrics)
+
+	assert.Nil(t, c.Gather(acc))
+	acc.Wait(1)
+	assert.Nil(t, c.Gather(acc))
+	acc.Wait(2)
+	c.Stop()
+	server.Stop()
+
+	assert.Empty(t, acc.Errors)
+	assert.Len(t, acc.Metrics, 2)
+	assert.Equal(t, "str", acc.Metrics[1].Tags["some/path/name"])
+}
+
+func TestGNMISelf(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 5}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57025")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57025", SelfAddress: "127.0.0.1:57026",
+		Username: "theuser", Password: "thepassword", Encoding: "proto",
+		Redial:        internal.Duration{Duration: 1 * time.Second},
+		Subscriptions: []Subscription{{Name: "counters", Origin: "type", Path: "/model"}}}
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+	defer server.Stop()
+	defer c.Stop()
+	acc.Wait(1)
+
+	conn, err := grpc.Dial("127.0.0.1:57026", grpc.WithInsecure())
+	assert.Nil(t, err)
+	defer conn.Close()
+	client := gnmi.NewGNMIClient(conn)
+
+	response, err := client.Get(context.Background(), &gnmi.GetRequest{Encoding: gnmi.Encoding_PROTO,
+		Path: []*gnmi.Path{gnmidecode.ParsePath("telegraf", "/collector/targets/target[address=*]/state/updates", "")}})
+	assert.Nil(t, err)
+	assert.Len(t, response.Notification, 1)
+	assert.Equal(t, "127.0.0.1:57025", response.Notification[0].Prefix.Elem[2].Key["address"])
+	assert.Equal(t, []*gnmi.Update{{Path: &gnmi.Path{Elem: []*gnmi.PathElem{{Name: "updates"}}},
+		Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: 2}}}}, response.Notification[0].Update)
+
+	// Subscriptions receive the statistics of the streams as well, followed by the sync response
+	subscribeClient, err := client.Subscribe(context.Background())
+	assert.Nil(t, err)
+	subscribeClient.Send(&gnmi.SubscribeRequest{Request: &gnmi.SubscribeRequest_Subscribe{Subscribe: &gnmi.SubscriptionList{
+		Mode: gnmi.SubscriptionList_ONCE, Encoding: gnmi.Encoding_JSON_IETF,
+		Subscription: []*gnmi.Subscription{{Path: gnmidecode.ParsePath("", "/collector/targets/target/streams", "")}}}}})
+	reply, err := subscribeClient.Recv()
+	assert.Nil(t, err)
+	decoder := gnmidecode.Decoder{IETFNumbers: []string{"updates"}}
+	_, fields, tags, err := decoder.Decode(reply.GetUpdate(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "counters", tags["name"])
+	assert.Equal(t, "established", fields["state"])
+	assert.Equal(t, int64(2), fields["updates"])
+	reply, err = subscribeClient.Recv()
+	assert.Nil(t, err)
+	assert.True(t, reply.GetSyncResponse())
+
+	_, err = client.Set(context.Background(), &gnmi.SetRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+// BridgeTunnelSession of a device between the tunnel and its local gNMI server
+func bridgeTunnelSession(client grpctunnel.TunnelClient, tag int32, address string) {
+	stream, err := client.Tunnel(context.Background())
+	if err != nil {
+		return
+	}
+	stream.Send(&grpctunnel.Data{Tag: tag})
+
+	local, err := net.Dial("tcp", address)
+	if err != nil {
+		stream.Send(&grpctunnel.Data{Tag: tag, Close: true})
+		return
+	}
+	defer local.Close()
+
+	go func() {
+		buffer := make([]byte, 4096)
+		for {
+			n, err := local.Read(buffer)
+			if err != nil {
+				stream.Send(&grpctunnel.Data{Tag: tag, Close: true})
+				return
+			}
+			stream.Send(&grpctunnel.Data{Tag: tag, Data: buffer[:n]})
+		}
+	}()
+
+	for {
+		data, err := stream.Recv()
+		if err != nil || data.Close {
+			return
+		}
+		local.Write(data.Data)
+	}
+}
+
+func TestGNMITunnel(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 5}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57018")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{TunnelAddress: "127.0.0.1:57017",
+		Username: "theuser", Password: "thepassword", Encoding: "proto",
+		Redial:        internal.Duration{Duration: 1 * time.Second},
+		Subscriptions: []Subscription{{Origin: "type", Path: "/model"}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	// The device dials out, registers its gNMI target and opens the sessions requested
+	conn, err := grpc.Dial("127.0.0.1:57017", grpc.WithInsecure())
+	assert.Nil(t, err)
+	client := grpctunnel.NewTunnelClient(conn)
+	register, err := client.Register(context.Background())
+	assert.Nil(t, err)
+
+	assert.Nil(t, register.Send(&grpctunnel.RegisterOp{Target: &grpctunnel.Target{Op: grpctunnel.Target_ADD,
+		Target: "router1", TargetType: "GNMI_GNOI"}}))
+	op, err := register.Recv()
+	assert.Nil(t, err)
+	assert.True(t, op.GetTarget().GetAccept())
+
+	// Targets of other types are refused
+	assert.Nil(t, register.Send(&grpctunnel.RegisterOp{Target: &grpctunnel.Target{Op: grpctunnel.Target_ADD,
+		Target: "router1-ssh", TargetType: "SSH"}}))
+
+	removed := make(chan struct{})
+	go func() {
+		for {
+			op, err := register.Recv()
+			if err == io.EOF || err != nil {
+				return
+			}
+			if op.GetSession() != nil {
+				go bridgeTunnelSession(client, op.Session.Tag, "127.0.0.1:57018")
+			} else if op.GetTarget().GetOp() == grpctunnel.Target_REMOVE {
+				close(removed)
+			} else {
+				assert.False(t, op.GetTarget().GetAccept())
+			}
+		}
+	}()
+
+	acc.Wait(1)
+	tags := map[string]string{"some/path/name": "str", "some/path/uint64": "1234", "Producer": "router1",
+		"Target": "subscription", "foo": "bar", "address": "127.0.0.1"}
+	fields := map[string]interface{}{"some/path": int64(5678), "other/path": "foobar"}
+	acc.AssertContainsTaggedFields(t, "type:/model", fields, tags)
+
+	// Deregistered targets are no longer subscribed to
+	assert.Nil(t, register.Send(&grpctunnel.RegisterOp{Target: &grpctunnel.Target{Op: grpctunnel.Target_REMOVE,
+		Target: "router1", TargetType: "GNMI_GNOI"}}))
+	<-removed
+	assert.Empty(t, c.Statistics())
+
+	c.Stop()
+	conn.Close()
+	server.Stop()
+}
+
+func TestGNMIRetryServiceConfig(t *testing.T) {
+	c := &CiscoTelemetryGNMI{RetryMaxAttempts: 3, RetryInitialBackoff: internal.Duration{Duration: 500 * time.Millisecond}}
+	config := c.retryServiceConfig()
+	assert.Equal(t, `{"methodConfig":[{"name":[{"service":"gnmi.gNMI"}],"retryPolicy":{"maxAttempts":3,`+
+		`"initialBackoff":"0.500000000s","maxBackoff":"5.000000000s","backoffMultiplier":2,`+
+		`"retryableStatusCodes":["UNAVAILABLE"]}}]}`, config)
+
+	// The service config is validated by gRPC when dialing
+	client, err := grpc.Dial("127.0.0.1:57021", grpc.WithInsecure(), grpc.WithDefaultServiceConfig(config))
+	assert.Nil(t, err)
+	client.Close()
+}
+
+func TestGNMIKeepalive(t *testing.T) {
+	m := &mockGNMIServer{t: t, scenario: 5}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57021")
+	server := grpc.NewServer(grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime: 10 * time.Second, PermitWithoutStream: true}))
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+
+	c := &CiscoTelemetryGNMI{ServiceAddress: "127.0.0.1:57021",
+		Username: "theuser", Password: "thepassword",
+		Subscriptions:    []Subscription{{Path: "/model"}},
+		KeepaliveTime:    internal.Duration{Duration: 10 * time.Second},
+		KeepaliveTimeout: internal.Duration{Duration: time.Second},
+		Redial:           internal.Duration{Duration: 10 * time.Second}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	time.Sleep(500 * time.Millisecond)
+
+	c.Stop()
+	server.Stop()
+
+	assert.Empty(t, acc.Errors)
+	assert.Len(t, acc.Metrics, 1)
+}
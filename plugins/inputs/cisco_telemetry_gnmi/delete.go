@@ -0,0 +1,41 @@
+package cisco_telemetry_gnmi
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Field marking tombstones of deleted paths unless configured
+const defaultDeleteMarker = "_deleted"
+
+// HandleDeletes emits a tombstone per path deleted by a notification, tagged like the data of the
+// path so consumers can expire its series, with the marker field as only value
+func (c *CiscoTelemetryGNMI) handleDeletes(t *target, notification *gnmi.Notification, timestamp time.Time) {
+	marker := c.DeleteMarker
+	if len(marker) == 0 {
+		marker = defaultDeleteMarker
+	}
+
+	for _, path := range notification.Delete {
+		subscription := c.lookupSubscription(notification.GetPrefix(), path)
+
+		// Decode the deleted path like an update without value to get the tags of its data
+		deleted := &gnmi.Notification{Timestamp: notification.Timestamp, Prefix: notification.Prefix,
+			Update: []*gnmi.Update{{Path: path}}}
+		name, fields, tags := c.decodeNotification(t, deleted, subscription)
+		name = c.measurementName(name, subscription)
+
+		if c.IncludePathTag {
+			tags["path"] = gnmidecode.CanonicalPath(deleted)
+		}
+		if subscription != nil {
+			subscription.renameMergeKeys(tags)
+		}
+		c.tagRules.Apply(tags)
+
+		fields[marker] = true
+		c.addMetric(name, fields, tags, timestamp)
+	}
+}
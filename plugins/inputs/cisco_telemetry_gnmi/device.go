@@ -0,0 +1,185 @@
+package cisco_telemetry_gnmi
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	internaltls "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/common/auth"
+	"github.com/influxdata/telegraf/plugins/common/secret"
+	"github.com/influxdata/telegraf/plugins/common/tlsprofile"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// DeviceConfig overrides the credentials, TLS settings and timestamp unit of the plugin for the
+// targets of the device with the address, settings not given are those of the plugin
+type DeviceConfig struct {
+	Address  string
+	Username string
+	Password string
+
+	TLS                *bool
+	TLSCA              string `toml:"tls_ca"`
+	TLSCert            string `toml:"tls_cert"`
+	TLSKey             string `toml:"tls_key"`
+	TLSKeyPassphrase   string `toml:"tls_key_passphrase"`
+	InsecureSkipVerify *bool  `toml:"insecure_skip_verify"`
+	TLSProfile         string `toml:"tls_profile"`
+
+	TimestampUnit string `toml:"timestamp_unit"`
+}
+
+// Credentials, transport and timestamp scale of the targets of a device
+type deviceSettings struct {
+	auth           auth.Provider
+	transport      grpc.DialOption
+	timestampScale int64
+}
+
+// SetupDevices resolves the settings of the devices overriding those of the plugin
+func (c *CiscoTelemetryGNMI) setupDevices(config auth.Config, username string, password string) error {
+	c.deviceSettings = make(map[string]*deviceSettings, len(c.DeviceConfigs))
+	for _, device := range c.DeviceConfigs {
+		if len(device.Address) == 0 {
+			return fmt.Errorf("E! GNMI device settings require an address")
+		}
+		if _, ok := c.deviceSettings[device.Address]; ok {
+			return fmt.Errorf("E! Duplicate GNMI device settings for %s", device.Address)
+		}
+
+		deviceUsername, devicePassword := username, password
+		if len(device.Username) > 0 {
+			rendered, err := renderTemplate(device.Username, c.TargetTags)
+			if err != nil {
+				return fmt.Errorf("E! Invalid GNMI username template of %s: %v", device.Address, err)
+			}
+			if deviceUsername, err = secret.Resolve(rendered); err != nil {
+				return fmt.Errorf("E! Failed to resolve GNMI username of %s: %v", device.Address, err)
+			}
+		}
+		if len(device.Password) > 0 {
+			var err error
+			if devicePassword, err = secret.Resolve(device.Password); err != nil {
+				return fmt.Errorf("E! Failed to resolve GNMI password of %s: %v", device.Address, err)
+			}
+		}
+
+		provider, err := config.Provider(deviceUsername, devicePassword)
+		if err != nil {
+			return fmt.Errorf("E! Invalid GNMI auth provider: %v", err)
+		}
+
+		// Devices referencing a TLS profile use its settings instead of those of the plugin
+		enabled, clientConfig, passphrase, err := c.tlsSettings()
+		if err != nil {
+			return fmt.Errorf("E! Invalid GNMI TLS settings: %v", err)
+		}
+		if len(device.TLSProfile) > 0 {
+			enabled, clientConfig, passphrase = true, internaltls.ClientConfig{}, ""
+		}
+		if device.TLS != nil {
+			enabled = *device.TLS
+		}
+		if len(device.TLSCA) > 0 {
+			clientConfig.TLSCA = device.TLSCA
+		}
+		if len(device.TLSCert) > 0 {
+			clientConfig.TLSCert = device.TLSCert
+		}
+		if len(device.TLSKey) > 0 {
+			clientConfig.TLSKey = device.TLSKey
+			passphrase = device.TLSKeyPassphrase
+		}
+		if device.InsecureSkipVerify != nil {
+			clientConfig.InsecureSkipVerify = *device.InsecureSkipVerify
+		}
+		if len(device.TLSProfile) > 0 {
+			if passphrase, err = c.applyTLSProfile(device.TLSProfile, &clientConfig, passphrase); err != nil {
+				return fmt.Errorf("E! Invalid GNMI TLS settings of %s: %v", device.Address, err)
+			}
+		}
+
+		transport, err := c.transportOption(enabled, clientConfig, passphrase)
+		if err != nil {
+			return fmt.Errorf("E! Invalid GNMI TLS settings of %s: %v", device.Address, err)
+		}
+
+		scale := c.timestampScale
+		if len(device.TimestampUnit) > 0 {
+			if scale, err = timestampScale(device.TimestampUnit); err != nil {
+				return fmt.Errorf("E! Invalid GNMI timestamp unit of %s: %s", device.Address, device.TimestampUnit)
+			}
+		}
+
+		c.deviceSettings[device.Address] = &deviceSettings{auth: provider, transport: transport, timestampScale: scale}
+	}
+	return nil
+}
+
+// TLSSettings of the plugin, completed by its TLS profile if it references one
+func (c *CiscoTelemetryGNMI) tlsSettings() (bool, internaltls.ClientConfig, string, error) {
+	clientConfig := c.ClientConfig
+	if len(c.TLSProfile) == 0 {
+		return c.TLS, clientConfig, c.TLSKeyPassphrase, nil
+	}
+	passphrase, err := c.applyTLSProfile(c.TLSProfile, &clientConfig, c.TLSKeyPassphrase)
+	return true, clientConfig, passphrase, err
+}
+
+// ApplyTLSProfile of the profiles file to the TLS settings, returning the passphrase of the key
+func (c *CiscoTelemetryGNMI) applyTLSProfile(name string, clientConfig *internaltls.ClientConfig,
+	passphrase string) (string, error) {
+	if c.tlsProfiles == nil {
+		return "", fmt.Errorf("TLS profile %q requires a TLS profiles file", name)
+	}
+	return c.tlsProfiles.Apply(name, clientConfig, passphrase)
+}
+
+// LoadTLSProfiles of the profiles file if configured
+func (c *CiscoTelemetryGNMI) loadTLSProfiles() error {
+	c.tlsProfiles = nil
+	if len(c.TLSProfiles) == 0 {
+		return nil
+	}
+
+	var err error
+	if c.tlsProfiles, err = tlsprofile.Load(c.TLSProfiles); err != nil {
+		return fmt.Errorf("E! Failed to load GNMI TLS profiles: %v", err)
+	}
+	return nil
+}
+
+// TransportOption dialing with the TLS settings, or without TLS if disabled
+func (c *CiscoTelemetryGNMI) transportOption(enabled bool, clientConfig internaltls.ClientConfig,
+	passphrase string) (grpc.DialOption, error) {
+	if !enabled {
+		return grpc.WithInsecure(), nil
+	}
+
+	// The TLS key may reference a secret, only needed until it is loaded
+	keyFile, cleanup, err := secret.ResolveKeyFile(clientConfig.TLSKey, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve TLS key: %v", err)
+	}
+	clientConfig.TLSKey = keyFile
+
+	tlsConfig, err := clientConfig.TLSConfig()
+	cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	// Resume sessions when redialing, saving the devices full handshakes
+	tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(len(c.devices()))
+
+	// All targets share the same handshake slots, whichever TLS settings they use
+	creds := newLimitedCredentials(credentials.NewTLS(tlsConfig), c.MaxTLSHandshakes)
+	if limited, ok := creds.(*limitedCredentials); ok {
+		if c.handshakes == nil {
+			c.handshakes = limited.handshakes
+		}
+		limited.handshakes = c.handshakes
+	}
+	return grpc.WithTransportCredentials(creds), nil
+}
@@ -0,0 +1,70 @@
+package cisco_telemetry_gnmi
+
+import (
+	"strings"
+
+	"github.com/influxdata/telegraf/plugins/common/rpcerror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Classes of errors terminating a subscription
+const (
+	errorClassTLSExpired  = "tls_expired"
+	errorClassTLS         = "tls"
+	errorClassNetwork     = "network"
+	errorClassAuth        = "auth"
+	errorClassInvalid     = "invalid"
+	errorClassUnsupported = "unsupported"
+	errorClassResource    = "resource"
+	errorClassOther       = "other"
+)
+
+// Classes of the error tags of JSON encoded error lists of XR
+var errorTagClasses = map[string]string{
+	"access-denied":           errorClassAuth,
+	"invalid-value":           errorClassInvalid,
+	"unknown-element":         errorClassInvalid,
+	"unknown-namespace":       errorClassInvalid,
+	"bad-element":             errorClassInvalid,
+	"missing-element":         errorClassInvalid,
+	"data-missing":            errorClassInvalid,
+	"operation-not-supported": errorClassUnsupported,
+	"resource-denied":         errorClassResource,
+	"too-big":                 errorClassResource,
+}
+
+// ClassifyError distinguishes TLS handshake failures from network and other errors
+func classifyError(err error) string {
+	message := err.Error()
+
+	switch {
+	case strings.Contains(message, "certificate has expired or is not yet valid"):
+		return errorClassTLSExpired
+	case strings.Contains(message, "x509:") || strings.Contains(message, "tls:") ||
+		strings.Contains(message, "authentication handshake failed"):
+		return errorClassTLS
+	}
+
+	// Errors of the device reported with code Unknown are classified by their error tag
+	if decoded, ok := err.(*rpcerror.Error); ok {
+		if class, ok := errorTagClasses[decoded.Tag]; ok {
+			return class
+		}
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return errorClassNetwork
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return errorClassAuth
+	case codes.InvalidArgument, codes.NotFound:
+		return errorClassInvalid
+	case codes.Unimplemented:
+		return errorClassUnsupported
+	case codes.ResourceExhausted:
+		return errorClassResource
+	}
+
+	return errorClassOther
+}
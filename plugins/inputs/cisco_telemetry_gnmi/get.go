@@ -0,0 +1,71 @@
+package cisco_telemetry_gnmi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/influxdata/telegraf/plugins/common/rpcerror"
+	"github.com/influxdata/telegraf/plugins/common/transforms"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// SetupGets of paths polled every gather interval
+func (c *CiscoTelemetryGNMI) setupGets() error {
+	var err error
+	for i := range c.Gets {
+		get := &c.Gets[i]
+		if len(get.MergeKeys) > 0 && len(get.Name) == 0 {
+			return fmt.Errorf("E! GNMI get %s:%s merging keys requires a name", get.Origin, get.Path)
+		}
+		if get.transforms, err = transforms.NewRules(get.Transforms); err != nil {
+			return fmt.Errorf("E! Invalid GNMI get transforms: %v", err)
+		}
+	}
+	return nil
+}
+
+// PollTargets with a Get request of all polled paths, concurrently for all targets
+func (c *CiscoTelemetryGNMI) pollTargets(targets []*target) {
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t *target) {
+			defer wg.Done()
+			if err := c.poll(t); err != nil && t.ctx.Err() == nil {
+				c.acc.AddError(fmt.Errorf("E! GNMI get request to %s failed: %v", t.address, rpcerror.Decode(err, nil)))
+			}
+		}(t)
+	}
+	wg.Wait()
+}
+
+// Poll the paths of a target and handle the notifications like those of subscriptions
+func (c *CiscoTelemetryGNMI) poll(t *target) error {
+	ctx, err := c.authContext(t.ctx, t)
+	if err != nil {
+		return err
+	}
+
+	request := &gnmi.GetRequest{
+		Prefix:   c.requestPath(c.Origin, c.Prefix, c.Target),
+		Path:     make([]*gnmi.Path, len(c.Gets)),
+		Encoding: gnmi.Encoding(gnmi.Encoding_value[strings.ToUpper(c.encodings[atomic.LoadInt32(&t.encoding)])]),
+	}
+	for i, get := range c.Gets {
+		request.Path[i] = c.requestPath(get.Origin, get.Path, get.Target)
+	}
+
+	response, err := gnmi.NewGNMIClient(t.client).Get(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	for _, notification := range response.GetNotification() {
+		atomic.AddUint64(&t.notifications, 1)
+		atomic.AddUint64(&t.updates, uint64(len(notification.GetUpdate())))
+		c.handleSubscribeResponse(t, &gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}})
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package cisco_telemetry_gnmi
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Transport credentials bounding the number of concurrent TLS handshakes, so reconnecting many
+// targets at once does not saturate the CPU of the collector
+type limitedCredentials struct {
+	credentials.TransportCredentials
+	handshakes chan struct{}
+}
+
+func newLimitedCredentials(creds credentials.TransportCredentials, limit int) credentials.TransportCredentials {
+	if limit <= 0 {
+		return creds
+	}
+	return &limitedCredentials{TransportCredentials: creds, handshakes: make(chan struct{}, limit)}
+}
+
+// ClientHandshake waits for one of the handshake slots, or gives up when the dial is canceled
+func (l *limitedCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	select {
+	case l.handshakes <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	defer func() { <-l.handshakes }()
+
+	return l.TransportCredentials.ClientHandshake(ctx, authority, conn)
+}
+
+// Clone shares the handshake slots with the clone
+func (l *limitedCredentials) Clone() credentials.TransportCredentials {
+	return &limitedCredentials{TransportCredentials: l.TransportCredentials.Clone(), handshakes: l.handshakes}
+}
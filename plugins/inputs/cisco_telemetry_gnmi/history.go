@@ -0,0 +1,121 @@
+package cisco_telemetry_gnmi
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/influxdata/telegraf/plugins/common/rpcerror"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmi/proto/gnmi_ext"
+)
+
+// Field numbers of the history extension of gNMI 0.8, unknown to the vendored gnmi_ext
+const (
+	extensionHistory = 3
+	historyRange     = 2
+	timeRangeStart   = 1
+	timeRangeEnd     = 2
+)
+
+// HistoryExtension requests the data of a past time range, encoded as unrecognized field
+// of an extension as the vendored gnmi_ext predates the history extension
+func historyExtension(start time.Time, end time.Time) *gnmi_ext.Extension {
+	var timeRange, history, extension proto.Buffer
+
+	timeRange.EncodeVarint(timeRangeStart<<3 | proto.WireVarint)
+	timeRange.EncodeVarint(uint64(start.UnixNano()))
+	timeRange.EncodeVarint(timeRangeEnd<<3 | proto.WireVarint)
+	timeRange.EncodeVarint(uint64(end.UnixNano()))
+
+	history.EncodeVarint(historyRange<<3 | proto.WireBytes)
+	history.EncodeRawBytes(timeRange.Bytes())
+
+	extension.EncodeVarint(extensionHistory<<3 | proto.WireBytes)
+	extension.EncodeRawBytes(history.Bytes())
+
+	return &gnmi_ext.Extension{XXX_unrecognized: extension.Bytes()}
+}
+
+// ParseBackfill range of the configuration, the end defaulting to now
+func (c *CiscoTelemetryGNMI) parseBackfill() error {
+	if len(c.BackfillStart) == 0 {
+		if len(c.BackfillEnd) > 0 {
+			return fmt.Errorf("E! GNMI backfill_end requires backfill_start")
+		}
+		return nil
+	}
+
+	var err error
+	if c.backfillStart, err = time.Parse(time.RFC3339, c.BackfillStart); err != nil {
+		return fmt.Errorf("E! Invalid GNMI backfill_start: %v", err)
+	}
+
+	c.backfillEnd = time.Now()
+	if len(c.BackfillEnd) > 0 {
+		if c.backfillEnd, err = time.Parse(time.RFC3339, c.BackfillEnd); err != nil {
+			return fmt.Errorf("E! Invalid GNMI backfill_end: %v", err)
+		}
+	}
+
+	if !c.backfillStart.Before(c.backfillEnd) {
+		return fmt.Errorf("E! GNMI backfill_start must be before backfill_end")
+	}
+	return nil
+}
+
+// BackfillGNMI requests the data of the backfill range once and emits it with its original timestamps
+func (c *CiscoTelemetryGNMI) backfillGNMI(s *stream) {
+	defer c.wg.Done()
+	defer c.trackGoroutine(-1)
+	defer s.setState(streamStopped)
+	defer s.cancel()
+
+	if err := c.backfill(s); err != nil {
+		c.acc.AddError(fmt.Errorf("E! GNMI backfill from %s failed: %v", s.target.address, err))
+		return
+	}
+	log.Printf("I! GNMI backfill from %s of %s to %s complete", s.target.address,
+		c.backfillStart.Format(time.RFC3339), c.backfillEnd.Format(time.RFC3339))
+}
+
+// Backfill the data of a stream until the target signals the end of the history
+func (c *CiscoTelemetryGNMI) backfill(s *stream) error {
+	t := s.target
+	ctx, err := c.authContext(s.ctx, t)
+	if err != nil {
+		return err
+	}
+
+	request := c.subscribeRequest(c.encodings[0], s.subscriptions...)
+	request.Extension = append(request.Extension, historyExtension(c.backfillStart, c.backfillEnd))
+
+	// History is always streamed, even of subscriptions polled when live
+	request.GetSubscribe().Mode = gnmi.SubscriptionList_STREAM
+
+	subscribeClient, err := gnmi.NewGNMIClient(t.client).Subscribe(ctx)
+	if err == nil {
+		err = subscribeClient.Send(request)
+	}
+	if err != nil {
+		return rpcerror.Decode(err, nil)
+	}
+	s.setState(streamEstablished)
+
+	for {
+		reply, err := subscribeClient.Recv()
+		if err == io.EOF || s.ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return rpcerror.Decode(err, subscribeClient.Trailer())
+		}
+
+		// Targets close the stream or signal sync once all historical data has been sent
+		if reply.GetSyncResponse() {
+			return nil
+		}
+		c.handleSubscribeResponse(t, reply)
+	}
+}
@@ -0,0 +1,43 @@
+package cisco_telemetry_gnmi
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Interval of a subscription with sub-second precision. Unlike internal.Duration, which truncates
+// numbers to whole seconds, fractional seconds (e.g. 0.1) are kept as milliseconds.
+type Interval struct {
+	Duration time.Duration
+}
+
+// UnmarshalTOML parses durations (e.g. "100ms"), integer and fractional seconds
+func (i *Interval) UnmarshalTOML(b []byte) error {
+	value := string(bytes.Trim(b, `'`))
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+
+	// Parse numbers as seconds exactly by their decimal representation, avoiding float rounding
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		value += "s"
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid interval %s: %v", value, err)
+	}
+	if duration < 0 {
+		return fmt.Errorf("invalid negative interval %s", value)
+	}
+
+	i.Duration = duration
+	return nil
+}
+
+// SubSecond checks whether an interval is not a multiple of seconds
+func (i Interval) subSecond() bool {
+	return i.Duration%time.Second != 0
+}
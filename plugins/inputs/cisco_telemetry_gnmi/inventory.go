@@ -0,0 +1,96 @@
+package cisco_telemetry_gnmi
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/common/inventory"
+)
+
+// Interval of fetching the devices of the inventory if not configured
+const defaultInventoryInterval = 5 * time.Minute
+
+// SetInventory provides the devices to subscribe to in addition to the configured ones, e.g. by
+// applications embedding Telegraf with their own source of truth. It must be called before Start.
+func (c *CiscoTelemetryGNMI) SetInventory(provider inventory.Provider) {
+	c.inventory = provider
+}
+
+// SyncInventory keeps the targets of the devices of the inventory in sync with it
+func (c *CiscoTelemetryGNMI) syncInventory() {
+	defer c.wg.Done()
+	defer c.trackGoroutine(-1)
+
+	interval := c.InventoryInterval.Duration
+	if interval <= 0 {
+		interval = defaultInventoryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	targets := make(map[string]*target)
+	for {
+		c.updateInventory(targets)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// UpdateInventory fetches the devices of the inventory, starting targets of new devices and
+// stopping those of removed ones. Targets are kept as they are if the inventory is unavailable.
+func (c *CiscoTelemetryGNMI) updateInventory(targets map[string]*target) {
+	devices, err := c.inventory.Devices(c.ctx)
+	if err != nil {
+		if c.ctx.Err() == nil {
+			c.acc.AddError(fmt.Errorf("E! Failed to fetch GNMI inventory: %v", err))
+		}
+		return
+	}
+
+	// Configured devices take precedence over those of the inventory
+	configured := make(map[string]bool)
+	for _, device := range c.devices() {
+		configured[device] = true
+	}
+	wanted := make(map[string]inventory.Device, len(devices))
+	for _, device := range devices {
+		if configured[device.Address] {
+			continue
+		}
+		if device.Tags == nil {
+			device.Tags = make(map[string]string)
+		}
+		wanted[device.Address] = device
+	}
+
+	// Targets of devices with changed tags are restarted to apply them
+	for address, t := range targets {
+		if device, ok := wanted[address]; !ok || !reflect.DeepEqual(device.Tags, t.tags) {
+			c.stopTarget(t)
+			delete(targets, address)
+			log.Printf("I! Removed GNMI inventory device %s", address)
+		}
+	}
+
+	for address, device := range wanted {
+		if _, ok := targets[address]; ok {
+			continue
+		}
+
+		t := &target{address: address, producer: address, tags: device.Tags, intervalScale: 1}
+		if err := c.startTarget(t); err != nil {
+			if c.ctx.Err() == nil {
+				c.acc.AddError(fmt.Errorf("E! Failed to start GNMI inventory device %s: %v", address, err))
+			}
+			continue
+		}
+		targets[address] = t
+		log.Printf("I! Added GNMI inventory device %s", address)
+	}
+}
@@ -0,0 +1,21 @@
+package cisco_telemetry_gnmi
+
+import "strings"
+
+// RenameMergeKeys of a measurement of a subscription merged with subscriptions of other origins,
+// renaming tags of list keys by their short or path-qualified name to the shared key tags
+func (s *Subscription) renameMergeKeys(tags map[string]string) {
+	for tag, val := range tags {
+		key := tag
+		if i := strings.LastIndexByte(tag, '/'); i >= 0 {
+			key = tag[i+1:]
+		}
+
+		merged, ok := s.MergeKeys[key]
+		if !ok || merged == tag {
+			continue
+		}
+		delete(tags, tag)
+		tags[merged] = val
+	}
+}
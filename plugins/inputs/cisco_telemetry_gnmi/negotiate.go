@@ -0,0 +1,61 @@
+package cisco_telemetry_gnmi
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/common/rpcerror"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Encoding negotiated with the target by its capabilities
+const encodingAuto = "auto"
+
+// Encodings in order of preference when negotiated
+var negotiatedEncodings = []string{"proto", "json_ietf", "json"}
+
+// Timeout of the capability request negotiating the encoding
+const negotiationTimeout = 10 * time.Second
+
+// NegotiateEncoding requests the encodings supported by the target once and selects the most
+// preferred of them. Streams of the target wait until the negotiation is done.
+func (c *CiscoTelemetryGNMI) negotiateEncoding(t *target) {
+	t.negotiation.Do(func() {
+		ctx, cancel := context.WithTimeout(t.ctx, negotiationTimeout)
+		defer cancel()
+
+		ctx, err := c.authContext(ctx, t)
+		var response *gnmi.CapabilityResponse
+		if err == nil {
+			response, err = gnmi.NewGNMIClient(t.client).Capabilities(ctx, &gnmi.CapabilityRequest{})
+		}
+		if err != nil {
+			if t.ctx.Err() == nil {
+				log.Printf("W! GNMI capability request of %s failed, not negotiating encoding: %v",
+					t.address, rpcerror.Decode(err, nil))
+			}
+			return
+		}
+
+		supported := make(map[gnmi.Encoding]bool, len(response.SupportedEncodings))
+		names := make([]string, 0, len(response.SupportedEncodings))
+		for _, encoding := range response.SupportedEncodings {
+			supported[encoding] = true
+			names = append(names, strings.ToLower(encoding.String()))
+		}
+
+		for i, encoding := range c.encodings {
+			if supported[gnmi.Encoding(gnmi.Encoding_value[strings.ToUpper(encoding)])] {
+				atomic.StoreInt32(&t.encoding, int32(i))
+				log.Printf("I! GNMI device %s supports encodings %s and %d models, using %s", t.address,
+					strings.Join(names, ", "), len(response.SupportedModels), encoding)
+				return
+			}
+		}
+		log.Printf("W! GNMI device %s supports none of the encodings %s, only %s", t.address,
+			strings.Join(c.encodings, ", "), strings.Join(names, ", "))
+	})
+}
@@ -0,0 +1,216 @@
+package cisco_telemetry_gnmi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Period of checking the paths files for changes
+const pathsFilePeriod = 10 * time.Second
+
+// Subscriptions of a paths file sharing origin, mode, interval and aliases
+type pathsFile struct {
+	Origin           string            `yaml:"origin"`
+	SubscriptionMode string            `yaml:"subscription_mode"`
+	SampleInterval   Interval          `yaml:"sample_interval"`
+	Aliases          map[string]string `yaml:"aliases"`
+	Paths            pathList          `yaml:"paths"`
+}
+
+// List of paths given as sequence or as multi-line string with one path per line
+type pathList []string
+
+// UnmarshalYAML parses a sequence of paths or a multi-line string, skipping empty and comment lines
+func (p *pathList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return value.Decode((*[]string)(p))
+	}
+
+	for _, line := range strings.Split(value.Value, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) > 0 && !strings.HasPrefix(line, "#") {
+			*p = append(*p, line)
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML parses intervals like UnmarshalTOML
+func (i *Interval) UnmarshalYAML(value *yaml.Node) error {
+	return i.UnmarshalTOML([]byte(value.Value))
+}
+
+// PathsFilesState returns the names, sizes and modification times of the paths files to detect changes
+func (c *CiscoTelemetryGNMI) pathsFilesState() ([]string, string, error) {
+	files, err := filepath.Glob(c.PathsFile)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(files)
+
+	var state strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(&state, "%s %d %d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return files, state.String(), nil
+}
+
+// LoadPathsFiles into subscriptions, returning the state of the files
+func (c *CiscoTelemetryGNMI) loadPathsFiles() ([]*Subscription, string, error) {
+	files, state, err := c.pathsFilesState()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var subscriptions []*Subscription
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, state, err
+		}
+
+		var config pathsFile
+		if err = yaml.Unmarshal(data, &config); err != nil {
+			return nil, state, fmt.Errorf("%s: %v", file, err)
+		}
+
+		switch strings.ToLower(config.SubscriptionMode) {
+		case "", "target_defined", "sample", "on_change", "poll":
+		default:
+			return nil, state, fmt.Errorf("%s: invalid subscription mode: %s", file, config.SubscriptionMode)
+		}
+
+		for _, path := range config.Paths {
+			subscription := &Subscription{
+				Origin:           config.Origin,
+				Path:             path,
+				SubscriptionMode: config.SubscriptionMode,
+				SampleInterval:   config.SampleInterval,
+				alias:            config.Aliases[path],
+				file:             file,
+			}
+
+			// Paths may also be given in the form origin:path as used by MDT encoding paths
+			if i := strings.IndexRune(path, ':'); i > 0 && !strings.ContainsRune(path[:i], '/') {
+				subscription.Origin, subscription.Path = path[:i], path[i+1:]
+			}
+
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+
+	return subscriptions, state, nil
+}
+
+// WatchPathsFiles periodically and update the subscriptions once the files changed
+func (c *CiscoTelemetryGNMI) watchPathsFiles(state string) {
+	defer c.wg.Done()
+	defer c.trackGoroutine(-1)
+
+	ticker := time.NewTicker(pathsFilePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, current, err := c.pathsFilesState(); err != nil || current == state {
+			continue
+		}
+
+		// Invalid files are reported once, keeping the previous subscriptions until fixed
+		subscriptions, current, err := c.loadPathsFiles()
+		state = current
+		if err != nil {
+			c.acc.AddError(fmt.Errorf("E! Failed to reload GNMI paths files: %v", err))
+			continue
+		}
+
+		c.updateFileSubscriptions(subscriptions)
+	}
+}
+
+// UpdateFileSubscriptions to the subscriptions loaded from the paths files, restarting the
+// streams of added, removed and changed subscriptions only
+func (c *CiscoTelemetryGNMI) updateFileSubscriptions(subscriptions []*Subscription) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.ctx.Err() != nil {
+		return
+	}
+
+	loaded := make(map[string]*Subscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		loaded[subscription.Origin+":"+subscription.Path] = subscription
+	}
+
+	runtime := make([]*Subscription, 0, len(c.runtimeSubscriptions)+len(subscriptions))
+	var added []*Subscription
+	for _, subscription := range c.runtimeSubscriptions {
+		key := subscription.Origin + ":" + subscription.Path
+		if len(subscription.file) == 0 || equalFileSubscriptions(subscription, loaded[key]) {
+			runtime = append(runtime, subscription)
+			delete(loaded, key)
+			continue
+		}
+
+		c.stopStreams(subscription)
+		log.Printf("I! Removed GNMI subscription %s from paths file %s", key, subscription.file)
+	}
+
+	for _, subscription := range subscriptions {
+		key := subscription.Origin + ":" + subscription.Path
+		if loaded[key] != subscription {
+			continue
+		}
+		delete(loaded, key)
+
+		if c.configuredSubscription(subscription.Origin, subscription.Path) {
+			log.Printf("W! Ignoring GNMI subscription %s from paths file %s, configured already", key, subscription.file)
+			continue
+		}
+
+		runtime = append(runtime, subscription)
+		added = append(added, subscription)
+	}
+
+	// Subscriptions are looked up by their notifications once streamed
+	c.runtimeSubscriptions = runtime
+	c.indexSubscriptions()
+	for _, subscription := range added {
+		c.startStreams(subscription)
+		log.Printf("I! Added GNMI subscription %s from paths file %s", subscription.Origin+":"+subscription.Path, subscription.file)
+	}
+}
+
+// ConfiguredSubscription checks whether a subscription to a path is part of the configuration
+func (c *CiscoTelemetryGNMI) configuredSubscription(origin string, path string) bool {
+	for _, configured := range c.Subscriptions {
+		if configured.Origin == origin && configured.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// EqualFileSubscriptions checks whether a subscription loaded from a paths file is unchanged
+func equalFileSubscriptions(a *Subscription, b *Subscription) bool {
+	return b != nil && a.file == b.file && a.SubscriptionMode == b.SubscriptionMode &&
+		a.SampleInterval == b.SampleInterval && a.alias == b.alias
+}
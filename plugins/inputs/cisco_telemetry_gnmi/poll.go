@@ -0,0 +1,56 @@
+package cisco_telemetry_gnmi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Polled checks whether all subscriptions are polled instead of streamed
+func polled(subscriptions []*Subscription) bool {
+	for _, subscription := range subscriptions {
+		if !strings.EqualFold(subscription.SubscriptionMode, "poll") {
+			return false
+		}
+	}
+	return len(subscriptions) > 0
+}
+
+// PollStreams of all targets once per gather interval, skipping streams not established or
+// with a poll still pending
+func (c *CiscoTelemetryGNMI) pollStreams(targets []*target) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, t := range targets {
+		for _, s := range t.streams {
+			if s.polls == nil || s.getState() != streamStates[streamEstablished] {
+				continue
+			}
+			select {
+			case s.polls <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// SendPolls on a subscription of a polled stream until the subscription ends
+func (c *CiscoTelemetryGNMI) sendPolls(ctx context.Context, s *stream, client gnmi.GNMI_SubscribeClient) {
+	defer c.trackGoroutine(-1)
+
+	request := &gnmi.SubscribeRequest{Request: &gnmi.SubscribeRequest_Poll{Poll: &gnmi.Poll{}}}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.polls:
+		}
+
+		// Send failures end the subscription, reported by its receiving side
+		if err := client.Send(request); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package cisco_telemetry_gnmi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	"github.com/influxdata/telegraf/plugins/common/rpcerror"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// State of the leaves of on-change subscriptions of a target as last received
+type stateCache struct {
+	mutex  sync.Mutex
+	values map[string]*gnmi.TypedValue
+}
+
+func newStateCache() *stateCache {
+	return &stateCache{values: make(map[string]*gnmi.TypedValue)}
+}
+
+// StateKey of a leaf, its full path with keys sorted
+func stateKey(prefix *gnmi.Path, path *gnmi.Path) string {
+	full := &gnmi.Path{Origin: prefix.GetOrigin()}
+	if len(path.GetOrigin()) > 0 {
+		full.Origin = path.Origin
+	}
+	full.Elem = append(append(full.Elem, gnmidecode.PathElems(prefix)...), gnmidecode.PathElems(path)...)
+	return gnmidecode.CanonicalPath(&gnmi.Notification{Prefix: full})
+}
+
+// Update the state by the deletes and updates of a notification
+func (s *stateCache) update(notification *gnmi.Notification) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, path := range notification.Delete {
+		deleted := stateKey(notification.GetPrefix(), path)
+		for key := range s.values {
+			if key == deleted || strings.HasPrefix(key, deleted+"/") {
+				delete(s.values, key)
+			}
+		}
+	}
+
+	walker := s.newLeafWalker(notification.GetPrefix())
+	for _, update := range notification.Update {
+		walker.walkUpdate(update)
+	}
+	for _, leaf := range walker.leaves {
+		s.values[leaf.key] = leaf.val
+	}
+}
+
+// Mismatches returns the leaves of a notification differing from the state, nil if none
+func (s *stateCache) mismatches(notification *gnmi.Notification) *gnmi.Notification {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	walker := s.newLeafWalker(notification.GetPrefix())
+	for _, update := range notification.Update {
+		walker.walkUpdate(update)
+	}
+
+	var updates []*gnmi.Update
+	for _, leaf := range walker.leaves {
+		if !proto.Equal(s.values[leaf.key], leaf.val) {
+			updates = append(updates, &gnmi.Update{Path: leaf.path, Val: leaf.val})
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	corrected := *notification
+	corrected.Update = updates
+	corrected.Delete = nil
+	return &corrected
+}
+
+// Leaf of a notification by its state key and its path relative to the prefix
+type stateLeaf struct {
+	key  string
+	path *gnmi.Path
+	val  *gnmi.TypedValue
+}
+
+// LeafWalker flattening the updates of a notification into leaves, so that JSON containers, e.g.
+// of Get responses, compare with the leaves of PROTO subscriptions
+type leafWalker struct {
+	state  *stateCache
+	prefix *gnmi.Path
+	keys   map[string][]string
+	leaves []stateLeaf
+}
+
+func (s *stateCache) newLeafWalker(prefix *gnmi.Path) *leafWalker {
+	return &leafWalker{state: s, prefix: prefix, keys: make(map[string][]string)}
+}
+
+// WalkUpdate adds the leaves of an update, the update itself unless its value is JSON
+func (w *leafWalker) walkUpdate(update *gnmi.Update) {
+	var data []byte
+	switch value := update.GetVal().GetValue().(type) {
+	case *gnmi.TypedValue_JsonVal:
+		data = value.JsonVal
+	case *gnmi.TypedValue_JsonIetfVal:
+		data = value.JsonIetfVal
+	default:
+		w.leaves = append(w.leaves, stateLeaf{key: stateKey(w.prefix, update.GetPath()), path: update.GetPath(), val: update.GetVal()})
+		return
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return
+	}
+	path := &gnmi.Path{Origin: update.GetPath().GetOrigin(), Elem: gnmidecode.PathElems(update.GetPath())}
+	w.walk(path, value)
+}
+
+// Walk a JSON value at a path. Entries of lists are keyed by the keys of the list known from the
+// state, entries of lists whose keys were never received cannot be compared and are skipped.
+func (w *leafWalker) walk(path *gnmi.Path, value interface{}) {
+	key := stateKey(w.prefix, path)
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, member := range v {
+			// Members are qualified by module where the namespace changes in JSON_IETF
+			if i := strings.IndexByte(name, ':'); i >= 0 {
+				name = name[i+1:]
+			}
+			w.walk(appendElem(path, &gnmi.PathElem{Name: name}), member)
+		}
+
+	case []interface{}:
+		if len(v) > 0 {
+			if _, ok := v[0].(map[string]interface{}); ok {
+				w.walkList(path, key, v)
+				return
+			}
+		}
+
+		var like *gnmi.TypedValue
+		if elements := w.state.values[key].GetLeaflistVal().GetElement(); len(elements) > 0 {
+			like = elements[0]
+		}
+		leaflist := &gnmi.ScalarArray{}
+		for _, element := range v {
+			if val := jsonValue(element, like); val != nil {
+				leaflist.Element = append(leaflist.Element, val)
+			}
+		}
+		w.leaves = append(w.leaves, stateLeaf{key: key, path: path,
+			val: &gnmi.TypedValue{Value: &gnmi.TypedValue_LeaflistVal{LeaflistVal: leaflist}}})
+
+	default:
+		if val := jsonValue(v, w.state.values[key]); val != nil {
+			w.leaves = append(w.leaves, stateLeaf{key: key, path: path, val: val})
+		}
+	}
+}
+
+// WalkList adds the leaves of the entries of a list, except for its keys being part of the path
+func (w *leafWalker) walkList(path *gnmi.Path, key string, entries []interface{}) {
+	names := w.listKeys(key)
+	if len(names) == 0 {
+		return
+	}
+
+	for _, entry := range entries {
+		members, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		elem := &gnmi.PathElem{Name: path.Elem[len(path.Elem)-1].Name, Key: make(map[string]string, len(names))}
+		for _, name := range names {
+			val, ok := members[name]
+			if !ok {
+				break
+			}
+			elem.Key[name] = fmt.Sprint(val)
+		}
+		if len(elem.Key) < len(names) {
+			continue
+		}
+
+		entryPath := &gnmi.Path{Origin: path.Origin, Elem: append(append([]*gnmi.PathElem{}, path.Elem[:len(path.Elem)-1]...), elem)}
+		for name, member := range members {
+			if _, isKey := elem.Key[name]; !isKey {
+				w.walk(appendElem(entryPath, &gnmi.PathElem{Name: name}), member)
+			}
+		}
+	}
+}
+
+// ListKeys of the list at a state key, from the paths of the state below the list
+func (w *leafWalker) listKeys(list string) []string {
+	if names, ok := w.keys[list]; ok {
+		return names
+	}
+
+	var names []string
+	for key := range w.state.values {
+		if !strings.HasPrefix(key, list+"[") {
+			continue
+		}
+		for selector := key[len(list):]; strings.HasPrefix(selector, "["); {
+			end := strings.IndexByte(selector, ']')
+			equals := strings.IndexByte(selector, '=')
+			if end < 0 || equals < 0 || equals > end {
+				break
+			}
+			names = append(names, selector[1:equals])
+			selector = selector[end+1:]
+		}
+		break
+	}
+
+	w.keys[list] = names
+	return names
+}
+
+// AppendElem to a copy of a path
+func appendElem(path *gnmi.Path, elem *gnmi.PathElem) *gnmi.Path {
+	elems := make([]*gnmi.PathElem, len(path.Elem), len(path.Elem)+1)
+	copy(elems, path.Elem)
+	return &gnmi.Path{Origin: path.Origin, Elem: append(elems, elem)}
+}
+
+// JsonValue of a JSON scalar as typed value of the same kind as the value it is compared with,
+// e.g. the numeric strings of 64-bit integers in JSON_IETF as unsigned integers, nil for empty leaves
+func jsonValue(value interface{}, like *gnmi.TypedValue) *gnmi.TypedValue {
+	if value == nil {
+		return nil
+	}
+	text := fmt.Sprint(value)
+
+	switch like.GetValue().(type) {
+	case *gnmi.TypedValue_UintVal:
+		if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: n}}
+		}
+	case *gnmi.TypedValue_IntVal:
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return &gnmi.TypedValue{Value: &gnmi.TypedValue_IntVal{IntVal: n}}
+		}
+	case *gnmi.TypedValue_FloatVal:
+		if f, err := strconv.ParseFloat(text, 32); err == nil {
+			return &gnmi.TypedValue{Value: &gnmi.TypedValue_FloatVal{FloatVal: float32(f)}}
+		}
+	case *gnmi.TypedValue_DecimalVal:
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			precision := like.GetDecimalVal().GetPrecision()
+			digits := int64(math.Round(f * math.Pow10(int(precision))))
+			return &gnmi.TypedValue{Value: &gnmi.TypedValue_DecimalVal{DecimalVal: &gnmi.Decimal64{Digits: digits, Precision: precision}}}
+		}
+	case *gnmi.TypedValue_BoolVal:
+		if b, err := strconv.ParseBool(text); err == nil {
+			return &gnmi.TypedValue{Value: &gnmi.TypedValue_BoolVal{BoolVal: b}}
+		}
+	case *gnmi.TypedValue_StringVal:
+		return &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: text}}
+	case nil:
+		// Doubles are kept as unrecognized field by this gNMI version
+		if double, _ := gnmidecode.DecodeTypedValue(like); double != nil {
+			if f, err := strconv.ParseFloat(text, 64); err == nil {
+				return gnmidecode.NewDoubleValue(f)
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case bool:
+		return &gnmi.TypedValue{Value: &gnmi.TypedValue_BoolVal{BoolVal: v}}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return &gnmi.TypedValue{Value: &gnmi.TypedValue_IntVal{IntVal: n}}
+		}
+		if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: n}}
+		}
+		if f, err := v.Float64(); err == nil {
+			return gnmidecode.NewDoubleValue(f)
+		}
+	}
+	return &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: text}}
+}
+
+// ReconcileGNMI state of an on-change subscription periodically with a Get request
+func (c *CiscoTelemetryGNMI) reconcileGNMI(t *target, subscription *Subscription) {
+	ticker := time.NewTicker(subscription.ReconcileInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			c.trackGoroutine(-1)
+			c.wg.Done()
+			return
+		case <-ticker.C:
+		}
+
+		if err := c.reconcile(t, subscription); err != nil && t.ctx.Err() == nil {
+			c.acc.AddError(fmt.Errorf("E! GNMI reconciliation of %s failed: %v", subscription.Path, rpcerror.Decode(err, nil)))
+		}
+	}
+}
+
+// Reconcile the state of a subscription with the device, emitting values missed by the subscription
+func (c *CiscoTelemetryGNMI) reconcile(t *target, subscription *Subscription) error {
+	ctx, err := c.authContext(t.ctx, t)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, subscription.ReconcileInterval.Duration)
+	defer cancel()
+
+	// Devices answer Get with JSON containers, compared leaf by leaf with the subscribed state
+	response, err := gnmi.NewGNMIClient(t.client).Get(ctx, &gnmi.GetRequest{
+		Prefix:   c.requestPath(c.Origin, c.Prefix, c.Target),
+		Path:     []*gnmi.Path{c.requestPath(subscription.Origin, subscription.Path, subscription.Target)},
+		Encoding: gnmi.Encoding_JSON_IETF,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, notification := range response.GetNotification() {
+		corrected := t.state.mismatches(notification)
+		if corrected == nil {
+			continue
+		}
+
+		atomic.AddUint64(&t.corrections, uint64(len(corrected.Update)))
+		log.Printf("D! GNMI reconciliation of %s on %s corrected %d updates", subscription.Path, t.address, len(corrected.Update))
+		c.handleSubscribeResponse(t, &gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: corrected}})
+	}
+	return nil
+}
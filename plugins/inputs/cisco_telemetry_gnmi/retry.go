@@ -0,0 +1,69 @@
+package cisco_telemetry_gnmi
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Backoffs of retries if not configured
+const (
+	defaultRetryInitialBackoff = time.Second
+	defaultRetryMaxBackoff     = 5 * time.Second
+)
+
+// Service config of gRPC, limited to the retry policy of the methods of the gNMI service
+type serviceConfig struct {
+	MethodConfig []methodConfig `json:"methodConfig"`
+}
+
+type methodConfig struct {
+	Name        []methodName `json:"name"`
+	RetryPolicy retryPolicy  `json:"retryPolicy"`
+}
+
+type methodName struct {
+	Service string `json:"service"`
+}
+
+type retryPolicy struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+// RetryServiceConfig retrying all RPCs of the gNMI service failing with UNAVAILABLE, which gRPC
+// only does until the first response of a stream has been received
+func (c *CiscoTelemetryGNMI) retryServiceConfig() string {
+	initial, max := c.RetryInitialBackoff.Duration, c.RetryMaxBackoff.Duration
+	if initial <= 0 {
+		initial = defaultRetryInitialBackoff
+	}
+	if max < initial {
+		max = defaultRetryMaxBackoff
+		if max < initial {
+			max = initial
+		}
+	}
+
+	config := serviceConfig{MethodConfig: []methodConfig{{
+		Name: []methodName{{Service: "gnmi.gNMI"}},
+		RetryPolicy: retryPolicy{
+			MaxAttempts:          c.RetryMaxAttempts,
+			InitialBackoff:       serviceConfigDuration(initial),
+			MaxBackoff:           serviceConfigDuration(max),
+			BackoffMultiplier:    2,
+			RetryableStatusCodes: []string{"UNAVAILABLE"},
+		},
+	}}}
+
+	data, _ := json.Marshal(config)
+	return string(data)
+}
+
+// ServiceConfigDuration in the JSON representation of protobuf durations, e.g. "1.5s"
+func serviceConfigDuration(d time.Duration) string {
+	return fmt.Sprintf("%.9fs", d.Seconds())
+}
@@ -0,0 +1,281 @@
+package cisco_telemetry_gnmi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Origin of the paths of the statistics served by the self target
+const selfOrigin = "telegraf"
+
+// Sample interval of streamed subscriptions to the self target not requesting one
+const selfSampleInterval = 10 * time.Second
+
+// SelfServer serves the statistics of the plugin as read-only gNMI target
+type selfServer struct {
+	plugin *CiscoTelemetryGNMI
+}
+
+// StartSelf listens for gNMI clients monitoring the plugin itself
+func (c *CiscoTelemetryGNMI) startSelf() error {
+	listener, err := net.Listen("tcp", c.SelfAddress)
+	if err != nil {
+		return fmt.Errorf("E! Failed to listen for GNMI self target: %v", err)
+	}
+
+	c.self = grpc.NewServer()
+	gnmi.RegisterGNMIServer(c.self, &selfServer{plugin: c})
+
+	c.wg.Add(1)
+	c.trackGoroutine(1)
+	go func() {
+		c.self.Serve(listener)
+		c.trackGoroutine(-1)
+		c.wg.Done()
+	}()
+
+	log.Printf("I! Serving GNMI self target on %s", listener.Addr())
+	return nil
+}
+
+// Capabilities of the self target
+func (s *selfServer) Capabilities(context.Context, *gnmi.CapabilityRequest) (*gnmi.CapabilityResponse, error) {
+	return &gnmi.CapabilityResponse{
+		SupportedEncodings: []gnmi.Encoding{gnmi.Encoding_PROTO, gnmi.Encoding_JSON, gnmi.Encoding_JSON_IETF},
+		GNMIVersion:        "0.7.0",
+	}, nil
+}
+
+// Get the current statistics matching the requested paths
+func (s *selfServer) Get(_ context.Context, request *gnmi.GetRequest) (*gnmi.GetResponse, error) {
+	notifications, err := s.plugin.selfNotifications(request.GetPrefix(), request.GetPath(), request.GetEncoding(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &gnmi.GetResponse{Notification: notifications}, nil
+}
+
+// Set is rejected, the self target is read-only
+func (s *selfServer) Set(context.Context, *gnmi.SetRequest) (*gnmi.SetResponse, error) {
+	return nil, status.Error(codes.PermissionDenied, "self target is read-only")
+}
+
+// Subscribe to the statistics once, polled or sampled periodically
+func (s *selfServer) Subscribe(server gnmi.GNMI_SubscribeServer) error {
+	request, err := server.Recv()
+	if err != nil {
+		return err
+	}
+	list := request.GetSubscribe()
+	if list == nil {
+		return status.Error(codes.InvalidArgument, "subscription list expected")
+	}
+
+	paths := make([]*gnmi.Path, len(list.Subscription))
+	interval := time.Duration(0)
+	for i, subscription := range list.Subscription {
+		paths[i] = subscription.GetPath()
+		sample := time.Duration(subscription.SampleInterval)
+		if sample > 0 && (interval == 0 || sample < interval) {
+			interval = sample
+		}
+	}
+	if interval == 0 {
+		interval = selfSampleInterval
+	}
+
+	send := func(sync bool) error {
+		notifications, err := s.plugin.selfNotifications(list.Prefix, paths, list.Encoding, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, notification := range notifications {
+			if err := server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: notification}}); err != nil {
+				return err
+			}
+		}
+		if !sync {
+			return nil
+		}
+		return server.Send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}})
+	}
+
+	if err := send(true); err != nil {
+		return err
+	}
+
+	switch list.Mode {
+	case gnmi.SubscriptionList_ONCE:
+		return nil
+	case gnmi.SubscriptionList_POLL:
+		for {
+			request, err := server.Recv()
+			if err != nil {
+				return err
+			}
+			if request.GetPoll() == nil {
+				return status.Error(codes.InvalidArgument, "poll expected")
+			}
+			if err := send(true); err != nil {
+				return err
+			}
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-server.Context().Done():
+			return nil
+		case <-s.plugin.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := send(false); err != nil {
+			return err
+		}
+	}
+}
+
+// SelfNotifications of the statistics of all targets and their streams matching any of the paths
+// below the prefix, all statistics if no path is given
+func (c *CiscoTelemetryGNMI) selfNotifications(prefix *gnmi.Path, paths []*gnmi.Path, encoding gnmi.Encoding,
+	now time.Time) ([]*gnmi.Notification, error) {
+	switch encoding {
+	case gnmi.Encoding_PROTO, gnmi.Encoding_JSON, gnmi.Encoding_JSON_IETF:
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "unsupported encoding %s", encoding)
+	}
+
+	var requested [][]*gnmi.PathElem
+	for _, path := range paths {
+		requested = append(requested, append(append([]*gnmi.PathElem{}, prefix.GetElem()...), path.GetElem()...))
+	}
+	if len(paths) == 0 && len(prefix.GetElem()) > 0 {
+		requested = append(requested, prefix.GetElem())
+	}
+
+	notifications := make([]*gnmi.Notification, 0)
+	add := func(elems []*gnmi.PathElem, leaves map[string]interface{}) error {
+		notification := &gnmi.Notification{Timestamp: now.UnixNano(), Prefix: &gnmi.Path{Origin: selfOrigin, Elem: elems}}
+		for name, value := range leaves {
+			full := append(append([]*gnmi.PathElem{}, elems...), &gnmi.PathElem{Name: name})
+			if !selfRequested(requested, full) {
+				continue
+			}
+			val, err := selfValue(value, encoding)
+			if err != nil {
+				return err
+			}
+			notification.Update = append(notification.Update, &gnmi.Update{Path: &gnmi.Path{Elem: []*gnmi.PathElem{{Name: name}}}, Val: val})
+		}
+		if len(notification.Update) > 0 {
+			notifications = append(notifications, notification)
+		}
+		return nil
+	}
+
+	for _, statistics := range c.Statistics() {
+		target := &gnmi.PathElem{Name: "target", Key: map[string]string{"address": statistics.Address}}
+		leaves := map[string]interface{}{
+			"producer":       statistics.Producer,
+			"responses":      statistics.Responses,
+			"errors":         statistics.Errors,
+			"redials":        statistics.Redials,
+			"notifications":  statistics.Notifications,
+			"updates":        statistics.Updates,
+			"decode-errors":  statistics.DecodeErrors,
+			"corrections":    statistics.Corrections,
+			"bytes-received": statistics.BytesReceived,
+			"bandwidth":      statistics.Bandwidth,
+		}
+		if len(statistics.LastError) > 0 {
+			leaves["last-error"] = statistics.LastError
+		}
+		if err := add([]*gnmi.PathElem{{Name: "collector"}, {Name: "targets"}, target, {Name: "state"}}, leaves); err != nil {
+			return nil, err
+		}
+
+		for _, stream := range statistics.Streams {
+			leaves := map[string]interface{}{
+				"path":    stream.Path,
+				"state":   stream.State,
+				"updates": stream.Updates,
+				"errors":  stream.Errors,
+			}
+			elems := []*gnmi.PathElem{{Name: "collector"}, {Name: "targets"}, target, {Name: "streams"},
+				{Name: "stream", Key: map[string]string{"name": stream.Name}}, {Name: "state"}}
+			if err := add(elems, leaves); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return notifications, nil
+}
+
+// SelfRequested checks whether a leaf is below any of the requested paths, matching wildcards
+// and the keys given in the requested paths
+func selfRequested(requested [][]*gnmi.PathElem, leaf []*gnmi.PathElem) bool {
+	if len(requested) == 0 {
+		return true
+	}
+
+next:
+	for _, path := range requested {
+		if len(path) > len(leaf) {
+			continue
+		}
+		for i, elem := range path {
+			if elem.Name == "..." {
+				return true
+			}
+			if elem.Name != "*" && elem.Name != leaf[i].Name {
+				continue next
+			}
+			for key, val := range elem.Key {
+				if val != "*" && leaf[i].Key[key] != val {
+					continue next
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// SelfValue of a leaf in the requested encoding
+func selfValue(value interface{}, encoding gnmi.Encoding) (*gnmi.TypedValue, error) {
+	switch encoding {
+	case gnmi.Encoding_JSON, gnmi.Encoding_JSON_IETF:
+		// JSON_IETF encodes 64-bit integers as strings
+		if number, ok := value.(uint64); ok && encoding == gnmi.Encoding_JSON_IETF {
+			value = fmt.Sprint(number)
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if encoding == gnmi.Encoding_JSON {
+			return &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonVal{JsonVal: data}}, nil
+		}
+		return &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: data}}, nil
+	}
+
+	switch v := value.(type) {
+	case uint64:
+		return &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: v}}, nil
+	case string:
+		return &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: v}}, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
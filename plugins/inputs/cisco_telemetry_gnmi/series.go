@@ -0,0 +1,153 @@
+package cisco_telemetry_gnmi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SeriesStatistics is a snapshot of when a series of a target was first and last seen
+type SeriesStatistics struct {
+	Address   string    `json:"address"`
+	Producer  string    `json:"producer"`
+	Path      string    `json:"path"`
+	Updates   uint64    `json:"updates"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Series of a target by path including keys, tracked if enabled
+type seriesTracker struct {
+	series map[string]*seriesTimes
+	mutex  sync.Mutex
+
+	// Series created and rejected since start, and created and rejected in the current window
+	created, rejected             uint64
+	windowCreated, windowRejected int
+}
+
+// Reception times of a series
+type seriesTimes struct {
+	updates   uint64
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+func newSeriesTracker() *seriesTracker {
+	return &seriesTracker{series: make(map[string]*seriesTimes)}
+}
+
+// Admit the reception of an update of a series, rejecting new series once the limit of series
+// created in the current window is reached, unless the limit is zero
+func (s *seriesTracker) admit(path string, now time.Time, limit int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	times, ok := s.series[path]
+	if !ok {
+		if limit > 0 && s.windowCreated >= limit {
+			s.rejected++
+			s.windowRejected++
+			return false
+		}
+		times = &seriesTimes{firstSeen: now}
+		s.series[path] = times
+		s.created++
+		s.windowCreated++
+	}
+	times.updates++
+	times.lastSeen = now
+	return true
+}
+
+// NextWindow starts a new window, returning the series created and rejected in the last one
+func (s *seriesTracker) nextWindow() (int, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	created, rejected := s.windowCreated, s.windowRejected
+	s.windowCreated, s.windowRejected = 0, 0
+	return created, rejected
+}
+
+// Counts of the series known, created and rejected since start
+func (s *seriesTracker) counts() (int, uint64, uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.series), s.created, s.rejected
+}
+
+// NextSeriesWindows of all targets, warning about targets exceeding the limit of new series
+func (c *CiscoTelemetryGNMI) nextSeriesWindows(targets []*target) {
+	for _, t := range targets {
+		if t.series == nil {
+			continue
+		}
+		if created, rejected := t.series.nextWindow(); rejected > 0 {
+			log.Printf("W! GNMI device %s exceeded %d new series per interval, rejected %d of %d new series",
+				t.address, c.MaxNewSeries, rejected, created+rejected)
+		}
+	}
+}
+
+// Series returns snapshots of the series of all targets not seen since the cutoff, or all
+// series if it is zero, ordered by target and path
+func (c *CiscoTelemetryGNMI) Series(cutoff time.Time) []SeriesStatistics {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	statistics := make([]SeriesStatistics, 0)
+	for _, t := range c.targets {
+		if t.series == nil {
+			continue
+		}
+
+		producer := c.producer(t)
+		t.series.mutex.Lock()
+		for path, times := range t.series.series {
+			if !cutoff.IsZero() && times.lastSeen.After(cutoff) {
+				continue
+			}
+			statistics = append(statistics, SeriesStatistics{Address: t.address, Producer: producer, Path: path,
+				Updates: times.updates, FirstSeen: times.firstSeen, LastSeen: times.lastSeen})
+		}
+		t.series.mutex.Unlock()
+	}
+
+	sort.Slice(statistics, func(i, j int) bool {
+		if statistics[i].Address != statistics[j].Address {
+			return statistics[i].Address < statistics[j].Address
+		}
+		return statistics[i].Path < statistics[j].Path
+	})
+	return statistics
+}
+
+// HandleAdminSeries returns the series of all targets as JSON, only those not seen within the
+// duration of the "stale" parameter if given
+func (c *CiscoTelemetryGNMI) handleAdminSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.TrackSeries {
+		http.Error(w, "series tracking disabled", http.StatusNotFound)
+		return
+	}
+
+	var cutoff time.Time
+	if stale := r.URL.Query().Get("stale"); len(stale) > 0 {
+		duration, err := time.ParseDuration(stale)
+		if err != nil {
+			http.Error(w, "invalid stale duration: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		cutoff = time.Now().Add(-duration)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Series(cutoff))
+}
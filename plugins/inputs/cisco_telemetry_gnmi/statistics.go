@@ -0,0 +1,209 @@
+package cisco_telemetry_gnmi
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// TargetStatistics is a snapshot of the statistics of a target
+type TargetStatistics struct {
+	Address      string             `json:"address"`
+	Producer     string             `json:"producer"`
+	Tags         map[string]string  `json:"tags,omitempty"`
+	Streams      []StreamStatistics `json:"streams"`
+	Responses    uint64             `json:"responses"`
+	Errors       uint64             `json:"errors"`
+	Redials      uint64             `json:"redials"`
+	LastResponse *time.Time         `json:"last_response,omitempty"`
+
+	// Update notifications and their updates received, and notifications failing to decode
+	Notifications uint64 `json:"notifications"`
+	Updates       uint64 `json:"updates"`
+	DecodeErrors  uint64 `json:"decode_errors"`
+
+	// Updates missed by on-change subscriptions and corrected by reconciliation
+	Corrections uint64 `json:"corrections"`
+
+	// Series known, and series created and rejected since start, if series are tracked
+	Series         int    `json:"series,omitempty"`
+	SeriesCreated  uint64 `json:"series_created,omitempty"`
+	SeriesRejected uint64 `json:"series_rejected,omitempty"`
+
+	// Last error terminating a subscription, decoded from the details provided by the device
+	LastError      string `json:"last_error,omitempty"`
+	LastErrorClass string `json:"last_error_class,omitempty"`
+
+	// Bytes received in total and per second, and the factor sample intervals are widened by
+	// to stay below the bandwidth cap
+	BytesReceived uint64 `json:"bytes_received"`
+	Bandwidth     uint64 `json:"bandwidth"`
+	IntervalScale int64  `json:"interval_scale"`
+}
+
+// StreamStatistics is a snapshot of the state of a subscription stream
+type StreamStatistics struct {
+	Name       string     `json:"name"`
+	Path       string     `json:"path,omitempty"`
+	State      string     `json:"state"`
+	Updates    uint64     `json:"updates"`
+	Errors     uint64     `json:"errors"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+
+	// Time the stream was established, omitted while not established
+	Established *time.Time `json:"established,omitempty"`
+}
+
+// Statistics returns snapshots of all targets, safe to be called concurrently
+// e.g. by supervisors embedding Telegraf to rebalance targets between collectors
+func (c *CiscoTelemetryGNMI) Statistics() []TargetStatistics {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	statistics := make([]TargetStatistics, 0, len(c.targets))
+	for _, t := range c.targets {
+		snapshot := TargetStatistics{
+			Address:   t.address,
+			Producer:  c.producer(t),
+			Streams:   make([]StreamStatistics, 0, len(t.streams)),
+			Responses: atomic.LoadUint64(&t.responses),
+			Errors:    atomic.LoadUint64(&t.errors),
+			Redials:   atomic.LoadUint64(&t.redials),
+
+			Notifications: atomic.LoadUint64(&t.notifications),
+			Updates:       atomic.LoadUint64(&t.updates),
+			DecodeErrors:  atomic.LoadUint64(&t.decodeErrors),
+			Corrections:   atomic.LoadUint64(&t.corrections),
+
+			BytesReceived: atomic.LoadUint64(&t.bytesReceived),
+			Bandwidth:     atomic.LoadUint64(&t.bandwidth),
+			IntervalScale: atomic.LoadInt64(&t.intervalScale),
+		}
+
+		if len(t.tags) > 0 {
+			snapshot.Tags = make(map[string]string, len(t.tags))
+			for key, val := range t.tags {
+				snapshot.Tags[key] = val
+			}
+		}
+
+		if last := atomic.LoadInt64(&t.lastResponse); last > 0 {
+			timestamp := time.Unix(0, last)
+			snapshot.LastResponse = &timestamp
+		}
+
+		if t.series != nil {
+			snapshot.Series, snapshot.SeriesCreated, snapshot.SeriesRejected = t.series.counts()
+		}
+
+		if last, ok := t.lastError.Load().(targetError); ok {
+			snapshot.LastError, snapshot.LastErrorClass = last.message, last.class
+		}
+
+		for _, s := range t.streams {
+			stream := StreamStatistics{
+				Name:    s.name(),
+				Path:    s.path(),
+				State:   s.getState(),
+				Updates: atomic.LoadUint64(&s.updates),
+				Errors:  atomic.LoadUint64(&s.errors),
+			}
+			if last := atomic.LoadInt64(&s.lastUpdate); last > 0 {
+				timestamp := time.Unix(0, last)
+				stream.LastUpdate = &timestamp
+			}
+			if established := atomic.LoadInt64(&s.established); established > 0 && stream.State == streamStates[streamEstablished] {
+				timestamp := time.Unix(0, established)
+				stream.Established = &timestamp
+			}
+			snapshot.Streams = append(snapshot.Streams, stream)
+		}
+
+		statistics = append(statistics, snapshot)
+	}
+
+	return statistics
+}
+
+// GatherHealth emits the health of each target, which is healthy while all its streams are established
+func (c *CiscoTelemetryGNMI) gatherHealth(acc telegraf.Accumulator, now time.Time) {
+	for _, statistics := range c.Statistics() {
+		established := 0
+		for _, s := range statistics.Streams {
+			if s.State == streamStates[streamEstablished] {
+				established++
+			}
+		}
+
+		tags := map[string]string{"Producer": statistics.Producer}
+		for key, val := range c.TargetTags {
+			tags[key] = val
+		}
+		for key, val := range statistics.Tags {
+			tags[key] = val
+		}
+
+		fields := map[string]interface{}{
+			"healthy":             len(statistics.Streams) > 0 && established == len(statistics.Streams),
+			"streams":             len(statistics.Streams),
+			"streams_established": established,
+			"responses":           statistics.Responses,
+			"errors":              statistics.Errors,
+			"redials":             statistics.Redials,
+			"notifications":       statistics.Notifications,
+			"updates":             statistics.Updates,
+			"decode_errors":       statistics.DecodeErrors,
+			"corrections":         statistics.Corrections,
+			"bytes_received":      statistics.BytesReceived,
+		}
+		if c.TrackSeries || c.MaxNewSeries > 0 {
+			fields["series"] = statistics.Series
+			fields["series_created"] = statistics.SeriesCreated
+			fields["series_rejected"] = statistics.SeriesRejected
+		}
+		if statistics.LastResponse != nil {
+			fields["last_response_age"] = now.Sub(*statistics.LastResponse).Seconds()
+		}
+		if len(statistics.LastError) > 0 {
+			fields["last_error"] = statistics.LastError
+			fields["last_error_class"] = statistics.LastErrorClass
+		}
+
+		acc.AddFields("gnmi_health", fields, tags, now)
+	}
+}
+
+// GatherSubscriptions emits the updates, errors and age of the last update of each stream of each target
+func (c *CiscoTelemetryGNMI) gatherSubscriptions(acc telegraf.Accumulator, now time.Time) {
+	for _, statistics := range c.Statistics() {
+		for _, s := range statistics.Streams {
+			tags := map[string]string{"Producer": statistics.Producer, "path": s.Path}
+			if len(s.Path) == 0 {
+				tags["path"] = s.Name
+			} else if s.Name != s.Path {
+				tags["name"] = s.Name
+			}
+			for key, val := range c.TargetTags {
+				tags[key] = val
+			}
+			for key, val := range statistics.Tags {
+				tags[key] = val
+			}
+
+			fields := map[string]interface{}{
+				"state":            s.State,
+				"updates_received": s.Updates,
+				"errors":           s.Errors,
+			}
+			if s.LastUpdate != nil {
+				fields["last_update_age"] = now.Sub(*s.LastUpdate).Seconds()
+			}
+			if s.Established != nil {
+				fields["uptime"] = now.Sub(*s.Established).Seconds()
+			}
+
+			acc.AddFields("gnmi_subscription", fields, tags, now)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package cisco_telemetry_gnmi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// States of a subscription stream
+const (
+	streamConnecting int32 = iota
+	streamEstablished
+	streamBackoff
+	streamStopped
+)
+
+var streamStates = []string{"connecting", "established", "backoff", "stopped"}
+
+// Error of subscriptions ended to resubscribe immediately
+var errResubscribe = errors.New("resubscribe")
+
+// Stream of subscriptions on a target, redialed independently of other streams
+type stream struct {
+	// Statistics updated atomically, kept first for 64-bit alignment
+	updates    uint64
+	errors     uint64
+	lastUpdate int64
+
+	// Time the current subscription was established, zero while not established
+	established int64
+
+	target        *target
+	subscriptions []*Subscription
+	state         int32
+	ctx           context.Context
+	cancel        context.CancelFunc
+
+	// Cancels the current subscription of the stream to resubscribe
+	restart context.CancelFunc
+	mutex   sync.Mutex
+
+	// Triggers of polls of polled streams, nil for streamed ones
+	polls chan struct{}
+}
+
+// NewStreams creates one stream per configured and runtime subscription of the plugin, and
+// one for the canary if configured
+func (c *CiscoTelemetryGNMI) newStreams(t *target) []*stream {
+	streams := make([]*stream, 0, len(c.Subscriptions)+len(c.runtimeSubscriptions)+1)
+
+	// Without subscriptions the device decides what to stream on a single stream
+	if len(c.Subscriptions) == 0 && len(c.runtimeSubscriptions) == 0 {
+		streams = append(streams, c.newStream(t))
+	}
+
+	for i := range c.Subscriptions {
+		streams = append(streams, c.newStream(t, &c.Subscriptions[i]))
+	}
+	for _, subscription := range c.runtimeSubscriptions {
+		streams = append(streams, c.newStream(t, subscription))
+	}
+
+	if c.Canary != nil {
+		t.canary, t.canaryStarted = c.newStream(t, c.Canary), time.Now()
+		streams = append(streams, t.canary)
+	}
+	return streams
+}
+
+// NewBackfillStream requests the history of all subscriptions of the plugin at once
+func (c *CiscoTelemetryGNMI) newBackfillStream(t *target) *stream {
+	subscriptions := make([]*Subscription, len(c.Subscriptions))
+	for i := range c.Subscriptions {
+		subscriptions[i] = &c.Subscriptions[i]
+	}
+	return c.newStream(t, subscriptions...)
+}
+
+// NewStream of subscriptions on a target, canceled with the target or on its own
+func (c *CiscoTelemetryGNMI) newStream(t *target, subscriptions ...*Subscription) *stream {
+	s := &stream{target: t, subscriptions: subscriptions}
+	s.ctx, s.cancel = context.WithCancel(t.ctx)
+	if polled(subscriptions) {
+		s.polls = make(chan struct{}, 1)
+	}
+	return s
+}
+
+// Name of the stream for logging
+func (s *stream) name() string {
+	if len(s.subscriptions) == 0 {
+		return "default"
+	}
+	if s.subscriptions[0].Name != "" {
+		return s.subscriptions[0].Name
+	}
+	return s.subscriptions[0].Origin + ":" + s.subscriptions[0].Path
+}
+
+// Path subscribed by the stream, empty for the default stream
+func (s *stream) path() string {
+	if len(s.subscriptions) == 0 {
+		return ""
+	}
+	return s.subscriptions[0].Origin + ":" + s.subscriptions[0].Path
+}
+
+// OnChange checks whether all subscriptions of the stream are on-change subscriptions
+func (s *stream) onChange() bool {
+	for _, subscription := range s.subscriptions {
+		if strings.ToLower(subscription.SubscriptionMode) != "on_change" {
+			return false
+		}
+	}
+	return len(s.subscriptions) > 0
+}
+
+// SetState of the stream logging transitions
+func (s *stream) setState(state int32) {
+	if atomic.SwapInt32(&s.state, state) != state {
+		log.Printf("D! GNMI stream %s on %s %s", s.name(), s.target.address, streamStates[state])
+	}
+}
+
+// State of the stream
+func (s *stream) getState() string {
+	return streamStates[atomic.LoadInt32(&s.state)]
+}
+
+// SetRestart sets the function canceling the current subscription of the stream
+func (s *stream) setRestart(restart context.CancelFunc) {
+	s.mutex.Lock()
+	s.restart = restart
+	s.mutex.Unlock()
+}
+
+// Resubscribe the stream, e.g. to apply changed sample intervals
+func (s *stream) resubscribe() {
+	s.mutex.Lock()
+	if s.restart != nil {
+		s.restart()
+	}
+	s.mutex.Unlock()
+}
@@ -0,0 +1,109 @@
+package cisco_telemetry_gnmi
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/common/auth"
+	"google.golang.org/grpc"
+)
+
+// Target connection of the plugin to a device
+type target struct {
+	// Statistics updated atomically, kept first for 64-bit alignment
+	responses    uint64
+	errors       uint64
+	redials      uint64
+	lastResponse int64
+
+	// Update notifications, updates of the notifications and notifications failing to decode
+	notifications uint64
+	updates       uint64
+	decodeErrors  uint64
+
+	// Updates of reconciled subscriptions missed and corrected by reconciliation
+	corrections uint64
+
+	// Time of the first failure of an ongoing outage, zero while up
+	downSince int64
+
+	// Bandwidth accounting, bytes received and bytes per second of the last period
+	bytesReceived uint64
+	bytesMeasured uint64
+	bandwidth     uint64
+
+	// Factor sample intervals are widened by to stay below the bandwidth cap
+	intervalScale int64
+
+	// Last error terminating a subscription
+	lastError atomic.Value
+
+	address  string
+	producer string
+	tags     map[string]string
+	client   *grpc.ClientConn
+	streams  []*stream
+
+	// Auth provider of the device of the target if overriding that of the plugin
+	auth auth.Provider
+
+	// Nanoseconds per unit of the timestamps of the target, zero if detected, and whether
+	// detected timestamps were scaled yet
+	timestampScale  int64
+	timestampScaled int32
+
+	// Canceled once the target is removed, e.g. when its tunnel closes
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// First and last reception of each series, if tracked
+	series *seriesTracker
+
+	// State of reconciled subscriptions, if any
+	state *stateCache
+
+	// Liveness canary stream, the time it was started and whether it is silent for too long
+	canary        *stream
+	canaryStarted time.Time
+	canaryStale   int32
+
+	// Tunnel of targets of devices dialing out
+	tunnel *tunnelTarget
+
+	// Index of the encoding used for subscriptions, negotiated once if configured
+	encoding    int32
+	negotiation sync.Once
+
+	// State of triggers run by gather cycles
+	triggering int32
+	triggered  map[int]time.Time
+}
+
+// Last error terminating a subscription of a target and its class
+type targetError struct {
+	message string
+	class   string
+}
+
+// ResolveAddresses returns one address per IP the host of an address resolves to
+func resolveAddresses(address string) ([]string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, len(ips))
+	for i, ip := range ips {
+		addresses[i] = net.JoinHostPort(ip, port)
+	}
+
+	return addresses, nil
+}
@@ -0,0 +1,66 @@
+package cisco_telemetry_gnmi
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Timestamp unit detected by the magnitude of each timestamp
+const timestampUnitAuto = "auto"
+
+// Nanoseconds per unit of the timestamps sent by targets not conforming to the specification
+var timestampUnits = map[string]int64{
+	"ns": 1,
+	"us": int64(time.Microsecond),
+	"ms": int64(time.Millisecond),
+	"s":  int64(time.Second),
+}
+
+// Smallest timestamps of each unit considered plausible, those of 1973 onward
+const (
+	minTimestampNanos   = 1e17
+	minTimestampMicros  = 1e14
+	minTimestampMillis  = 1e11
+	minTimestampSeconds = 1e8
+)
+
+// TimestampScale of a unit, or zero to detect the unit of each timestamp
+func timestampScale(unit string) (int64, error) {
+	if len(unit) == 0 || unit == timestampUnitAuto {
+		return 0, nil
+	}
+	scale, ok := timestampUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid timestamp unit: %s", unit)
+	}
+	return scale, nil
+}
+
+// DetectTimestampScale by the magnitude of a timestamp, keeping those of no plausible unit
+func detectTimestampScale(timestamp int64) int64 {
+	switch {
+	case timestamp >= minTimestampNanos:
+		return 1
+	case timestamp >= minTimestampMicros:
+		return timestampUnits["us"]
+	case timestamp >= minTimestampMillis:
+		return timestampUnits["ms"]
+	case timestamp >= minTimestampSeconds:
+		return timestampUnits["s"]
+	}
+	return 1
+}
+
+// NotificationTime of a timestamp in the unit of the target, detecting it unless configured
+func (c *CiscoTelemetryGNMI) notificationTime(t *target, timestamp int64) time.Time {
+	scale := t.timestampScale
+	if scale == 0 {
+		scale = detectTimestampScale(timestamp)
+		if scale != 1 && atomic.CompareAndSwapInt32(&t.timestampScaled, 0, 1) {
+			log.Printf("W! GNMI timestamps of %s are not in nanoseconds, scaling them by %d", t.address, scale)
+		}
+	}
+	return time.Unix(0, timestamp*scale)
+}
@@ -0,0 +1,90 @@
+package cisco_telemetry_gnmi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/rpcerror"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Trigger of a device-side action by setting a value before each gather cycle
+type Trigger struct {
+	Origin string
+	Path   string
+	Target string
+	Value  string
+
+	// Retries of requests not processed by the device
+	Retries int
+
+	// Skip the trigger if it last succeeded less than an interval ago
+	MinInterval internal.Duration `toml:"min_interval"`
+}
+
+// CheckTriggers for valid JSON values
+func (c *CiscoTelemetryGNMI) checkTriggers() error {
+	for _, trigger := range c.Triggers {
+		var value interface{}
+		if err := json.Unmarshal([]byte(trigger.Value), &value); err != nil {
+			return fmt.Errorf("E! Invalid GNMI trigger value for %s: %v", trigger.Path, err)
+		}
+	}
+	return nil
+}
+
+// RunTriggers of a target unless a previous run is still in progress
+func (c *CiscoTelemetryGNMI) runTriggers(t *target) {
+	if !atomic.CompareAndSwapInt32(&t.triggering, 0, 1) {
+		log.Printf("D! GNMI triggers for %s still in progress, skipping", t.address)
+		return
+	}
+	defer atomic.StoreInt32(&t.triggering, 0)
+
+	if t.triggered == nil {
+		t.triggered = make(map[int]time.Time)
+	}
+
+	for i, trigger := range c.Triggers {
+		if last, ok := t.triggered[i]; ok && time.Since(last) < trigger.MinInterval.Duration {
+			continue
+		}
+
+		if err := c.setTrigger(t, &trigger); err != nil {
+			c.acc.AddError(fmt.Errorf("E! GNMI trigger %s failed: %v", trigger.Path, rpcerror.Decode(err, nil)))
+			continue
+		}
+		t.triggered[i] = time.Now()
+	}
+}
+
+// SetTrigger value on the device, retrying only requests that were not processed
+func (c *CiscoTelemetryGNMI) setTrigger(t *target, trigger *Trigger) error {
+	// Replacing a leaf is idempotent, so repeating the request is always safe
+	request := &gnmi.SetRequest{
+		Prefix: c.requestPath(c.Origin, c.Prefix, c.Target),
+		Replace: []*gnmi.Update{{
+			Path: c.requestPath(trigger.Origin, trigger.Path, trigger.Target),
+			Val:  &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonVal{JsonVal: []byte(trigger.Value)}},
+		}},
+	}
+
+	ctx, err := c.authContext(c.ctx, t)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt <= trigger.Retries && c.ctx.Err() == nil; attempt++ {
+		_, err = gnmi.NewGNMIClient(t.client).Set(ctx, request)
+		if status.Code(err) != codes.Unavailable {
+			break
+		}
+	}
+	return err
+}
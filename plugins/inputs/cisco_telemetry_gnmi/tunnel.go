@@ -0,0 +1,357 @@
+package cisco_telemetry_gnmi
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	internaltls "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/common/secret"
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_gnmi/grpctunnel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Target type of gNMI servers registered by devices
+	tunnelTargetType = "GNMI_GNOI"
+
+	// Timeout of devices opening the stream of a requested session
+	tunnelSessionTimeout = 20 * time.Second
+)
+
+// Server of the gRPC tunnel service, devices register their targets and the plugin opens
+// sessions to them through the tunnel
+type tunnelServer struct {
+	plugin *CiscoTelemetryGNMI
+
+	// Sessions requested from devices by tag, waiting for their tunnel stream
+	tag     int32
+	pending map[int32]chan tunnelResult
+	targets map[string]*target
+	mutex   sync.Mutex
+}
+
+// Tunnel of a target registered by a device
+type tunnelTarget struct {
+	server *tunnelServer
+	name   string
+	stream grpctunnel.Tunnel_RegisterServer
+	mutex  *sync.Mutex
+}
+
+// Result of a session request
+type tunnelResult struct {
+	conn net.Conn
+	err  error
+}
+
+// StartTunnel listens for devices dialing out through gRPC tunnels
+func (c *CiscoTelemetryGNMI) startTunnel() error {
+	var opts []grpc.ServerOption
+	if len(c.TunnelTLSCert) > 0 && len(c.TunnelTLSKey) > 0 {
+		// The TLS key may reference a secret, only needed until it is loaded
+		keyFile, cleanup, err := secret.ResolveFile(c.TunnelTLSKey)
+		if err != nil {
+			return fmt.Errorf("E! Failed to resolve GNMI tunnel TLS key: %v", err)
+		}
+
+		serverConfig := internaltls.ServerConfig{TLSCert: c.TunnelTLSCert, TLSKey: keyFile,
+			TLSAllowedCACerts: c.TunnelTLSAllowedCACerts}
+		tlsConfig, err := serverConfig.TLSConfig()
+		cleanup()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	listener, err := net.Listen("tcp", c.TunnelAddress)
+	if err != nil {
+		return fmt.Errorf("E! Failed to listen for GNMI tunnels: %v", err)
+	}
+
+	c.tunnel = grpc.NewServer(opts...)
+	grpctunnel.RegisterTunnelServer(c.tunnel, &tunnelServer{plugin: c,
+		pending: make(map[int32]chan tunnelResult), targets: make(map[string]*target)})
+
+	c.wg.Add(1)
+	c.trackGoroutine(1)
+	go func() {
+		c.tunnel.Serve(listener)
+		c.trackGoroutine(-1)
+		c.wg.Done()
+	}()
+
+	log.Printf("I! Listening for GNMI tunnels on %s", listener.Addr())
+	return nil
+}
+
+// Register targets of a device, subscribing to each through the tunnel until it is removed
+// or the device disconnects
+func (s *tunnelServer) Register(stream grpctunnel.Tunnel_RegisterServer) error {
+	c := s.plugin
+	c.mutex.Lock()
+	if c.ctx.Err() != nil {
+		c.mutex.Unlock()
+		return status.Error(codes.Unavailable, "collector stopping")
+	}
+	c.wg.Add(1)
+	c.mutex.Unlock()
+	defer c.wg.Done()
+
+	address := ""
+	if peer, ok := peer.FromContext(stream.Context()); ok {
+		address, _, _ = net.SplitHostPort(peer.Addr.String())
+	}
+
+	// Targets of the device, removed once it disconnects
+	var mutex sync.Mutex
+	targets := make(map[string]*target)
+	defer func() {
+		for name, t := range targets {
+			s.removeTarget(name, t)
+		}
+	}()
+
+	for {
+		op, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF || c.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		switch {
+		case op.GetTarget() != nil:
+			request := op.Target
+			response := &grpctunnel.Target{Op: request.Op, Target: request.Target, TargetType: request.TargetType}
+			var added *target
+			switch request.Op {
+			case grpctunnel.Target_ADD:
+				added, err = s.addTarget(&tunnelTarget{server: s, name: request.Target, stream: stream, mutex: &mutex},
+					request.TargetType, address)
+				if err != nil {
+					response.Error = err.Error()
+				} else {
+					targets[request.Target] = added
+					response.Accept = true
+				}
+			case grpctunnel.Target_REMOVE:
+				if t, ok := targets[request.Target]; ok {
+					s.removeTarget(request.Target, t)
+					delete(targets, request.Target)
+				}
+				response.Accept = true
+			}
+
+			mutex.Lock()
+			err = stream.Send(&grpctunnel.RegisterOp{Target: response})
+			mutex.Unlock()
+			if err != nil {
+				return err
+			}
+
+			// Sessions are requested once the device knows its target was accepted
+			if added != nil {
+				s.startTarget(request.Target, added, address)
+			}
+
+		case op.GetSession() != nil && !op.Session.Accept:
+			// Devices refusing a session report it instead of opening the tunnel stream
+			s.fail(op.Session.Tag, fmt.Errorf("session refused: %s", op.Session.Error))
+		}
+	}
+}
+
+// AddTarget registered by a device unless its name is taken already
+func (s *tunnelServer) addTarget(tunneled *tunnelTarget, targetType string, address string) (*target, error) {
+	if len(targetType) > 0 && targetType != tunnelTargetType {
+		return nil, fmt.Errorf("unsupported target type %s", targetType)
+	}
+	if len(tunneled.name) == 0 {
+		return nil, fmt.Errorf("target name missing")
+	}
+
+	s.mutex.Lock()
+	_, exists := s.targets[tunneled.name]
+	t := &target{address: tunneled.name, producer: tunneled.name, tunnel: tunneled,
+		tags: map[string]string{"address": address}, intervalScale: 1}
+	if !exists {
+		s.targets[tunneled.name] = t
+	}
+	s.mutex.Unlock()
+	if exists {
+		return nil, fmt.Errorf("target %s registered already", tunneled.name)
+	}
+	return t, nil
+}
+
+// StartTarget subscribes to a target added by a device
+func (s *tunnelServer) startTarget(name string, t *target, address string) {
+	if err := s.plugin.startTarget(t); err != nil {
+		s.plugin.acc.AddError(fmt.Errorf("E! Failed to start GNMI tunnel target %s: %v", name, err))
+		return
+	}
+	log.Printf("I! GNMI tunnel target %s registered from %s", name, address)
+}
+
+// RemoveTarget of a device and stop subscribing to it
+func (s *tunnelServer) removeTarget(name string, t *target) {
+	s.mutex.Lock()
+	delete(s.targets, name)
+	s.mutex.Unlock()
+
+	// Targets failing to start were never added
+	if t.cancel != nil {
+		s.plugin.stopTarget(t)
+	}
+	log.Printf("I! GNMI tunnel target %s removed", name)
+}
+
+// Tunnel stream of a session, passed to the pending session request of its tag
+func (s *tunnelServer) Tunnel(stream grpctunnel.Tunnel_TunnelServer) error {
+	data, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	result, ok := s.pending[data.Tag]
+	delete(s.pending, data.Tag)
+	s.mutex.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "no session with tag %d", data.Tag)
+	}
+
+	conn := &tunnelConn{stream: stream, tag: data.Tag, done: make(chan struct{})}
+	if peer, ok := peer.FromContext(stream.Context()); ok {
+		conn.addr = peer.Addr
+	}
+	result <- tunnelResult{conn: conn}
+
+	// The stream ends with the handler, i.e. once the connection is closed
+	select {
+	case <-conn.done:
+	case <-stream.Context().Done():
+	}
+	return nil
+}
+
+// Fail a pending session request
+func (s *tunnelServer) fail(tag int32, err error) {
+	s.mutex.Lock()
+	result, ok := s.pending[tag]
+	delete(s.pending, tag)
+	s.mutex.Unlock()
+	if ok {
+		result <- tunnelResult{err: err}
+	}
+}
+
+// Session requests a new session to the target and waits for the device to open its stream
+func (t *tunnelTarget) session(timeout time.Duration) (net.Conn, error) {
+	s := t.server
+	if timeout <= 0 {
+		timeout = tunnelSessionTimeout
+	}
+
+	tag := atomic.AddInt32(&s.tag, 1)
+	result := make(chan tunnelResult, 1)
+
+	s.mutex.Lock()
+	s.pending[tag] = result
+	s.mutex.Unlock()
+
+	t.mutex.Lock()
+	err := t.stream.Send(&grpctunnel.RegisterOp{Session: &grpctunnel.Session{Tag: tag, Accept: true,
+		Target: t.name, TargetType: tunnelTargetType}})
+	t.mutex.Unlock()
+	if err != nil {
+		s.fail(tag, err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-result:
+		return r.conn, r.err
+	case <-timer.C:
+	}
+
+	// Close sessions opened by the device after giving up on them
+	s.mutex.Lock()
+	delete(s.pending, tag)
+	s.mutex.Unlock()
+	select {
+	case r := <-result:
+		if r.conn != nil {
+			r.conn.Close()
+		}
+	default:
+	}
+	return nil, fmt.Errorf("session to GNMI tunnel target %s timed out", t.name)
+}
+
+// Connection of a session tunneled through the data of a stream
+type tunnelConn struct {
+	stream grpctunnel.Tunnel_TunnelServer
+	tag    int32
+	addr   net.Addr
+	buffer []byte
+
+	done  chan struct{}
+	once  sync.Once
+	mutex sync.Mutex
+}
+
+func (c *tunnelConn) Read(b []byte) (int, error) {
+	for len(c.buffer) == 0 {
+		data, err := c.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		if data.Close {
+			return 0, io.EOF
+		}
+		c.buffer = data.Data
+	}
+
+	n := copy(b, c.buffer)
+	c.buffer = c.buffer[n:]
+	return n, nil
+}
+
+func (c *tunnelConn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.stream.Send(&grpctunnel.Data{Tag: c.tag, Data: b}); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *tunnelConn) Close() error {
+	c.once.Do(func() {
+		c.mutex.Lock()
+		c.stream.Send(&grpctunnel.Data{Tag: c.tag, Close: true})
+		c.mutex.Unlock()
+		close(c.done)
+	})
+	return nil
+}
+
+func (c *tunnelConn) LocalAddr() net.Addr                { return c.addr }
+func (c *tunnelConn) RemoteAddr() net.Addr               { return c.addr }
+func (c *tunnelConn) SetDeadline(t time.Time) error      { return nil }
+func (c *tunnelConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *tunnelConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,77 @@
+package cisco_telemetry_gnmi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	"github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Workarounds for the gNMI implementation of a vendor
+type vendorQuirks struct {
+	// Encode paths only as elem, rejecting requests also setting the deprecated element field
+	elemOnly bool
+
+	// Origin of subscription and trigger paths configured without one
+	origin string
+
+	// Encodings to fall back to unless encoding_fallback is configured
+	encodings []string
+}
+
+// Known quirks by vendor
+var vendors = map[string]vendorQuirks{
+	"cisco":   {},
+	"arista":  {elemOnly: true, origin: "openconfig", encodings: []string{"json", "json_ietf"}},
+	"juniper": {encodings: []string{"json"}},
+	"nokia":   {elemOnly: true, encodings: []string{"json_ietf", "json"}},
+}
+
+// SetupVendor selects the quirks of the configured vendor and the encodings to fall back to
+func (c *CiscoTelemetryGNMI) setupVendor() error {
+	var ok bool
+	if c.quirks, ok = vendors[strings.ToLower(c.Vendor)]; !ok && len(c.Vendor) > 0 {
+		return fmt.Errorf("E! Invalid GNMI vendor: %s", c.Vendor)
+	}
+
+	// Encodings in order of preference
+	c.encodings = []string{c.Encoding}
+	if strings.EqualFold(c.Encoding, encodingAuto) {
+		c.encodings = append([]string{}, negotiatedEncodings...)
+	}
+	fallback := c.EncodingFallback
+	if len(fallback) == 0 {
+		fallback = c.quirks.encodings
+	}
+	for _, encoding := range fallback {
+		if !containsFold(c.encodings, encoding) {
+			c.encodings = append(c.encodings, encoding)
+		}
+	}
+	return nil
+}
+
+// RequestPath parses a path of a request to the target, applying the quirks of its vendor
+func (c *CiscoTelemetryGNMI) requestPath(origin string, path string, target string) *gnmi.Path {
+	// Paths below a prefix are not given an origin, as it is set on the prefix
+	if len(origin) == 0 && len(c.Origin) == 0 && len(c.Prefix) == 0 && len(path) > 0 {
+		origin = c.quirks.origin
+	}
+
+	gnmiPath := gnmidecode.ParsePath(origin, path, target)
+	if c.quirks.elemOnly {
+		gnmiPath.Element = nil
+	}
+	return gnmiPath
+}
+
+// ContainsFold returns true if the list contains the string under case folding
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package cisco_telemetry_mdt
+
+import (
+	"net"
+	"sync"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// Resource statistics of a peer
+type peerStats struct {
+	goroutines  selfstat.Stat
+	connections selfstat.Stat
+}
+
+// PeerStats returns the resource statistics of a peer host, or nil if accounting is disabled
+func (c *CiscoTelemetryMDT) peerStats(addr net.Addr) *peerStats {
+	if !c.ResourceAccounting || addr == nil {
+		return nil
+	}
+
+	host := peerHost(addr.String())
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.peers == nil {
+		c.peers = make(map[string]*peerStats)
+	}
+
+	stats, ok := c.peers[host]
+	if !ok {
+		tags := map[string]string{"address": c.ServiceAddress, "peer": host}
+		stats = &peerStats{
+			goroutines:  selfstat.Register("cisco_telemetry_mdt", "goroutines", tags),
+			connections: selfstat.Register("cisco_telemetry_mdt", "open_connections", tags),
+		}
+		c.peers[host] = stats
+	}
+
+	return stats
+}
+
+// TrackGoroutine adjusts the number of running goroutines of the peer
+func (s *peerStats) trackGoroutine(delta int64) {
+	if s != nil {
+		s.goroutines.Incr(delta)
+	}
+}
+
+// TrackConnection counts a connection of the peer until it is closed
+func (s *peerStats) trackConnection(conn net.Conn) net.Conn {
+	if s == nil {
+		return conn
+	}
+
+	s.connections.Incr(1)
+	return &countedConn{Conn: conn, stat: s.connections}
+}
+
+// Connection decrementing its statistic once closed
+type countedConn struct {
+	net.Conn
+	stat selfstat.Stat
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.stat.Incr(-1) })
+	return c.Conn.Close()
+}
+
+// PeerHost returns the host of a peer address, or the address itself if it has no port
+func peerHost(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
@@ -8,11 +8,14 @@ package cisco_telemetry_mdt
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,13 +25,26 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	internaltls "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/common/backoff"
+	"github.com/influxdata/telegraf/plugins/common/cpulimit"
+	"github.com/influxdata/telegraf/plugins/common/exporter"
+	"github.com/influxdata/telegraf/plugins/common/reorder"
+	"github.com/influxdata/telegraf/plugins/common/rpcerror"
+	"github.com/influxdata/telegraf/plugins/common/schema"
+	"github.com/influxdata/telegraf/plugins/common/secret"
+	"github.com/influxdata/telegraf/plugins/common/tlsprofile"
+	"github.com/influxdata/telegraf/plugins/common/transforms"
+	"github.com/influxdata/telegraf/plugins/common/wal"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt/ems"
 	dialout "github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt/mdt_dialout"
 	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt/telemetry"
+	"github.com/influxdata/telegraf/selfstat"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -37,6 +53,10 @@ const (
 
 	// IOS XR EMS dialin telemetry GPBKV encoding
 	grpcEncodeGPBKV int64 = 3
+
+	// IPv6 flow labels of dialout connections derived automatically or left zero
+	flowLabelAuto = "auto"
+	flowLabelNone = "none"
 )
 
 // CiscoTelemetryMDT plugin for IOS XR, IOS XE and NXOS platforms
@@ -51,30 +71,236 @@ type CiscoTelemetryMDT struct {
 	Subscription string
 	Redial       internal.Duration
 
-	// GRPC TLS settings
+	// Exponential backoff of redials up to the maximum until subscriptions stay established
+	RedialMax        internal.Duration `toml:"redial_max"`
+	RedialResetAfter internal.Duration `toml:"redial_reset_after"`
+
+	// GRPC TLS settings, with the passphrase of encrypted keys
 	TLS bool
 	internaltls.ServerConfig
 	internaltls.ClientConfig
+	TLSKeyPassphrase string `toml:"tls_key_passphrase"`
+
+	// GRPC dialin: file of named TLS profiles shared with other plugins, and the profile of the device
+	TLSProfiles string `toml:"tls_profiles"`
+	TLSProfile  string `toml:"tls_profile"`
+
+	// GRPC dialout acknowledgements
+	DialoutAcks bool `toml:"dialout_acks"`
+
+	// GRPC dialout metadata added as tags, e.g. destination group or policy names set by the device
+	// or by proxies multiplexing many devices
+	DialoutMetadataTags []string `toml:"dialout_metadata_tags"`
+
+	// Dialout socket options
+	TCPNoDelay      *bool         `toml:"tcp_nodelay"`
+	ReadBufferSize  internal.Size `toml:"read_buffer_size"`
+	WriteBufferSize internal.Size `toml:"write_buffer_size"`
+	TrafficClass    int           `toml:"traffic_class"`
+	FlowLabel       string        `toml:"flow_label"`
+
+	// Limit of decompressed message sizes
+	MaxDecompressedSize internal.Size `toml:"max_decompressed_size"`
+
+	// Compiled proto descriptors decoding compact GPB, and content messages by encoding path
+	ProtoDescriptors []string          `toml:"proto_descriptors"`
+	ProtoMessages    map[string]string `toml:"proto_messages"`
+
+	// Timestamp of measurements with differently timestamped fields
+	TimestampPolicy string `toml:"timestamp_policy"`
+
+	// Emit measurements of a collection only once it is complete, or once incomplete for the timeout
+	CollectionBuffering bool              `toml:"collection_buffering"`
+	CollectionTimeout   internal.Duration `toml:"collection_timeout"`
+
+	// Emit delay between collection end on the device and reception
+	PipelineDelay bool `toml:"pipeline_delay"`
+
+	// Emit changes of the fields and field types of encoding paths
+	SchemaEvents bool `toml:"schema_events"`
+
+	// Record encoding, subscription, collector and reception time of each measurement
+	Provenance bool
+
+	// Emit tombstones for deleted rows and boundaries of completed collections
+	CollectionEvents bool `toml:"collection_events"`
+
+	// Unit conversions of fields by encoding path and field name
+	Transforms map[string]map[string]string
+
+	// Sentinel values reported for "not available" by encoding path and field name, either
+	// dropped or replaced by an availability flag
+	Sentinels      map[string]map[string]string
+	SentinelPolicy string `toml:"sentinel_policy"`
+
+	// Types fields are coerced to by encoding path and field name, for leaves changing their type
+	// across releases
+	Coercions map[string]map[string]string
+
+	// Sanitizers normalizing tag values by tag name, "*" for all tags
+	TagSanitizers map[string][]string `toml:"tag_sanitizers"`
+
+	// Export goroutine and connection counts per peer as internal statistics
+	ResourceAccounting bool `toml:"resource_accounting"`
+
+	// Maximum number of messages decoded concurrently, by default the CPUs of the container
+	DecodeWorkers int `toml:"decode_workers"`
+
+	// Emit the health of the transport every gather interval
+	HealthMetrics bool `toml:"health_metrics"`
+
+	// Prometheus endpoint for the last values
+	PrometheusAddress    string            `toml:"prometheus_address"`
+	PrometheusExpiration internal.Duration `toml:"prometheus_expiration"`
+
+	// Maximum latency for emitting telemetry data in timestamp order
+	ReorderLatency internal.Duration `toml:"reorder_latency"`
+
+	// Directory of the write-ahead log persisting metrics until delivered by the outputs
+	WALDirectory string `toml:"wal_directory"`
 
 	// Internal listener / client handle
 	listener net.Listener
+	udpConn  *net.UDPConn
 
 	// Internal state
-	acc    telegraf.Accumulator
-	cancel context.CancelFunc
-	ctx    context.Context
-	wg     sync.WaitGroup
+	acc         telegraf.Accumulator
+	collector   string
+	health      *health
+	transforms  map[string]transforms.Rules
+	sentinels   map[string]transforms.Sentinels
+	coercions   map[string]transforms.Coercions
+	tagRules    transforms.TagRules
+	schemas     *schema.Tracker
+	exporter    *exporter.Exporter
+	reorder     *reorder.Buffer
+	wal         *wal.Log
+	collections map[string]*collection
+	incomplete  selfstat.Stat
+	compact     *compactDecoder
+	unsupported map[string]bool
+	decoders    chan struct{}
+	peers       map[string]*peerStats
+	counters    map[string]selfstat.Stat
+	counted     map[string]bool
+	mutex       sync.Mutex
+	cancel      context.CancelFunc
+	ctx         context.Context
+	wg          sync.WaitGroup
 }
 
 // Start the Cisco MDT service
-func (c *CiscoTelemetryMDT) Start(acc telegraf.Accumulator) error {
-	var err error
+func (c *CiscoTelemetryMDT) Start(acc telegraf.Accumulator) (err error) {
 	c.acc = acc
+	if len(c.WALDirectory) > 0 {
+		if c.wal, err = wal.Open(c.WALDirectory, acc); err != nil {
+			return fmt.Errorf("E! Failed to open Cisco MDT write-ahead log: %v", err)
+		}
+		c.acc = c.wal
+	}
+
 	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.health = &health{}
+
+	// Routines and the write-ahead log started before a failure are stopped again, e.g. when a
+	// reload fails
+	defer func() {
+		if err != nil {
+			c.Stop()
+		}
+	}()
+
+	if workers := c.decodeWorkers(); workers > 0 {
+		c.decoders = make(chan struct{}, workers)
+		log.Printf("D! Decoding Cisco MDT messages with up to %d workers", workers)
+	}
+
+	c.transforms = make(map[string]transforms.Rules, len(c.Transforms))
+	for path, config := range c.Transforms {
+		if c.transforms[path], err = transforms.NewRules(config); err != nil {
+			return fmt.Errorf("E! Invalid Cisco MDT transforms for %s: %v", path, err)
+		}
+	}
+
+	switch c.FlowLabel {
+	case "", flowLabelAuto, flowLabelNone:
+	default:
+		return fmt.Errorf("E! Invalid Cisco MDT flow label: %s", c.FlowLabel)
+	}
+
+	switch c.SentinelPolicy {
+	case "", "drop", "flag":
+	default:
+		return fmt.Errorf("E! Invalid Cisco MDT sentinel policy: %s", c.SentinelPolicy)
+	}
+	c.sentinels = make(map[string]transforms.Sentinels, len(c.Sentinels))
+	for path, config := range c.Sentinels {
+		if c.sentinels[path], err = transforms.NewSentinels(config); err != nil {
+			return fmt.Errorf("E! Invalid Cisco MDT sentinels for %s: %v", path, err)
+		}
+	}
+
+	c.coercions = make(map[string]transforms.Coercions, len(c.Coercions))
+	for path, config := range c.Coercions {
+		if c.coercions[path], err = transforms.NewCoercions(config); err != nil {
+			return fmt.Errorf("E! Invalid Cisco MDT coercions for %s: %v", path, err)
+		}
+	}
+
+	if c.tagRules, err = transforms.NewTagRules(c.TagSanitizers); err != nil {
+		return fmt.Errorf("E! Invalid Cisco MDT tag sanitizers: %v", err)
+	}
+
+	if len(c.ProtoDescriptors) > 0 {
+		if c.compact, err = newCompactDecoder(c.ProtoDescriptors, c.ProtoMessages); err != nil {
+			return fmt.Errorf("E! Failed to load Cisco MDT proto descriptors: %v", err)
+		}
+	}
+
+	switch c.TimestampPolicy {
+	case "", "row", "message", "earliest", "latest":
+	default:
+		return fmt.Errorf("E! Invalid Cisco MDT timestamp policy: %s", c.TimestampPolicy)
+	}
+
+	if c.SchemaEvents {
+		c.schemas = schema.NewTracker()
+	}
+
+	if c.CollectionBuffering {
+		tags := map[string]string{"address": c.ServiceAddress}
+		c.incomplete = selfstat.Register("cisco_telemetry_mdt", "incomplete_collections", tags)
+	}
+
+	if c.Provenance {
+		if c.collector, err = os.Hostname(); err != nil {
+			return fmt.Errorf("E! Failed to determine Cisco MDT collector hostname: %v", err)
+		}
+	}
+
+	if c.ReorderLatency.Duration > 0 {
+		c.reorder = reorder.New(c.ReorderLatency.Duration, c.acc.AddFields)
+		c.wg.Add(1)
+		go func() {
+			c.reorder.Run(c.ctx)
+			c.wg.Done()
+		}()
+	}
+
+	if len(c.PrometheusAddress) > 0 {
+		if c.PrometheusExpiration.Duration <= 0 {
+			c.PrometheusExpiration.Duration = 10 * time.Minute
+		}
+
+		c.exporter = exporter.New(c.PrometheusExpiration.Duration)
+		if err = c.exporter.Start(c.PrometheusAddress); err != nil {
+			return fmt.Errorf("E! Failed to start Cisco MDT Prometheus endpoint: %v", err)
+		}
+	}
 
 	switch c.Transport {
 	case "tcp-dialout":
-		c.listener, err = net.Listen("tcp", c.ServiceAddress)
+		c.listener, err = c.listenDialout()
 		if err != nil {
 			return err
 		}
@@ -83,11 +309,31 @@ func (c *CiscoTelemetryMDT) Start(acc telegraf.Accumulator) error {
 		c.wg.Add(1)
 		go c.acceptTCPDialoutClients()
 
+	case "udp-dialout":
+		c.udpConn, err = c.listenUDPDialout()
+		if err != nil {
+			return err
+		}
+
+		// UDP dialout server receive routine
+		c.wg.Add(1)
+		go c.receiveUDPDialout()
+
 	case "grpc-dialout":
-		var opts []grpc.ServerOption
+		// Messages are decompressed by gRPC before their size is checked
+		opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(int(c.maxDecompressedSize()))}
 
 		if c.TLS {
-			tlsConfig, err := c.ServerConfig.TLSConfig()
+			// The TLS key may reference a secret, only needed until it is loaded
+			serverConfig := c.ServerConfig
+			keyFile, cleanup, err := secret.ResolveKeyFile(serverConfig.TLSKey, c.TLSKeyPassphrase)
+			if err != nil {
+				return fmt.Errorf("E! Failed to resolve Cisco MDT TLS key: %v", err)
+			}
+			serverConfig.TLSKey = keyFile
+
+			tlsConfig, err := serverConfig.TLSConfig()
+			cleanup()
 			if err != nil {
 				return err
 			}
@@ -95,7 +341,7 @@ func (c *CiscoTelemetryMDT) Start(acc telegraf.Accumulator) error {
 			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
 		}
 
-		c.listener, err = net.Listen("tcp", c.ServiceAddress)
+		c.listener, err = c.listenDialout()
 		if err != nil {
 			return err
 		}
@@ -111,14 +357,49 @@ func (c *CiscoTelemetryMDT) Start(acc telegraf.Accumulator) error {
 
 	case "grpc-dialin":
 		var opt grpc.DialOption
-		c.ctx = metadata.AppendToOutgoingContext(c.ctx, "username", c.Username, "password", c.Password)
 
-		if c.TLS {
-			tlsConfig, err := c.ClientConfig.TLSConfig()
+		// Secrets are resolved on each start, i.e. also when Telegraf reloads its configuration
+		username, err := secret.Resolve(c.Username)
+		if err != nil {
+			return fmt.Errorf("E! Failed to resolve Cisco MDT username: %v", err)
+		}
+		password, err := secret.Resolve(c.Password)
+		if err != nil {
+			return fmt.Errorf("E! Failed to resolve Cisco MDT password: %v", err)
+		}
+		c.ctx = metadata.AppendToOutgoingContext(c.ctx, "username", username, "password", password)
+
+		// Referencing a TLS profile enables TLS with the settings of the profile not given above
+		clientConfig, passphrase := c.ClientConfig, c.TLSKeyPassphrase
+		if len(c.TLSProfile) > 0 {
+			if len(c.TLSProfiles) == 0 {
+				return fmt.Errorf("E! Cisco MDT TLS profile %q requires a TLS profiles file", c.TLSProfile)
+			}
+			profiles, err := tlsprofile.Load(c.TLSProfiles)
+			if err != nil {
+				return fmt.Errorf("E! Failed to load Cisco MDT TLS profiles: %v", err)
+			}
+			if passphrase, err = profiles.Apply(c.TLSProfile, &clientConfig, passphrase); err != nil {
+				return fmt.Errorf("E! Invalid Cisco MDT TLS settings: %v", err)
+			}
+		}
+
+		if c.TLS || len(c.TLSProfile) > 0 {
+			keyFile, cleanup, err := secret.ResolveKeyFile(clientConfig.TLSKey, passphrase)
+			if err != nil {
+				return fmt.Errorf("E! Failed to resolve Cisco MDT TLS key: %v", err)
+			}
+			clientConfig.TLSKey = keyFile
+
+			tlsConfig, err := clientConfig.TLSConfig()
+			cleanup()
 			if err != nil {
 				return err
 			}
 
+			// Resume sessions when redialing, saving the device a full handshake
+			tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+
 			opt = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
 		} else {
 			opt = grpc.WithInsecure()
@@ -155,7 +436,13 @@ func (c *CiscoTelemetryMDT) acceptTCPDialoutClients() {
 				break
 			}
 
+			// Keep accepting clients after temporary errors, e.g. running out of file descriptors
 			c.acc.AddError(fmt.Errorf("E! Failed to accept TCP connection: %v", err))
+			select {
+			case <-c.ctx.Done():
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
 		}
 
 		mutex.Lock()
@@ -163,20 +450,19 @@ func (c *CiscoTelemetryMDT) acceptTCPDialoutClients() {
 		mutex.Unlock()
 
 		// Individual client connection routine
+		stats := c.peerStats(conn.RemoteAddr())
+		stats.trackGoroutine(1)
 		c.wg.Add(1)
 		go func() {
 			log.Printf("D! Accepted Cisco MDT TCP dialout connection from %s", conn.RemoteAddr())
+			c.health.trackSession(1)
+			defer c.health.trackSession(-1)
 
 			// TCP Dialout telemetry framing header
-			var hdr struct {
-				MsgType       uint16
-				MsgEncap      uint16
-				MsgHdrVersion uint16
-				MsgFlags      uint16
-				MsgLen        uint32
-			}
+			var hdr dialoutHeader
 
 			var payload bytes.Buffer
+			mislabeled := false
 
 			for {
 				// Read and validate dialout telemetry header
@@ -192,7 +478,7 @@ func (c *CiscoTelemetryMDT) acceptTCPDialoutClients() {
 					break
 				}
 
-				if hdr.MsgFlags != 0 {
+				if hdr.MsgFlags&^tcpFlagZlib != 0 {
 					c.acc.AddError(fmt.Errorf("E! Invalid dialout flags: %v", hdr.MsgFlags))
 					break
 				}
@@ -210,7 +496,28 @@ func (c *CiscoTelemetryMDT) acceptTCPDialoutClients() {
 					break
 				}
 
-				c.handleTelemetry(payload.Bytes())
+				data := payload.Bytes()
+				if hdr.MsgFlags&tcpFlagZlib != 0 {
+					var err error
+					if data, err = c.decompressZlib(data); err != nil {
+						if err == errDecompressedSizeExceeded {
+							c.sizeViolations(conn.RemoteAddr()).Incr(1)
+						}
+						c.acc.AddError(fmt.Errorf("E! TCP dialout decompression failed for %s: %v", conn.RemoteAddr(), err))
+						break
+					}
+				}
+
+				if encap := payloadEncap(data); encap != hdr.MsgEncap {
+					c.encodingMismatches(conn.RemoteAddr()).Incr(1)
+					if !mislabeled {
+						log.Printf("W! Cisco MDT TCP dialout header of %s claims encoding %d, payload is %d",
+							conn.RemoteAddr(), hdr.MsgEncap, encap)
+						mislabeled = true
+					}
+				}
+
+				c.handleTelemetry(data, peerHost(conn.RemoteAddr().String()))
 			}
 
 			log.Printf("D! Closed Cisco MDT TCP dialout connection from %s", conn.RemoteAddr())
@@ -220,6 +527,7 @@ func (c *CiscoTelemetryMDT) acceptTCPDialoutClients() {
 			mutex.Unlock()
 
 			conn.Close()
+			stats.trackGoroutine(-1)
 			c.wg.Done()
 		}()
 	}
@@ -242,6 +550,18 @@ func (c *CiscoTelemetryMDT) MdtDialout(stream dialout.GRPCMdtDialout_MdtDialoutS
 	peer, peerOK := peer.FromContext(stream.Context())
 	if peerOK {
 		log.Printf("D! Accepted Cisco MDT GRPC dialout connection from %s", peer.Addr)
+
+		stats := c.peerStats(peer.Addr)
+		stats.trackGoroutine(1)
+		defer stats.trackGoroutine(-1)
+	}
+	c.health.trackSession(1)
+	defer c.health.trackSession(-1)
+
+	tags := c.dialoutMetadataTags(stream.Context())
+	var host string
+	if peerOK {
+		host = peerHost(peer.Addr.String())
 	}
 
 	for {
@@ -250,6 +570,9 @@ func (c *CiscoTelemetryMDT) MdtDialout(stream dialout.GRPCMdtDialout_MdtDialoutS
 			if err != io.EOF && c.ctx.Err() == nil {
 				c.acc.AddError(fmt.Errorf("E! GRPC dialout receive error: %v", err))
 			}
+			if status.Code(err) == codes.ResourceExhausted && peerOK {
+				c.sizeViolations(peer.Addr).Incr(1)
+			}
 			break
 		}
 
@@ -258,7 +581,22 @@ func (c *CiscoTelemetryMDT) MdtDialout(stream dialout.GRPCMdtDialout_MdtDialoutS
 			break
 		}
 
-		c.handleTelemetry(packet.Data)
+		err = c.handleTaggedTelemetry(packet.Data, host, tags)
+
+		// Acknowledge the message, reporting decoding errors back to the device
+		if c.DialoutAcks {
+			ack := &dialout.MdtDialoutArgs{ReqId: packet.ReqId}
+			if err != nil {
+				ack.Errors = err.Error()
+			}
+
+			if err := stream.Send(ack); err != nil {
+				if c.ctx.Err() == nil {
+					c.acc.AddError(fmt.Errorf("E! GRPC dialout acknowledgement failed: %v", err))
+				}
+				break
+			}
+		}
 	}
 
 	if peerOK {
@@ -270,6 +608,7 @@ func (c *CiscoTelemetryMDT) MdtDialout(stream dialout.GRPCMdtDialout_MdtDialoutS
 
 // SubscribeMDTDialinDevice and extract GPB telemetry data
 func (c *CiscoTelemetryMDT) subscribeMDTDialinDevice(client *grpc.ClientConn) {
+	b := &backoff.Backoff{Min: c.Redial.Duration, Max: c.RedialMax.Duration, ResetAfter: c.RedialResetAfter.Duration}
 	for c.ctx.Err() == nil {
 		request := &ems.CreateSubsArgs{
 			ReqId:    1,
@@ -279,38 +618,48 @@ func (c *CiscoTelemetryMDT) subscribeMDTDialinDevice(client *grpc.ClientConn) {
 		client := ems.NewGRPCConfigOperClient(client)
 		stream, err := client.CreateSubs(c.ctx, request)
 		if err != nil {
-			c.acc.AddError(fmt.Errorf("E! GRPC dialin subscription failed: %v", err))
+			c.acc.AddError(fmt.Errorf("E! GRPC dialin subscription failed: %v", rpcerror.Decode(err, nil)))
 		} else {
 			log.Printf("D! Subscribed to Cisco MDT device %s", c.ServiceAddress)
+			c.health.trackSession(1)
+			established := time.Now()
 
 			// After subscription is setup, read and handle telemetry packets
+			var packet *ems.CreateSubsReply
 			for {
-				packet, err := stream.Recv()
+				packet, err = stream.Recv()
 				if err != nil {
 					break
 				}
 
 				if len(packet.Errors) != 0 {
-					c.acc.AddError(fmt.Errorf("E! GRPC dialin error: %s", packet.Errors))
+					message, _ := rpcerror.ParseMessage(packet.Errors)
+					c.acc.AddError(fmt.Errorf("E! GRPC dialin error: %s", message))
 				} else {
-					c.handleTelemetry(packet.Data)
+					c.handleTelemetry(packet.Data, peerHost(c.ServiceAddress))
 				}
 			}
 
-			if err != nil && err != io.EOF {
+			// Devices provide details of the error in the status and trailers, lost connections
+			// are redialed silently
+			if err != io.EOF && status.Code(err) != codes.Unavailable && c.ctx.Err() == nil {
+				err = rpcerror.Decode(err, stream.Trailer())
 				c.acc.AddError(fmt.Errorf("E! GRPC dialin subscription receive error: %v", err))
 			}
 
 			log.Printf("D! Connection to Cisco MDT device %s closed", c.ServiceAddress)
+			c.health.trackSession(-1)
+			b.Established(time.Since(established))
 		}
 
-		if c.Redial.Duration.Nanoseconds() <= 0 {
+		redial := b.Next()
+		if redial.Nanoseconds() <= 0 {
 			break
 		}
 
 		select {
 		case <-c.ctx.Done():
-		case <-time.After(c.Redial.Duration):
+		case <-time.After(redial):
 		}
 	}
 
@@ -318,16 +667,82 @@ func (c *CiscoTelemetryMDT) subscribeMDTDialinDevice(client *grpc.ClientConn) {
 	c.wg.Done()
 }
 
-// Handle telemetry packet from any transport, decode and add as measurement
-func (c *CiscoTelemetryMDT) handleTelemetry(data []byte) {
+// DialoutMetadataTags of the configured keys of the metadata of a GRPC dialout stream
+func (c *CiscoTelemetryMDT) dialoutMetadataTags(ctx context.Context) map[string]string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(c.DialoutMetadataTags) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]string, len(c.DialoutMetadataTags))
+	for _, key := range c.DialoutMetadataTags {
+		if values := md.Get(key); len(values) > 0 && len(values[0]) > 0 {
+			tags[key] = values[0]
+		}
+	}
+	return tags
+}
+
+// Handle telemetry packet of a peer host from any transport, decode and add as measurement
+func (c *CiscoTelemetryMDT) handleTelemetry(data []byte, peer string) error {
+	return c.handleTaggedTelemetry(data, peer, nil)
+}
+
+// DecodeWorkers configured, or the CPUs available to the container if not configured
+func (c *CiscoTelemetryMDT) decodeWorkers() int {
+	if c.DecodeWorkers != 0 {
+		return c.DecodeWorkers
+	}
+	return cpulimit.CPUs()
+}
+
+// HandleTaggedTelemetry packet, adding the tags of its connection to all measurements
+func (c *CiscoTelemetryMDT) handleTaggedTelemetry(data []byte, peer string, connTags map[string]string) error {
+	// Messages of all connections are decoded by at most as many workers as CPUs are available
+	if c.decoders != nil {
+		c.decoders <- struct{}{}
+		defer func() { <-c.decoders }()
+	}
+
+	received := time.Now()
+	c.health.received()
 	var namebuf bytes.Buffer
 	telemetry := &telemetry.Telemetry{}
-	err := proto.Unmarshal(data, telemetry)
+
+	// Devices may mislabel the encoding, so it is detected from the payload
+	var err error
+	encoding := "gpbkv"
+	if payloadEncap(data) == tcpEncapJSON {
+		encoding = "json"
+		telemetry, err = decodeJSONTelemetry(data)
+	} else {
+		err = proto.Unmarshal(data, telemetry)
+	}
 	if err != nil {
-		c.acc.AddError(fmt.Errorf("E! Cisco MDT failed to decode: %v", err))
-		return
+		err = fmt.Errorf("E! Cisco MDT failed to decode: %v", err)
+		c.acc.AddError(err)
+		return err
+	}
+
+	// Compact GPB rows are converted into GPB-KV rows by the descriptors of their encoding path
+	if telemetry.DataGpb != nil && len(telemetry.DataGpbkv) == 0 {
+		decoded := false
+		if c.compact != nil {
+			if decoded, err = c.compact.decode(telemetry); err != nil {
+				err = fmt.Errorf("E! Cisco MDT failed to decode compact GPB: %v", err)
+				c.acc.AddError(err)
+				return err
+			}
+		}
+		if !decoded {
+			c.reportCompactGPB(telemetry)
+			return nil
+		}
+		encoding = "gpb"
 	}
 
+	rows := make([]collectionRow, 0, len(telemetry.DataGpbkv))
+
 	for _, gpbkv := range telemetry.DataGpbkv {
 		var fields map[string]interface{}
 
@@ -336,17 +751,21 @@ func (c *CiscoTelemetryMDT) handleTelemetry(data []byte) {
 
 		// Top-level field may have measurement timestamp, if not use message timestamp
 		measured := gpbkv.Timestamp
-		if measured == 0 {
+		if measured == 0 || c.TimestampPolicy == "message" {
 			measured = telemetry.MsgTimestamp
 		}
 
 		timestamp := time.Unix(int64(measured/1000), int64(measured%1000)*1000000)
+		deleted := gpbkv.Delete
 
 		// Populate tags and fields from toplevel GPBKV fields "keys" and "content"
 		for _, field := range gpbkv.Fields {
 			switch field.Name {
 			case "keys":
-				tags = make(map[string]string, len(field.Fields)+2)
+				tags = make(map[string]string, len(field.Fields)+len(connTags)+2)
+				for key, value := range connTags {
+					tags[key] = value
+				}
 				tags["Producer"] = telemetry.GetNodeIdStr()
 				tags["Target"] = telemetry.GetSubscriptionIdStr()
 				for _, subfield := range field.Fields {
@@ -357,19 +776,198 @@ func (c *CiscoTelemetryMDT) handleTelemetry(data []byte) {
 				for _, subfield := range field.Fields {
 					c.parseGPBKVField(subfield, &namebuf, telemetry.EncodingPath, timestamp, tags, fields)
 				}
+				timestamp = c.groupTimestamp(field, timestamp)
 			default:
 				log.Printf("I! Unexpected top-level MDT field: %s", field.Name)
 			}
 		}
+		c.tagRules.Apply(tags)
 
-		// Emit measurement
-		if len(fields) > 0 && len(tags) > 0 && len(telemetry.EncodingPath) > 0 {
-			c.acc.AddFields(telemetry.EncodingPath, fields, tags, timestamp)
+		if deleted {
+			// Deleted rows only carry last known values, never emit them as current data
+			if c.CollectionEvents && len(tags) > 0 && len(telemetry.EncodingPath) > 0 {
+				c.handleDelete(telemetry, tags, timestamp)
+			}
+		} else if len(fields) > 0 && len(tags) > 0 && len(telemetry.EncodingPath) > 0 {
+			c.sentinels[telemetry.EncodingPath].Apply(fields, c.SentinelPolicy == "flag")
+			c.coercions[telemetry.EncodingPath].Apply(fields)
+			if len(fields) == 0 {
+				// Rows of only unavailable values
+				continue
+			}
+			c.transforms[telemetry.EncodingPath].Apply(fields)
+			if c.Provenance {
+				c.addProvenance(telemetry, encoding, fields, tags, received)
+			}
+			if c.schemas != nil {
+				c.handleSchemaChange(telemetry, fields, timestamp)
+			}
+			rows = append(rows, collectionRow{fields: fields, tags: tags, timestamp: timestamp})
 		} else {
 			c.acc.AddError(fmt.Errorf("I! Cisco MDT invalid field: encoding path or measurement empty"))
 		}
 	}
 
+	// Measure telemetry freshness when the collection is complete
+	if c.PipelineDelay && telemetry.CollectionEndTime > 0 {
+		c.handlePipelineDelay(telemetry, peer, received)
+	}
+
+	// Hold back measurements until their collection is complete
+	if c.CollectionBuffering {
+		rows = c.bufferCollection(telemetry, rows)
+	}
+
+	c.emitRows(telemetry.EncodingPath, rows)
+
+	// Mark the end of the collection after its last measurements
+	if c.CollectionEvents && telemetry.CollectionEndTime > 0 {
+		c.handleCollectionEnd(telemetry)
+	}
+
+	return nil
+}
+
+// EmitRows as measurements of an encoding path
+func (c *CiscoTelemetryMDT) emitRows(path string, rows []collectionRow) {
+	for _, row := range rows {
+		if c.reorder != nil {
+			c.reorder.Add(path, row.fields, row.tags, row.timestamp)
+		} else {
+			c.acc.AddFields(path, row.fields, row.tags, row.timestamp)
+		}
+
+		if c.exporter != nil {
+			c.exporter.Update(path, row.fields, row.tags)
+		}
+	}
+}
+
+// HandleDelete adds a tombstone measurement for a row removed from the device
+func (c *CiscoTelemetryMDT) handleDelete(telemetry *telemetry.Telemetry, keys map[string]string, timestamp time.Time) {
+	tags := make(map[string]string, len(keys)+1)
+	for key, value := range keys {
+		tags[key] = value
+	}
+	tags["path"] = telemetry.EncodingPath
+
+	c.acc.AddFields("cisco_telemetry_mdt_delete", map[string]interface{}{"deleted": true}, tags, timestamp)
+}
+
+// HandleCollectionEnd adds a boundary measurement once all rows of a collection were sent
+func (c *CiscoTelemetryMDT) handleCollectionEnd(telemetry *telemetry.Telemetry) {
+	end := time.Unix(0, int64(telemetry.CollectionEndTime)*int64(time.Millisecond))
+
+	tags := map[string]string{
+		"Producer": telemetry.GetNodeIdStr(),
+		"Target":   telemetry.GetSubscriptionIdStr(),
+		"path":     telemetry.EncodingPath,
+	}
+	fields := map[string]interface{}{
+		"collection_id": telemetry.CollectionId,
+		"complete":      true,
+	}
+	if telemetry.CollectionStartTime > 0 {
+		fields["collection_start_time"] = int64(telemetry.CollectionStartTime)
+	}
+
+	c.acc.AddFields("cisco_telemetry_mdt_collection", fields, tags, end)
+}
+
+// AddProvenance of a measurement as tags and its reception time as field
+func (c *CiscoTelemetryMDT) addProvenance(telemetry *telemetry.Telemetry, encoding string, fields map[string]interface{},
+	tags map[string]string, received time.Time) {
+	fields["provenance_received"] = received.UnixNano()
+	tags["provenance_encoding"] = encoding
+	tags["provenance_transport"] = c.Transport
+	if len(telemetry.GetSubscriptionIdStr()) > 0 {
+		tags["provenance_subscription"] = telemetry.GetSubscriptionIdStr()
+	}
+	if len(c.collector) > 0 {
+		tags["provenance_collector"] = c.collector
+	}
+}
+
+// HandlePipelineDelay emits the delay between collection end on the device and reception from the peer host
+func (c *CiscoTelemetryMDT) handlePipelineDelay(telemetry *telemetry.Telemetry, peer string, received time.Time) {
+	end := time.Unix(0, int64(telemetry.CollectionEndTime)*int64(time.Millisecond))
+
+	tags := map[string]string{
+		"Producer": telemetry.GetNodeIdStr(),
+		"Target":   telemetry.GetSubscriptionIdStr(),
+		"path":     telemetry.EncodingPath,
+	}
+	if len(peer) > 0 {
+		tags["peer"] = peer
+	}
+	fields := map[string]interface{}{
+		"delay_ms": received.Sub(end).Nanoseconds() / int64(time.Millisecond),
+	}
+
+	if telemetry.CollectionStartTime > 0 && telemetry.CollectionStartTime <= telemetry.CollectionEndTime {
+		fields["collection_duration_ms"] = int64(telemetry.CollectionEndTime - telemetry.CollectionStartTime)
+	}
+
+	c.acc.AddFields("cisco_telemetry_mdt_pipeline", fields, tags, received)
+}
+
+// HandleSchemaChange and add a measurement if the fields of an encoding path changed
+func (c *CiscoTelemetryMDT) handleSchemaChange(telemetry *telemetry.Telemetry, fields map[string]interface{}, timestamp time.Time) {
+	change := c.schemas.Observe(telemetry.GetNodeIdStr(), telemetry.EncodingPath, fields)
+	if change == nil {
+		return
+	}
+
+	tags := map[string]string{
+		"Producer": telemetry.GetNodeIdStr(),
+		"path":     telemetry.EncodingPath,
+	}
+
+	event := map[string]interface{}{
+		"fingerprint":    change.Fingerprint,
+		"fields":         change.Fields,
+		"changed_fields": strings.Join(change.Changed, ","),
+	}
+	if len(change.Previous) > 0 {
+		event["previous_fingerprint"] = change.Previous
+	}
+
+	c.acc.AddFields("cisco_telemetry_mdt_schema", event, tags, timestamp)
+}
+
+// GroupTimestamp of the fields of a row according to the timestamp policy
+func (c *CiscoTelemetryMDT) groupTimestamp(content *telemetry.TelemetryField, timestamp time.Time) time.Time {
+	if c.TimestampPolicy != "earliest" && c.TimestampPolicy != "latest" {
+		return timestamp
+	}
+
+	earliest, latest := fieldTimestamps(content, 0, 0)
+	measured := latest
+	if c.TimestampPolicy == "earliest" {
+		measured = earliest
+	}
+
+	if measured == 0 {
+		return timestamp
+	}
+	return time.Unix(int64(measured/1000), int64(measured%1000)*1000000)
+}
+
+// FieldTimestamps returns the earliest and latest timestamp set in a GPBKV field structure
+func fieldTimestamps(field *telemetry.TelemetryField, earliest uint64, latest uint64) (uint64, uint64) {
+	if field.Timestamp > 0 {
+		if earliest == 0 || field.Timestamp < earliest {
+			earliest = field.Timestamp
+		}
+		if field.Timestamp > latest {
+			latest = field.Timestamp
+		}
+	}
+
+	for _, subfield := range field.Fields {
+		earliest, latest = fieldTimestamps(subfield, earliest, latest)
+	}
+	return earliest, latest
 }
 
 // Recursively parse GPBKV field structure into fields or tags
@@ -423,28 +1021,49 @@ func (c *CiscoTelemetryMDT) parseGPBKVField(field *telemetry.TelemetryField, nam
 
 // Stop listener and cleanup
 func (c *CiscoTelemetryMDT) Stop() {
+	if c.exporter != nil {
+		c.exporter.Stop()
+	}
+
 	c.cancel()
 	if c.listener != nil {
 		c.listener.Close()
 	}
+	if c.udpConn != nil {
+		c.udpConn.Close()
+	}
 	c.wg.Wait()
 
+	if c.reorder != nil {
+		c.reorder.Flush()
+	}
+	if c.wal != nil {
+		c.wal.Close()
+		c.wal = nil
+	}
+
 	log.Println("I! Stopped Cisco MDT service on ", c.ServiceAddress)
 }
 
 const sampleConfig = `
-  ## Telemetry transport (one of: tcp-dialout, grpc-dialout, grpc-dialin)
+  ## Telemetry transport (one of: tcp-dialout, udp-dialout, grpc-dialout, grpc-dialin)
   transport = "grpc-dialout"
 
   ## Address and port to host telemetry listener on (dialout) or address to connect to (dialin)
   service_address = ":57000"
   
-  ## grpc-dialin: define credentials and subscription
+  ## grpc-dialin: define credentials and subscription, credentials and TLS keys may reference
+  ## secrets as "vault:<path>#<key>", "aws-kms:<base64 ciphertext>", "env:<variable>" or "file:<path>"
   # username = "cisco"
   # password = "cisco"
   # subscription = "subscription"
   # redial = "10s"
 
+  ## grpc-dialin: back off exponentially with jitter from redial up to redial_max while the
+  ## subscription keeps failing, starting over once it stayed established for redial_reset_after
+  # redial_max = "5m"
+  # redial_reset_after = "1m"
+
   ## grpc-dialin: enable client-side TLS and define CA to authenticate the device
   # tls = true
   # tls_ca = "/etc/telegraf/ca.pem"
@@ -453,15 +1072,127 @@ const sampleConfig = `
   ## grpc-dialin: define client-side TLS certificate & key to authenticate to the device
   # tls_cert = "/etc/telegraf/cert.pem"
   # tls_key = "/etc/telegraf/key.pem"
+  # tls_key_passphrase = "env:TELEGRAF_TLS_KEY_PASSPHRASE"
+
+  ## grpc-dialin: YAML file of named TLS profiles shared with other plugins, e.g. the gNMI plugin,
+  ## and the profile enabling TLS for the device; the settings above override those of the profile
+  # tls_profiles = "/etc/telegraf/tls_profiles.yaml"
+  # tls_profile = "internal-ca"
 
 
   ## grpc-dialout: enable server-side TLS and define certificate and key
   # tls = true
   # tls_cert = "/etc/telegraf/cert.pem"
   # tls_key = "/etc/telegraf/key.pem"
+  # tls_key_passphrase = "env:TELEGRAF_TLS_KEY_PASSPHRASE"
   
   ## grpc-dialout: enable TLS client authentication and define allowed CA certificates
   # tls_allowed_cacerts = ["/etc/telegraf/clientca.pem"]
+
+  ## grpc-dialout: acknowledge each received message on the MdtDialout stream, required by
+  ## XR releases stalling or resending data without acknowledgements
+  # dialout_acks = false
+
+  ## grpc-dialout: metadata of the dialout streams added as tags if present, e.g. destination
+  ## group or policy names set by the device or by a proxy multiplexing many devices
+  # dialout_metadata_tags = ["destination-group"]
+
+  ## tcp-dialout / grpc-dialout: socket options applied to accepted connections
+  # tcp_nodelay = true
+  # read_buffer_size = "4MiB"
+  # write_buffer_size = "1MiB"
+
+  ## udp-dialout: size of the socket receive buffer, absorbing bursts of datagrams
+  # read_buffer_size = "4MiB"
+
+  ## tcp-dialout / grpc-dialout: IPv4 TOS / IPv6 traffic class of sent packets
+  # traffic_class = 0
+
+  ## tcp-dialout / grpc-dialout: IPv6 flow labels of sent packets (one of: "auto" deriving
+  ## them from each connection, "none" leaving them zero), kernel default if unset
+  # flow_label = "auto"
+
+  ## tcp-dialout / udp-dialout / grpc-dialout: maximum size of decompressed messages, peers
+  ## exceeding it are disconnected or their datagrams dropped
+  # max_decompressed_size = "16MiB"
+
+  ## compiled proto descriptors (protoc --include_imports --descriptor_set_out) of the models
+  ## of sensor paths streamed in compact GPB, and content messages of paths whose package is
+  ## not named like the path
+  # proto_descriptors = ["/etc/telegraf/xr-telemetry.pb"]
+  # proto_messages = {"Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface/latest/generic-counters" = "cisco_ios_xr_infra_statsd_oper.infra_statistics.interfaces.interface.latest.generic_counters.ifstatsbag_generic"}
+
+  ## Timestamp of measurements (one of: "row" for the timestamp of each row falling back to
+  ## the message timestamp, "message" for the message timestamp of all rows, "earliest" or
+  ## "latest" field timestamp of each row)
+  # timestamp_policy = "row"
+
+  ## Emit measurements of a collection round only once all its messages have been received,
+  ## or at the first gather once the collection is incomplete for collection_timeout
+  # collection_buffering = false
+  # collection_timeout = "1m"
+
+  ## Emit delay between collection end on the device and reception by the collector
+  # pipeline_delay = false
+
+  ## Emit new fields or changed field types of encoding paths as "cisco_telemetry_mdt_schema"
+  # schema_events = false
+
+  ## Record the encoding, transport, subscription and collector hostname of each measurement as
+  ## "provenance_*" tags and its reception time as "provenance_received" field
+  # provenance = false
+
+  ## Emit "cisco_telemetry_mdt_delete" tombstones for rows deleted on the device and
+  ## "cisco_telemetry_mdt_collection" boundaries once all rows of a collection have been sent
+  # collection_events = false
+
+  ## Convert fields reported in non-base units by encoding path and field name (any of:
+  ## "kbps_to_bps", "mbps_to_bps", "bytes_to_bits", "load_to_percent", "hundredths", "thousandths")
+  # [inputs.cisco_telemetry_mdt.transforms."Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface/latest/data-rate"]
+  #   input-data-rate = "kbps_to_bps"
+  #   input-load = "load_to_percent"
+
+  ## Drop fields holding the value a sensor reports for "not available" by encoding path and
+  ## field name, or with sentinel_policy = "flag" replace them by a "<field>_available" flag
+  # sentinel_policy = "drop"
+  # [inputs.cisco_telemetry_mdt.sentinels."Cisco-IOS-XR-ip-sla-oper:ipsla/operation-data/operations/operation/statistics/latest"]
+  #   rtt = "0xFFFFFFFFFFFFFFFF"
+
+  ## Coerce fields changing their type across releases by encoding path and field name (any of:
+  ## "string", "int", "uint", "float", "bool"), dropping values that cannot be converted
+  # [inputs.cisco_telemetry_mdt.coercions."Cisco-IOS-XR-ifmgr-oper:interface-properties/data-nodes/data-node/system-view/interfaces/interface"]
+  #   mac-address = "string"
+  #   state-transition-time = "uint"
+
+  ## Normalize tag values by tag name, or "*" for all tags, with sanitizers applied in order
+  ## (any of: "lowercase", "uppercase", "trim_space", "replace_spaces", "trim_domain")
+  # [inputs.cisco_telemetry_mdt.tag_sanitizers]
+  #   "*" = ["trim_space"]
+  #   Producer = ["trim_domain", "lowercase"]
+
+  ## tcp-dialout / grpc-dialout: export goroutine and open connection counts per peer as internal statistics
+  # resource_accounting = false
+
+  ## maximum number of messages decoded concurrently across all connections, by default the
+  ## CPUs available to the container according to its cgroup CPU limit (-1 for unlimited)
+  # decode_workers = 0
+
+  ## emit the health of the transport as "cisco_telemetry_mdt_health" measurement every
+  ## interval, e.g. for "telegraf --test" and health checks
+  # health_metrics = false
+
+  ## serve the last numeric values on a Prometheus /metrics endpoint, dropping values not
+  ## updated within the expiration
+  # prometheus_address = ":9273"
+  # prometheus_expiration = "10m"
+
+  ## hold back telemetry data for up to the given latency to emit bursts in non-decreasing
+  ## timestamp order, as required by some stream processors
+  # reorder_latency = "0s"
+
+  ## persist metrics in a write-ahead log in the given directory before handing them to the
+  ## outputs, replaying metrics not yet delivered after a crash or restart
+  # wal_directory = "/var/lib/telegraf/wal/mdt"
 `
 
 // SampleConfig of plugin
@@ -474,8 +1205,14 @@ func (c *CiscoTelemetryMDT) Description() string {
 	return "Cisco model-driven telemetry (MDT) input plugin for IOS XR, IOS XE and NX-OS platforms"
 }
 
-// Gather plugin measurements (unused)
-func (c *CiscoTelemetryMDT) Gather(_ telegraf.Accumulator) error {
+// Gather the health of the transport if enabled, telemetry data is emitted as it is received
+func (c *CiscoTelemetryMDT) Gather(acc telegraf.Accumulator) error {
+	if c.CollectionBuffering {
+		c.expireCollections(time.Now())
+	}
+	if c.HealthMetrics {
+		c.gatherHealth(acc, time.Now())
+	}
 	return nil
 }
 
@@ -6,10 +6,15 @@
 package cisco_telemetry_mdt
 
 import (
+	"bytes"
+	"compress/zlib"
 	"context"
 	"encoding/binary"
 	"errors"
+	"io/ioutil"
+	"math"
 	"net"
+	"os"
 	"testing"
 	"time"
 
@@ -20,6 +25,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt/ems"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 
 	dialout "github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt/mdt_dialout"
 	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt/telemetry"
@@ -41,11 +47,129 @@ func TestHandleTelemetryEmpty(t *testing.T) {
 	}
 	data, _ := proto.Marshal(telemetry)
 
-	c.handleTelemetry(data)
+	c.handleTelemetry(data, "")
 	assert.Contains(t, acc.Errors, errors.New("I! Cisco MDT invalid field: encoding path or measurement empty"))
 	assert.Empty(t, acc.Metrics)
 }
 
+func TestHandleTelemetryCompactGPB(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy"}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	telemetry := &telemetry.Telemetry{
+		NodeId:       &telemetry.Telemetry_NodeIdStr{NodeIdStr: "hostname"},
+		EncodingPath: "type:model/some/path",
+		DataGpb:      &telemetry.TelemetryGPBTable{Row: []*telemetry.TelemetryRowGPB{{Timestamp: 1543236572000}}},
+	}
+	data, _ := proto.Marshal(telemetry)
+
+	// Messages of the same encoding path are only reported once
+	assert.Nil(t, c.handleTelemetry(data, ""))
+	assert.Nil(t, c.handleTelemetry(data, ""))
+	assert.Len(t, acc.Errors, 1)
+	assert.Contains(t, acc.Errors[0].Error(), "compact GPB of type:model/some/path from hostname")
+	assert.Empty(t, acc.Metrics)
+}
+
+// DescriptorFile containing the keys and content messages of an encoding path
+func descriptorFile(t *testing.T) string {
+	field := func(name string, number int32, kind descriptor.FieldDescriptorProto_Type, typeName string) *descriptor.FieldDescriptorProto {
+		f := &descriptor.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Type: kind.Enum()}
+		if len(typeName) > 0 {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+
+	set := &descriptor.FileDescriptorSet{File: []*descriptor.FileDescriptorProto{{
+		Name:    proto.String("some_path.proto"),
+		Package: proto.String("type.model.some.path"),
+		EnumType: []*descriptor.EnumDescriptorProto{{Name: proto.String("state"),
+			Value: []*descriptor.EnumValueDescriptorProto{{Name: proto.String("up"), Number: proto.Int32(1)}}}},
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("bag_KEYS"), Field: []*descriptor.FieldDescriptorProto{
+				field("interface_name", 1, descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+			}},
+			{Name: proto.String("bag"), Field: []*descriptor.FieldDescriptorProto{
+				field("packets_received", 1, descriptor.FieldDescriptorProto_TYPE_UINT64, ""),
+				field("offset", 2, descriptor.FieldDescriptorProto_TYPE_SINT32, ""),
+				field("state", 3, descriptor.FieldDescriptorProto_TYPE_ENUM, ".type.model.some.path.state"),
+				field("rate", 4, descriptor.FieldDescriptorProto_TYPE_DOUBLE, ""),
+				field("counters", 5, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".type.model.some.path.bag.counters"),
+			}, NestedType: []*descriptor.DescriptorProto{
+				{Name: proto.String("counters"), Field: []*descriptor.FieldDescriptorProto{
+					field("drops", 1, descriptor.FieldDescriptorProto_TYPE_UINT32, ""),
+				}},
+			}},
+		},
+	}}}
+
+	data, err := proto.Marshal(set)
+	assert.Nil(t, err)
+	file, err := ioutil.TempFile("", "descriptors")
+	assert.Nil(t, err)
+	file.Write(data)
+	file.Close()
+	return file.Name()
+}
+
+func TestHandleTelemetryCompactGPBDescriptors(t *testing.T) {
+	file := descriptorFile(t)
+	defer os.Remove(file)
+
+	c := &CiscoTelemetryMDT{Transport: "dummy", ProtoDescriptors: []string{file}}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+	assert.NotNil(t, c.compact)
+
+	var keys, content, counters proto.Buffer
+	keys.EncodeVarint(1<<3 | proto.WireBytes)
+	keys.EncodeStringBytes("GigabitEthernet0/0/0/0")
+
+	counters.EncodeVarint(1<<3 | proto.WireVarint)
+	counters.EncodeVarint(3)
+
+	content.EncodeVarint(1<<3 | proto.WireVarint)
+	content.EncodeVarint(1234)
+	content.EncodeVarint(2<<3 | proto.WireVarint)
+	content.EncodeZigzag32(uint64(-5 & 0xffffffff))
+	content.EncodeVarint(3<<3 | proto.WireVarint)
+	content.EncodeVarint(1)
+	content.EncodeVarint(4<<3 | proto.WireFixed64)
+	content.EncodeFixed64(math.Float64bits(0.5))
+	content.EncodeVarint(5<<3 | proto.WireBytes)
+	content.EncodeRawBytes(counters.Bytes())
+	// Fields unknown to the descriptor are skipped
+	content.EncodeVarint(9<<3 | proto.WireVarint)
+	content.EncodeVarint(1)
+
+	telemetry := &telemetry.Telemetry{
+		MsgTimestamp: 1543236572000,
+		EncodingPath: "type:model/some/path",
+		NodeId:       &telemetry.Telemetry_NodeIdStr{NodeIdStr: "hostname"},
+		Subscription: &telemetry.Telemetry_SubscriptionIdStr{SubscriptionIdStr: "subscription"},
+		DataGpb: &telemetry.TelemetryGPBTable{Row: []*telemetry.TelemetryRowGPB{
+			{Timestamp: 1543236572000, Keys: keys.Bytes(), Content: content.Bytes()},
+		}},
+	}
+	data, _ := proto.Marshal(telemetry)
+
+	assert.Nil(t, c.handleTelemetry(data, ""))
+	assert.Empty(t, acc.Errors)
+
+	tags := map[string]string{"interface-name": "GigabitEthernet0/0/0/0", "Producer": "hostname", "Target": "subscription"}
+	fields := map[string]interface{}{"packets-received": uint64(1234), "offset": int32(-5), "state": "up", "rate": 0.5,
+		"counters/drops": uint32(3)}
+	acc.AssertContainsTaggedFields(t, "type:model/some/path", fields, tags)
+
+	// Paths without descriptors are still reported
+	telemetry.EncodingPath = "type:model/other/path"
+	data, _ = proto.Marshal(telemetry)
+	assert.Nil(t, c.handleTelemetry(data, ""))
+	assert.Len(t, acc.Errors, 1)
+}
+
 func TestHandleTelemetryTwoSimple(t *testing.T) {
 	c := &CiscoTelemetryMDT{Transport: "dummy"}
 	acc := &testutil.Accumulator{}
@@ -109,7 +233,7 @@ func TestHandleTelemetryTwoSimple(t *testing.T) {
 	}
 	data, _ := proto.Marshal(telemetry)
 
-	c.handleTelemetry(data)
+	c.handleTelemetry(data, "")
 	assert.Empty(t, acc.Errors)
 
 	tags := map[string]string{"name": "str", "uint64": "1234", "Producer": "hostname", "Target": "subscription"}
@@ -178,7 +302,7 @@ func TestHandleTelemetrySingleNested(t *testing.T) {
 	}
 	data, _ := proto.Marshal(telemetry)
 
-	c.handleTelemetry(data)
+	c.handleTelemetry(data, "")
 	assert.Empty(t, acc.Errors)
 
 	tags := map[string]string{"nested/key/level": "3", "Producer": "hostname", "Target": "subscription"}
@@ -299,6 +423,49 @@ func TestTCPDialoutMultiple(t *testing.T) {
 	acc.AssertContainsTaggedFields(t, "type:model/other/path", fields, tags)
 }
 
+func TestUDPDialout(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "udp-dialout", ServiceAddress: "127.0.0.1:57003"}
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	conn, _ := net.Dial("udp", "127.0.0.1:57003")
+
+	data, _ := proto.Marshal(mockTelemetryMessage())
+	var datagram bytes.Buffer
+	binary.Write(&datagram, binary.BigEndian, dialoutHeader{MsgEncap: tcpEncapGPB, MsgLen: uint32(len(data))})
+	datagram.Write(data)
+	conn.Write(datagram.Bytes())
+
+	// Datagrams holding part of a message are dropped
+	conn.Write(datagram.Bytes()[:datagram.Len()-1])
+
+	time.Sleep(100 * time.Millisecond)
+	c.Stop()
+	conn.Close()
+
+	tags := map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription"}
+	fields := map[string]interface{}{"value": int64(-1)}
+	acc.AssertContainsTaggedFields(t, "type:model/some/path", fields, tags)
+	assert.Len(t, acc.Metrics, 1)
+	assert.Len(t, acc.Errors, 1)
+	assert.Equal(t, int64(1), c.invalidDatagrams(conn.LocalAddr()).Get())
+}
+
+func TestPeerCounterLimit(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "udp-dialout", ServiceAddress: "127.0.0.1:57099"}
+	for i := 0; i <= maxCountedPeers; i++ {
+		c.invalidDatagrams(&net.UDPAddr{IP: net.IPv4(10, 0, byte(i/256), byte(i%256)), Port: 57500}).Incr(1)
+	}
+
+	assert.Len(t, c.counted, maxCountedPeers)
+	assert.Equal(t, int64(1), c.invalidDatagrams(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 0)}).Get())
+
+	// Peers beyond the limit share one statistic
+	c.invalidDatagrams(&net.UDPAddr{IP: net.IPv4(10, 1, 0, 0)}).Incr(1)
+	assert.Equal(t, int64(2), c.invalidDatagrams(&net.UDPAddr{IP: net.IPv4(10, 0, 1, 0)}).Get())
+	assert.Len(t, c.counted, maxCountedPeers)
+}
+
 func TestGRPCDialoutError(t *testing.T) {
 	c := &CiscoTelemetryMDT{Transport: "grpc-dialout", ServiceAddress: "127.0.0.1:57001"}
 	acc := &testutil.Accumulator{}
@@ -461,6 +628,26 @@ func TestGRPCDialinError(t *testing.T) {
 	assert.Equal(t, acc.Errors, []error{errors.New("E! GRPC dialin error: testerror")})
 }
 
+func TestGRPCDialinSecretPassword(t *testing.T) {
+	os.Unsetenv("VAULT_ADDR")
+	c := &CiscoTelemetryMDT{Transport: "grpc-dialin", ServiceAddress: "127.0.0.1:57002",
+		Username: "theuser", Password: "vault:secret/telemetry#password", Subscription: "thesubscription"}
+	err := c.Start(&testutil.Accumulator{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "VAULT_ADDR")
+}
+
+func TestGRPCDialinTLSProfile(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "grpc-dialin", ServiceAddress: "127.0.0.1:57002",
+		Subscription: "thesubscription", TLSProfile: "internal-ca"}
+	assert.EqualError(t, c.Start(&testutil.Accumulator{}), `E! Cisco MDT TLS profile "internal-ca" requires a TLS profiles file`)
+
+	c.TLSProfiles = "/nonexistent/tls_profiles.yaml"
+	err := c.Start(&testutil.Accumulator{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "E! Failed to load Cisco MDT TLS profiles")
+}
+
 func TestGRPCDialinMultipleRedial(t *testing.T) {
 	m := &mockDialinServer{t: t}
 	listener, _ := net.Listen("tcp", "127.0.0.1:57002")
@@ -503,3 +690,468 @@ func TestGRPCDialinMultipleRedial(t *testing.T) {
 	fields = map[string]interface{}{"value": int64(-1)}
 	acc.AssertContainsTaggedFields(t, "type:model/other/path", fields, tags)
 }
+
+func TestHandleTelemetryCollectionBuffering(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", CollectionBuffering: true}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	// Incomplete collection superseded by a newer one is never emitted
+	msg := mockTelemetryMessage()
+	msg.CollectionId = 1
+	msg.EncodingPath = "type:model/incomplete/path"
+	data, _ := proto.Marshal(msg)
+	c.handleTelemetry(data, "")
+
+	msg.CollectionId = 2
+	data, _ = proto.Marshal(msg)
+	c.handleTelemetry(data, "")
+	assert.Empty(t, acc.Metrics)
+
+	msg.DataGpbkv[0].Fields[0].Fields[0].ValueByType = &telemetry.TelemetryField_StringValue{StringValue: "str2"}
+	msg.CollectionEndTime = 1543236572000
+	data, _ = proto.Marshal(msg)
+	c.handleTelemetry(data, "")
+
+	assert.Empty(t, acc.Errors)
+	assert.Len(t, acc.Metrics, 2)
+
+	tags := map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription"}
+	fields := map[string]interface{}{"value": int64(-1)}
+	acc.AssertContainsTaggedFields(t, "type:model/incomplete/path", fields, tags)
+
+	tags = map[string]string{"name": "str2", "Producer": "hostname", "Target": "subscription"}
+	acc.AssertContainsTaggedFields(t, "type:model/incomplete/path", fields, tags)
+}
+
+func TestHandleTelemetryCollectionTimeout(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", CollectionBuffering: true, CollectionTimeout: internal.Duration{Duration: time.Minute}}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+	defer c.Stop()
+
+	msg := mockTelemetryMessage()
+	msg.CollectionId = 1
+	data, _ := proto.Marshal(msg)
+	c.handleTelemetry(data, "")
+
+	c.expireCollections(time.Now())
+	assert.Empty(t, acc.Metrics)
+
+	// Collections never completed are emitted once timed out
+	c.expireCollections(time.Now().Add(time.Minute))
+	assert.Len(t, acc.Metrics, 1)
+	assert.True(t, acc.HasMeasurement("type:model/some/path"))
+	assert.Equal(t, int64(1), c.incomplete.Get())
+	assert.Empty(t, c.collections)
+}
+
+func TestHandleTelemetryPipelineDelay(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", PipelineDelay: true}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	msg := mockTelemetryMessage()
+	data, _ := proto.Marshal(msg)
+	c.handleTelemetry(data, "192.0.2.1")
+	assert.False(t, acc.HasMeasurement("cisco_telemetry_mdt_pipeline"))
+
+	end := time.Now().Add(-2 * time.Second)
+	msg.CollectionEndTime = uint64(end.UnixNano() / int64(time.Millisecond))
+	msg.CollectionStartTime = msg.CollectionEndTime - 500
+	data, _ = proto.Marshal(msg)
+	c.handleTelemetry(data, "192.0.2.1")
+
+	assert.Empty(t, acc.Errors)
+	assert.True(t, acc.HasMeasurement("cisco_telemetry_mdt_pipeline"))
+	for _, metric := range acc.Metrics {
+		if metric.Measurement == "cisco_telemetry_mdt_pipeline" {
+			assert.Equal(t, map[string]string{"Producer": "hostname", "Target": "subscription", "path": "type:model/some/path", "peer": "192.0.2.1"}, metric.Tags)
+			assert.Equal(t, int64(500), metric.Fields["collection_duration_ms"])
+			assert.InDelta(t, 2000, metric.Fields["delay_ms"], 1000)
+		}
+	}
+}
+
+func TestHandleTelemetryTransforms(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", DecodeWorkers: 1, Transforms: map[string]map[string]string{
+		"type:model/some/path": {"value": "kbps_to_bps"},
+	}}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+	assert.Equal(t, 1, cap(c.decoders))
+
+	// Workers are released after each message
+	data, _ := proto.Marshal(mockTelemetryMessage())
+	c.handleTelemetry(data, "")
+	c.handleTelemetry(data, "")
+
+	assert.Empty(t, acc.Errors)
+
+	tags := map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription"}
+	fields := map[string]interface{}{"value": int64(-1000)}
+	acc.AssertContainsTaggedFields(t, "type:model/some/path", fields, tags)
+}
+
+func TestTCPDialoutResourceAccounting(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "tcp-dialout", ServiceAddress: "127.0.0.1:57000", ResourceAccounting: true}
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	conn, _ := net.Dial("tcp", "127.0.0.1:57000")
+	time.Sleep(time.Second)
+
+	stats := c.peerStats(conn.LocalAddr())
+	assert.Equal(t, int64(1), stats.goroutines.Get())
+	assert.Equal(t, int64(1), stats.connections.Get())
+
+	conn.Close()
+	time.Sleep(time.Second)
+
+	assert.Equal(t, int64(0), stats.goroutines.Get())
+	assert.Equal(t, int64(0), stats.connections.Get())
+
+	c.Stop()
+}
+
+func TestTCPDialoutZlib(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "tcp-dialout", ServiceAddress: "127.0.0.1:57000",
+		MaxDecompressedSize: internal.Size{Size: 1024}}
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	hdr := struct {
+		MsgType       uint16
+		MsgEncap      uint16
+		MsgHdrVersion uint16
+		MsgFlags      uint16
+		MsgLen        uint32
+	}{MsgFlags: tcpFlagZlib}
+
+	conn, _ := net.Dial("tcp", "127.0.0.1:57000")
+
+	var compressed bytes.Buffer
+	data, _ := proto.Marshal(mockTelemetryMessage())
+	writer := zlib.NewWriter(&compressed)
+	writer.Write(data)
+	writer.Close()
+
+	hdr.MsgLen = uint32(compressed.Len())
+	binary.Write(conn, binary.BigEndian, hdr)
+	conn.Write(compressed.Bytes())
+
+	// Highly compressible payload exceeding the limit once decompressed
+	compressed.Reset()
+	writer = zlib.NewWriter(&compressed)
+	writer.Write(make([]byte, 4096))
+	writer.Close()
+
+	hdr.MsgLen = uint32(compressed.Len())
+	binary.Write(conn, binary.BigEndian, hdr)
+	conn.Write(compressed.Bytes())
+
+	time.Sleep(time.Second)
+
+	// The connection has been closed by the plugin
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := conn.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+	assert.Equal(t, int64(1), c.sizeViolations(conn.LocalAddr()).Get())
+
+	c.Stop()
+	conn.Close()
+
+	tags := map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription"}
+	fields := map[string]interface{}{"value": int64(-1)}
+	acc.AssertContainsTaggedFields(t, "type:model/some/path", fields, tags)
+	assert.Len(t, acc.Errors, 1)
+}
+
+func TestTCPDialoutMislabeledEncoding(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "tcp-dialout", ServiceAddress: "127.0.0.1:57000"}
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	hdr := struct {
+		MsgType       uint16
+		MsgEncap      uint16
+		MsgHdrVersion uint16
+		MsgFlags      uint16
+		MsgLen        uint32
+	}{MsgEncap: tcpEncapGPB}
+
+	conn, _ := net.Dial("tcp", "127.0.0.1:57000")
+
+	// JSON payload claimed to be GPB
+	data := []byte(`{"node_id_str": "hostname", "subscription_id_str": "subscription",
+		"encoding_path": "type:model/json/path", "msg_timestamp": 1543236572000,
+		"data_json": [{"timestamp": 1543236572000, "keys": {"name": "str"},
+			"content": {"value": -1, "rate": 0.5, "nested": {"state": "up"}, "list": [{"id": 1}]}}]}`)
+	hdr.MsgLen = uint32(len(data))
+	binary.Write(conn, binary.BigEndian, hdr)
+	conn.Write(data)
+
+	data, _ = proto.Marshal(mockTelemetryMessage())
+	hdr.MsgLen = uint32(len(data))
+	binary.Write(conn, binary.BigEndian, hdr)
+	conn.Write(data)
+
+	time.Sleep(time.Second)
+
+	assert.Equal(t, int64(1), c.encodingMismatches(conn.LocalAddr()).Get())
+
+	c.Stop()
+	conn.Close()
+
+	assert.Empty(t, acc.Errors)
+
+	tags := map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription"}
+	fields := map[string]interface{}{"value": int64(-1), "rate": 0.5, "nested/state": "up", "list/id": int64(1)}
+	acc.AssertContainsTaggedFields(t, "type:model/json/path", fields, tags)
+	acc.AssertContainsTaggedFields(t, "type:model/some/path", map[string]interface{}{"value": int64(-1)}, tags)
+}
+
+func TestGRPCDialoutAcks(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "grpc-dialout", ServiceAddress: "127.0.0.1:57001", DialoutAcks: true}
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	conn, _ := grpc.Dial("127.0.0.1:57001", grpc.WithInsecure(), grpc.WithBlock())
+	client := dialout.NewGRPCMdtDialoutClient(conn)
+	stream, _ := client.MdtDialout(context.TODO())
+
+	data, _ := proto.Marshal(mockTelemetryMessage())
+	stream.Send(&dialout.MdtDialoutArgs{Data: data, ReqId: 456})
+	ack, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, &dialout.MdtDialoutArgs{ReqId: 456}, ack)
+
+	stream.Send(&dialout.MdtDialoutArgs{Data: []byte{0xff}, ReqId: 457})
+	ack, err = stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(457), ack.ReqId)
+	assert.Contains(t, ack.Errors, "failed to decode")
+
+	c.Stop()
+	conn.Close()
+
+	assert.Len(t, acc.Errors, 1)
+}
+
+func TestHandleTelemetrySchemaEvents(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", SchemaEvents: true}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	msg := mockTelemetryMessage()
+	data, _ := proto.Marshal(msg)
+	c.handleTelemetry(data, "")
+	c.handleTelemetry(data, "")
+
+	assert.Len(t, acc.Metrics, 3)
+	tags := map[string]string{"Producer": "hostname", "path": "type:model/some/path"}
+	acc.AssertContainsTaggedFields(t, "cisco_telemetry_mdt_schema", map[string]interface{}{
+		"fingerprint":    "be88239e26fd84ce",
+		"fields":         1,
+		"changed_fields": "value",
+	}, tags)
+}
+
+func TestHandleTelemetryHealth(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", ServiceAddress: "127.0.0.1:57000", HealthMetrics: true}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	data, _ := proto.Marshal(mockTelemetryMessage())
+	c.handleTelemetry(data, "")
+
+	health := &testutil.Accumulator{}
+	assert.Nil(t, c.Gather(health))
+	assert.Len(t, health.Metrics, 1)
+	assert.Equal(t, "cisco_telemetry_mdt_health", health.Metrics[0].Measurement)
+	assert.Equal(t, map[string]string{"address": "127.0.0.1:57000", "transport": "dummy"}, health.Metrics[0].Tags)
+	assert.Equal(t, false, health.Metrics[0].Fields["healthy"])
+	assert.Equal(t, uint64(1), health.Metrics[0].Fields["messages"])
+	assert.Contains(t, health.Metrics[0].Fields, "last_message_age")
+}
+
+func TestHandleTelemetryTimestampPolicy(t *testing.T) {
+	msg := mockTelemetryMessage()
+	content := msg.DataGpbkv[0].Fields[1]
+	content.Fields[0].Timestamp = 1543236573000
+	content.Fields = append(content.Fields, &telemetry.TelemetryField{Name: "other", Timestamp: 1543236571000,
+		ValueByType: &telemetry.TelemetryField_Sint64Value{Sint64Value: 1}})
+	msg.MsgTimestamp = 1543236570000
+	msg.DataGpbkv[0].Timestamp = 1543236572000
+	data, _ := proto.Marshal(msg)
+
+	for policy, expected := range map[string]int64{"row": 1543236572, "message": 1543236570, "earliest": 1543236571, "latest": 1543236573} {
+		c := &CiscoTelemetryMDT{Transport: "dummy", TimestampPolicy: policy}
+		acc := &testutil.Accumulator{}
+		c.Start(acc)
+		c.handleTelemetry(data, "")
+
+		assert.Len(t, acc.Metrics, 1)
+		assert.Equal(t, time.Unix(expected, 0), acc.Metrics[0].Time, policy)
+	}
+
+	c := &CiscoTelemetryMDT{Transport: "dummy", TimestampPolicy: "median"}
+	assert.NotNil(t, c.Start(&testutil.Accumulator{}))
+}
+
+func TestHandleTelemetryCollectionEvents(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", CollectionEvents: true}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	msg := mockTelemetryMessage()
+	msg.CollectionId = 42
+	msg.CollectionStartTime = 1543236571000
+	msg.CollectionEndTime = 1543236573000
+	msg.DataGpbkv[0].Delete = true
+	data, _ := proto.Marshal(msg)
+	c.handleTelemetry(data, "")
+
+	assert.Empty(t, acc.Errors)
+	assert.Len(t, acc.Metrics, 2)
+	assert.False(t, acc.HasMeasurement("type:model/some/path"))
+
+	tags := map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription", "path": "type:model/some/path"}
+	acc.AssertContainsTaggedFields(t, "cisco_telemetry_mdt_delete", map[string]interface{}{"deleted": true}, tags)
+
+	tags = map[string]string{"Producer": "hostname", "Target": "subscription", "path": "type:model/some/path"}
+	acc.AssertContainsTaggedFields(t, "cisco_telemetry_mdt_collection", map[string]interface{}{
+		"collection_id":         uint64(42),
+		"collection_start_time": int64(1543236571000),
+		"complete":              true,
+	}, tags)
+	assert.Equal(t, time.Unix(1543236573, 0), acc.Metrics[1].Time)
+}
+
+func TestHandleTelemetryProvenance(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", Provenance: true}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	before := time.Now().UnixNano()
+	data, _ := proto.Marshal(mockTelemetryMessage())
+	c.handleTelemetry(data, "")
+
+	assert.Len(t, acc.Metrics, 1)
+	metric := acc.Metrics[0]
+	hostname, _ := os.Hostname()
+	assert.Equal(t, map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription",
+		"provenance_encoding": "gpbkv", "provenance_transport": "dummy", "provenance_subscription": "subscription",
+		"provenance_collector": hostname}, metric.Tags)
+	assert.True(t, metric.Fields["provenance_received"].(int64) >= before)
+}
+
+func TestHandleTelemetrySentinels(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", SentinelPolicy: "flag", Sentinels: map[string]map[string]string{
+		"type:model/some/path": {"value": "-1"},
+	}}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	data, _ := proto.Marshal(mockTelemetryMessage())
+	c.handleTelemetry(data, "")
+
+	assert.Empty(t, acc.Errors)
+
+	tags := map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription"}
+	fields := map[string]interface{}{"value_available": false}
+	acc.AssertContainsTaggedFields(t, "type:model/some/path", fields, tags)
+
+	// Rows of only unavailable values are dropped
+	c = &CiscoTelemetryMDT{Transport: "dummy", Sentinels: map[string]map[string]string{
+		"type:model/some/path": {"value": "-1"},
+	}}
+	acc = &testutil.Accumulator{}
+	c.Start(acc)
+	c.handleTelemetry(data, "")
+
+	assert.Empty(t, acc.Errors)
+	assert.Empty(t, acc.Metrics)
+}
+
+func TestHandleTelemetryCoercions(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", Coercions: map[string]map[string]string{
+		"type:model/some/path": {"value": "string"},
+	}}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	data, _ := proto.Marshal(mockTelemetryMessage())
+	c.handleTelemetry(data, "")
+
+	assert.Empty(t, acc.Errors)
+
+	tags := map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription"}
+	fields := map[string]interface{}{"value": "-1"}
+	acc.AssertContainsTaggedFields(t, "type:model/some/path", fields, tags)
+
+	// Values that cannot be coerced are dropped
+	c = &CiscoTelemetryMDT{Transport: "dummy", Coercions: map[string]map[string]string{
+		"type:model/some/path": {"value": "uint"},
+	}}
+	acc = &testutil.Accumulator{}
+	c.Start(acc)
+	c.handleTelemetry(data, "")
+
+	assert.Empty(t, acc.Errors)
+	assert.Empty(t, acc.Metrics)
+
+	c = &CiscoTelemetryMDT{Transport: "dummy", Coercions: map[string]map[string]string{
+		"type:model/some/path": {"value": "mac"},
+	}}
+	assert.EqualError(t, c.Start(acc), `E! Invalid Cisco MDT coercions for type:model/some/path: unknown type "mac" for field "value"`)
+}
+
+func TestHandleTelemetryTagSanitizers(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "dummy", TagSanitizers: map[string][]string{
+		"*":        {"uppercase"},
+		"Producer": {"trim_domain", "lowercase"},
+	}}
+	acc := &testutil.Accumulator{}
+	c.Start(acc)
+
+	message := mockTelemetryMessage()
+	message.NodeId = &telemetry.Telemetry_NodeIdStr{NodeIdStr: "Router1.example.com"}
+	data, _ := proto.Marshal(message)
+	c.handleTelemetry(data, "")
+
+	assert.Empty(t, acc.Errors)
+
+	tags := map[string]string{"name": "STR", "Producer": "router1", "Target": "SUBSCRIPTION"}
+	fields := map[string]interface{}{"value": int64(-1)}
+	acc.AssertContainsTaggedFields(t, "type:model/some/path", fields, tags)
+
+	c = &CiscoTelemetryMDT{Transport: "dummy", TagSanitizers: map[string][]string{"name": {"titlecase"}}}
+	assert.EqualError(t, c.Start(acc), `E! Invalid Cisco MDT tag sanitizers: unknown sanitizer "titlecase" for tag "name"`)
+}
+
+func TestGRPCDialoutMetadataTags(t *testing.T) {
+	c := &CiscoTelemetryMDT{Transport: "grpc-dialout", ServiceAddress: "127.0.0.1:57004",
+		DialoutMetadataTags: []string{"destination-group", "policy"}}
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, c.Start(acc))
+
+	conn, _ := grpc.Dial("127.0.0.1:57004", grpc.WithInsecure(), grpc.WithBlock())
+	client := dialout.NewGRPCMdtDialoutClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "destination-group", "collectors", "other", "value")
+	stream, _ := client.MdtDialout(ctx)
+
+	data, _ := proto.Marshal(mockTelemetryMessage())
+	stream.Send(&dialout.MdtDialoutArgs{Data: data})
+	time.Sleep(time.Second)
+
+	c.Stop()
+	conn.Close()
+
+	assert.Empty(t, acc.Errors)
+
+	tags := map[string]string{"name": "str", "Producer": "hostname", "Target": "subscription",
+		"destination-group": "collectors"}
+	fields := map[string]interface{}{"value": int64(-1)}
+	acc.AssertContainsTaggedFields(t, "type:model/some/path", fields, tags)
+}
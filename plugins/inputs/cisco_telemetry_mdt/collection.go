@@ -0,0 +1,83 @@
+package cisco_telemetry_mdt
+
+import (
+	"log"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt/telemetry"
+)
+
+// Default time after which incomplete collections are flushed
+const defaultCollectionTimeout = time.Minute
+
+// Measurement decoded from a single telemetry row
+type collectionRow struct {
+	fields    map[string]interface{}
+	tags      map[string]string
+	timestamp time.Time
+}
+
+// Collection round of a sensor path which may span multiple telemetry messages
+type collection struct {
+	id      uint64
+	path    string
+	started time.Time
+	rows    []collectionRow
+}
+
+// BufferCollection holds back rows until their collection is complete and returns the rows ready to emit
+func (c *CiscoTelemetryMDT) bufferCollection(msg *telemetry.Telemetry, rows []collectionRow) []collectionRow {
+	key := msg.GetNodeIdStr() + "|" + msg.GetSubscriptionIdStr() + "|" + msg.EncodingPath
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.collections == nil {
+		c.collections = make(map[string]*collection)
+	}
+
+	current, ok := c.collections[key]
+	if ok && current.id != msg.CollectionId {
+		log.Printf("D! Discarding incomplete Cisco MDT collection %d of %s", current.id, key)
+		ok = false
+	}
+
+	if !ok {
+		current = &collection{id: msg.CollectionId, path: msg.EncodingPath, started: time.Now()}
+		c.collections[key] = current
+	}
+	current.rows = append(current.rows, rows...)
+
+	// The last message of a collection carries its end time
+	if msg.CollectionEndTime == 0 {
+		return nil
+	}
+
+	delete(c.collections, key)
+	return current.rows
+}
+
+// ExpireCollections emits the rows of collections still incomplete after the collection timeout,
+// e.g. of removed subscriptions, lost final messages or sensors not setting the collection end time
+func (c *CiscoTelemetryMDT) expireCollections(now time.Time) {
+	timeout := c.CollectionTimeout.Duration
+	if timeout <= 0 {
+		timeout = defaultCollectionTimeout
+	}
+
+	var expired []*collection
+	c.mutex.Lock()
+	for key, current := range c.collections {
+		if now.Sub(current.started) >= timeout {
+			log.Printf("D! Flushing incomplete Cisco MDT collection %d of %s", current.id, key)
+			expired = append(expired, current)
+			delete(c.collections, key)
+		}
+	}
+	c.mutex.Unlock()
+
+	for _, current := range expired {
+		c.incomplete.Incr(1)
+		c.emitRows(current.path, current.rows)
+	}
+}
@@ -0,0 +1,313 @@
+package cisco_telemetry_mdt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt/telemetry"
+)
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Decoder of compact GPB rows by the messages of compiled proto descriptors
+type compactDecoder struct {
+	messages map[string]*descriptor.DescriptorProto
+	enums    map[string]*descriptor.EnumDescriptorProto
+
+	// Keys and content messages by encoding path
+	paths map[string]compactMessages
+	mutex sync.Mutex
+}
+
+// Messages of the keys and content of the rows of an encoding path
+type compactMessages struct {
+	keys    *descriptor.DescriptorProto
+	content *descriptor.DescriptorProto
+}
+
+// NewCompactDecoder loads the FileDescriptorSets of the files, e.g. compiled by protoc with
+// --include_imports --descriptor_set_out, and the content messages of encoding paths overriding
+// the ones derived from their package
+func newCompactDecoder(files []string, overrides map[string]string) (*compactDecoder, error) {
+	d := &compactDecoder{
+		messages: make(map[string]*descriptor.DescriptorProto),
+		enums:    make(map[string]*descriptor.EnumDescriptorProto),
+		paths:    make(map[string]compactMessages),
+	}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var set descriptor.FileDescriptorSet
+		if err := proto.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("invalid descriptor set %s: %v", file, err)
+		}
+
+		for _, fd := range set.File {
+			prefix := ""
+			if len(fd.GetPackage()) > 0 {
+				prefix = "." + fd.GetPackage()
+			}
+			d.addMessages(prefix, fd.MessageType, fd.EnumType)
+		}
+	}
+
+	for path, name := range overrides {
+		content, ok := d.messages["."+strings.TrimPrefix(name, ".")]
+		if !ok {
+			return nil, fmt.Errorf("unknown message %s of %s", name, path)
+		}
+		d.paths[path] = compactMessages{keys: d.messages["."+strings.TrimPrefix(name, ".")+"_KEYS"], content: content}
+	}
+	return d, nil
+}
+
+// AddMessages and enums of a scope by their fully qualified names, including nested ones
+func (d *compactDecoder) addMessages(prefix string, messages []*descriptor.DescriptorProto, enums []*descriptor.EnumDescriptorProto) {
+	for _, enum := range enums {
+		d.enums[prefix+"."+enum.GetName()] = enum
+	}
+	for _, message := range messages {
+		name := prefix + "." + message.GetName()
+		d.messages[name] = message
+		d.addMessages(name, message.NestedType, message.EnumType)
+	}
+}
+
+// Messages of an encoding path, by default the "<bag>_KEYS" and "<bag>" messages of the package
+// named like the path, e.g. cisco_ios_xr_infra_statsd_oper.infra_statistics.interfaces.interface
+// for Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface
+func (d *compactDecoder) lookup(path string) (compactMessages, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if messages, ok := d.paths[path]; ok {
+		return messages, messages.content != nil
+	}
+
+	replacer := strings.NewReplacer("-", "_", ":", ".", "/", ".")
+	prefix := "." + replacer.Replace(strings.ToLower(path)) + "."
+
+	var messages compactMessages
+	for name, message := range d.messages {
+		if !strings.HasPrefix(name, prefix) || strings.Contains(name[len(prefix):], ".") {
+			continue
+		}
+		if strings.HasSuffix(name, "_KEYS") {
+			messages.keys = message
+		} else {
+			messages.content = message
+		}
+	}
+
+	// Paths without descriptors are remembered too
+	d.paths[path] = messages
+	return messages, messages.content != nil
+}
+
+// Decode the compact GPB rows of a message into GPB-KV rows, false if the encoding path has
+// no messages
+func (d *compactDecoder) decode(msg *telemetry.Telemetry) (bool, error) {
+	messages, ok := d.lookup(msg.EncodingPath)
+	if !ok {
+		return false, nil
+	}
+
+	for _, row := range msg.DataGpb.Row {
+		content, err := d.decodeMessage(messages.content, row.Content)
+		if err != nil {
+			return true, fmt.Errorf("invalid content of %s: %v", msg.EncodingPath, err)
+		}
+
+		var keys []*telemetry.TelemetryField
+		if messages.keys != nil {
+			if keys, err = d.decodeMessage(messages.keys, row.Keys); err != nil {
+				return true, fmt.Errorf("invalid keys of %s: %v", msg.EncodingPath, err)
+			}
+		}
+
+		msg.DataGpbkv = append(msg.DataGpbkv, &telemetry.TelemetryField{
+			Timestamp: row.Timestamp,
+			Fields: []*telemetry.TelemetryField{
+				{Name: "keys", Fields: keys},
+				{Name: "content", Fields: content},
+			},
+		})
+	}
+	return true, nil
+}
+
+// DecodeMessage into GPB-KV fields named like the YANG leaves, i.e. with hyphens instead of
+// underscores, repeated fields becoming fields of the same name like in GPB-KV
+func (d *compactDecoder) decodeMessage(message *descriptor.DescriptorProto, data []byte) ([]*telemetry.TelemetryField, error) {
+	var fields []*telemetry.TelemetryField
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid tag")
+		}
+		data = data[n:]
+
+		number, wire := int32(tag>>3), int(tag&7)
+		var value []byte
+		var scalar uint64
+		switch wire {
+		case wireVarint:
+			if scalar, n = binary.Uvarint(data); n <= 0 {
+				return nil, fmt.Errorf("invalid varint of field %d", number)
+			}
+		case wireFixed64:
+			if n = 8; len(data) < n {
+				return nil, fmt.Errorf("truncated field %d", number)
+			}
+			scalar = binary.LittleEndian.Uint64(data)
+		case wireFixed32:
+			if n = 4; len(data) < n {
+				return nil, fmt.Errorf("truncated field %d", number)
+			}
+			scalar = uint64(binary.LittleEndian.Uint32(data))
+		case wireBytes:
+			length, m := binary.Uvarint(data)
+			if m <= 0 || uint64(len(data)-m) < length {
+				return nil, fmt.Errorf("truncated field %d", number)
+			}
+			value, n = data[m:m+int(length)], m+int(length)
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d of field %d", wire, number)
+		}
+		data = data[n:]
+
+		// Fields unknown to the descriptor, e.g. of newer releases, are skipped
+		field := findField(message, number)
+		if field == nil {
+			continue
+		}
+
+		name := strings.Replace(field.GetName(), "_", "-", -1)
+		switch {
+		case field.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+			nested, ok := d.messages[field.GetTypeName()]
+			if !ok || wire != wireBytes {
+				continue
+			}
+			children, err := d.decodeMessage(nested, value)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, &telemetry.TelemetryField{Name: name, Fields: children})
+		case wire == wireBytes && field.GetType() != descriptor.FieldDescriptorProto_TYPE_STRING &&
+			field.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES:
+			// Packed repeated scalars
+			values, err := d.decodePacked(name, field, value)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, values...)
+		default:
+			if value := d.decodeScalar(name, field, scalar, value); value != nil {
+				fields = append(fields, value)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// DecodePacked values of a repeated scalar field
+func (d *compactDecoder) decodePacked(name string, field *descriptor.FieldDescriptorProto, data []byte) ([]*telemetry.TelemetryField, error) {
+	var values []*telemetry.TelemetryField
+	for len(data) > 0 {
+		var scalar uint64
+		var n int
+		switch field.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_DOUBLE, descriptor.FieldDescriptorProto_TYPE_FIXED64,
+			descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+			if n = 8; len(data) < n {
+				return nil, fmt.Errorf("truncated packed field %s", field.GetName())
+			}
+			scalar = binary.LittleEndian.Uint64(data)
+		case descriptor.FieldDescriptorProto_TYPE_FLOAT, descriptor.FieldDescriptorProto_TYPE_FIXED32,
+			descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+			if n = 4; len(data) < n {
+				return nil, fmt.Errorf("truncated packed field %s", field.GetName())
+			}
+			scalar = uint64(binary.LittleEndian.Uint32(data))
+		default:
+			if scalar, n = binary.Uvarint(data); n <= 0 {
+				return nil, fmt.Errorf("invalid packed field %s", field.GetName())
+			}
+		}
+		data = data[n:]
+		if value := d.decodeScalar(name, field, scalar, nil); value != nil {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// DecodeScalar value of a field into a GPB-KV field of its type
+func (d *compactDecoder) decodeScalar(name string, field *descriptor.FieldDescriptorProto, scalar uint64, data []byte) *telemetry.TelemetryField {
+	value := &telemetry.TelemetryField{Name: name}
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		value.ValueByType = &telemetry.TelemetryField_StringValue{StringValue: string(data)}
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		value.ValueByType = &telemetry.TelemetryField_BytesValue{BytesValue: data}
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		value.ValueByType = &telemetry.TelemetryField_BoolValue{BoolValue: scalar != 0}
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		value.ValueByType = &telemetry.TelemetryField_DoubleValue{DoubleValue: math.Float64frombits(scalar)}
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		value.ValueByType = &telemetry.TelemetryField_FloatValue{FloatValue: math.Float32frombits(uint32(scalar))}
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		value.ValueByType = &telemetry.TelemetryField_Uint32Value{Uint32Value: uint32(scalar)}
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		value.ValueByType = &telemetry.TelemetryField_Uint64Value{Uint64Value: scalar}
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		value.ValueByType = &telemetry.TelemetryField_Sint32Value{Sint32Value: int32(scalar)}
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		value.ValueByType = &telemetry.TelemetryField_Sint64Value{Sint64Value: int64(scalar)}
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		value.ValueByType = &telemetry.TelemetryField_Sint32Value{Sint32Value: int32(uint32(scalar)>>1) ^ -int32(scalar&1)}
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		value.ValueByType = &telemetry.TelemetryField_Sint64Value{Sint64Value: int64(scalar>>1) ^ -int64(scalar&1)}
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		// Enums are reported by name like in GPB-KV, unknown values by number
+		if enum, ok := d.enums[field.GetTypeName()]; ok {
+			for _, v := range enum.Value {
+				if v.GetNumber() == int32(scalar) {
+					value.ValueByType = &telemetry.TelemetryField_StringValue{StringValue: v.GetName()}
+					return value
+				}
+			}
+		}
+		value.ValueByType = &telemetry.TelemetryField_Sint32Value{Sint32Value: int32(scalar)}
+	default:
+		return nil
+	}
+	return value
+}
+
+// FindField of a message by number
+func findField(message *descriptor.DescriptorProto, number int32) *descriptor.FieldDescriptorProto {
+	for _, field := range message.Field {
+		if field.GetNumber() == number {
+			return field
+		}
+	}
+	return nil
+}
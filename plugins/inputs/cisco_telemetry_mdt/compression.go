@@ -0,0 +1,91 @@
+package cisco_telemetry_mdt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+const (
+	// TCP dialout header flag for zlib compressed payloads
+	tcpFlagZlib uint16 = 1
+
+	// Default maximum size (in bytes) of decompressed telemetry payloads
+	defaultMaxDecompressedSize int64 = 16 * 1024 * 1024
+
+	// Maximum number of peer hosts counted separately, as UDP source addresses may be spoofed.
+	// Further peers are counted together under otherPeers.
+	maxCountedPeers = 256
+	otherPeers      = "other"
+)
+
+var errDecompressedSizeExceeded = errors.New("decompressed payload exceeds maximum size")
+
+// MaxDecompressedSize returns the configured or default limit for decompressed payloads
+func (c *CiscoTelemetryMDT) maxDecompressedSize() int64 {
+	if c.MaxDecompressedSize.Size > 0 {
+		return c.MaxDecompressedSize.Size
+	}
+	return defaultMaxDecompressedSize
+}
+
+// DecompressZlib payload without exceeding the maximum decompressed size
+func (c *CiscoTelemetryMDT) decompressZlib(payload []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	limit := c.maxDecompressedSize()
+	data, err := ioutil.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > limit {
+		return nil, errDecompressedSizeExceeded
+	}
+	return data, nil
+}
+
+// SizeViolations returns the statistic counting oversized payloads of a peer host
+func (c *CiscoTelemetryMDT) sizeViolations(addr net.Addr) selfstat.Stat {
+	return c.peerCounter("size_violations", addr)
+}
+
+// PeerCounter returns the named statistic of a peer host, registering it on first use, or the
+// statistic of all other peers once maxCountedPeers hosts are counted
+func (c *CiscoTelemetryMDT) peerCounter(name string, addr net.Addr) selfstat.Stat {
+	host := peerHost(addr.String())
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.counters == nil {
+		c.counters = make(map[string]selfstat.Stat)
+		c.counted = make(map[string]bool)
+	}
+
+	if !c.counted[host] {
+		if len(c.counted) >= maxCountedPeers {
+			host = otherPeers
+		} else {
+			c.counted[host] = true
+		}
+	}
+
+	stat, ok := c.counters[name+"|"+host]
+	if !ok {
+		tags := map[string]string{"address": c.ServiceAddress, "peer": host}
+		stat = selfstat.Register("cisco_telemetry_mdt", name, tags)
+		c.counters[name+"|"+host] = stat
+	}
+
+	return stat
+}
@@ -0,0 +1,146 @@
+package cisco_telemetry_mdt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/influxdata/telegraf/plugins/inputs/cisco_telemetry_mdt/telemetry"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+const (
+	// TCP dialout header encapsulations
+	tcpEncapGPB  uint16 = 1
+	tcpEncapJSON uint16 = 2
+)
+
+// Telemetry message in JSON encoding, rows carrying keys and content as JSON objects
+type jsonTelemetry struct {
+	NodeID              string `json:"node_id_str"`
+	SubscriptionID      string `json:"subscription_id_str"`
+	EncodingPath        string `json:"encoding_path"`
+	CollectionID        uint64 `json:"collection_id"`
+	CollectionStartTime uint64 `json:"collection_start_time"`
+	MsgTimestamp        uint64 `json:"msg_timestamp"`
+	CollectionEndTime   uint64 `json:"collection_end_time"`
+	Data                []struct {
+		Timestamp uint64                 `json:"timestamp"`
+		Keys      map[string]interface{} `json:"keys"`
+		Content   map[string]interface{} `json:"content"`
+	} `json:"data_json"`
+}
+
+// PayloadEncap detects the encoding of a payload regardless of what its header claims. A GPB
+// message never starts with '{', which would be the deprecated start-group wire type of field 15.
+func payloadEncap(data []byte) uint16 {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return tcpEncapJSON
+	}
+	return tcpEncapGPB
+}
+
+// EncodingMismatches returns the statistic counting payloads of a peer host mislabeled in the header
+func (c *CiscoTelemetryMDT) encodingMismatches(addr net.Addr) selfstat.Stat {
+	return c.peerCounter("encoding_mismatches", addr)
+}
+
+// DecodeJSONTelemetry converts a JSON encoded message into its GPB-KV representation
+func decodeJSONTelemetry(data []byte) (*telemetry.Telemetry, error) {
+	var message jsonTelemetry
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&message); err != nil {
+		return nil, err
+	}
+
+	msg := &telemetry.Telemetry{
+		NodeId:              &telemetry.Telemetry_NodeIdStr{NodeIdStr: message.NodeID},
+		Subscription:        &telemetry.Telemetry_SubscriptionIdStr{SubscriptionIdStr: message.SubscriptionID},
+		EncodingPath:        message.EncodingPath,
+		CollectionId:        message.CollectionID,
+		CollectionStartTime: message.CollectionStartTime,
+		MsgTimestamp:        message.MsgTimestamp,
+		CollectionEndTime:   message.CollectionEndTime,
+	}
+
+	for _, row := range message.Data {
+		msg.DataGpbkv = append(msg.DataGpbkv, &telemetry.TelemetryField{
+			Timestamp: row.Timestamp,
+			Fields: []*telemetry.TelemetryField{
+				{Name: "keys", Fields: jsonFields(row.Keys)},
+				{Name: "content", Fields: jsonFields(row.Content)},
+			},
+		})
+	}
+	return msg, nil
+}
+
+// JSONFields converts the members of a JSON object into GPB-KV fields, lists becoming
+// repeated fields of the same name like in GPB-KV
+func jsonFields(object map[string]interface{}) []*telemetry.TelemetryField {
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]*telemetry.TelemetryField, 0, len(object))
+	for _, name := range names {
+		values := []interface{}{object[name]}
+		if list, ok := object[name].([]interface{}); ok {
+			values = list
+		}
+
+		for _, value := range values {
+			if field := jsonField(name, value); field != nil {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// JSONField converts a JSON value into a GPB-KV field, keeping integers as integers
+func jsonField(name string, value interface{}) *telemetry.TelemetryField {
+	field := &telemetry.TelemetryField{Name: name}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		field.Fields = jsonFields(v)
+	case string:
+		field.ValueByType = &telemetry.TelemetryField_StringValue{StringValue: v}
+	case bool:
+		field.ValueByType = &telemetry.TelemetryField_BoolValue{BoolValue: v}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			field.ValueByType = &telemetry.TelemetryField_Sint64Value{Sint64Value: i}
+		} else if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			field.ValueByType = &telemetry.TelemetryField_Uint64Value{Uint64Value: u}
+		} else if f, err := v.Float64(); err == nil {
+			field.ValueByType = &telemetry.TelemetryField_DoubleValue{DoubleValue: f}
+		}
+	default:
+		return nil
+	}
+	return field
+}
+
+// ReportCompactGPB reports messages of an encoding path in compact GPB once, as their rows cannot
+// be decoded without the descriptor of the protos of the model
+func (c *CiscoTelemetryMDT) reportCompactGPB(msg *telemetry.Telemetry) {
+	c.mutex.Lock()
+	if c.unsupported == nil {
+		c.unsupported = make(map[string]bool)
+	}
+	reported := c.unsupported[msg.EncodingPath]
+	c.unsupported[msg.EncodingPath] = true
+	c.mutex.Unlock()
+
+	if !reported {
+		c.acc.AddError(fmt.Errorf("E! Cisco MDT compact GPB of %s from %s has no proto descriptor, "+
+			"configure proto_descriptors or use self-describing-gpb encoding", msg.EncodingPath, msg.GetNodeIdStr()))
+	}
+}
@@ -0,0 +1,51 @@
+package cisco_telemetry_mdt
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Health of the transport reported by Gather, updated atomically
+type health struct {
+	messages    uint64
+	lastMessage int64
+	sessions    int64
+}
+
+// Received counts a telemetry message
+func (h *health) received() {
+	if h != nil {
+		atomic.AddUint64(&h.messages, 1)
+		atomic.StoreInt64(&h.lastMessage, time.Now().UnixNano())
+	}
+}
+
+// TrackSession adjusts the number of connected dialout devices or established dialin subscriptions
+func (h *health) trackSession(delta int64) {
+	if h != nil {
+		atomic.AddInt64(&h.sessions, delta)
+	}
+}
+
+// GatherHealth emits the health of the transport, which is healthy while devices are connected
+func (c *CiscoTelemetryMDT) gatherHealth(acc telegraf.Accumulator, now time.Time) {
+	h := c.health
+	if h == nil {
+		return
+	}
+
+	sessions := atomic.LoadInt64(&h.sessions)
+	tags := map[string]string{"address": c.ServiceAddress, "transport": c.Transport}
+	fields := map[string]interface{}{
+		"healthy":  sessions > 0,
+		"sessions": sessions,
+		"messages": atomic.LoadUint64(&h.messages),
+	}
+	if last := atomic.LoadInt64(&h.lastMessage); last > 0 {
+		fields["last_message_age"] = now.Sub(time.Unix(0, last)).Seconds()
+	}
+
+	acc.AddFields("cisco_telemetry_mdt_health", fields, tags, now)
+}
@@ -0,0 +1,51 @@
+package cisco_telemetry_mdt
+
+import (
+	"context"
+	"log"
+	"net"
+	"syscall"
+)
+
+// Listener wrapper applying configured socket options to accepted dialout connections
+type dialoutListener struct {
+	net.Listener
+	plugin *CiscoTelemetryMDT
+}
+
+// ListenDialout creates the TCP listener for dialout transports. Socket options are set on the
+// listening socket, so accepted connections inherit them before their first packet.
+func (c *CiscoTelemetryMDT) listenDialout() (net.Listener, error) {
+	config := net.ListenConfig{Control: c.controlListener}
+	listener, err := config.Listen(context.Background(), "tcp", c.ServiceAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dialoutListener{Listener: listener, plugin: c}, nil
+}
+
+// ControlListener sets the socket options of the listening socket
+func (c *CiscoTelemetryMDT) controlListener(network string, address string, raw syscall.RawConn) error {
+	var err error
+	if cerr := raw.Control(func(fd uintptr) { err = c.setListenerOptions(fd, network == "tcp6") }); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// Accept connection and apply socket options
+func (l *dialoutListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return conn, err
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := l.plugin.setConnectionOptions(tcpConn); err != nil {
+			log.Printf("W! Failed to set socket options for %s: %v", conn.RemoteAddr(), err)
+		}
+	}
+
+	return l.plugin.peerStats(conn.RemoteAddr()).trackConnection(conn), nil
+}
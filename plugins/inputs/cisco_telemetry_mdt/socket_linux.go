@@ -0,0 +1,59 @@
+//go:build linux
+// +build linux
+
+package cisco_telemetry_mdt
+
+import (
+	"net"
+	"syscall"
+)
+
+// IPV6_AUTOFLOWLABEL, missing from package syscall
+const ipv6AutoFlowLabel = 0x46
+
+// SetListenerOptions sets buffer sizes, IPv4 TOS or IPv6 traffic class and the IPv6 flow label
+// policy on the listening socket, all of which are inherited by accepted connections
+func (c *CiscoTelemetryMDT) setListenerOptions(fd uintptr, ipv6 bool) error {
+	if c.ReadBufferSize.Size > 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF, int(c.ReadBufferSize.Size)); err != nil {
+			return err
+		}
+	}
+
+	if c.WriteBufferSize.Size > 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF, int(c.WriteBufferSize.Size)); err != nil {
+			return err
+		}
+	}
+
+	if c.TrafficClass > 0 {
+		// Dual-stack sockets send IPv4 packets of mapped addresses with the IPv4 TOS
+		if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, c.TrafficClass); err != nil {
+			return err
+		}
+		if ipv6 {
+			if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, c.TrafficClass); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Flow labels only exist in IPv6 headers
+	if ipv6 && len(c.FlowLabel) > 0 {
+		auto := 0
+		if c.FlowLabel == flowLabelAuto {
+			auto = 1
+		}
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, ipv6AutoFlowLabel, auto)
+	}
+
+	return nil
+}
+
+// SetConnectionOptions on an accepted dialout TCP connection, which Go enables TCP_NODELAY on
+func (c *CiscoTelemetryMDT) setConnectionOptions(conn *net.TCPConn) error {
+	if c.TCPNoDelay != nil {
+		return conn.SetNoDelay(*c.TCPNoDelay)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+package cisco_telemetry_mdt
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTCPDialoutSocketOptions(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:0", "[::1]:0"} {
+		nodelay := false
+		c := &CiscoTelemetryMDT{Transport: "tcp-dialout", ServiceAddress: address, TCPNoDelay: &nodelay,
+			ReadBufferSize: internal.Size{Size: 64 * 1024}, WriteBufferSize: internal.Size{Size: 32 * 1024},
+			TrafficClass: 0x20, FlowLabel: "none"}
+		listener, err := c.listenDialout()
+		if err != nil && address == "[::1]:0" {
+			t.Skip("IPv6 not available")
+		}
+		assert.Nil(t, err)
+
+		client, err := net.Dial("tcp", listener.Addr().String())
+		assert.Nil(t, err)
+		conn, err := listener.Accept()
+		assert.Nil(t, err)
+
+		// Options are read back from the accepted socket, the kernel doubles buffer sizes
+		raw, err := conn.(*net.TCPConn).SyscallConn()
+		assert.Nil(t, err)
+		assert.Nil(t, raw.Control(func(fd uintptr) {
+			option := func(level int, name int) int {
+				value, err := syscall.GetsockoptInt(int(fd), level, name)
+				assert.Nil(t, err)
+				return value
+			}
+
+			assert.Equal(t, 0, option(syscall.IPPROTO_TCP, syscall.TCP_NODELAY))
+			assert.Equal(t, 2*64*1024, option(syscall.SOL_SOCKET, syscall.SO_RCVBUF))
+			assert.Equal(t, 2*32*1024, option(syscall.SOL_SOCKET, syscall.SO_SNDBUF))
+			if address == "[::1]:0" {
+				assert.Equal(t, 0x20, option(syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS))
+				assert.Equal(t, 0, option(syscall.IPPROTO_IPV6, ipv6AutoFlowLabel))
+			} else {
+				assert.Equal(t, 0x20, option(syscall.IPPROTO_IP, syscall.IP_TOS))
+			}
+		}))
+
+		conn.Close()
+		client.Close()
+		listener.Close()
+	}
+
+	c := &CiscoTelemetryMDT{Transport: "tcp-dialout", ServiceAddress: "127.0.0.1:0", FlowLabel: "fixed"}
+	assert.NotNil(t, c.Start(nil))
+}
@@ -0,0 +1,41 @@
+//go:build !linux
+// +build !linux
+
+package cisco_telemetry_mdt
+
+import (
+	"fmt"
+	"net"
+)
+
+// SetListenerOptions rejects the traffic class and flow label, which are not supported on this
+// platform
+func (c *CiscoTelemetryMDT) setListenerOptions(fd uintptr, ipv6 bool) error {
+	if c.TrafficClass > 0 {
+		return fmt.Errorf("traffic class not supported on this platform")
+	}
+	if len(c.FlowLabel) > 0 {
+		return fmt.Errorf("flow label not supported on this platform")
+	}
+	return nil
+}
+
+// SetConnectionOptions on an accepted dialout TCP connection, including the buffer sizes
+func (c *CiscoTelemetryMDT) setConnectionOptions(conn *net.TCPConn) error {
+	if c.TCPNoDelay != nil {
+		if err := conn.SetNoDelay(*c.TCPNoDelay); err != nil {
+			return err
+		}
+	}
+
+	if c.ReadBufferSize.Size > 0 {
+		if err := conn.SetReadBuffer(int(c.ReadBufferSize.Size)); err != nil {
+			return err
+		}
+	}
+
+	if c.WriteBufferSize.Size > 0 {
+		return conn.SetWriteBuffer(int(c.WriteBufferSize.Size))
+	}
+	return nil
+}
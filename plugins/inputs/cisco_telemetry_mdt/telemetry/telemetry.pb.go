@@ -307,6 +307,10 @@ type TelemetryField struct {
 	// the corresponding YANG element name.
 	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
 	//
+	// delete: set when the key, value pair has been removed from the
+	// device and the data carried in this message is the last known value.
+	Delete bool `protobuf:"varint,3,opt,name=delete,proto3" json:"delete,omitempty"`
+	//
 	// value_by_type, if present, for the corresponding YANG element
 	// represented by the name field in the same TelemetryField message. The
 	// value is encoded to the matching type as defined in the YANG model.
@@ -375,6 +379,13 @@ func (m *TelemetryField) GetName() string {
 	return ""
 }
 
+func (m *TelemetryField) GetDelete() bool {
+	if m != nil {
+		return m.Delete
+	}
+	return false
+}
+
 type isTelemetryField_ValueByType interface {
 	isTelemetryField_ValueByType()
 }
@@ -820,40 +831,40 @@ func init() {
 func init() { proto.RegisterFile("telemetry.proto", fileDescriptor_edbfcf76559f568d) }
 
 var fileDescriptor_edbfcf76559f568d = []byte{
-	// 546 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x94, 0x41, 0x6f, 0x9b, 0x40,
-	0x10, 0x85, 0xb3, 0xb1, 0x6b, 0x9b, 0x01, 0xc7, 0xf2, 0x46, 0x95, 0xb6, 0x55, 0xa4, 0x12, 0xe7,
-	0xc2, 0xa1, 0xb2, 0x5a, 0x27, 0x4a, 0x7b, 0xad, 0xa5, 0xd6, 0xc9, 0xcd, 0xda, 0x58, 0xbd, 0x55,
-	0x08, 0xcc, 0xc6, 0x41, 0x01, 0x16, 0xb1, 0xeb, 0x44, 0xfe, 0x77, 0xbd, 0xf7, 0x4f, 0x55, 0xb3,
-	0x80, 0xc1, 0x6d, 0xa4, 0xdc, 0xe0, 0xcd, 0x37, 0x4f, 0xc3, 0xcc, 0x13, 0x30, 0xd2, 0x22, 0x11,
-	0xa9, 0xd0, 0xc5, 0x6e, 0x9a, 0x17, 0x52, 0x4b, 0x6a, 0xed, 0x85, 0xc9, 0xef, 0x0e, 0x58, 0xab,
-	0xfa, 0x8d, 0xba, 0x60, 0x67, 0x32, 0x12, 0x7e, 0x1c, 0xf9, 0x4a, 0x17, 0x8c, 0xb8, 0xc4, 0xb3,
-	0x6e, 0x8e, 0xb8, 0x85, 0xe2, 0x6d, 0x74, 0xa7, 0x0b, 0xfa, 0x09, 0x4e, 0xd5, 0x36, 0x54, 0xeb,
-	0x22, 0xce, 0x75, 0x2c, 0xb3, 0x9a, 0xec, 0x18, 0x92, 0xf0, 0x71, 0xbb, 0x58, 0x76, 0x5c, 0xc0,
-	0x50, 0x64, 0x6b, 0x19, 0xc5, 0xd9, 0xc6, 0xcf, 0x03, 0xfd, 0xc0, 0x7a, 0xc8, 0x72, 0xa7, 0x16,
-	0x97, 0x81, 0x7e, 0x40, 0x68, 0x2d, 0x93, 0x44, 0xac, 0x2b, 0x53, 0x36, 0x70, 0x89, 0xd7, 0xe5,
-	0x4e, 0x23, 0xde, 0x46, 0x74, 0x06, 0x6f, 0x5b, 0x90, 0xd2, 0x41, 0xa1, 0x7d, 0x1d, 0xa7, 0x82,
-	0x59, 0x06, 0x3e, 0x6d, 0x8a, 0x77, 0x58, 0x5b, 0xc5, 0xa9, 0x40, 0xe3, 0x54, 0x6d, 0x0c, 0xa6,
-	0x74, 0x90, 0xe6, 0x0c, 0x4a, 0xe3, 0x54, 0x6d, 0x56, 0xb5, 0x46, 0xbf, 0x02, 0x44, 0x81, 0x0e,
-	0xfc, 0x4d, 0x1e, 0x3e, 0x3e, 0x31, 0xdb, 0xed, 0x78, 0xf6, 0xec, 0xdd, 0xb4, 0xd9, 0xda, 0x7e,
-	0x41, 0x3f, 0x62, 0x91, 0x44, 0xdc, 0x42, 0x78, 0x81, 0x2c, 0xfd, 0x02, 0x83, 0xba, 0x93, 0x39,
-	0x2e, 0xf1, 0xec, 0xd9, 0xd9, 0x4b, 0x7d, 0x8b, 0xe5, 0x7c, 0x15, 0x84, 0x89, 0xe0, 0xfd, 0xaa,
-	0x95, 0x4e, 0xa1, 0x35, 0xae, 0x2f, 0xb2, 0xa8, 0xfc, 0x92, 0xa1, 0x99, 0x6e, 0xdc, 0x94, 0xbe,
-	0x67, 0x11, 0xce, 0x39, 0xb7, 0xa0, 0x5f, 0x5d, 0x66, 0x7e, 0x02, 0x4e, 0x7b, 0xcb, 0x93, 0x3f,
-	0x1d, 0x38, 0x39, 0x9c, 0x90, 0x9e, 0x81, 0xd5, 0x7c, 0x31, 0x31, 0x9e, 0x8d, 0x40, 0x29, 0x74,
-	0xb3, 0x20, 0x15, 0xec, 0xd8, 0x1c, 0xc2, 0x3c, 0xd3, 0x73, 0xb0, 0xc3, 0x9d, 0x16, 0xca, 0x7f,
-	0x0a, 0x92, 0xad, 0x60, 0x5d, 0x97, 0x78, 0xce, 0xcd, 0x11, 0x07, 0x23, 0xfe, 0x44, 0x8d, 0x5e,
-	0x80, 0xa3, 0x74, 0x81, 0x67, 0x2c, 0x99, 0x37, 0x55, 0x3a, 0xec, 0x52, 0x2d, 0xa1, 0x0f, 0x00,
-	0xa1, 0x94, 0x49, 0x85, 0xe0, 0xa9, 0x07, 0x18, 0x20, 0xd4, 0xf6, 0x2e, 0xdb, 0x38, 0xd3, 0x97,
-	0xb3, 0x0a, 0xe9, 0xbb, 0xc4, 0x1b, 0xa2, 0x4b, 0xa9, 0x1e, 0x40, 0xd7, 0x57, 0x15, 0x64, 0xd2,
-	0x50, 0x43, 0xd7, 0x57, 0xcd, 0x3c, 0x6d, 0x27, 0x4c, 0xc1, 0xd8, 0xcc, 0x73, 0xe8, 0xa4, 0xda,
-	0x4e, 0x78, 0x7e, 0x5a, 0x43, 0x2d, 0xa7, 0x48, 0x6e, 0xc3, 0x44, 0x54, 0x90, 0xed, 0x12, 0x8f,
-	0x20, 0x54, 0xaa, 0x25, 0x74, 0x0e, 0xf6, 0x7d, 0x22, 0x03, 0x5d, 0x31, 0x78, 0xed, 0x63, 0xdc,
-	0x90, 0x11, 0x4b, 0xe4, 0x33, 0xf4, 0xee, 0x71, 0xff, 0x8a, 0x8d, 0x5e, 0xcb, 0x50, 0x05, 0xce,
-	0x47, 0x30, 0x34, 0x7e, 0x7e, 0xb8, 0xf3, 0xf5, 0x2e, 0x17, 0x93, 0x6f, 0x30, 0xfe, 0x2f, 0x36,
-	0xf4, 0x23, 0x74, 0x0a, 0xf9, 0xcc, 0x88, 0x71, 0x7d, 0xff, 0x92, 0x2b, 0x97, 0xcf, 0x8b, 0xe5,
-	0x9c, 0x23, 0x36, 0xf9, 0x05, 0xa3, 0x7f, 0xf4, 0xd7, 0x03, 0xf1, 0x28, 0x76, 0xca, 0x2c, 0xc7,
-	0xe1, 0xe6, 0x99, 0x32, 0xe8, 0xaf, 0x65, 0xa6, 0x45, 0xa6, 0xcd, 0x3a, 0x1c, 0x5e, 0xbf, 0x86,
-	0x3d, 0xf3, 0x13, 0xb9, 0xfc, 0x1b, 0x00, 0x00, 0xff, 0xff, 0xcd, 0x43, 0x6f, 0x37, 0x57, 0x04,
-	0x00, 0x00,
+	// 558 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x94, 0xd1, 0x6a, 0xdb, 0x3e,
+	0x14, 0xc6, 0xab, 0x26, 0xff, 0x24, 0x3e, 0x76, 0x1a, 0xa2, 0xf2, 0x1f, 0xda, 0x28, 0xcc, 0x4d,
+	0x6f, 0x7c, 0x31, 0xc2, 0x96, 0x96, 0x6e, 0xb7, 0x0b, 0x6c, 0x69, 0xef, 0x82, 0x1a, 0x76, 0x37,
+	0x8c, 0x1d, 0xa9, 0xa9, 0xa9, 0x6d, 0x19, 0x4b, 0x69, 0xc9, 0xdb, 0xed, 0x5d, 0xf6, 0x22, 0x43,
+	0xb2, 0x1c, 0x3b, 0x5b, 0xa1, 0x77, 0xd6, 0xa7, 0xdf, 0xf9, 0x38, 0x3a, 0xe7, 0xc3, 0x30, 0x52,
+	0x3c, 0xe5, 0x19, 0x57, 0xe5, 0x6e, 0x5a, 0x94, 0x42, 0x09, 0xec, 0xec, 0x85, 0xc9, 0xaf, 0x0e,
+	0x38, 0xab, 0xfa, 0x84, 0x7d, 0x70, 0x73, 0xc1, 0x78, 0x98, 0xb0, 0x50, 0xaa, 0x92, 0x20, 0x1f,
+	0x05, 0xce, 0xcd, 0x11, 0x75, 0xb4, 0x78, 0xcb, 0xee, 0x54, 0x89, 0x3f, 0xc2, 0xa9, 0xdc, 0xc6,
+	0x72, 0x5d, 0x26, 0x85, 0x4a, 0x44, 0x5e, 0x93, 0x1d, 0x43, 0x22, 0x3a, 0x6e, 0x5f, 0x56, 0x15,
+	0x17, 0x30, 0xe4, 0xf9, 0x5a, 0xb0, 0x24, 0xdf, 0x84, 0x45, 0xa4, 0x1e, 0x48, 0x4f, 0xb3, 0xd4,
+	0xab, 0xc5, 0x65, 0xa4, 0x1e, 0x34, 0xb4, 0x16, 0x69, 0xca, 0xd7, 0xd6, 0x94, 0x0c, 0x7c, 0x14,
+	0x74, 0xa9, 0xd7, 0x88, 0xb7, 0x0c, 0xcf, 0xe0, 0xff, 0x16, 0x24, 0x55, 0x54, 0xaa, 0x50, 0x25,
+	0x19, 0x27, 0x8e, 0x81, 0x4f, 0x9b, 0xcb, 0x3b, 0x7d, 0xb7, 0x4a, 0x32, 0xae, 0x8d, 0x33, 0xb9,
+	0x31, 0x98, 0x54, 0x51, 0x56, 0x10, 0xa8, 0x8c, 0x33, 0xb9, 0x59, 0xd5, 0x1a, 0xfe, 0x02, 0xc0,
+	0x22, 0x15, 0x85, 0x9b, 0x22, 0x7e, 0x7c, 0x22, 0xae, 0xdf, 0x09, 0xdc, 0xd9, 0xdb, 0x69, 0x33,
+	0xb5, 0xfd, 0x80, 0xbe, 0x27, 0x3c, 0x65, 0xd4, 0xd1, 0xf0, 0x42, 0xb3, 0xf8, 0x33, 0x0c, 0xea,
+	0x4a, 0xe2, 0xf9, 0x28, 0x70, 0x67, 0x67, 0x2f, 0xd5, 0x2d, 0x96, 0xf3, 0x55, 0x14, 0xa7, 0x9c,
+	0xf6, 0x6d, 0x29, 0x9e, 0x42, 0xab, 0xdd, 0x90, 0xe7, 0xac, 0x7a, 0xc9, 0xd0, 0x74, 0x37, 0x6e,
+	0xae, 0xbe, 0xe5, 0x4c, 0xf7, 0x39, 0x77, 0xa0, 0x6f, 0x37, 0x33, 0x3f, 0x01, 0xaf, 0x3d, 0xe5,
+	0xc9, 0xef, 0x0e, 0x9c, 0x1c, 0x76, 0x88, 0xcf, 0xc0, 0x69, 0x5e, 0x8c, 0x8c, 0x67, 0x23, 0x60,
+	0x0c, 0xdd, 0x3c, 0xca, 0x38, 0x39, 0x36, 0x8b, 0x30, 0xdf, 0xf8, 0x0d, 0xf4, 0x18, 0x4f, 0xb9,
+	0xe2, 0x66, 0x95, 0x03, 0x6a, 0x4f, 0xf8, 0x1c, 0xdc, 0x78, 0xa7, 0xb8, 0x0c, 0x9f, 0xa2, 0x74,
+	0xcb, 0x49, 0xd7, 0x47, 0x81, 0x77, 0x73, 0x44, 0xc1, 0x88, 0x3f, 0xb4, 0x86, 0x2f, 0xc0, 0x93,
+	0xaa, 0xd4, 0xeb, 0xad, 0x98, 0xff, 0x6c, 0x6a, 0xdc, 0x4a, 0xad, 0xa0, 0xf7, 0x00, 0xb1, 0x10,
+	0xa9, 0x45, 0x74, 0x04, 0x06, 0x3a, 0x58, 0x5a, 0xdb, 0xbb, 0x6c, 0x93, 0x5c, 0x5d, 0xce, 0x2c,
+	0xd2, 0xf7, 0x51, 0x30, 0xd4, 0x2e, 0x95, 0x7a, 0x00, 0x5d, 0x5f, 0x59, 0xc8, 0xa4, 0xa4, 0x86,
+	0xae, 0xaf, 0x9a, 0x7e, 0xda, 0x4e, 0x3a, 0x1d, 0x63, 0xd3, 0xcf, 0xa1, 0x93, 0x6c, 0x3b, 0xe9,
+	0x58, 0xe0, 0x1a, 0x6a, 0x39, 0x31, 0xb1, 0x8d, 0x53, 0x6e, 0x21, 0xd7, 0x47, 0x01, 0xd2, 0x50,
+	0xa5, 0x56, 0xd0, 0x39, 0xb8, 0xf7, 0xa9, 0x88, 0x94, 0x65, 0x74, 0x0a, 0x8e, 0xf5, 0x84, 0x8c,
+	0x58, 0x21, 0x9f, 0xa0, 0x77, 0xaf, 0xf7, 0x22, 0xc9, 0xe8, 0xb5, 0x6c, 0x59, 0x70, 0x3e, 0x82,
+	0xa1, 0xf1, 0x0b, 0xe3, 0x5d, 0xa8, 0x76, 0x05, 0x9f, 0x7c, 0x85, 0xf1, 0x3f, 0x71, 0xc2, 0x1f,
+	0xa0, 0x53, 0x8a, 0x67, 0x82, 0x8c, 0xeb, 0xbb, 0x97, 0x5c, 0xa9, 0x78, 0x5e, 0x2c, 0xe7, 0x54,
+	0x63, 0x93, 0x9f, 0x30, 0xfa, 0x4b, 0x7f, 0x3d, 0x28, 0x8f, 0x7c, 0x27, 0xcd, 0x70, 0x3c, 0x6a,
+	0xbe, 0x31, 0x81, 0xfe, 0x5a, 0xe4, 0x8a, 0xe7, 0xca, 0x8c, 0xc3, 0xa3, 0xf5, 0x31, 0xee, 0x99,
+	0x9f, 0xcb, 0xe5, 0x9f, 0x01, 0x00, 0xab, 0xc1, 0xf9, 0xec, 0x6f, 0x04, 0x00, 0x00,
 }
@@ -0,0 +1,117 @@
+package cisco_telemetry_mdt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+const (
+	// Size of the dialout header preceding each message
+	dialoutHeaderLen = 12
+
+	// Maximum size of UDP datagrams
+	udpMaxDatagramLen = 65535
+)
+
+// Dialout header framing each telemetry message of the TCP and UDP dialout transports
+type dialoutHeader struct {
+	MsgType       uint16
+	MsgEncap      uint16
+	MsgHdrVersion uint16
+	MsgFlags      uint16
+	MsgLen        uint32
+}
+
+// ListenUDPDialout creates the UDP socket for the udp-dialout transport
+func (c *CiscoTelemetryMDT) listenUDPDialout() (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", c.ServiceAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bursts of datagrams exceeding the socket buffer are dropped by the kernel
+	if c.ReadBufferSize.Size > 0 {
+		if err := conn.SetReadBuffer(int(c.ReadBufferSize.Size)); err != nil {
+			log.Printf("W! Failed to set Cisco MDT UDP read buffer size: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+// ReceiveUDPDialout defines the UDP dialout server main routine, each datagram holding one message
+func (c *CiscoTelemetryMDT) receiveUDPDialout() {
+	defer c.wg.Done()
+
+	// One extra byte to detect datagrams truncated by the buffer
+	buffer := make([]byte, udpMaxDatagramLen+1)
+	for {
+		n, addr, err := c.udpConn.ReadFromUDP(buffer)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			c.acc.AddError(fmt.Errorf("E! Failed to receive UDP dialout datagram: %v", err))
+			continue
+		}
+
+		c.handleDatagram(buffer[:n], addr)
+	}
+}
+
+// HandleDatagram decodes the message of a UDP dialout datagram. Datagrams not holding exactly
+// one complete message, e.g. truncated or segmented ones, are counted and dropped.
+func (c *CiscoTelemetryMDT) handleDatagram(datagram []byte, addr net.Addr) {
+	var hdr dialoutHeader
+	if len(datagram) < dialoutHeaderLen {
+		c.invalidDatagrams(addr).Incr(1)
+		c.acc.AddError(fmt.Errorf("E! UDP dialout datagram from %s too short: %d bytes", addr, len(datagram)))
+		return
+	}
+	binary.Read(bytes.NewReader(datagram), binary.BigEndian, &hdr)
+
+	data := datagram[dialoutHeaderLen:]
+	if int64(hdr.MsgLen) != int64(len(data)) {
+		c.invalidDatagrams(addr).Incr(1)
+		c.acc.AddError(fmt.Errorf("E! UDP dialout datagram from %s holds %d of %d bytes of its message",
+			addr, len(data), hdr.MsgLen))
+		return
+	}
+
+	if hdr.MsgFlags&^tcpFlagZlib != 0 {
+		c.invalidDatagrams(addr).Incr(1)
+		c.acc.AddError(fmt.Errorf("E! Invalid dialout flags: %v", hdr.MsgFlags))
+		return
+	}
+
+	if hdr.MsgFlags&tcpFlagZlib != 0 {
+		var err error
+		if data, err = c.decompressZlib(data); err != nil {
+			if err == errDecompressedSizeExceeded {
+				c.sizeViolations(addr).Incr(1)
+			}
+			c.acc.AddError(fmt.Errorf("E! UDP dialout decompression failed for %s: %v", addr, err))
+			return
+		}
+	}
+
+	if encap := payloadEncap(data); encap != hdr.MsgEncap {
+		c.encodingMismatches(addr).Incr(1)
+	}
+
+	c.handleTelemetry(data, peerHost(addr.String()))
+}
+
+// InvalidDatagrams returns the statistic counting dropped datagrams of a peer host
+func (c *CiscoTelemetryMDT) invalidDatagrams(addr net.Addr) selfstat.Stat {
+	return c.peerCounter("invalid_datagrams", addr)
+}
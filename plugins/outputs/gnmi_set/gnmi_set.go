@@ -0,0 +1,423 @@
+/**
+ * Copyright (c) 2018 Cisco Systems
+ * Author: Steven Barth <stbarth@cisco.com>
+ */
+
+package gnmi_set
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	internaltls "github.com/influxdata/telegraf/internal/tls"
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	"github.com/influxdata/telegraf/plugins/common/secret"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	operationUpdate  = "update"
+	operationReplace = "replace"
+	operationDelete  = "delete"
+)
+
+// Escapes characters structuring paths in metric values interpolated into them
+var pathEscaper = strings.NewReplacer(`\`, `\\`, `/`, `\/`, `[`, `\[`, `]`, `\]`)
+
+// GNMISet output plugin mapping metrics to gNMI Set requests against the originating device
+type GNMISet struct {
+	// Tag holding the address of the originating device and the port to use if it has none
+	AddressTag string `toml:"address_tag"`
+	Port       int
+
+	Username string
+	Password string
+
+	// GRPC TLS settings
+	TLS bool
+	internaltls.ClientConfig
+
+	// Log the requests instead of sending them
+	DryRun bool `toml:"dry_run"`
+
+	// Patterns of the only paths that may be set, "*" matching within an element or key value
+	AllowedPaths []string `toml:"allowed_paths"`
+
+	// File appending an audit record for each request as JSON line
+	AuditFile string `toml:"audit_file"`
+
+	Timeout internal.Duration
+	Actions []Action `toml:"action"`
+
+	// Internal state
+	allowed []*regexp.Regexp
+	opts    []grpc.DialOption
+	clients map[string]*grpc.ClientConn
+	audit   *os.File
+	mutex   sync.Mutex
+}
+
+// Action setting a value on the device for each metric matching the measurement and tags
+type Action struct {
+	Measurement string
+	Tags        map[string]string
+
+	// Operation (one of: "update", "replace", "delete"), path and JSON value, paths and values
+	// are templates of the metric, e.g. {{ .Tags.name }} or {{ .Fields.value }}
+	Operation string
+	Origin    string
+	Path      string
+	Value     string
+
+	path  *template.Template
+	value *template.Template
+}
+
+// Audit record of a request
+type auditRecord struct {
+	Time      time.Time `json:"time"`
+	Address   string    `json:"address"`
+	Metric    string    `json:"metric"`
+	Operation string    `json:"operation"`
+	Path      string    `json:"path"`
+	Value     string    `json:"value,omitempty"`
+	Result    string    `json:"result"`
+}
+
+// Data of a metric accessible by path and value templates
+type templateData struct {
+	Name   string
+	Tags   map[string]string
+	Fields map[string]interface{}
+}
+
+// Connect validates the actions and opens the audit file, devices are dialed on first use
+func (g *GNMISet) Connect() error {
+	for i := range g.Actions {
+		action := &g.Actions[i]
+		switch action.Operation {
+		case "":
+			action.Operation = operationUpdate
+		case operationUpdate, operationReplace, operationDelete:
+		default:
+			return fmt.Errorf("E! Invalid gNMI set operation: %s", action.Operation)
+		}
+
+		var err error
+		if action.path, err = template.New("path").Option("missingkey=error").Parse(action.Path); err != nil {
+			return fmt.Errorf("E! Invalid gNMI set path template %s: %v", action.Path, err)
+		}
+		if action.value, err = template.New("value").Option("missingkey=error").Parse(action.Value); err != nil {
+			return fmt.Errorf("E! Invalid gNMI set value template %s: %v", action.Value, err)
+		}
+	}
+
+	// Paths contain slashes and brackets, so "*" is the only wildcard. It matches escaped
+	// characters but no separators, so it cannot span elements or key values.
+	g.allowed = nil
+	for _, pattern := range g.AllowedPaths {
+		expression := strings.Replace(regexp.QuoteMeta(pattern), `\*`, `(?:[^/\[\]\\]|\\.)*`, -1)
+		g.allowed = append(g.allowed, regexp.MustCompile("^"+expression+"$"))
+	}
+
+	password, err := secret.Resolve(g.Password)
+	if err != nil {
+		return fmt.Errorf("E! Failed to resolve gNMI set password: %v", err)
+	}
+	g.Password = password
+
+	g.opts = []grpc.DialOption{grpc.WithInsecure()}
+	if g.TLS {
+		tlsConfig, err := g.ClientConfig.TLSConfig()
+		if err != nil {
+			return err
+		}
+		g.opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	}
+
+	if len(g.AuditFile) > 0 {
+		if g.audit, err = os.OpenFile(g.AuditFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640); err != nil {
+			return fmt.Errorf("E! Failed to open gNMI set audit file: %v", err)
+		}
+	}
+
+	g.clients = make(map[string]*grpc.ClientConn)
+	return nil
+}
+
+// Close the connections to all devices and the audit file
+func (g *GNMISet) Close() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for _, client := range g.clients {
+		client.Close()
+	}
+	g.clients = nil
+
+	if g.audit != nil {
+		g.audit.Close()
+		g.audit = nil
+	}
+	return nil
+}
+
+// Write performs the actions matching the metrics. Failed requests are audited and logged but
+// never retried by returning an error, as repeating actions on devices may not be safe.
+func (g *GNMISet) Write(metrics []telegraf.Metric) error {
+	for _, metric := range metrics {
+		for i := range g.Actions {
+			action := &g.Actions[i]
+			if action.matches(metric) {
+				g.perform(action, metric)
+			}
+		}
+	}
+	return nil
+}
+
+// Escaped copy of the data interpolated into paths, so values cannot add elements or keys
+func (d *templateData) escaped() *templateData {
+	escaped := &templateData{Name: pathEscaper.Replace(d.Name), Tags: make(map[string]string, len(d.Tags)),
+		Fields: make(map[string]interface{}, len(d.Fields))}
+	for key, val := range d.Tags {
+		escaped.Tags[key] = pathEscaper.Replace(val)
+	}
+	for key, val := range d.Fields {
+		if s, ok := val.(string); ok {
+			val = pathEscaper.Replace(s)
+		}
+		escaped.Fields[key] = val
+	}
+	return escaped
+}
+
+// Matches checks whether the measurement and tags of a metric match the action
+func (a *Action) matches(metric telegraf.Metric) bool {
+	if len(a.Measurement) > 0 && a.Measurement != metric.Name() {
+		return false
+	}
+	for key, val := range a.Tags {
+		if tag, ok := metric.GetTag(key); !ok || tag != val {
+			return false
+		}
+	}
+	return true
+}
+
+// Perform an action for a metric, auditing its outcome
+func (g *GNMISet) perform(action *Action, metric telegraf.Metric) {
+	record := &auditRecord{Time: time.Now(), Metric: metric.Name(), Operation: action.Operation}
+	defer g.auditRequest(record)
+
+	data := &templateData{Name: metric.Name(), Tags: metric.Tags(), Fields: metric.Fields()}
+	var buffer bytes.Buffer
+	if err := action.path.Execute(&buffer, data.escaped()); err != nil {
+		record.Result = fmt.Sprintf("invalid path: %v", err)
+		return
+	}
+	record.Path = buffer.String()
+
+	if action.Operation != operationDelete {
+		buffer.Reset()
+		if err := action.value.Execute(&buffer, data); err != nil {
+			record.Result = fmt.Sprintf("invalid value: %v", err)
+			return
+		}
+		record.Value = buffer.String()
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(record.Value), &value); err != nil {
+			record.Result = fmt.Sprintf("invalid JSON value: %v", err)
+			return
+		}
+	}
+
+	address, ok := metric.GetTag(g.addressTag())
+	if !ok {
+		record.Result = fmt.Sprintf("missing %s tag", g.addressTag())
+		return
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil && g.Port > 0 {
+		address = net.JoinHostPort(address, fmt.Sprint(g.Port))
+	}
+	record.Address = address
+
+	if !g.isAllowed(record.Path) {
+		record.Result = "refused"
+		return
+	}
+
+	if g.DryRun {
+		record.Result = "dry-run"
+		return
+	}
+
+	if err := g.set(address, action, record); err != nil {
+		record.Result = fmt.Sprintf("failed: %v", err)
+		log.Printf("E! gNMI set of %s on %s failed: %v", record.Path, address, err)
+		return
+	}
+	record.Result = "ok"
+}
+
+// IsAllowed checks whether a path matches one of the allowed paths
+func (g *GNMISet) isAllowed(path string) bool {
+	for _, pattern := range g.allowed {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Set the value of an action on a device
+func (g *GNMISet) set(address string, action *Action, record *auditRecord) error {
+	client, err := g.client(address)
+	if err != nil {
+		return err
+	}
+
+	request := &gnmi.SetRequest{}
+	gnmiPath := gnmidecode.ParsePath(action.Origin, record.Path, "")
+	update := &gnmi.Update{Path: gnmiPath, Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonVal{JsonVal: []byte(record.Value)}}}
+	switch action.Operation {
+	case operationUpdate:
+		request.Update = []*gnmi.Update{update}
+	case operationReplace:
+		request.Replace = []*gnmi.Update{update}
+	case operationDelete:
+		request.Delete = []*gnmi.Path{gnmiPath}
+	}
+
+	timeout := g.Timeout.Duration
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if len(g.Username) > 0 && len(g.Password) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, "username", g.Username, "password", g.Password)
+	}
+
+	_, err = gnmi.NewGNMIClient(client).Set(ctx, request)
+	return err
+}
+
+// Client connection to a device, dialed on first use
+func (g *GNMISet) client(address string) (*grpc.ClientConn, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if client, ok := g.clients[address]; ok {
+		return client, nil
+	}
+
+	client, err := grpc.Dial(address, g.opts...)
+	if err != nil {
+		return nil, err
+	}
+	g.clients[address] = client
+	return client, nil
+}
+
+// AuditRequest appends a record to the audit file or logs it
+func (g *GNMISet) auditRequest(record *auditRecord) {
+	data, _ := json.Marshal(record)
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.audit == nil {
+		log.Printf("I! gNMI set audit: %s", data)
+		return
+	}
+	if _, err := g.audit.Write(append(data, '\n')); err != nil {
+		log.Printf("E! Failed to write gNMI set audit record %s: %v", data, err)
+	}
+}
+
+func (g *GNMISet) addressTag() string {
+	if len(g.AddressTag) > 0 {
+		return g.AddressTag
+	}
+	return "Producer"
+}
+
+const sampleConfig = `
+  ## tag holding the address of the device a metric originates from, and the port used for
+  ## addresses without one (e.g. node IDs of MDT)
+  # address_tag = "Producer"
+  # port = 57400
+
+  ## define credentials, the password may reference a secret as "vault:<path>#<key>" or "env:<variable>"
+  username = "cisco"
+  password = "cisco"
+
+  ## enable client-side TLS and define CA to authenticate the device
+  # tls = true
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # insecure_skip_verify = true
+
+  ## define client-side TLS certificate & key to authenticate to the device
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+
+  ## only audit the requests without sending them, disable once the actions are verified
+  dry_run = true
+
+  ## patterns of the only paths that may be set ("*" matches within a single element or key
+  ## value), requests to other paths are refused
+  allowed_paths = ["interfaces/interface[name=*]/config/enabled"]
+
+  ## file appending an audit record as JSON line for each request, logged if unset
+  # audit_file = "/var/log/telegraf/gnmi_set.log"
+
+  ## timeout of set requests
+  # timeout = "10s"
+
+  ## set values for metrics of the measurement matching all given tags, the path and the JSON
+  ## value are templates of the metric (e.g. {{ .Name }}, {{ .Tags.name }}, {{ .Fields.value }})
+  [[outputs.gnmi_set.action]]
+	measurement = "threshold_breach"
+	tags = {severity = "critical"}
+
+	## operation (one of: "update", "replace", "delete")
+	operation = "update"
+	origin = "openconfig-interfaces"
+	path = "interfaces/interface[name={{ .Tags.name }}]/config/enabled"
+	value = "false"
+`
+
+// SampleConfig of plugin
+func (g *GNMISet) SampleConfig() string {
+	return sampleConfig
+}
+
+// Description of plugin
+func (g *GNMISet) Description() string {
+	return "Set values on gNMI devices in response to metrics, e.g. for closed-loop automation"
+}
+
+func init() {
+	outputs.Add("gnmi_set", func() telegraf.Output {
+		return &GNMISet{DryRun: true}
+	})
+}
@@ -0,0 +1,149 @@
+package gnmi_set
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type mockGNMIServer struct {
+	gnmi.GNMIServer
+	t    *testing.T
+	sets []*gnmi.SetRequest
+}
+
+func (m *mockGNMIServer) Set(ctx context.Context, request *gnmi.SetRequest) (*gnmi.SetResponse, error) {
+	metadata, _ := metadata.FromIncomingContext(ctx)
+	assert.Equal(m.t, []string{"theuser"}, metadata.Get("username"))
+	m.sets = append(m.sets, request)
+	return &gnmi.SetResponse{}, nil
+}
+
+func breach(name string, severity string) telegraf.Metric {
+	m, _ := metric.New("threshold_breach", map[string]string{"Producer": "127.0.0.1", "name": name, "severity": severity},
+		map[string]interface{}{"value": int64(95)}, time.Now())
+	return m
+}
+
+func TestGNMISet(t *testing.T) {
+	m := &mockGNMIServer{t: t}
+	listener, _ := net.Listen("tcp", "127.0.0.1:57015")
+	server := grpc.NewServer()
+	gnmi.RegisterGNMIServer(server, m)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	dir, _ := ioutil.TempDir("", "gnmi_set")
+	defer os.RemoveAll(dir)
+
+	g := &GNMISet{Port: 57015, Username: "theuser", Password: "thepassword", DryRun: true,
+		AllowedPaths: []string{"interfaces/interface[name=*]/config/enabled"},
+		AuditFile:    filepath.Join(dir, "audit.log"),
+		Actions: []Action{{
+			Measurement: "threshold_breach",
+			Tags:        map[string]string{"severity": "critical"},
+			Origin:      "openconfig-interfaces",
+			Path:        "interfaces/interface[name={{ .Tags.name }}]/config/enabled",
+			Value:       "false",
+		}, {
+			Operation: "delete",
+			Path:      "system/config/hostname",
+		}},
+	}
+	assert.Nil(t, g.Connect())
+
+	// Dry-run only audits the requests
+	assert.Nil(t, g.Write([]telegraf.Metric{breach("Gi0/0/0/0", "critical")}))
+	assert.Len(t, m.sets, 0)
+
+	g.DryRun = false
+	assert.Nil(t, g.Write([]telegraf.Metric{breach("Gi0/0/0/1", "critical"), breach("Gi0/0/0/2", "minor")}))
+	assert.Nil(t, g.Close())
+
+	assert.Len(t, m.sets, 1)
+	assert.Equal(t, gnmi.SetRequest{Update: []*gnmi.Update{{
+		Path: &gnmi.Path{Origin: "openconfig-interfaces",
+			Element: []string{"interfaces", "interface[name=Gi0/0/0/1]", "config", "enabled"},
+			Elem: []*gnmi.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "Gi0/0/0/1"}},
+				{Name: "config"}, {Name: "enabled"}}},
+		Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonVal{JsonVal: []byte("false")}},
+	}}}, *m.sets[0])
+
+	data, _ := ioutil.ReadFile(g.AuditFile)
+	var results []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record auditRecord
+		assert.Nil(t, json.Unmarshal([]byte(line), &record))
+		assert.Equal(t, "127.0.0.1:57015", record.Address)
+		results = append(results, record.Path+" "+record.Result)
+	}
+	assert.Equal(t, []string{
+		`interfaces/interface[name=Gi0\/0\/0\/0]/config/enabled dry-run`,
+		"system/config/hostname refused",
+		`interfaces/interface[name=Gi0\/0\/0\/1]/config/enabled ok`,
+		"system/config/hostname refused",
+		"system/config/hostname refused",
+	}, results)
+
+	g = &GNMISet{Actions: []Action{{Operation: "merge"}}}
+	assert.NotNil(t, g.Connect())
+}
+
+func TestGNMISetInjectedPath(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "gnmi_set")
+	defer os.RemoveAll(dir)
+
+	g := &GNMISet{DryRun: true,
+		AllowedPaths: []string{"interfaces/interface[name=*]/config/enabled"},
+		AuditFile:    filepath.Join(dir, "audit.log"),
+		Actions: []Action{{
+			Measurement: "threshold_breach",
+			Path:        "interfaces/interface[name={{ .Tags.name }}]/config/enabled",
+			Value:       "false",
+		}, {
+			Measurement: "threshold_breach",
+			Path:        "interfaces/{{ .Tags.name }}/config/enabled",
+			Value:       "false",
+		}},
+	}
+	assert.Nil(t, g.Connect())
+
+	// Wildcards do not span elements or key values
+	assert.True(t, g.isAllowed(`interfaces/interface[name=Gi0\/0\/0\/0]/config/enabled`))
+	assert.False(t, g.isAllowed("interfaces/interface[name=x]/config/mtu[name=y]/config/enabled"))
+
+	// Tag values cannot add elements or keys to the path
+	assert.Nil(t, g.Write([]telegraf.Metric{breach("x]/config/mtu[name=y", "critical")}))
+	assert.Nil(t, g.Write([]telegraf.Metric{breach("interface[name=x]", "critical")}))
+	assert.Nil(t, g.Close())
+
+	data, _ := ioutil.ReadFile(g.AuditFile)
+	var records []auditRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record auditRecord
+		assert.Nil(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	assert.Len(t, records, 4)
+
+	assert.Equal(t, "dry-run", records[0].Result)
+	assert.Equal(t, []*gnmi.PathElem{{Name: "interfaces"}, {Name: "interface", Key: map[string]string{"name": "x]/config/mtu[name=y"}},
+		{Name: "config"}, {Name: "enabled"}}, gnmidecode.ParsePath("", records[0].Path, "").Elem)
+	assert.Equal(t, `interfaces/x\]\/config\/mtu\[name=y/config/enabled refused`, records[1].Path+" "+records[1].Result)
+	assert.Equal(t, `interfaces/interface[name=interface\[name=x\]]/config/enabled dry-run`, records[2].Path+" "+records[2].Result)
+	assert.Equal(t, `interfaces/interface\[name=x\]/config/enabled refused`, records[3].Path+" "+records[3].Result)
+}
@@ -0,0 +1,385 @@
+/**
+ * Copyright (c) 2018 Cisco Systems
+ * Author: Steven Barth <stbarth@cisco.com>
+ */
+
+package telemetry_threshold
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+const (
+	ruleValue   = "value"
+	ruleRate    = "rate"
+	ruleAbsence = "absence"
+
+	defaultEventMeasurement = "telemetry_event"
+
+	// Period of evaluating absence rules while no metrics arrive
+	absenceCheckInterval = time.Second
+
+	// States of series not updated for this many timeouts of their rule, or for the default
+	// expiry if the rule has no timeout, are dropped
+	expiryTimeouts = 10
+	defaultExpiry  = time.Hour
+)
+
+// TelemetryThreshold processor emitting events when telemetry crosses thresholds
+type TelemetryThreshold struct {
+	// Name of emitted event measurements
+	EventMeasurement string `toml:"event_measurement"`
+
+	Rules []Rule `toml:"rule"`
+
+	initialized bool
+	states      map[string]*state
+	now         func() time.Time
+	interval    time.Duration
+	mutex       sync.Mutex
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// Rule evaluated against a field of all series of the matching measurements
+type Rule struct {
+	Name     string
+	Severity string
+
+	// Measurement name pattern, e.g. "Cisco-IOS-XR-infra-statsd-oper:*", and field name
+	Measurement string
+	Field       string
+
+	// Type (one of: "value", "rate" per second, "absence") and thresholds of breaches
+	Type  string
+	Above *float64
+	Below *float64
+
+	// Absence of updates of a series for longer than the timeout
+	Timeout internal.Duration
+
+	pattern  *regexp.Regexp
+	disabled bool
+}
+
+// State of a rule for a series
+type state struct {
+	rule   *Rule
+	name   string
+	tags   map[string]string
+	active bool
+
+	// Last value and time for rates, last update for absence and expiry
+	value float64
+	time  time.Time
+	seen  time.Time
+}
+
+// Apply the rules to the metrics, passing them on followed by the events they caused
+func (t *TelemetryThreshold) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.initialized {
+		t.init()
+	}
+
+	out := in
+	now := t.now()
+	for _, m := range in {
+		for i := range t.Rules {
+			rule := &t.Rules[i]
+			if !rule.matches(m.Name()) {
+				continue
+			}
+
+			// Absence rules without field consider any update of the series
+			var number float64
+			if rule.Type != ruleAbsence || len(rule.Field) > 0 {
+				value, ok := m.GetField(rule.Field)
+				if !ok {
+					continue
+				}
+				if number, ok = toFloat(value); !ok && rule.Type != ruleAbsence {
+					continue
+				}
+			}
+
+			s := t.state(rule, m)
+			s.seen = now
+			if event := t.evaluate(s, number, m.Time(), now); event != nil {
+				out = append(out, event)
+			}
+		}
+	}
+
+	return append(out, t.absent(now)...)
+}
+
+// Start evaluating absence rules on a timer, so their events are emitted even if no metric
+// arrives at all, e.g. when a device or the whole pipeline went silent. Agents running streaming
+// processors start the processor and pass metrics to Add instead of Apply.
+func (t *TelemetryThreshold) Start(acc telegraf.Accumulator) error {
+	t.mutex.Lock()
+	if !t.initialized {
+		t.init()
+	}
+	t.done = make(chan struct{})
+	t.mutex.Unlock()
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+				t.mutex.Lock()
+				events := t.absent(t.now())
+				t.mutex.Unlock()
+
+				for _, event := range events {
+					acc.AddMetric(event)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Add a metric, passing it on followed by the events it caused
+func (t *TelemetryThreshold) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	for _, out := range t.Apply(m) {
+		acc.AddMetric(out)
+	}
+	return nil
+}
+
+// Stop the evaluation of absence rules
+func (t *TelemetryThreshold) Stop() error {
+	if t.done != nil {
+		close(t.done)
+		t.wg.Wait()
+		t.done = nil
+	}
+	return nil
+}
+
+// Absent series of absence rules not updated within their timeout, returning their events.
+// States of series idle for longer than the expiry of their rule are dropped, so removed
+// series do not accumulate, without clearing events of absence rules still active.
+func (t *TelemetryThreshold) absent(now time.Time) []telegraf.Metric {
+	var events []telegraf.Metric
+	for key, s := range t.states {
+		if now.Sub(s.seen) > s.rule.expiry() {
+			delete(t.states, key)
+			continue
+		}
+		if s.rule.Type == ruleAbsence && !s.active && now.Sub(s.seen) > s.rule.Timeout.Duration {
+			s.active = true
+			if event := t.event(s, nil, now); event != nil {
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+func (t *TelemetryThreshold) init() {
+	t.states = make(map[string]*state)
+	if t.now == nil {
+		t.now = time.Now
+	}
+	if t.interval <= 0 {
+		t.interval = absenceCheckInterval
+	}
+	if len(t.EventMeasurement) == 0 {
+		t.EventMeasurement = defaultEventMeasurement
+	}
+
+	for i := range t.Rules {
+		rule := &t.Rules[i]
+		if len(rule.Type) == 0 {
+			rule.Type = ruleValue
+		}
+		if len(rule.Name) == 0 {
+			rule.Name = rule.Measurement + "/" + rule.Field
+		}
+
+		// Measurements are paths, so "*" is the only wildcard and matches "/" too
+		expression := strings.Replace(regexp.QuoteMeta(rule.Measurement), `\*`, ".*", -1)
+		rule.pattern = regexp.MustCompile("^" + expression + "$")
+
+		switch rule.Type {
+		case ruleValue, ruleRate:
+		case ruleAbsence:
+			if rule.Timeout.Duration <= 0 {
+				log.Printf("E! Telemetry threshold rule %s without timeout, disabling it", rule.Name)
+				rule.disabled = true
+			}
+		default:
+			log.Printf("E! Invalid telemetry threshold rule type %s of %s, disabling it", rule.Type, rule.Name)
+			rule.disabled = true
+		}
+	}
+	t.initialized = true
+}
+
+// State of a rule for the series of a metric, created on first use
+func (t *TelemetryThreshold) state(rule *Rule, m telegraf.Metric) *state {
+	keys := make([]string, 0, len(m.TagList()))
+	for _, tag := range m.TagList() {
+		keys = append(keys, tag.Key+"="+tag.Value)
+	}
+	sort.Strings(keys)
+	key := rule.Name + "|" + m.Name() + "|" + strings.Join(keys, ",")
+
+	s, ok := t.states[key]
+	if !ok {
+		s = &state{rule: rule, name: m.Name(), tags: m.Tags()}
+		t.states[key] = s
+	}
+	return s
+}
+
+// Evaluate a new value of a series, returning an event if the state of the rule changed
+func (t *TelemetryThreshold) evaluate(s *state, value float64, timestamp time.Time, now time.Time) telegraf.Metric {
+	switch s.rule.Type {
+	case ruleAbsence:
+		if s.active {
+			s.active = false
+			return t.event(s, nil, now)
+		}
+		return nil
+
+	case ruleRate:
+		previous, last := s.value, s.time
+		s.value, s.time = value, timestamp
+		if last.IsZero() || !timestamp.After(last) || value < previous {
+			// Rates need two samples, counter resets are skipped
+			return nil
+		}
+		value = (value - previous) / timestamp.Sub(last).Seconds()
+	}
+
+	breach := (s.rule.Above != nil && value > *s.rule.Above) || (s.rule.Below != nil && value < *s.rule.Below)
+	if breach == s.active {
+		return nil
+	}
+	s.active = breach
+	return t.event(s, &value, timestamp)
+}
+
+// Event of a rule becoming active or clearing for a series, carrying the tags of the series
+func (t *TelemetryThreshold) event(s *state, value *float64, timestamp time.Time) telegraf.Metric {
+	tags := make(map[string]string, len(s.tags)+3)
+	for key, val := range s.tags {
+		tags[key] = val
+	}
+	tags["rule"] = s.rule.Name
+	tags["measurement"] = s.name
+	if len(s.rule.Severity) > 0 {
+		tags["severity"] = s.rule.Severity
+	}
+
+	fields := map[string]interface{}{"active": s.active, "field": s.rule.Field}
+	if value != nil {
+		fields["value"] = *value
+	}
+	if s.rule.Above != nil {
+		fields["above"] = *s.rule.Above
+	}
+	if s.rule.Below != nil {
+		fields["below"] = *s.rule.Below
+	}
+
+	m, err := metric.New(t.EventMeasurement, tags, fields, timestamp)
+	if err != nil {
+		log.Printf("E! Telemetry threshold rule %s failed to create event: %v", s.rule.Name, err)
+		return nil
+	}
+	return m
+}
+
+// Expiry of the states of idle series of the rule
+func (r *Rule) expiry() time.Duration {
+	if r.Timeout.Duration > 0 {
+		return expiryTimeouts * r.Timeout.Duration
+	}
+	return defaultExpiry
+}
+
+// Matches checks whether a measurement name matches the pattern of the rule
+func (r *Rule) matches(name string) bool {
+	return !r.disabled && (len(r.Measurement) == 0 || r.pattern.MatchString(name))
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+const sampleConfig = `
+  ## name of the emitted event measurements
+  # event_measurement = "telemetry_event"
+
+  ## rules evaluated against a field of each series of the matching measurements, events are
+  ## emitted when a rule becomes active and when it clears
+  [[processors.telemetry_threshold.rule]]
+	name = "high-input-rate"
+	severity = "critical"
+
+	## measurement name pattern ("*" matches any characters) and field name
+	measurement = "Cisco-IOS-XR-infra-statsd-oper:infra-statistics/*"
+	field = "input-data-rate"
+
+	## type of the rule (one of: "value", "rate" per second, "absence") and thresholds
+	type = "value"
+	above = 900000.0
+	# below = 10.0
+
+	## absence rules become active once a series was not updated for longer than the timeout,
+	## series of any rule not updated for ten times the timeout (an hour without) are forgotten
+	# timeout = "60s"
+`
+
+// SampleConfig of plugin
+func (t *TelemetryThreshold) SampleConfig() string {
+	return sampleConfig
+}
+
+// Description of plugin
+func (t *TelemetryThreshold) Description() string {
+	return "Emit events when telemetry values, rates or updates cross thresholds"
+}
+
+func init() {
+	processors.Add("telemetry_threshold", func() telegraf.Processor {
+		return &TelemetryThreshold{}
+	})
+}
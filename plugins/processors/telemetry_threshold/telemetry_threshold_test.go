@@ -0,0 +1,130 @@
+package telemetry_threshold
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func counters(name string, fields map[string]interface{}, timestamp time.Time) telegraf.Metric {
+	m, _ := metric.New("Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface/latest/generic-counters",
+		map[string]string{"Producer": "router1", "interface-name": name}, fields, timestamp)
+	return m
+}
+
+func TestValueAndRateRules(t *testing.T) {
+	above, below := 100.0, 10.0
+	p := &TelemetryThreshold{Rules: []Rule{
+		{Name: "high-rate", Severity: "critical", Measurement: "Cisco-IOS-XR-infra-statsd-oper:*",
+			Field: "input-data-rate", Above: &above},
+		{Name: "low-growth", Measurement: "*/generic-counters", Field: "packets-received", Type: "rate", Below: &below},
+		{Name: "invalid", Type: "derivative", Field: "input-data-rate"},
+	}}
+
+	start := time.Unix(1000, 0)
+	out := p.Apply(counters("Gi0/0/0/0", map[string]interface{}{"input-data-rate": int64(50), "packets-received": uint64(0)}, start))
+	assert.Len(t, out, 1)
+
+	// Value above the threshold and a rate of 5 packets per second below
+	out = p.Apply(counters("Gi0/0/0/0", map[string]interface{}{"input-data-rate": int64(150), "packets-received": uint64(50)},
+		start.Add(10*time.Second)))
+	assert.Len(t, out, 3)
+	assert.Equal(t, "telemetry_event", out[1].Name())
+	assert.Equal(t, map[string]string{"Producer": "router1", "interface-name": "Gi0/0/0/0", "rule": "high-rate",
+		"severity": "critical", "measurement": "Cisco-IOS-XR-infra-statsd-oper:infra-statistics/interfaces/interface/latest/generic-counters"},
+		out[1].Tags())
+	assert.Equal(t, map[string]interface{}{"active": true, "field": "input-data-rate", "value": 150.0, "above": 100.0},
+		out[1].Fields())
+	assert.Equal(t, map[string]interface{}{"active": true, "field": "packets-received", "value": 5.0, "below": 10.0},
+		out[2].Fields())
+
+	// Only changes of the state are emitted
+	out = p.Apply(counters("Gi0/0/0/0", map[string]interface{}{"input-data-rate": int64(20), "packets-received": uint64(300)},
+		start.Add(20*time.Second)))
+	assert.Len(t, out, 3)
+	assert.Equal(t, false, out[1].Fields()["active"])
+	assert.Equal(t, "high-rate", out[1].Tags()["rule"])
+	assert.Equal(t, false, out[2].Fields()["active"])
+}
+
+func TestAbsenceRule(t *testing.T) {
+	now := time.Unix(1000, 0)
+	p := &TelemetryThreshold{EventMeasurement: "threshold_breach", now: func() time.Time { return now },
+		Rules: []Rule{{Name: "stale", Type: "absence", Timeout: internal.Duration{Duration: time.Minute}}}}
+
+	assert.Len(t, p.Apply(counters("Gi0/0/0/0", map[string]interface{}{"input-data-rate": int64(50)}, now)), 1)
+
+	now = now.Add(2 * time.Minute)
+	out := p.Apply(counters("Gi0/0/0/1", map[string]interface{}{"input-data-rate": int64(50)}, now))
+	assert.Len(t, out, 2)
+	assert.Equal(t, "threshold_breach", out[1].Name())
+	assert.Equal(t, "Gi0/0/0/0", out[1].Tags()["interface-name"])
+	assert.Equal(t, map[string]interface{}{"active": true, "field": ""}, out[1].Fields())
+
+	assert.Len(t, p.Apply(), 0)
+
+	out = p.Apply(counters("Gi0/0/0/0", map[string]interface{}{"input-data-rate": int64(50)}, now))
+	assert.Len(t, out, 2)
+	assert.Equal(t, false, out[1].Fields()["active"])
+}
+
+func TestAbsenceRuleSilentStream(t *testing.T) {
+	var mutex sync.Mutex
+	now := time.Unix(1000, 0)
+	p := &TelemetryThreshold{interval: 10 * time.Millisecond,
+		now:   func() time.Time { mutex.Lock(); defer mutex.Unlock(); return now },
+		Rules: []Rule{{Name: "stale", Type: "absence", Timeout: internal.Duration{Duration: time.Minute}}}}
+
+	acc := &testutil.Accumulator{}
+	assert.Nil(t, p.Start(acc))
+	defer p.Stop()
+
+	assert.Nil(t, p.Add(counters("Gi0/0/0/0", map[string]interface{}{"input-data-rate": int64(50)}, now), acc))
+	acc.Wait(1)
+
+	// No metric arrives anymore, the timer detects the absence
+	mutex.Lock()
+	now = now.Add(2 * time.Minute)
+	mutex.Unlock()
+	acc.Wait(2)
+
+	acc.Lock()
+	defer acc.Unlock()
+	assert.Equal(t, "telemetry_event", acc.Metrics[1].Measurement)
+	assert.Equal(t, "Gi0/0/0/0", acc.Metrics[1].Tags["interface-name"])
+	assert.Equal(t, true, acc.Metrics[1].Fields["active"])
+}
+
+func TestStateExpiry(t *testing.T) {
+	above := 100.0
+	now := time.Unix(1000, 0)
+	p := &TelemetryThreshold{now: func() time.Time { return now }, Rules: []Rule{
+		{Name: "high-rate", Field: "input-data-rate", Above: &above},
+		{Name: "stale", Type: "absence", Timeout: internal.Duration{Duration: time.Minute}},
+	}}
+
+	p.Apply(counters("Gi0/0/0/0", map[string]interface{}{"input-data-rate": int64(50)}, now))
+	p.Apply(counters("Gi0/0/0/1", map[string]interface{}{"input-data-rate": int64(50)}, now))
+	assert.Len(t, p.states, 4)
+
+	// Absence is detected before the state of the removed series expires
+	now = now.Add(5 * time.Minute)
+	out := p.Apply(counters("Gi0/0/0/0", map[string]interface{}{"input-data-rate": int64(50)}, now))
+	assert.Len(t, out, 2)
+	assert.Equal(t, "Gi0/0/0/1", out[1].Tags()["interface-name"])
+
+	now = now.Add(10 * time.Minute)
+	assert.Len(t, p.Apply(counters("Gi0/0/0/0", map[string]interface{}{"input-data-rate": int64(50)}, now)), 1)
+	assert.Len(t, p.states, 3)
+
+	// Rules without timeout expire after the default expiry
+	now = now.Add(2 * time.Hour)
+	assert.Len(t, p.Apply(), 0)
+	assert.Len(t, p.states, 0)
+}
@@ -0,0 +1,212 @@
+/**
+ * Copyright (c) 2018 Cisco Systems
+ * Author: Steven Barth <stbarth@cisco.com>
+ */
+
+// Package gnmi serializes metrics as gNMI notifications, rebuilding the paths
+// from measurement names, field names and tags as produced by the
+// cisco_telemetry_gnmi input plugin.
+package gnmi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	gnmiproto "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Tags added by the gNMI input plugin not describing path keys: the device, the resolved address
+// of the session, the inventory tags of the device and the tags of provenance_ prefix
+var metadataTags = []string{"Producer", "path", "atomic", "address", "device", "site", "role", "platform"}
+
+const provenancePrefix = "provenance_"
+
+// Serializer of metrics as gNMI notifications
+type Serializer struct {
+	// Tags not to be encoded as path keys
+	ExcludeTags []string
+}
+
+// NewSerializer creates a serializer ignoring the given tags in addition to the metadata tags
+func NewSerializer(excludeTags []string) *Serializer {
+	return &Serializer{ExcludeTags: excludeTags}
+}
+
+// Serialize a metric as SubscribeResponse containing a single notification
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	notification, err := s.Notification(metric)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&gnmiproto.SubscribeResponse{
+		Response: &gnmiproto.SubscribeResponse_Update{Update: notification},
+	})
+}
+
+// SerializeBatch of metrics as length-delimited SubscribeResponses
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var buffer []byte
+	for _, metric := range metrics {
+		data, err := s.Serialize(metric)
+		if err != nil {
+			return nil, err
+		}
+
+		buffer = append(buffer, proto.EncodeVarint(uint64(len(data)))...)
+		buffer = append(buffer, data...)
+	}
+	return buffer, nil
+}
+
+// Notification of a metric with the prefix given by the measurement and an update for each field
+func (s *Serializer) Notification(metric telegraf.Metric) (*gnmiproto.Notification, error) {
+	prefix := parsePath(metric.Name())
+	if target, ok := metric.GetTag("Target"); ok {
+		prefix.Target = target
+	}
+
+	notification := &gnmiproto.Notification{
+		Timestamp: metric.Time().UnixNano(),
+		Prefix:    prefix,
+	}
+	if atomic, ok := metric.GetTag("atomic"); ok && atomic == "true" {
+		notification.Atomic = true
+	}
+
+	// Sort fields for a deterministic encoding
+	fields := metric.FieldList()
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+
+	for _, field := range fields {
+		value, err := typedValue(field.Value)
+		if err != nil {
+			return nil, fmt.Errorf("E! Failed to serialize field %s as gNMI: %v", field.Key, err)
+		}
+
+		notification.Update = append(notification.Update, &gnmiproto.Update{
+			Path: parsePath(field.Key),
+			Val:  value,
+		})
+	}
+
+	s.addKeys(metric, notification)
+	return notification, nil
+}
+
+// AddKeys of path elements from the tags of a metric
+func (s *Serializer) addKeys(metric telegraf.Metric, notification *gnmiproto.Notification) {
+	measurement := strings.TrimSuffix(metric.Name(), "/") + "/"
+
+	for _, tag := range metric.TagList() {
+		if tag.Key == "Target" || s.excluded(tag.Key) {
+			continue
+		}
+
+		i := strings.LastIndexByte(tag.Key, '/')
+		if i < 0 {
+			// Short-form keys belong to the last element of the prefix
+			setKey(notification.Prefix.Elem, len(notification.Prefix.Elem), tag.Key, tag.Value)
+			continue
+		}
+
+		name, key := tag.Key[:i], tag.Key[i+1:]
+		if strings.HasPrefix(name+"/", measurement) {
+			// Long-form keys of prefix elements carry the measurement name
+			elems := parsePath(name).Elem
+			setKey(notification.Prefix.Elem, len(elems), key, tag.Value)
+			continue
+		}
+
+		// Keys of update paths are relative to the prefix
+		elems := parsePath(name).Elem
+		for _, update := range notification.Update {
+			if hasElems(update.Path.Elem, elems) {
+				setKey(update.Path.Elem, len(elems), key, tag.Value)
+			}
+		}
+	}
+}
+
+// Excluded checks whether a tag is not a path key
+func (s *Serializer) excluded(tag string) bool {
+	if strings.HasPrefix(tag, provenancePrefix) {
+		return true
+	}
+	for _, excluded := range metadataTags {
+		if tag == excluded {
+			return true
+		}
+	}
+	for _, excluded := range s.ExcludeTags {
+		if tag == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// SetKey of the n-th path element, if it exists
+func setKey(elems []*gnmiproto.PathElem, n int, key string, value string) {
+	if n < 1 || n > len(elems) {
+		return
+	}
+
+	elem := elems[n-1]
+	if elem.Key == nil {
+		elem.Key = make(map[string]string)
+	}
+	elem.Key[key] = value
+}
+
+// HasElems checks whether a path starts with the given elements by name
+func hasElems(path []*gnmiproto.PathElem, elems []*gnmiproto.PathElem) bool {
+	if len(elems) > len(path) {
+		return false
+	}
+	for i, elem := range elems {
+		if path[i].Name != elem.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// ParsePath of the form origin:/elem/elem without keys as used in measurement and field names
+func parsePath(name string) *gnmiproto.Path {
+	path := &gnmiproto.Path{}
+
+	if i := strings.IndexRune(name, ':'); i >= 0 && !strings.ContainsRune(name[:i], '/') {
+		path.Origin, name = name[:i], name[i+1:]
+	}
+
+	for _, elem := range strings.Split(name, "/") {
+		if len(elem) > 0 {
+			path.Elem = append(path.Elem, &gnmiproto.PathElem{Name: elem})
+		}
+	}
+	return path
+}
+
+// TypedValue of a field value
+func typedValue(value interface{}) (*gnmiproto.TypedValue, error) {
+	switch v := value.(type) {
+	case int64:
+		return &gnmiproto.TypedValue{Value: &gnmiproto.TypedValue_IntVal{IntVal: v}}, nil
+	case uint64:
+		return &gnmiproto.TypedValue{Value: &gnmiproto.TypedValue_UintVal{UintVal: v}}, nil
+	case float64:
+		return gnmidecode.NewDoubleValue(v), nil
+	case bool:
+		return &gnmiproto.TypedValue{Value: &gnmiproto.TypedValue_BoolVal{BoolVal: v}}, nil
+	case string:
+		return &gnmiproto.TypedValue{Value: &gnmiproto.TypedValue_StringVal{StringVal: v}}, nil
+	case []byte:
+		return &gnmiproto.TypedValue{Value: &gnmiproto.TypedValue_BytesVal{BytesVal: v}}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", value)
+}
@@ -0,0 +1,92 @@
+/**
+ * Copyright (c) 2018 Cisco Systems
+ * Author: Steven Barth <stbarth@cisco.com>
+ */
+
+package gnmi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/gnmidecode"
+	gnmiproto "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerializeNotification(t *testing.T) {
+	m, _ := metric.New("openconfig-interfaces:/interfaces/interface",
+		map[string]string{
+			"name":                             "Ethernet1",
+			"Producer":                         "127.0.0.1:57500",
+			"Target":                           "router1",
+			"subinterfaces/subinterface/index": "0",
+			"site":                             "dc1",
+			"address":                          "10.0.0.1",
+			"provenance_encoding":              "proto",
+			"rack":                             "r1",
+		},
+		map[string]interface{}{
+			"state/counters/in-octets":              uint64(42),
+			"state/enabled":                         true,
+			"state/counters/in-rate":                0.1,
+			"subinterfaces/subinterface/state/name": "Ethernet1.0",
+		},
+		time.Unix(0, 1543236572000000000))
+
+	notification, err := NewSerializer([]string{"rack"}).Notification(m)
+	assert.Nil(t, err)
+
+	assert.Equal(t, int64(1543236572000000000), notification.Timestamp)
+	assert.Equal(t, &gnmiproto.Path{
+		Origin: "openconfig-interfaces",
+		Target: "router1",
+		Elem: []*gnmiproto.PathElem{
+			{Name: "interfaces"},
+			{Name: "interface", Key: map[string]string{"name": "Ethernet1"}},
+		},
+	}, notification.Prefix)
+
+	assert.Len(t, notification.Update, 4)
+	assert.Equal(t, &gnmiproto.Path{Elem: []*gnmiproto.PathElem{
+		{Name: "state"}, {Name: "counters"}, {Name: "in-octets"},
+	}}, notification.Update[0].Path)
+	assert.Equal(t, uint64(42), notification.Update[0].Val.GetUintVal())
+	assert.Equal(t, true, notification.Update[2].Val.GetBoolVal())
+	assert.Equal(t, &gnmiproto.Path{Elem: []*gnmiproto.PathElem{
+		{Name: "subinterfaces"}, {Name: "subinterface", Key: map[string]string{"index": "0"}}, {Name: "state"}, {Name: "name"},
+	}}, notification.Update[3].Path)
+}
+
+func TestSerializeDoubleRoundTrip(t *testing.T) {
+	m, _ := metric.New("/system", nil, map[string]interface{}{"load": 0.1}, time.Unix(1, 0))
+	data, err := NewSerializer(nil).Serialize(m)
+	assert.Nil(t, err)
+
+	var response gnmiproto.SubscribeResponse
+	assert.Nil(t, proto.Unmarshal(data, &response))
+
+	value, _ := gnmidecode.DecodeTypedValue(response.GetUpdate().Update[0].Val)
+	assert.Equal(t, 0.1, value)
+}
+
+func TestSerializeBatch(t *testing.T) {
+	m, _ := metric.New("/system", nil, map[string]interface{}{"uptime": int64(1)}, time.Unix(1, 0))
+	serializer := NewSerializer(nil)
+
+	data, err := serializer.SerializeBatch([]telegraf.Metric{m, m})
+	assert.Nil(t, err)
+
+	var response gnmiproto.SubscribeResponse
+	buffer := proto.NewBuffer(data)
+	for i := 0; i < 2; i++ {
+		message, err := buffer.DecodeRawBytes(false)
+		assert.Nil(t, err)
+		assert.Nil(t, proto.Unmarshal(message, &response))
+		assert.Equal(t, int64(1), response.GetUpdate().Update[0].Val.GetIntVal())
+	}
+
+}